@@ -40,3 +40,17 @@ func NewStringList(name, help string) *StringList {
 	flag.Var(&r, name, help)
 	return &r
 }
+
+// MultiString provides a flag type that collects the values of a flag given several times into a
+// []string.
+type MultiString []string
+
+func (i *MultiString) String() string {
+	return strings.Join([]string(*i), ",")
+}
+
+// Set appends the flag value.
+func (i *MultiString) Set(v string) error {
+	*i = append(*i, v)
+	return nil
+}
@@ -25,6 +25,13 @@
 //
 // The xml2.Encoder.EncodeToken verifies the validity of namespaces and encodes
 // them. For everything else, xml2.Encoder will fallback to the xml.Encoder.
+//
+// xml2.Decoder:
+//
+// The encoding/xml Decoder leaves undeclared prefixes in xml.Name.Space, where
+// declared prefixes are replaced by their namespace URI. xml2.Decoder resolves
+// undeclared prefixes with default URIs, so that names always hold URIs and
+// compare as such, e.g. when rewriting documents.
 package xml2
 
 import (
@@ -44,6 +51,10 @@ type Encoder struct {
 	prefixURI map[string]string
 	state     []state
 	uriPrefix *uriPrefixMap
+	selfClose bool
+	// pending is set while the start tag of an element is missing its closing '>', until
+	// it is known whether the element is empty.
+	pending bool
 }
 
 // ChildEncoder returns an encoder whose state is copied the given parent Encoder and writes to w.
@@ -70,6 +81,28 @@ func NewEncoder(w io.Writer) *Encoder {
 	return e
 }
 
+// SelfCloseEmpty makes the encoder write elements without content as
+// self-closing tags, e.g. <uses-sdk/> rather than <uses-sdk></uses-sdk>.
+func (enc *Encoder) SelfCloseEmpty() {
+	enc.selfClose = true
+}
+
+// Flush flushes any buffered XML to the underlying writer.
+func (enc *Encoder) Flush() error {
+	enc.closePending()
+	return enc.Encoder.Flush()
+}
+
+// closePending ends the pending start tag, if any.
+func (enc *Encoder) closePending() {
+	if !enc.pending {
+		return
+	}
+	enc.pending = false
+	enc.Encoder.Flush()
+	enc.p.Write([]byte{'>'})
+}
+
 // EncodeToken behaves almost the same as encoding/xml.Encoder.EncodeToken
 // but deals with StartElement and EndElement differently.
 func (enc *Encoder) EncodeToken(t xml.Token) error {
@@ -86,6 +119,7 @@ func (enc *Encoder) EncodeToken(t xml.Token) error {
 		}
 	default:
 		// Delegate to the embedded encoder for everything else.
+		enc.closePending()
 		return enc.Encoder.EncodeToken(t)
 	}
 	return nil
@@ -95,6 +129,7 @@ func (enc *Encoder) writeStart(start *xml.StartElement) error {
 	if start.Name.Local == "" {
 		return fmt.Errorf("start tag with no name")
 	}
+	enc.closePending()
 	enc.setUpState(start)
 
 	// Begin creating the start tag.
@@ -120,7 +155,11 @@ func (enc *Encoder) writeStart(start *xml.StartElement) error {
 		xml.EscapeText(&st, []byte(attr.Value))
 		st.WriteByte('"')
 	}
-	st.WriteByte('>')
+	if enc.selfClose {
+		enc.pending = true
+	} else {
+		st.WriteByte('>')
+	}
 
 	enc.p.writeIndent(1)
 	enc.p.Write(st.Bytes())
@@ -140,6 +179,13 @@ func (enc *Encoder) writeEnd(name xml.Name) error {
 		return fmt.Errorf("tags are unbalanced, got: %v, wanted: %v", name, sn)
 	}
 
+	if enc.pending {
+		enc.pending = false
+		enc.p.writeIndent(-1)
+		enc.p.Write([]byte("/>"))
+		return nil
+	}
+
 	// Begin creating the end tag
 	var et bytes.Buffer
 	et.WriteString("</")
@@ -117,6 +117,61 @@ func TestEncoderEncodeToken(t *testing.T) {
 	}
 }
 
+func TestEncoderSelfCloseEmpty(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "emptyElement",
+			in:   `<foo xmlns:bar="baz"><bar:qux bar:quux="corge"></bar:qux></foo>`,
+			want: `<foo xmlns:bar="baz"><bar:qux bar:quux="corge"/></foo>`,
+		},
+		{
+			name: "elementWithContent",
+			in:   `<foo><bar>baz</bar><qux><!-- quux --></qux></foo>`,
+			want: `<foo><bar>baz</bar><qux><!-- quux --></qux></foo>`,
+		},
+		{
+			name: "whitespaceIsContent",
+			in:   "<foo>\n  <bar></bar>\n</foo>",
+			want: "<foo>\n  <bar/>\n</foo>",
+		},
+		{
+			name: "emptyRoot",
+			in:   `<foo></foo>`,
+			want: `<foo/>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b bytes.Buffer
+			e := NewEncoder(&b)
+			e.SelfCloseEmpty()
+			d := xml.NewDecoder(strings.NewReader(test.in))
+			for {
+				tkn, err := d.Token()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Unexpected error got: %v while reading: %s", err, test.in)
+				}
+				if err := e.EncodeToken(tkn); err != nil {
+					t.Fatalf("Unexpected error during encode: %v", err)
+				}
+			}
+			if err := e.Flush(); err != nil {
+				t.Fatalf("Unexpected error during flush: %v", err)
+			}
+			if b.String() != test.want {
+				t.Errorf("got: <%s> expected: <%s>", b.String(), test.want)
+			}
+		})
+	}
+}
+
 func TestChildEncoder(t *testing.T) {
 	// Setup the parent Encoder with the namespace "bar".
 	d := xml.NewDecoder(strings.NewReader("<foo xmlns:bar=\"bar\"><bar:baz>Hello World</bar:baz></foo>"))
@@ -0,0 +1,153 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xml2
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+const xmlURL = "http://www.w3.org/XML/1998/namespace"
+
+// Namespaces tracks the namespace declarations in scope while going through the elements of a
+// document, resolving prefixes to namespace URIs. Prefixes that are not declared resolve to their
+// default URI, if any.
+type Namespaces struct {
+	defaults map[string]string
+	// scopes holds the prefix to URI declarations of each open element.
+	scopes []map[string]string
+}
+
+// NewNamespaces returns the namespaces of a document in which the prefixes of defaults resolve to
+// their URI unless declared otherwise.
+func NewNamespaces(defaults map[string]string) *Namespaces {
+	return &Namespaces{defaults: defaults}
+}
+
+// Push enters the scope of the start element, recording its namespace declarations.
+func (n *Namespaces) Push(start xml.StartElement) {
+	var decls map[string]string
+	for _, attr := range start.Attr {
+		prefix, ok := "", false
+		switch {
+		case attr.Name.Space == xmlNS:
+			prefix, ok = attr.Name.Local, true
+		case attr.Name.Space == "" && attr.Name.Local == xmlNS:
+			ok = true
+		}
+		if !ok {
+			continue
+		}
+		if decls == nil {
+			decls = make(map[string]string)
+		}
+		decls[prefix] = attr.Value
+	}
+	n.scopes = append(n.scopes, decls)
+}
+
+// Pop leaves the scope of the innermost element.
+func (n *Namespaces) Pop() {
+	if len(n.scopes) > 0 {
+		n.scopes = n.scopes[:len(n.scopes)-1]
+	}
+}
+
+// URI returns the namespace URI the prefix resolves to in the current scope, and whether it
+// resolves at all.
+func (n *Namespaces) URI(prefix string) (string, bool) {
+	for i := len(n.scopes) - 1; i >= 0; i-- {
+		if uri, ok := n.scopes[i][prefix]; ok {
+			return uri, true
+		}
+	}
+	uri, ok := n.defaults[prefix]
+	return uri, ok
+}
+
+// declared reports whether uri is declared in the current scope.
+func (n *Namespaces) declared(uri string) bool {
+	for _, decls := range n.scopes {
+		for _, u := range decls {
+			if u == uri {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Resolve returns the name with its space resolved to a namespace URI.
+//
+// The space of names read by an encoding/xml Decoder is either the URI of a declared prefix or an
+// undeclared prefix, which Resolve replaces by its default URI. Other names are left as is.
+func (n *Namespaces) Resolve(name xml.Name) xml.Name {
+	switch name.Space {
+	case "", xmlNS, xmlURL:
+		return name
+	}
+	if n.declared(name.Space) {
+		return name
+	}
+	if uri, ok := n.defaults[name.Space]; ok {
+		name.Space = uri
+	}
+	return name
+}
+
+// Decoder is an xml decoder which behaves much like the encoding/xml Decoder, but resolves the
+// undeclared prefixes of element and attribute names to default namespace URIs.
+type Decoder struct {
+	*xml.Decoder
+	ns *Namespaces
+}
+
+// NewDecoder returns a new decoder reading from r, in which the prefixes of defaults resolve to
+// their URI unless declared otherwise.
+func NewDecoder(r io.Reader, defaults map[string]string) *Decoder {
+	return WrapDecoder(xml.NewDecoder(r), defaults)
+}
+
+// WrapDecoder returns a decoder resolving the names of the tokens read from dec.
+func WrapDecoder(dec *xml.Decoder, defaults map[string]string) *Decoder {
+	return &Decoder{Decoder: dec, ns: NewNamespaces(defaults)}
+}
+
+// Token behaves like encoding/xml.Decoder.Token, with the names of start and end elements and
+// attributes resolved. Namespace declarations are returned untouched, so that the documents
+// encode back with their original prefixes.
+func (d *Decoder) Token() (xml.Token, error) {
+	t, err := d.Decoder.Token()
+	if err != nil {
+		return t, err
+	}
+	switch tt := t.(type) {
+	case xml.StartElement:
+		d.ns.Push(tt)
+		tt.Name = d.ns.Resolve(tt.Name)
+		attr := make([]xml.Attr, len(tt.Attr))
+		for i, a := range tt.Attr {
+			a.Name = d.ns.Resolve(a.Name)
+			attr[i] = a
+		}
+		tt.Attr = attr
+		return tt, nil
+	case xml.EndElement:
+		tt.Name = d.ns.Resolve(tt.Name)
+		d.ns.Pop()
+		return tt, nil
+	}
+	return t, nil
+}
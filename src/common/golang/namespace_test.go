@@ -0,0 +1,150 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xml2
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const androidURI = "http://schemas.android.com/apk/res/android"
+
+var testDefaults = map[string]string{"android": androidURI}
+
+func readTokens(r io.Reader) ([]xml.Token, error) {
+	var tokens []xml.Token
+	d := NewDecoder(r, testDefaults)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, xml.CopyToken(t))
+	}
+}
+
+func TestDecoderToken(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []xml.Name
+	}{
+		{
+			name: "declaredPrefix",
+			in:   `<manifest xmlns:a="` + androidURI + `" a:name="x"></manifest>`,
+			want: []xml.Name{{Local: "manifest"}, {Space: androidURI, Local: "name"}},
+		},
+		{
+			name: "undeclaredDefaultPrefix",
+			in:   `<manifest android:name="x"></manifest>`,
+			want: []xml.Name{{Local: "manifest"}, {Space: androidURI, Local: "name"}},
+		},
+		{
+			name: "redeclaredDefaultPrefix",
+			in:   `<manifest xmlns:android="foo" android:name="x"></manifest>`,
+			want: []xml.Name{{Local: "manifest"}, {Space: "foo", Local: "name"}},
+		},
+		{
+			name: "undeclaredPrefix",
+			in:   `<foo:manifest bar:name="x"></foo:manifest>`,
+			want: []xml.Name{{Space: "foo", Local: "manifest"}, {Space: "bar", Local: "name"}},
+		},
+		{
+			name: "defaultNamespace",
+			in:   `<manifest xmlns="foo" android:name="x"></manifest>`,
+			want: []xml.Name{{Space: "foo", Local: "manifest"}, {Space: androidURI, Local: "name"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, err := readTokens(strings.NewReader(test.in))
+			if err != nil {
+				t.Fatalf("Unexpected error got: %v while reading: %s", err, test.in)
+			}
+			start := tokens[0].(xml.StartElement)
+			got := []xml.Name{start.Name}
+			for _, a := range start.Attr {
+				if a.Name.Space != xmlNS && a.Name.Local != xmlNS {
+					got = append(got, a.Name)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got: %v expected: %v", got, test.want)
+			}
+			if end := tokens[len(tokens)-1].(xml.EndElement); end.Name != start.Name {
+				t.Errorf("end element %v does not match start element %v", end.Name, start.Name)
+			}
+		})
+	}
+}
+
+func TestNamespacesScope(t *testing.T) {
+	ns := NewNamespaces(testDefaults)
+	ns.Push(xml.StartElement{Name: xml.Name{Local: "foo"}, Attr: []xml.Attr{{Name: xml.Name{Space: xmlNS, Local: "android"}, Value: "bar"}}})
+	if uri, _ := ns.URI("android"); uri != "bar" {
+		t.Errorf("URI(android) within the declaring element = %q, want %q", uri, "bar")
+	}
+	ns.Pop()
+	if uri, _ := ns.URI("android"); uri != androidURI {
+		t.Errorf("URI(android) after the declaring element = %q, want %q", uri, androidURI)
+	}
+	if _, ok := ns.URI("tools"); ok {
+		t.Errorf("URI(tools) resolved without declaration nor default")
+	}
+}
+
+// FuzzRoundTrip checks that documents keep their tokens when decoded and encoded back, with empty
+// elements self-closed.
+func FuzzRoundTrip(f *testing.F) {
+	for _, s := range []string{
+		`<manifest xmlns:android="` + androidURI + `" package="p"><uses-sdk android:minSdkVersion="21"></uses-sdk></manifest>`,
+		`<manifest xmlns:a="` + androidURI + `"><application a:name=".App"><activity a:name=".Main"/></application></manifest>`,
+		"<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<!-- c --><foo xmlns=\"bar\">\n  <baz>&amp;qux</baz>\n</foo>\n",
+		`<foo xmlns:bar="baz"><bar:qux xmlns:bar="quux" bar:corge="grault"/></foo>`,
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		tokens, err := readTokens(strings.NewReader(in))
+		if err != nil {
+			return
+		}
+		var b bytes.Buffer
+		e := NewEncoder(&b)
+		e.SelfCloseEmpty()
+		for _, tkn := range tokens {
+			if err := e.EncodeToken(tkn); err != nil {
+				return
+			}
+		}
+		if err := e.Flush(); err != nil {
+			return
+		}
+		got, err := readTokens(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatalf("reading back %q, encoded from %q, failed: %v", b.String(), in, err)
+		}
+		if !reflect.DeepEqual(got, tokens) {
+			t.Errorf("reading back %q, encoded from %q, got tokens %v, want %v", b.String(), in, got, tokens)
+		}
+	})
+}
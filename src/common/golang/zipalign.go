@@ -0,0 +1,383 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ziputils
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultAlignment is the alignment zipalign applies to all stored entries.
+	DefaultAlignment = 4
+	// PageAlignment is the alignment required for native libraries loaded directly from the APK.
+	// 16 KB aligned libraries are also 4 KB aligned, so this works for every page size.
+	PageAlignment = 16384
+	// NativeLibsPattern matches the native libraries of an APK.
+	NativeLibsPattern = "lib/*/*.so"
+
+	// alignmentExtraID is the extra field used by zipalign and apksigner to pad local file headers.
+	alignmentExtraID = 0xd935
+	// Size of the alignment extra field without padding: id, size and alignment.
+	alignmentExtraLen = 6
+	// Id and size of the extended timestamp extra field recording FileHeader.Modified.
+	extTimeExtraID  = 0x5455
+	extTimeExtraLen = 9
+
+	dataDescriptorFlag = 0x8
+	utf8Flag           = 0x800
+	zipVersion20       = 20
+	maxAlignment       = 32768
+)
+
+// AlignRule requires the data of entries matching Pattern to start on a multiple of Alignment.
+type AlignRule struct {
+	Pattern   string
+	Alignment int
+}
+
+// ParseAlignRule parses an AlignRule from "pattern:alignment".
+func ParseAlignRule(s string) (AlignRule, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return AlignRule{}, fmt.Errorf("%q is not of the form pattern:alignment", s)
+	}
+	a, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return AlignRule{}, fmt.Errorf("%q has an invalid alignment: %v", s, err)
+	}
+	r := AlignRule{Pattern: s[:i], Alignment: a}
+	if _, err := path.Match(r.Pattern, ""); err != nil {
+		return AlignRule{}, fmt.Errorf("%q has an invalid pattern: %v", s, err)
+	}
+	if err := CheckAlignment(a); err != nil {
+		return AlignRule{}, fmt.Errorf("%q: %v", s, err)
+	}
+	return r, nil
+}
+
+// CheckAlignment returns an error unless a is 0, for none, or a power of two fitting the
+// alignment extra field.
+func CheckAlignment(a int) error {
+	if a < 0 || a > maxAlignment || a&(a-1) != 0 {
+		return fmt.Errorf("alignment %d is neither 0 nor a power of two up to %d", a, maxAlignment)
+	}
+	return nil
+}
+
+// Aligner returns the alignment required for the data of the named entry, 0 or 1 for none.
+type Aligner func(name string) int
+
+// NewAligner returns an Aligner using the first rule matching an entry, or def if none does.
+func NewAligner(def int, rules ...AlignRule) Aligner {
+	return func(name string) int {
+		for _, r := range rules {
+			if ok, _ := path.Match(r.Pattern, name); ok {
+				return r.Alignment
+			}
+		}
+		return def
+	}
+}
+
+// AlignedWriter is a zip.Writer that pads the extra field of stored entries, the way zipalign
+// does, so that their data starts on the boundary returned by its Aligner. Compressed entries
+// and directories are written unchanged.
+//
+// The position of an entry is only known once the previous one is complete, so entries added
+// with Create or CreateHeader are buffered and written, without data descriptor, when the next
+// entry is added or the writer is flushed or closed.
+type AlignedWriter struct {
+	*zip.Writer
+	cw          *countWriter
+	align       Aligner
+	compressors map[uint16]zip.Compressor
+	pending     *entryWriter
+}
+
+// NewAlignedWriter returns an AlignedWriter writing to w. A nil align disables the padding.
+func NewAlignedWriter(w io.Writer, align Aligner) *AlignedWriter {
+	cw := &countWriter{w: w}
+	return &AlignedWriter{
+		Writer:      zip.NewWriter(cw),
+		cw:          cw,
+		align:       align,
+		compressors: make(map[uint16]zip.Compressor),
+	}
+}
+
+// RegisterCompressor registers a compressor for the method, see zip.Writer.RegisterCompressor.
+func (w *AlignedWriter) RegisterCompressor(method uint16, comp zip.Compressor) {
+	w.compressors[method] = comp
+}
+
+// Create adds a deflated file with the given name, see zip.Writer.Create.
+func (w *AlignedWriter) Create(name string) (io.Writer, error) {
+	return w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+}
+
+// CreateHeader adds a file using the given header, see zip.Writer.CreateHeader.
+func (w *AlignedWriter) CreateHeader(fh *zip.FileHeader) (io.Writer, error) {
+	if err := w.writePending(); err != nil {
+		return nil, err
+	}
+	prepareHeader(fh)
+	if strings.HasSuffix(fh.Name, "/") {
+		fh.Method = zip.Store
+		fh.CompressedSize64 = 0
+		fh.UncompressedSize64 = 0
+		return w.CreateRaw(fh)
+	}
+	comp, err := w.compressor(fh.Method)
+	if err != nil {
+		return nil, err
+	}
+	e := &entryWriter{fh: fh, crc: crc32.NewIEEE()}
+	if e.comp, err = comp(&e.buf); err != nil {
+		return nil, err
+	}
+	w.pending = e
+	return e, nil
+}
+
+// CreateRaw adds a file with already compressed data, see zip.Writer.CreateRaw. The CRC-32 and
+// sizes of fh must be set, no data descriptor is written.
+func (w *AlignedWriter) CreateRaw(fh *zip.FileHeader) (io.Writer, error) {
+	if err := w.writePending(); err != nil {
+		return nil, err
+	}
+	fh.Flags &^= dataDescriptorFlag
+	if err := w.pad(fh); err != nil {
+		return nil, err
+	}
+	return w.Writer.CreateRaw(fh)
+}
+
+// Copy copies f without decompressing it, realigning its data.
+func (w *AlignedWriter) Copy(f *zip.File) error {
+	r, err := f.OpenRaw()
+	if err != nil {
+		return err
+	}
+	fh := f.FileHeader
+	fw, err := w.CreateRaw(&fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+// Flush writes the buffered entry and flushes the underlying writer.
+func (w *AlignedWriter) Flush() error {
+	if err := w.writePending(); err != nil {
+		return err
+	}
+	return w.Writer.Flush()
+}
+
+// Close writes the buffered entry and finishes the archive.
+func (w *AlignedWriter) Close() error {
+	if err := w.writePending(); err != nil {
+		return err
+	}
+	return w.Writer.Close()
+}
+
+func (w *AlignedWriter) compressor(method uint16) (zip.Compressor, error) {
+	if comp, ok := w.compressors[method]; ok {
+		return comp, nil
+	}
+	switch method {
+	case zip.Store:
+		return func(out io.Writer) (io.WriteCloser, error) { return nopCloser{out}, nil }, nil
+	case zip.Deflate:
+		return func(out io.Writer) (io.WriteCloser, error) { return flate.NewWriter(out, flate.DefaultCompression) }, nil
+	}
+	return nil, zip.ErrAlgorithm
+}
+
+// writePending writes the entry buffered by CreateHeader, if any.
+func (w *AlignedWriter) writePending() error {
+	e := w.pending
+	if e == nil {
+		return nil
+	}
+	w.pending = nil
+	if err := e.comp.Close(); err != nil {
+		return err
+	}
+	e.fh.CRC32 = e.crc.Sum32()
+	e.fh.UncompressedSize64 = e.n
+	e.fh.CompressedSize64 = uint64(e.buf.Len())
+	fw, err := w.CreateRaw(e.fh)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(e.buf.Bytes())
+	return err
+}
+
+// pad replaces the alignment of fh with one matching the current offset.
+func (w *AlignedWriter) pad(fh *zip.FileHeader) error {
+	fh.Extra = stripAlignment(fh.Extra)
+	if w.align == nil || fh.Method != zip.Store || strings.HasSuffix(fh.Name, "/") {
+		return nil
+	}
+	a := w.align(fh.Name)
+	if a <= 1 {
+		return nil
+	}
+	if err := CheckAlignment(a); err != nil {
+		return fmt.Errorf("%s: %v", fh.Name, err)
+	}
+	// The offset is only known once the buffered writes reached cw.
+	if err := w.Writer.Flush(); err != nil {
+		return err
+	}
+	headerLen, err := localHeaderLen(fh, alignmentExtraLen)
+	if err != nil {
+		return err
+	}
+	start := w.cw.n + headerLen
+	n := (a - int(start%int64(a))) % a
+	field := make([]byte, alignmentExtraLen+n)
+	binary.LittleEndian.PutUint16(field, alignmentExtraID)
+	binary.LittleEndian.PutUint16(field[2:], uint16(2+n))
+	binary.LittleEndian.PutUint16(field[4:], uint16(a))
+	fh.Extra = append(fh.Extra, field...)
+	return nil
+}
+
+// localHeaderLen returns the size of the local file header zip.Writer.CreateRaw writes for fh
+// with extraLen more bytes of extra field, by writing it. Depending on the Go version, the header
+// of entries of 4GB or more holds a zip64 extra field.
+func localHeaderLen(fh *zip.FileHeader, extraLen int) (int64, error) {
+	h := *fh
+	h.Extra = append(append([]byte(nil), fh.Extra...), make([]byte, extraLen)...)
+	cw := &countWriter{w: io.Discard}
+	zw := zip.NewWriter(cw)
+	if _, err := zw.CreateRaw(&h); err != nil {
+		return 0, err
+	}
+	if err := zw.Flush(); err != nil {
+		return 0, err
+	}
+	return cw.n, nil
+}
+
+// prepareHeader sets the fields zip.Writer.CreateHeader derives from fh, which CreateRaw keeps.
+func prepareHeader(fh *zip.FileHeader) {
+	if !fh.NonUTF8 && utf8.ValidString(fh.Name+fh.Comment) && needsUTF8(fh.Name+fh.Comment) {
+		fh.Flags |= utf8Flag
+	}
+	fh.CreatorVersion = fh.CreatorVersion&0xff00 | zipVersion20
+	fh.ReaderVersion = zipVersion20
+	if fh.Modified.IsZero() {
+		return
+	}
+	t := fh.Modified
+	fh.ModifiedDate = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	fh.ModifiedTime = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	ts := make([]byte, extTimeExtraLen)
+	binary.LittleEndian.PutUint16(ts, extTimeExtraID)
+	binary.LittleEndian.PutUint16(ts[2:], extTimeExtraLen-4)
+	ts[4] = 1 // Only the modification time.
+	binary.LittleEndian.PutUint32(ts[5:], uint32(t.Unix()))
+	fh.Extra = append(fh.Extra, ts...)
+}
+
+// needsUTF8 reports whether s has characters outside of the ASCII subset shared by CP-437.
+func needsUTF8(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r > 0x7d || r == 0x5c {
+			return true
+		}
+	}
+	return false
+}
+
+// stripAlignment returns extra without alignment fields. Zero ids and trailing bytes which do
+// not form a field, as left by older versions of zipalign, are dropped as well.
+func stripAlignment(extra []byte) []byte {
+	var out []byte
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := 4 + int(binary.LittleEndian.Uint16(extra[2:]))
+		if size > len(extra) {
+			break
+		}
+		if id != alignmentExtraID && id != 0 {
+			out = append(out, extra[:size]...)
+		}
+		extra = extra[size:]
+	}
+	return out
+}
+
+// IsAligned reports whether the data of f starts on a multiple of alignment.
+func IsAligned(f *zip.File, alignment int) (bool, error) {
+	if f.Method != zip.Store || alignment <= 1 || strings.HasSuffix(f.Name, "/") {
+		return true, nil
+	}
+	off, err := f.DataOffset()
+	if err != nil {
+		return false, err
+	}
+	return off%int64(alignment) == 0, nil
+}
+
+type entryWriter struct {
+	fh   *zip.FileHeader
+	buf  bytes.Buffer
+	comp io.WriteCloser
+	crc  hash.Hash32
+	n    uint64
+}
+
+func (e *entryWriter) Write(p []byte) (int, error) {
+	e.crc.Write(p)
+	e.n += uint64(len(p))
+	return e.comp.Write(p)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
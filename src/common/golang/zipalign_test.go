@@ -0,0 +1,199 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ziputils
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+type entry struct {
+	name   string
+	method uint16
+	size   int
+}
+
+var entries = []entry{
+	{name: "AndroidManifest.xml", method: zip.Deflate, size: 7},
+	{name: "resources.arsc", method: zip.Store, size: 13},
+	{name: "res/", method: zip.Store},
+	{name: "res/raw/a.bin", method: zip.Store, size: 1},
+	{name: "lib/arm64-v8a/liba.so", method: zip.Store, size: 5000},
+	{name: "lib/arm64-v8a/libb.so", method: zip.Store, size: 3},
+	{name: "lib/x86_64/libc.so", method: zip.Deflate, size: 100},
+	{name: "assets/data", method: zip.Store, size: 11},
+}
+
+var aligner = NewAligner(DefaultAlignment, AlignRule{Pattern: NativeLibsPattern, Alignment: PageAlignment})
+
+func writeEntries(t *testing.T, zw interface {
+	CreateHeader(*zip.FileHeader) (io.Writer, error)
+	Close() error
+}, modified time.Time) {
+	t.Helper()
+	for _, e := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: e.method, Modified: modified})
+		if err != nil {
+			t.Fatalf("CreateHeader(%q) failed: %v", e.name, err)
+		}
+		if _, err := w.Write(bytes.Repeat([]byte{1}, e.size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkAligned(t *testing.T, b []byte) {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != len(entries) {
+		t.Fatalf("got %d entries, want %d", len(r.File), len(entries))
+	}
+	for i, f := range r.File {
+		if f.Name != entries[i].name {
+			t.Errorf("got entry %q, want %q", f.Name, entries[i].name)
+		}
+		ok, err := IsAligned(f, aligner(f.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			off, _ := f.DataOffset()
+			t.Errorf("%s: data offset %d is not aligned to %d", f.Name, off, aligner(f.Name))
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Errorf("%s: reading failed: %v", f.Name, err)
+		}
+		if len(data) != entries[i].size {
+			t.Errorf("%s: got %d bytes, want %d", f.Name, len(data), entries[i].size)
+		}
+	}
+}
+
+func TestAlignedWriter(t *testing.T) {
+	for _, modified := range []time.Time{{}, time.Unix(0, 0)} {
+		var b bytes.Buffer
+		writeEntries(t, NewAlignedWriter(&b, aligner), modified)
+		checkAligned(t, b.Bytes())
+	}
+}
+
+func TestAlignedWriterCopy(t *testing.T) {
+	var in bytes.Buffer
+	writeEntries(t, zip.NewWriter(&in), time.Unix(0, 0))
+	r, err := zip.NewReader(bytes.NewReader(in.Bytes()), int64(in.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Aligning twice must not accumulate padding.
+	var sizes []int
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		w := NewAlignedWriter(&out, aligner)
+		for _, f := range r.File {
+			if err := w.Copy(f); err != nil {
+				t.Fatalf("Copy(%q) failed: %v", f.Name, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		checkAligned(t, out.Bytes())
+		sizes = append(sizes, out.Len())
+		r, err = zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sizes[0] != sizes[1] {
+		t.Errorf("realigning changed the archive size from %d to %d", sizes[0], sizes[1])
+	}
+}
+
+func TestAlignedWriterLargeEntry(t *testing.T) {
+	w := NewAlignedWriter(io.Discard, aligner)
+	if _, err := w.CreateRaw(&zip.FileHeader{Name: "a.txt", Method: zip.Store}); err != nil {
+		t.Fatal(err)
+	}
+	// Entries of more than 4GB have their sizes in a zip64 extra field of the central directory,
+	// only the data is needed to check where it starts.
+	const size = 5 << 30
+	fh := &zip.FileHeader{Name: "lib/arm64-v8a/libbig.so", Method: zip.Store, CompressedSize64: size, UncompressedSize64: size}
+	if _, err := w.CreateRaw(fh); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if w.cw.n%PageAlignment != 0 {
+		t.Errorf("data of %s starts at %d, want a multiple of %d", fh.Name, w.cw.n, PageAlignment)
+	}
+}
+
+func TestParseAlignRule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    AlignRule
+		wantErr bool
+	}{
+		{in: "lib/*/*.so:16384", want: AlignRule{Pattern: "lib/*/*.so", Alignment: 16384}},
+		{in: "*:4", want: AlignRule{Pattern: "*", Alignment: 4}},
+		{in: "assets/a:b:8", want: AlignRule{Pattern: "assets/a:b", Alignment: 8}},
+		{in: "lib/*/*.so", wantErr: true},
+		{in: "lib/*/*.so:x", wantErr: true},
+		{in: "lib/*/*.so:3", wantErr: true},
+		{in: "lib/*/*.so:65536", wantErr: true},
+		{in: "lib/[/*.so:4", wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := ParseAlignRule(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseAlignRule(%q) got err: %v, want err: %v", tc.in, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseAlignRule(%q) got: %+v want: %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestNewAligner(t *testing.T) {
+	a := NewAligner(4, AlignRule{Pattern: "lib/*/libx.so", Alignment: 4096}, AlignRule{Pattern: NativeLibsPattern, Alignment: PageAlignment})
+	for name, want := range map[string]int{
+		"lib/arm64-v8a/libx.so": 4096,
+		"lib/arm64-v8a/liby.so": PageAlignment,
+		"lib/liby.so":           4,
+		"classes.dex":           4,
+	} {
+		if got := a(name); got != want {
+			t.Errorf("aligner(%q) got: %d want: %d", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ziptest writes the zip archives used as fixtures by tests.
+package ziptest
+
+import (
+	"archive/zip"
+	"os"
+	"sort"
+	"testing"
+)
+
+// Entry is an entry of a zip archive.
+type Entry struct {
+	Name   string
+	Data   []byte
+	Method uint16
+}
+
+// Write writes a zip archive of the files, by name, to path. Files are deflated and written in
+// name order.
+func Write(t testing.TB, path string, files map[string]string) {
+	t.Helper()
+	var entries []Entry
+	for name, data := range files {
+		entries = append(entries, Entry{Name: name, Data: []byte(data), Method: zip.Deflate})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	WriteEntries(t, path, entries)
+}
+
+// WriteEntries writes a zip archive of the entries, in order, to path.
+func WriteEntries(t testing.TB, path string, entries []Entry) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.Name, Method: e.Method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(e.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
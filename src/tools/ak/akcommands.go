@@ -22,15 +22,22 @@ import (
 	"src/tools/ak/extractresources/extractresources"
 	"src/tools/ak/finalrjar/finalrjar"
 	"src/tools/ak/generatemanifest/generatemanifest"
+	"src/tools/ak/keeprules/keeprules"
 	"src/tools/ak/link/link"
 	"src/tools/ak/liteparse/liteparse"
 	"src/tools/ak/manifest/manifest"
+	"src/tools/ak/manifestdiff/manifestdiff"
+	"src/tools/ak/manifestlint/manifestlint"
+	"src/tools/ak/mergemanifests/mergemanifests"
 	"src/tools/ak/minsdkfloor/minsdkfloor"
 	"src/tools/ak/nativelib/nativelib"
 	"src/tools/ak/patch/patch"
+	"src/tools/ak/prefab/prefab"
 	"src/tools/ak/repack/repack"
 	"src/tools/ak/rjar/rjar"
+	"src/tools/ak/shrinkres/shrinkres"
 	"src/tools/ak/types"
+	"src/tools/ak/zipalign/zipalign"
 )
 
 var (
@@ -40,15 +47,22 @@ var (
 		"compile":          compile.Cmd,
 		"extractaar":       extractaar.Cmd,
 		"extractresources": extractresources.Cmd,
+		"keeprules":        keeprules.Cmd,
 		"link":             link.Cmd,
 		"liteparse":        liteparse.Cmd,
 		"generatemanifest": generatemanifest.Cmd,
 		"manifest":         manifest.Cmd,
+		"manifestdiff":     manifestdiff.Cmd,
+		"manifestlint":     manifestlint.Cmd,
+		"mergemanifests":   mergemanifests.Cmd,
 		"nativelib":        nativelib.Cmd,
 		"patch":            patch.Cmd,
+		"prefab":           prefab.Cmd,
 		"repack":           repack.Cmd,
 		"rjar":             rjar.Cmd,
+		"shrinkres":        shrinkres.Cmd,
 		"finalrjar":        finalrjar.Cmd,
 		"minsdkfloor":      minsdkfloor.Cmd,
+		"zipalign":         zipalign.Cmd,
 	}
 )
@@ -0,0 +1,208 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestutils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Edit operations.
+const (
+	// EditSet sets an attribute, adding it when missing: set:{path}@{attr}={value}.
+	EditSet = "set"
+	// EditAdd adds an attribute, keeping its value when present: add:{path}@{attr}={value}.
+	EditAdd = "add"
+	// EditRemove removes an attribute, remove:{path}@{attr}, or elements, remove:{path}.
+	EditRemove = "remove"
+	// EditInsert appends child elements: insert:{path}={xml}.
+	EditInsert = "insert"
+)
+
+// Edit is a change to the elements of a document selected by a Path.
+type Edit struct {
+	raw   string
+	op    string
+	path  *Path
+	attr  string
+	value string
+}
+
+// ParseEdit parses an edit of the form {op}:{path}..., see the Edit* operations.
+func ParseEdit(s string) (*Edit, error) {
+	op, rest, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, fmt.Errorf("edit %q does not start with an operation", s)
+	}
+	e := &Edit{raw: s, op: op}
+	var path string
+	switch op {
+	case EditSet, EditAdd:
+		i := indexOutside(rest, '@')
+		if i < 0 {
+			return nil, fmt.Errorf("edit %q is not of the form %s:{path}@{attr}={value}", s, op)
+		}
+		path = rest[:i]
+		if e.attr, e.value, ok = strings.Cut(rest[i+1:], "="); !ok || e.attr == "" {
+			return nil, fmt.Errorf("edit %q is not of the form %s:{path}@{attr}={value}", s, op)
+		}
+	case EditRemove:
+		path = rest
+		if i := indexOutside(rest, '@'); i >= 0 {
+			path, e.attr = rest[:i], rest[i+1:]
+		}
+	case EditInsert:
+		i := indexOutside(rest, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("edit %q is not of the form %s:{path}={xml}", s, op)
+		}
+		path, e.value = rest[:i], rest[i+1:]
+	default:
+		return nil, fmt.Errorf("edit %q has unknown operation %q, want %s, %s, %s or %s", s, op, EditSet, EditAdd, EditRemove, EditInsert)
+	}
+	var err error
+	if e.path, err = ParsePath(path); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Apply applies the edit to the document. Edits other than removals fail when their path selects
+// no element.
+func (e *Edit) Apply(doc *Document) error {
+	matches, err := e.path.selectMatches(doc)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 && e.op != EditRemove {
+		return fmt.Errorf("edit %q: path %q selects no element", e.raw, e.path)
+	}
+	ns := namespaces(doc)
+	switch {
+	case e.op == EditRemove && e.attr == "":
+		for _, m := range matches {
+			if m.parent == nil {
+				return fmt.Errorf("edit %q: cannot remove the root element", e.raw)
+			}
+			m.parent.Remove(m.elem)
+		}
+	case e.op == EditInsert:
+		for _, m := range matches {
+			children, err := parseFragment(e.value, ns)
+			if err != nil {
+				return fmt.Errorf("edit %q: %v", e.raw, err)
+			}
+			for _, c := range children {
+				m.elem.Append(c)
+			}
+		}
+	default:
+		attr, err := resolveName(e.attr, ns)
+		if err != nil {
+			return fmt.Errorf("edit %q: %v", e.raw, err)
+		}
+		for _, m := range matches {
+			switch e.op {
+			case EditRemove:
+				m.elem.RemoveAttr(attr)
+			case EditAdd:
+				if _, ok := m.elem.AttrValue(attr); !ok {
+					m.elem.SetAttr(attr, e.value)
+				}
+			default:
+				m.elem.SetAttr(attr, e.value)
+			}
+		}
+	}
+	declareNamespaces(doc.Root(), ns)
+	return nil
+}
+
+// parseFragment parses the elements of an XML fragment, within which the prefixes of ns are
+// declared.
+func parseFragment(fragment string, ns map[string]string) ([]*Element, error) {
+	var prefixes []string
+	for p := range ns {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	var b bytes.Buffer
+	b.WriteString("<fragment")
+	for _, p := range prefixes {
+		fmt.Fprintf(&b, " xmlns:%s=%q", p, ns[p])
+	}
+	b.WriteString(">" + fragment + "</fragment>")
+	doc, err := ReadDocument(xml.NewDecoder(&b))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %v", fragment, err)
+	}
+	children := doc.Root().Elements("")
+	if len(children) == 0 {
+		return nil, fmt.Errorf("%q holds no element", fragment)
+	}
+	return children, nil
+}
+
+// declareNamespaces declares on root the namespaces used in the document but not declared, with
+// their prefix in ns.
+func declareNamespaces(root *Element, ns map[string]string) {
+	declared := make(map[string]bool)
+	for _, a := range root.Attr {
+		if a.Name.Space == "xmlns" {
+			declared[a.Value] = true
+		}
+	}
+	prefixes := make(map[string]string)
+	for p, uri := range ns {
+		prefixes[uri] = p
+	}
+	declare := func(uri string) {
+		if p, ok := prefixes[uri]; ok && !declared[uri] && uri != "xmlns" {
+			declared[uri] = true
+			root.Attr = append(root.Attr, xml.Attr{Name: xml.Name{Space: "xmlns", Local: p}, Value: uri})
+		}
+	}
+	root.Walk(func(e *Element) {
+		declare(e.Name.Space)
+		for _, a := range e.Attr {
+			declare(a.Name.Space)
+		}
+	})
+}
+
+// EditManifest applies the edits, as parsed by ParseEdit, to the manifest in order.
+func EditManifest(manifest []byte, edits []string) ([]byte, error) {
+	doc, err := ReadDocument(xml.NewDecoder(bytes.NewReader(manifest)))
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range edits {
+		e, err := ParseEdit(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.Apply(doc); err != nil {
+			return nil, err
+		}
+	}
+	var b bytes.Buffer
+	if err := doc.Write(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const editManifest = `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <application android:label="App">
+        <!-- Main. -->
+        <activity android:name=".Main" android:exported="true">
+            <meta-data android:name="a" android:value="1"/>
+        </activity>
+        <activity android:name=".Debug"/>
+    </application>
+</manifest>
+`
+
+func TestEditManifest(t *testing.T) {
+	tests := []struct {
+		name  string
+		edits []string
+		want  string
+	}{
+		{
+			name:  "set attribute",
+			edits: []string{"set:activity[@android:name=.Main]@android:exported=false", "set:application@android:theme=@style/App"},
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <application android:label="App" android:theme="@style/App">
+        <!-- Main. -->
+        <activity android:name=".Main" android:exported="false">
+            <meta-data android:name="a" android:value="1"/>
+        </activity>
+        <activity android:name=".Debug"/>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name:  "add attribute",
+			edits: []string{"add:activity@android:exported=false"},
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <application android:label="App">
+        <!-- Main. -->
+        <activity android:name=".Main" android:exported="true">
+            <meta-data android:name="a" android:value="1"/>
+        </activity>
+        <activity android:name=".Debug" android:exported="false"/>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name:  "remove attribute and element",
+			edits: []string{"remove:application@android:label", "remove:activity[@android:name=.Debug]", "remove:service"},
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <application>
+        <!-- Main. -->
+        <activity android:name=".Main" android:exported="true">
+            <meta-data android:name="a" android:value="1"/>
+        </activity>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name: "insert children",
+			edits: []string{
+				`insert:activity[@android:name=.Main]=<meta-data android:name="b" android:value="2"/>`,
+				`insert:/manifest=<dist:module dist:onDemand="true"/>`,
+			},
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" xmlns:dist="http://schemas.android.com/apk/distribution">
+    <application android:label="App">
+        <!-- Main. -->
+        <activity android:name=".Main" android:exported="true">
+            <meta-data android:name="a" android:value="1"/>
+            <meta-data android:name="b" android:value="2"/>
+        </activity>
+        <activity android:name=".Debug"/>
+    </application>
+    <dist:module dist:onDemand="true"/>
+</manifest>
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := EditManifest([]byte(editManifest), tc.edits)
+			if err != nil {
+				t.Fatalf("EditManifest returned unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("EditManifest returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestEditManifestErrors(t *testing.T) {
+	tests := []struct {
+		edit    string
+		wantErr string
+	}{
+		{"activity", "does not start with an operation"},
+		{"activity@android:exported=false", `unknown operation "activity@android"`},
+		{"replace:activity@android:exported=false", `unknown operation "replace"`},
+		{"set:activity@android:exported", "is not of the form set:{path}@{attr}={value}"},
+		{"set:activity", "is not of the form set:{path}@{attr}={value}"},
+		{"insert:activity", "is not of the form insert:{path}={xml}"},
+		{"set:service@android:exported=false", `path "service" selects no element`},
+		{"set:activity@foo:bar=1", `undeclared namespace prefix "foo"`},
+		{"remove:/manifest", "cannot remove the root element"},
+		{"insert:application=text", "holds no element"},
+		{"insert:application=<a>", "parsing"},
+	}
+	for _, tc := range tests {
+		_, err := EditManifest([]byte(editManifest), []string{tc.edit})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("EditManifest(%q) returned error %v, want error containing %q", tc.edit, err, tc.wantErr)
+		}
+	}
+}
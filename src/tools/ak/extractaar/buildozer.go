@@ -17,32 +17,37 @@ package extractaar
 import (
 	"fmt"
 	"strings"
+
+	"src/tools/ak/fixit"
 )
 
 // BuildozerError represent a rule configuration error fixable with a buildozer command.
 type BuildozerError struct {
 	Msg      string
 	RuleAttr string
+	OldValue string
 	NewValue string
 }
 
+// buildozerFixes returns the fixes of the errors fixable with a buildozer command.
+func buildozerFixes(label string, errs []*BuildozerError) []fixit.Fix {
+	var fixes []fixit.Fix
+	for _, err := range errs {
+		if err.NewValue != "" {
+			fixes = append(fixes, fixit.Fix{Label: label, Attr: err.RuleAttr, OldValue: err.OldValue, NewValue: err.NewValue, Message: err.Msg})
+		}
+	}
+	return fixes
+}
+
 func mergeBuildozerErrors(label string, errs []*BuildozerError) string {
 	var msg strings.Builder
 	msg.WriteString(fmt.Sprintf("error(s) found while processing aar '%s':\n", label))
-	var buildozerCommand strings.Builder
-	buildozerCommand.WriteString("Use the following command to fix the target:\nbuildozer ")
-	useBuildozer := false
 	for _, err := range errs {
 		msg.WriteString(fmt.Sprintf("\t- %s\n", err.Msg))
-		if err.NewValue != "" {
-			useBuildozer = true
-			buildozerCommand.WriteString(fmt.Sprintf("'set %s %s' ", err.RuleAttr, err.NewValue))
-		}
 	}
-	buildozerCommand.WriteString(label)
-
-	if useBuildozer {
-		msg.WriteString(buildozerCommand.String())
+	for _, cmd := range fixit.Commands(buildozerFixes(label, errs)) {
+		msg.WriteString("Use the following command to fix the target:\n" + cmd)
 	}
 	return msg.String()
 }
@@ -24,9 +24,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
+	"src/common/golang/ziputils"
+	"src/tools/ak/fixit"
 	"src/tools/ak/types"
 )
 
@@ -48,6 +51,28 @@ const (
 	manifest = iota
 	res
 	assets
+	classesJar
+	libJars
+	nativeLibs
+	proguard
+	lintJar
+	rTxt
+	publicTxt
+	annotations
+	prefab
+	aarMetadata
+)
+
+// Paths of files within an aar.
+const (
+	manifestPath    = "AndroidManifest.xml"
+	classesJarPath  = "classes.jar"
+	proguardPath    = "proguard.txt"
+	lintJarPath     = "lint.jar"
+	rTxtPath        = "R.txt"
+	publicTxtPath   = "public.txt"
+	annotationsPath = "annotations.zip"
+	aarMetadataPath = "META-INF/com/android/build/gradle/aar-metadata.properties"
 )
 
 var (
@@ -60,30 +85,78 @@ var (
 			"aar", "label",
 			"out_manifest", "out_res_dir", "out_assets_dir",
 			"has_res", "has_assets",
+			"out_classes_jar", "out_jars_dir", "out_jars_params",
+			"cpu", "out_native_libs_zip",
+			"out_proguard", "out_lint_jar", "has_lint_jar",
+			"out_r_txt", "out_public_txt", "out_annotations_zip",
+			"out_prefab_dir", "out_aar_metadata",
+			"compile_sdk", "compile_sdk_extension", "core_library_desugaring",
+			"fixes_out",
 		},
 	}
 
-	aar             string
-	label           string
-	outputManifest  string
-	outputResDir    string
-	outputAssetsDir string
-	hasRes          int
-	hasAssets       int
+	aar      string
+	label    string
+	out      outputs
+	cons     consumer
+	fixesOut string
 
 	initOnce sync.Once
 )
 
+// outputs holds the destinations of the files of the aar, which are not extracted when empty.
+type outputs struct {
+	manifest  string
+	resDir    string
+	assetsDir string
+	hasRes    int
+	hasAssets int
+
+	classesJar string
+	// jarsDir receives the jars of the libs/ directory, which jarsParams lists as singlejar
+	// sources.
+	jarsDir    string
+	jarsParams string
+	// nativeLibsZip receives the native libraries of cpu, as lib/<cpu>/*.so entries.
+	cpu           string
+	nativeLibsZip string
+	proguard      string
+	lintJar       string
+	hasLintJar    int
+	rTxt          string
+	publicTxt     string
+	annotations   string
+	prefabDir     string
+	aarMetadata   string
+}
+
 // Init initializes the extractor.
 func Init() {
 	initOnce.Do(func() {
 		flag.StringVar(&aar, "aar", "", "Path to the aar")
 		flag.StringVar(&label, "label", "", "Target's label")
-		flag.StringVar(&outputManifest, "out_manifest", "", "Output manifest")
-		flag.StringVar(&outputResDir, "out_res_dir", "", "Output resources directory")
-		flag.StringVar(&outputAssetsDir, "out_assets_dir", "", "Output assets directory")
-		flag.IntVar(&hasRes, "has_res", 0, "Whether the aar has resources")
-		flag.IntVar(&hasAssets, "has_assets", 0, "Whether the aar has assets")
+		flag.StringVar(&out.manifest, "out_manifest", "", "Output manifest")
+		flag.StringVar(&out.resDir, "out_res_dir", "", "Output resources directory")
+		flag.StringVar(&out.assetsDir, "out_assets_dir", "", "Output assets directory")
+		flag.IntVar(&out.hasRes, "has_res", 0, "Whether the aar has resources")
+		flag.IntVar(&out.hasAssets, "has_assets", 0, "Whether the aar has assets")
+		flag.StringVar(&out.classesJar, "out_classes_jar", "", "(optional) Output classes jar")
+		flag.StringVar(&out.jarsDir, "out_jars_dir", "", "(optional) Output directory of the jars in libs/")
+		flag.StringVar(&out.jarsParams, "out_jars_params", "", "(optional) Output singlejar param file listing the jars in libs/")
+		flag.StringVar(&out.cpu, "cpu", "", "(optional) CPU architecture of the native libraries to extract")
+		flag.StringVar(&out.nativeLibsZip, "out_native_libs_zip", "", "(optional) Output zip of the native libraries of -cpu")
+		flag.StringVar(&out.proguard, "out_proguard", "", "(optional) Output proguard specs")
+		flag.StringVar(&out.lintJar, "out_lint_jar", "", "(optional) Output lint rules jar")
+		flag.IntVar(&out.hasLintJar, "has_lint_jar", 0, "Whether the aar has a lint rules jar")
+		flag.StringVar(&out.rTxt, "out_r_txt", "", "(optional) Output R.txt")
+		flag.StringVar(&out.publicTxt, "out_public_txt", "", "(optional) Output public.txt")
+		flag.StringVar(&out.annotations, "out_annotations_zip", "", "(optional) Output annotations zip")
+		flag.StringVar(&out.prefabDir, "out_prefab_dir", "", "(optional) Output prefab directory")
+		flag.StringVar(&out.aarMetadata, "out_aar_metadata", "", "(optional) Output aar-metadata.properties")
+		flag.StringVar(&cons.compileSdk, "compile_sdk", "", "(optional) SDK level compiled against, checked against minCompileSdk of aar-metadata.properties")
+		flag.IntVar(&cons.compileSdkExtension, "compile_sdk_extension", -1, "(optional) SDK extension level compiled against, checked against minCompileSdkExtension of aar-metadata.properties")
+		flag.IntVar(&cons.desugaring, "core_library_desugaring", 0, "Whether core library desugaring is enabled, checked against coreLibraryDesugaringEnabled of aar-metadata.properties")
+		flag.StringVar(&fixesOut, "fixes_out", "", "(optional) Path to write the JSON fix-it suggestions to")
 	})
 }
 
@@ -107,12 +180,12 @@ type toCopy struct {
 
 // Run runs the extractor
 func Run() {
-	if err := doWork(aar, label, outputManifest, outputResDir, outputAssetsDir, hasRes, hasAssets); err != nil {
+	if err := doWork(aar, label, out, cons, fixesOut); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func doWork(aar, label, outputManifest, outputResDir, outputAssetsDir string, hasRes, hasAssets int) error {
+func doWork(aar, label string, out outputs, cons consumer, fixesOut string) error {
 	tmpDir, err := os.MkdirTemp("", "extractaar_")
 	if err != nil {
 		return err
@@ -124,10 +197,25 @@ func doWork(aar, label, outputManifest, outputResDir, outputAssetsDir string, ha
 		return err
 	}
 
+	// Native libraries are staged under lib/<cpu>/ to be zipped.
+	nativeLibsDir := ""
+	if out.nativeLibsZip != "" {
+		nativeLibsDir = filepath.Join(tmpDir, "_native_libs")
+	}
 	validators := map[int]validator{
-		manifest: manifestValidator{dest: outputManifest},
-		res:      resourceValidator{dest: outputResDir, hasRes: tristate(hasRes), ruleAttr: "has_res"},
-		assets:   resourceValidator{dest: outputAssetsDir, hasRes: tristate(hasAssets), ruleAttr: "has_assets"},
+		manifest:    manifestValidator{dest: out.manifest},
+		res:         resourceValidator{dest: out.resDir, hasRes: tristate(out.hasRes), ruleAttr: "has_res"},
+		assets:      resourceValidator{dest: out.assetsDir, hasRes: tristate(out.hasAssets), ruleAttr: "has_assets"},
+		classesJar:  fileValidator{dest: out.classesJar, name: classesJarPath},
+		libJars:     dirValidator{dest: out.jarsDir},
+		nativeLibs:  nativeLibsValidator{dest: nativeLibsDir, cpu: out.cpu},
+		proguard:    fileValidator{dest: out.proguard, name: proguardPath},
+		lintJar:     fileValidator{dest: out.lintJar, name: lintJarPath, has: tristate(out.hasLintJar), ruleAttr: "has_lint_jar"},
+		rTxt:        fileValidator{dest: out.rTxt, name: rTxtPath},
+		publicTxt:   fileValidator{dest: out.publicTxt, name: publicTxtPath},
+		annotations: fileValidator{dest: out.annotations, name: annotationsPath},
+		prefab:      dirValidator{dest: out.prefabDir},
+		aarMetadata: aarMetadataValidator{fileValidator: fileValidator{dest: out.aarMetadata, name: aarMetadataPath}, consumer: cons},
 	}
 
 	var filesToCopy []*toCopy
@@ -141,50 +229,155 @@ func doWork(aar, label, outputManifest, outputResDir, outputAssetsDir string, ha
 		filesToCopy = append(filesToCopy, validatedFiles...)
 	}
 
+	if err := fixit.WriteFile(fixesOut, buildozerFixes(label, validationErrs)); err != nil {
+		return err
+	}
 	if len(validationErrs) != 0 {
 		return errors.New(mergeBuildozerErrors(label, validationErrs))
 	}
 
+	sort.Slice(filesToCopy, func(i, j int) bool { return filesToCopy[i].dest < filesToCopy[j].dest })
+	var jars []string
 	for _, file := range filesToCopy {
 		if err := copyFile(file.src, file.dest); err != nil {
 			return err
 		}
+		if out.jarsDir != "" && strings.HasPrefix(file.dest, out.jarsDir+string(os.PathSeparator)) {
+			jars = append(jars, file.dest)
+		}
 	}
 
 	// TODO(ostonge): Add has_res/has_assets attr to avoid having to do this
 	// We need to create at least one file so that Bazel does not complain
 	// that the output tree artifact was not created.
-	if err := createIfEmpty(outputResDir, "res/values/empty.xml", "<resources/>"); err != nil {
+	if err := createIfEmpty(out.resDir, "res/values/empty.xml", "<resources/>"); err != nil {
 		return err
 	}
 	// aapt will ignore this file and not print an error message, because it
 	// thinks that it is a swap file
-	if err := createIfEmpty(outputAssetsDir, "assets/empty_asset_generated_by_bazel~", ""); err != nil {
+	if err := createIfEmpty(out.assetsDir, "assets/empty_asset_generated_by_bazel~", ""); err != nil {
 		return err
 	}
+	return writeOptionalOutputs(out, nativeLibsDir, jars)
+}
+
+// writeOptionalOutputs writes the outputs derived from the extracted files, and creates the
+// requested outputs for which the aar has no files, as Bazel expects every output to be created.
+func writeOptionalOutputs(out outputs, nativeLibsDir string, jars []string) error {
+	if out.jarsParams != "" {
+		var params strings.Builder
+		if len(jars) > 0 {
+			params.WriteString("--sources\n")
+		}
+		for _, jar := range jars {
+			params.WriteString(jar + "\n")
+		}
+		if err := os.WriteFile(out.jarsParams, []byte(params.String()), 0644); err != nil {
+			return err
+		}
+	}
+	if out.nativeLibsZip != "" {
+		isEmpty, err := dirIsEmpty(nativeLibsDir)
+		if err != nil {
+			return err
+		}
+		if isEmpty {
+			err = ziputils.EmptyZip(out.nativeLibsZip)
+		} else {
+			err = ziputils.Zip(nativeLibsDir, out.nativeLibsZip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, dir := range []string{out.jarsDir, out.prefabDir} {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	for _, f := range []struct {
+		dest string
+		zip  bool
+	}{
+		{out.classesJar, true},
+		{out.proguard, false},
+		{out.lintJar, true},
+		{out.rTxt, false},
+		{out.publicTxt, false},
+		{out.annotations, true},
+		{out.aarMetadata, false},
+	} {
+		if f.dest == "" {
+			continue
+		}
+		if _, err := os.Stat(f.dest); err == nil || !os.IsNotExist(err) {
+			continue
+		}
+		var err error
+		if f.zip {
+			err = ziputils.EmptyZip(f.dest)
+		} else {
+			err = os.WriteFile(f.dest, nil, 0644)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func groupAARFiles(aarFiles []*aarFile) map[int][]*aarFile {
 	// Map of file type to channel of aarFile
 	filesMap := make(map[int][]*aarFile)
-	for _, fileType := range []int{manifest, res, assets} {
+	for _, fileType := range []int{manifest, res, assets, classesJar, libJars, nativeLibs, proguard, lintJar, rTxt, publicTxt, annotations, prefab, aarMetadata} {
 		filesMap[fileType] = make([]*aarFile, 0)
 	}
 
+	singleFiles := map[string]int{
+		manifestPath:    manifest,
+		classesJarPath:  classesJar,
+		proguardPath:    proguard,
+		lintJarPath:     lintJar,
+		rTxtPath:        rTxt,
+		publicTxtPath:   publicTxt,
+		annotationsPath: annotations,
+		aarMetadataPath: aarMetadata,
+	}
 	for _, file := range aarFiles {
-		if file.relPath == "AndroidManifest.xml" {
-			filesMap[manifest] = append(filesMap[manifest], file)
+		if fileType, ok := singleFiles[file.relPath]; ok {
+			filesMap[fileType] = append(filesMap[fileType], file)
 		} else if strings.HasPrefix(file.relPath, "res"+string(os.PathSeparator)) {
 			filesMap[res] = append(filesMap[res], file)
 		} else if strings.HasPrefix(file.relPath, "assets"+string(os.PathSeparator)) {
 			filesMap[assets] = append(filesMap[assets], file)
+		} else if strings.HasPrefix(file.relPath, "libs"+string(os.PathSeparator)) && strings.HasSuffix(file.relPath, ".jar") {
+			filesMap[libJars] = append(filesMap[libJars], file)
+		} else if strings.HasPrefix(file.relPath, "jni"+string(os.PathSeparator)) && strings.HasSuffix(file.relPath, ".so") {
+			filesMap[nativeLibs] = append(filesMap[nativeLibs], file)
+		} else if strings.HasPrefix(file.relPath, "prefab"+string(os.PathSeparator)) {
+			filesMap[prefab] = append(filesMap[prefab], file)
 		}
-		// TODO(ostonge): support jar and aidl files
+		// TODO(ostonge): support aidl files
 	}
 	return filesMap
 }
 
+// Extract extracts the files of the aar under dest, and returns their paths within the aar.
+func Extract(aar, dest string) ([]string, error) {
+	files, err := extractAAR(aar, dest)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.relPath)
+	}
+	return paths, nil
+}
+
 func extractAAR(aar string, dest string) ([]*aarFile, error) {
 	reader, err := zip.OpenReader(aar)
 	if err != nil {
@@ -198,6 +391,9 @@ func extractAAR(aar string, dest string) ([]*aarFile, error) {
 			continue
 		}
 		extractedPath := filepath.Join(dest, f.Name)
+		if !strings.HasPrefix(extractedPath, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return nil, fmt.Errorf("%s: invalid file path %q", aar, f.Name)
+		}
 		if err := extractFile(f, extractedPath); err != nil {
 			return nil, err
 		}
@@ -15,9 +15,17 @@
 package extractaar
 
 import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
+	"src/common/golang/ziptest"
+	"src/tools/ak/fixit"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestGroupAARFiles(t *testing.T) {
@@ -30,9 +38,19 @@ func TestGroupAARFiles(t *testing.T) {
 			name:  "empty aar",
 			files: []*aarFile{},
 			expectedMap: map[int][]*aarFile{
-				manifest: []*aarFile{},
-				res:      []*aarFile{},
-				assets:   []*aarFile{},
+				manifest:    []*aarFile{},
+				res:         []*aarFile{},
+				assets:      []*aarFile{},
+				classesJar:  []*aarFile{},
+				libJars:     []*aarFile{},
+				nativeLibs:  []*aarFile{},
+				proguard:    []*aarFile{},
+				lintJar:     []*aarFile{},
+				rTxt:        []*aarFile{},
+				publicTxt:   []*aarFile{},
+				annotations: []*aarFile{},
+				prefab:      []*aarFile{},
+				aarMetadata: []*aarFile{},
 			},
 		},
 		{
@@ -47,6 +65,13 @@ func TestGroupAARFiles(t *testing.T) {
 				&aarFile{relPath: "libs/foo.jar"},
 				&aarFile{relPath: "resource/some/file.txt"},
 				&aarFile{relPath: "assets/some/asset.png"},
+				&aarFile{relPath: "libs/README"},
+				&aarFile{relPath: "jni/arm64-v8a/libfoo.so"},
+				&aarFile{relPath: "R.txt"},
+				&aarFile{relPath: "public.txt"},
+				&aarFile{relPath: "annotations.zip"},
+				&aarFile{relPath: "prefab/prefab.json"},
+				&aarFile{relPath: "META-INF/com/android/build/gradle/aar-metadata.properties"},
 			},
 			expectedMap: map[int][]*aarFile{
 				manifest: []*aarFile{
@@ -58,6 +83,36 @@ func TestGroupAARFiles(t *testing.T) {
 				assets: []*aarFile{
 					&aarFile{relPath: "assets/some/asset.png"},
 				},
+				classesJar: []*aarFile{
+					&aarFile{relPath: "classes.jar"},
+				},
+				libJars: []*aarFile{
+					&aarFile{relPath: "libs/foo.jar"},
+				},
+				nativeLibs: []*aarFile{
+					&aarFile{relPath: "jni/arm64-v8a/libfoo.so"},
+				},
+				proguard: []*aarFile{
+					&aarFile{relPath: "proguard.txt"},
+				},
+				lintJar: []*aarFile{
+					&aarFile{relPath: "lint.jar"},
+				},
+				rTxt: []*aarFile{
+					&aarFile{relPath: "R.txt"},
+				},
+				publicTxt: []*aarFile{
+					&aarFile{relPath: "public.txt"},
+				},
+				annotations: []*aarFile{
+					&aarFile{relPath: "annotations.zip"},
+				},
+				prefab: []*aarFile{
+					&aarFile{relPath: "prefab/prefab.json"},
+				},
+				aarMetadata: []*aarFile{
+					&aarFile{relPath: "META-INF/com/android/build/gradle/aar-metadata.properties"},
+				},
 			},
 		},
 	}
@@ -71,3 +126,134 @@ func TestGroupAARFiles(t *testing.T) {
 		})
 	}
 }
+
+func zipEntries(t *testing.T, path string) []string {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDoWork(t *testing.T) {
+	tmp := t.TempDir()
+	aarPath := filepath.Join(tmp, "lib.aar")
+	ziptest.Write(t, aarPath, map[string]string{
+		"AndroidManifest.xml":     "<manifest/>",
+		"classes.jar":             "classes",
+		"libs/a.jar":              "a",
+		"libs/b.jar":              "b",
+		"jni/arm64-v8a/libfoo.so": "arm64",
+		"jni/x86/libfoo.so":       "x86",
+		"proguard.txt":            "-keep class *",
+		"R.txt":                   "int string app_name 0x7f010001",
+		"prefab/prefab.json":      "{}",
+	})
+	out := outputs{
+		manifest:      filepath.Join(tmp, "AndroidManifest.xml"),
+		resDir:        filepath.Join(tmp, "res"),
+		assetsDir:     filepath.Join(tmp, "assets"),
+		classesJar:    filepath.Join(tmp, "classes.jar"),
+		jarsDir:       filepath.Join(tmp, "jars"),
+		jarsParams:    filepath.Join(tmp, "jars.params"),
+		cpu:           "arm64-v8a",
+		nativeLibsZip: filepath.Join(tmp, "native_libs.zip"),
+		proguard:      filepath.Join(tmp, "proguard.txt"),
+		lintJar:       filepath.Join(tmp, "lint.jar"),
+		rTxt:          filepath.Join(tmp, "R.txt"),
+		publicTxt:     filepath.Join(tmp, "public.txt"),
+		annotations:   filepath.Join(tmp, "annotations.zip"),
+		prefabDir:     filepath.Join(tmp, "prefab"),
+		aarMetadata:   filepath.Join(tmp, "aar-metadata.properties"),
+	}
+	if err := doWork(aarPath, "//java:lib", out, consumer{compileSdkExtension: -1}, ""); err != nil {
+		t.Fatalf("doWork() unexpected error: %v", err)
+	}
+
+	for path, want := range map[string]string{
+		out.manifest:    "<manifest/>",
+		out.classesJar:  "classes",
+		out.proguard:    "-keep class *",
+		out.rTxt:        "int string app_name 0x7f010001",
+		out.publicTxt:   "",
+		out.aarMetadata: "",
+		out.jarsParams: "--sources\n" +
+			filepath.Join(out.jarsDir, "libs", "a.jar") + "\n" +
+			filepath.Join(out.jarsDir, "libs", "b.jar") + "\n",
+		filepath.Join(out.prefabDir, "prefab", "prefab.json"): "{}",
+	} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("os.ReadFile(%s) unexpected error: %v", path, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+	if diff := cmp.Diff([]string{"lib/arm64-v8a/libfoo.so"}, zipEntries(t, out.nativeLibsZip)); diff != "" {
+		t.Errorf("native libs zip entries returned diff (-want, +got):\n%v", diff)
+	}
+	for _, path := range []string{out.lintJar, out.annotations} {
+		if entries := zipEntries(t, path); len(entries) != 0 {
+			t.Errorf("%s has entries %v, want an empty zip", path, entries)
+		}
+	}
+}
+
+func TestExtractInvalidPath(t *testing.T) {
+	tmp := t.TempDir()
+	aarPath := filepath.Join(tmp, "lib.aar")
+	ziptest.Write(t, aarPath, map[string]string{
+		"AndroidManifest.xml": "<manifest/>",
+		"../escaped.txt":      "escaped",
+	})
+	dest := filepath.Join(tmp, "out")
+	if _, err := Extract(aarPath, dest); err == nil || !strings.Contains(err.Error(), `invalid file path "../escaped.txt"`) {
+		t.Errorf("Extract(%s) returned error %v, want invalid file path", aarPath, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "escaped.txt")); !os.IsNotExist(err) {
+		t.Errorf("Extract(%s) wrote outside of %s", aarPath, dest)
+	}
+}
+
+func TestDoWorkLintJarError(t *testing.T) {
+	tmp := t.TempDir()
+	aarPath := filepath.Join(tmp, "lib.aar")
+	ziptest.Write(t, aarPath, map[string]string{
+		"AndroidManifest.xml": "<manifest/>",
+		"lint.jar":            "lint",
+	})
+	out := outputs{
+		manifest:   filepath.Join(tmp, "AndroidManifest.xml"),
+		resDir:     filepath.Join(tmp, "res"),
+		assetsDir:  filepath.Join(tmp, "assets"),
+		lintJar:    filepath.Join(tmp, "lint.jar"),
+		hasLintJar: int(tsFalse),
+	}
+	fixesOut := filepath.Join(tmp, "fixes.json")
+	if err := doWork(aarPath, "//java:lib", out, consumer{compileSdkExtension: -1}, fixesOut); err == nil {
+		t.Error("doWork() expected error for mismatched has_lint_jar but succeeded")
+	}
+
+	f, err := os.Open(fixesOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fixes, err := fixit.Decode(f)
+	if err != nil {
+		t.Fatalf("fixit.Decode() unexpected error: %v", err)
+	}
+	want := []fixit.Fix{{Label: "//java:lib", Attr: "has_lint_jar", OldValue: "False", NewValue: "True"}}
+	if diff := cmp.Diff(want, fixes, cmpopts.IgnoreFields(fixit.Fix{}, "Message")); diff != "" {
+		t.Errorf("doWork() wrote fixes diff (-want, +got):\n%v", diff)
+	}
+}
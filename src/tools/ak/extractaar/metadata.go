@@ -0,0 +1,136 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractaar
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"src/common/golang/ini"
+	"src/tools/ak/sdklevel"
+)
+
+// Keys of aar-metadata.properties.
+const (
+	minCompileSdkKey                 = "minCompileSdk"
+	minCompileSdkExtensionKey        = "minCompileSdkExtension"
+	minAndroidGradlePluginVersionKey = "minAndroidGradlePluginVersion"
+	coreLibraryDesugaringEnabledKey  = "coreLibraryDesugaringEnabled"
+)
+
+// consumer describes the build consuming the aar, against which aar-metadata.properties is
+// checked. Unset fields are not checked.
+type consumer struct {
+	// compileSdk is the SDK level the consumer compiles against.
+	compileSdk string
+	// compileSdkExtension is the SDK extension level of the compile SDK, -1 if unknown.
+	compileSdkExtension int
+	// desugaring is whether core library desugaring is enabled.
+	desugaring int
+}
+
+// gradleMetadata holds the requirements an aar declares on its consumers in
+// aar-metadata.properties.
+type gradleMetadata struct {
+	minCompileSdk          sdklevel.Level
+	minCompileSdkExtension int
+	// minAGPVersion is the minimum Android Gradle Plugin version. It does not apply to Bazel
+	// builds and is only kept for reporting.
+	minAGPVersion         string
+	coreLibraryDesugaring bool
+}
+
+func parseAARMetadata(props map[string]string) (gradleMetadata, error) {
+	var m gradleMetadata
+	var err error
+	if v := props[minCompileSdkKey]; v != "" {
+		if m.minCompileSdk, err = sdklevel.Parse(v); err != nil {
+			return gradleMetadata{}, fmt.Errorf("%s: %v", minCompileSdkKey, err)
+		}
+	}
+	if v := props[minCompileSdkExtensionKey]; v != "" {
+		if m.minCompileSdkExtension, err = strconv.Atoi(v); err != nil || m.minCompileSdkExtension < 0 {
+			return gradleMetadata{}, fmt.Errorf("%s: invalid SDK extension level %q", minCompileSdkExtensionKey, v)
+		}
+	}
+	m.minAGPVersion = props[minAndroidGradlePluginVersionKey]
+	if v := props[coreLibraryDesugaringEnabledKey]; v != "" {
+		if m.coreLibraryDesugaring, err = strconv.ParseBool(v); err != nil {
+			return gradleMetadata{}, fmt.Errorf("%s: invalid boolean %q", coreLibraryDesugaringEnabledKey, v)
+		}
+	}
+	return m, nil
+}
+
+// check returns the requirements of m the consumer does not meet.
+func (m gradleMetadata) check(c consumer) ([]string, error) {
+	var violations []string
+	if c.compileSdk != "" && !m.minCompileSdk.IsZero() {
+		compileSdk, err := sdklevel.Parse(c.compileSdk)
+		if err != nil {
+			return nil, fmt.Errorf("compile SDK: %v", err)
+		}
+		cmp, err := compileSdk.Compare(m.minCompileSdk)
+		if err != nil {
+			violations = append(violations, fmt.Sprintf("%s is %s, but the compile SDK is %s: %v", minCompileSdkKey, m.minCompileSdk, compileSdk, err))
+		} else if cmp < 0 {
+			violations = append(violations, fmt.Sprintf("%s is %s, but the compile SDK is %s", minCompileSdkKey, m.minCompileSdk, compileSdk))
+		}
+	}
+	if c.compileSdkExtension >= 0 && m.minCompileSdkExtension > c.compileSdkExtension {
+		violations = append(violations, fmt.Sprintf("%s is %d, but the compile SDK extension is %d", minCompileSdkExtensionKey, m.minCompileSdkExtension, c.compileSdkExtension))
+	}
+	if desugaring := tristate(c.desugaring); m.coreLibraryDesugaring && desugaring.isSet() && !desugaring.value() {
+		violations = append(violations, fmt.Sprintf("%s is true, but core library desugaring is disabled", coreLibraryDesugaringEnabledKey))
+	}
+	return violations, nil
+}
+
+// aarMetadataValidator copies aar-metadata.properties like fileValidator, and checks its
+// requirements against the consumer.
+type aarMetadataValidator struct {
+	fileValidator
+	consumer consumer
+}
+
+func (v aarMetadataValidator) validate(files []*aarFile) ([]*toCopy, *BuildozerError) {
+	filesToCopy, bErr := v.fileValidator.validate(files)
+	if bErr != nil || len(files) == 0 {
+		return filesToCopy, bErr
+	}
+	props, err := ini.Read(files[0].path)
+	if err != nil {
+		return nil, &BuildozerError{Msg: fmt.Sprintf("Failed to read %s: %v", v.name, err)}
+	}
+	m, err := parseAARMetadata(props)
+	if err != nil {
+		return nil, &BuildozerError{Msg: fmt.Sprintf("Invalid %s: %v", v.name, err)}
+	}
+	violations, err := m.check(v.consumer)
+	if err != nil {
+		return nil, &BuildozerError{Msg: err.Error()}
+	}
+	if len(violations) > 0 {
+		// The compile SDK and desugaring are set by the consuming build, not by attributes of the
+		// aar_import target, so there is no buildozer fix.
+		for i, violation := range violations {
+			violations[i] = path.Base(v.name) + " " + violation
+		}
+		return nil, &BuildozerError{Msg: strings.Join(violations, "; ")}
+	}
+	return filesToCopy, nil
+}
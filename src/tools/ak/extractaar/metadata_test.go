@@ -0,0 +1,177 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractaar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"src/tools/ak/sdklevel"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseAARMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		props   map[string]string
+		want    gradleMetadata
+		wantErr bool
+	}{
+		{
+			name: "all keys",
+			props: map[string]string{
+				"aarFormatVersion":              "1.0",
+				"minCompileSdk":                 "34",
+				"minCompileSdkExtension":        "7",
+				"minAndroidGradlePluginVersion": "8.1.0",
+				"coreLibraryDesugaringEnabled":  "true",
+			},
+			want: gradleMetadata{
+				minCompileSdk:          sdklevel.API(34),
+				minCompileSdkExtension: 7,
+				minAGPVersion:          "8.1.0",
+				coreLibraryDesugaring:  true,
+			},
+		},
+		{
+			name:  "no keys",
+			props: map[string]string{},
+		},
+		{
+			name:  "preview compile SDK",
+			props: map[string]string{"minCompileSdk": "VanillaIceCream"},
+			want:  gradleMetadata{minCompileSdk: sdklevel.Level{Major: 35, Codename: "VanillaIceCream"}},
+		},
+		{
+			name:    "invalid compile SDK",
+			props:   map[string]string{"minCompileSdk": "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid SDK extension",
+			props:   map[string]string{"minCompileSdkExtension": "seven"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid desugaring",
+			props:   map[string]string{"coreLibraryDesugaringEnabled": "maybe"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseAARMetadata(tc.props)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseAARMetadata(%v) error = %v, want error %t", tc.props, err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(gradleMetadata{})); diff != "" {
+				t.Errorf("parseAARMetadata(%v) returned diff (-want, +got):\n%v", tc.props, diff)
+			}
+		})
+	}
+}
+
+func TestAARMetadataCheck(t *testing.T) {
+	m := gradleMetadata{
+		minCompileSdk:          sdklevel.API(34),
+		minCompileSdkExtension: 7,
+		coreLibraryDesugaring:  true,
+	}
+	tests := []struct {
+		name           string
+		consumer       consumer
+		wantViolations int
+	}{
+		{
+			name:     "unchecked",
+			consumer: consumer{compileSdkExtension: -1},
+		},
+		{
+			name:     "requirements met",
+			consumer: consumer{compileSdk: "35", compileSdkExtension: 7, desugaring: int(tsTrue)},
+		},
+		{
+			name:           "compile SDK too low",
+			consumer:       consumer{compileSdk: "33", compileSdkExtension: -1},
+			wantViolations: 1,
+		},
+		{
+			name:           "compile SDK preview",
+			consumer:       consumer{compileSdk: "VanillaIceCream", compileSdkExtension: -1},
+			wantViolations: 1,
+		},
+		{
+			name:           "all requirements unmet",
+			consumer:       consumer{compileSdk: "33", compileSdkExtension: 6, desugaring: int(tsFalse)},
+			wantViolations: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			violations, err := m.check(tc.consumer)
+			if err != nil {
+				t.Fatalf("check(%+v) unexpected error: %v", tc.consumer, err)
+			}
+			if len(violations) != tc.wantViolations {
+				t.Errorf("check(%+v) = %q, want %d violations", tc.consumer, violations, tc.wantViolations)
+			}
+		})
+	}
+}
+
+func TestValidateAARMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aar-metadata.properties")
+	content := "aarFormatVersion=1.0\nminCompileSdk=34\ncoreLibraryDesugaringEnabled=true\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := []*aarFile{&aarFile{path: path, relPath: aarMetadataPath}}
+	tests := []struct {
+		name          string
+		consumer      consumer
+		expectedFiles []*toCopy
+		expectedError *BuildozerError
+	}{
+		{
+			name:          "requirements met",
+			consumer:      consumer{compileSdk: "34", compileSdkExtension: -1, desugaring: int(tsTrue)},
+			expectedFiles: []*toCopy{&toCopy{src: path, dest: "/dest/aar-metadata.properties"}},
+		},
+		{
+			name:          "requirements not met",
+			consumer:      consumer{compileSdk: "33", compileSdkExtension: -1, desugaring: int(tsFalse)},
+			expectedError: &BuildozerError{Msg: "aar-metadata.properties minCompileSdk is 34, but the compile SDK is 33; aar-metadata.properties coreLibraryDesugaringEnabled is true, but core library desugaring is disabled"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			validator := aarMetadataValidator{
+				fileValidator: fileValidator{dest: "/dest/aar-metadata.properties", name: aarMetadataPath},
+				consumer:      tc.consumer,
+			}
+			got, err := validator.validate(files)
+			if diff := cmp.Diff(tc.expectedError, err); diff != "" {
+				t.Errorf("aarMetadataValidator.validate(%s) returned error diff (-want, +got):\n%v", files, diff)
+			}
+			if diff := cmp.Diff(tc.expectedFiles, got, cmp.AllowUnexported(toCopy{})); diff != "" {
+				t.Errorf("aarMetadataValidator.validate(%s) returned diff (-want, +got):\n%v", files, diff)
+			}
+		})
+	}
+}
@@ -70,8 +70,80 @@ func (v resourceValidator) validate(files []*aarFile) ([]*toCopy, *BuildozerErro
 				not = "not "
 			}
 			msg := fmt.Sprintf("%s attribute is %s, but files were %sfound", v.ruleAttr, boolToString(v.hasRes.value()), not)
-			return nil, &BuildozerError{Msg: msg, RuleAttr: v.ruleAttr, NewValue: boolToString(seen)}
+			return nil, &BuildozerError{Msg: msg, RuleAttr: v.ruleAttr, OldValue: boolToString(v.hasRes.value()), NewValue: boolToString(seen)}
 		}
 	}
 	return filesToCopy, nil
 }
+
+// fileValidator validates a file that appears at most once in the aar, e.g. classes.jar.
+type fileValidator struct {
+	dest     string
+	name     string
+	ruleAttr string
+	has      tristate
+}
+
+func (v fileValidator) validate(files []*aarFile) ([]*toCopy, *BuildozerError) {
+	if len(files) > 1 {
+		return nil, &BuildozerError{Msg: fmt.Sprintf("More than one %s was found", v.name)}
+	}
+	seen := len(files) == 1
+	if v.has.isSet() && seen != v.has.value() {
+		var not string
+		if !seen {
+			not = "not "
+		}
+		msg := fmt.Sprintf("%s attribute is %s, but %s was %sfound", v.ruleAttr, boolToString(v.has.value()), v.name, not)
+		return nil, &BuildozerError{Msg: msg, RuleAttr: v.ruleAttr, OldValue: boolToString(v.has.value()), NewValue: boolToString(seen)}
+	}
+	if !seen || v.dest == "" {
+		return nil, nil
+	}
+	return []*toCopy{{src: files[0].path, dest: v.dest}}, nil
+}
+
+// dirValidator copies files of the aar under dest, keeping their path within the aar.
+type dirValidator struct {
+	dest string
+}
+
+func (v dirValidator) validate(files []*aarFile) ([]*toCopy, *BuildozerError) {
+	if v.dest == "" {
+		return nil, nil
+	}
+	var filesToCopy []*toCopy
+	for _, file := range files {
+		filesToCopy = append(filesToCopy,
+			&toCopy{src: file.path, dest: filepath.Join(v.dest, file.relPath)},
+		)
+	}
+	return filesToCopy, nil
+}
+
+// nativeLibsValidator copies the jni/<cpu>/*.so files of the aar to dest/lib/<cpu>/, the layout
+// of native libraries in an apk.
+type nativeLibsValidator struct {
+	dest string
+	cpu  string
+}
+
+func (v nativeLibsValidator) validate(files []*aarFile) ([]*toCopy, *BuildozerError) {
+	if v.dest == "" {
+		return nil, nil
+	}
+	if v.cpu == "" {
+		return nil, &BuildozerError{Msg: "-out_native_libs_zip is set but -cpu is empty, set -cpu to the architecture of the native libraries"}
+	}
+	var filesToCopy []*toCopy
+	prefix := filepath.Join("jni", v.cpu) + string(filepath.Separator)
+	for _, file := range files {
+		if !strings.HasPrefix(file.relPath, prefix) {
+			continue
+		}
+		filesToCopy = append(filesToCopy,
+			&toCopy{src: file.path, dest: filepath.Join(v.dest, "lib", v.cpu, strings.TrimPrefix(file.relPath, prefix))},
+		)
+	}
+	return filesToCopy, nil
+}
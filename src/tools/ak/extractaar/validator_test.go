@@ -15,6 +15,7 @@
 package extractaar
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -149,14 +150,14 @@ func TestValidateResourcesError(t *testing.T) {
 			},
 			hasRes:        tristate(-1),
 			ruleAttr:      "test",
-			expectedError: &BuildozerError{RuleAttr: "test", NewValue: "True"},
+			expectedError: &BuildozerError{RuleAttr: "test", OldValue: "False", NewValue: "True"},
 		},
 		{
 			name:          "no resources with invalid hasRes attribute",
 			files:         []*aarFile{},
 			hasRes:        tristate(1),
 			ruleAttr:      "test",
-			expectedError: &BuildozerError{RuleAttr: "test", NewValue: "False"},
+			expectedError: &BuildozerError{RuleAttr: "test", OldValue: "True", NewValue: "False"},
 		},
 	}
 
@@ -173,3 +174,125 @@ func TestValidateResourcesError(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         []*aarFile
+		dest          string
+		has           tristate
+		expectedFiles []*toCopy
+		expectedError *BuildozerError
+	}{
+		{
+			name:          "file copied",
+			files:         []*aarFile{&aarFile{path: "/tmp/aar/lint.jar", relPath: "lint.jar"}},
+			dest:          "/dest/lint.jar",
+			expectedFiles: []*toCopy{&toCopy{src: "/tmp/aar/lint.jar", dest: "/dest/lint.jar"}},
+		},
+		{
+			name:  "file not requested",
+			files: []*aarFile{&aarFile{path: "/tmp/aar/lint.jar", relPath: "lint.jar"}},
+		},
+		{
+			name:          "file found with has attribute false",
+			files:         []*aarFile{&aarFile{path: "/tmp/aar/lint.jar", relPath: "lint.jar"}},
+			dest:          "/dest/lint.jar",
+			has:           tsFalse,
+			expectedError: &BuildozerError{RuleAttr: "has_lint_jar", OldValue: "False", NewValue: "True"},
+		},
+		{
+			name:          "file not found with has attribute true",
+			dest:          "/dest/lint.jar",
+			has:           tsTrue,
+			expectedError: &BuildozerError{RuleAttr: "has_lint_jar", OldValue: "True", NewValue: "False"},
+		},
+		{
+			name: "more than one file",
+			files: []*aarFile{
+				&aarFile{path: "/tmp/aar/lint.jar", relPath: "lint.jar"},
+				&aarFile{path: "/tmp/aar/lint.jar", relPath: "lint.jar"},
+			},
+			expectedError: &BuildozerError{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			validator := fileValidator{dest: tc.dest, name: "lint.jar", ruleAttr: "has_lint_jar", has: tc.has}
+			files, err := validator.validate(tc.files)
+			if diff := cmp.Diff(tc.expectedError, err, cmpopts.IgnoreFields(BuildozerError{}, "Msg")); diff != "" {
+				t.Errorf("fileValidator.validate(%s) returned error diff (-want, +got):\n%v", tc.files, diff)
+			}
+			if diff := cmp.Diff(tc.expectedFiles, files, cmp.AllowUnexported(toCopy{})); diff != "" {
+				t.Errorf("fileValidator.validate(%s) returned diff (-want, +got):\n%v", tc.files, diff)
+			}
+		})
+	}
+}
+
+func TestValidateDir(t *testing.T) {
+	files := []*aarFile{
+		&aarFile{path: "/tmp/aar/libs/a.jar", relPath: "libs/a.jar"},
+		&aarFile{path: "/tmp/aar/libs/b.jar", relPath: "libs/b.jar"},
+	}
+	want := []*toCopy{
+		&toCopy{src: "/tmp/aar/libs/a.jar", dest: "/dest/jars/libs/a.jar"},
+		&toCopy{src: "/tmp/aar/libs/b.jar", dest: "/dest/jars/libs/b.jar"},
+	}
+	got, err := dirValidator{dest: "/dest/jars"}.validate(files)
+	if err != nil {
+		t.Fatalf("dirValidator.validate(%s) unexpected error: %v", files, err)
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(toCopy{})); diff != "" {
+		t.Errorf("dirValidator.validate(%s) returned diff (-want, +got):\n%v", files, diff)
+	}
+}
+
+func TestValidateNativeLibs(t *testing.T) {
+	files := []*aarFile{
+		&aarFile{path: "/tmp/aar/jni/arm64-v8a/libfoo.so", relPath: "jni/arm64-v8a/libfoo.so"},
+		&aarFile{path: "/tmp/aar/jni/armeabi-v7a/libfoo.so", relPath: "jni/armeabi-v7a/libfoo.so"},
+	}
+	tests := []struct {
+		name          string
+		cpu           string
+		expectedFiles []*toCopy
+	}{
+		{
+			name: "matching cpu",
+			cpu:  "arm64-v8a",
+			expectedFiles: []*toCopy{
+				&toCopy{src: "/tmp/aar/jni/arm64-v8a/libfoo.so", dest: "/dest/lib/arm64-v8a/libfoo.so"},
+			},
+		},
+		{
+			name: "no matching cpu",
+			cpu:  "x86",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := nativeLibsValidator{dest: "/dest", cpu: tc.cpu}.validate(files)
+			if err != nil {
+				t.Fatalf("nativeLibsValidator.validate(%s) unexpected error: %v", files, err)
+			}
+			if diff := cmp.Diff(tc.expectedFiles, got, cmp.AllowUnexported(toCopy{})); diff != "" {
+				t.Errorf("nativeLibsValidator.validate(%s) returned diff (-want, +got):\n%v", files, diff)
+			}
+		})
+	}
+}
+
+func TestValidateNativeLibsNoCPU(t *testing.T) {
+	files := []*aarFile{
+		&aarFile{path: "/tmp/aar/jni/arm64-v8a/libfoo.so", relPath: "jni/arm64-v8a/libfoo.so"},
+	}
+	if _, err := (nativeLibsValidator{dest: "/dest"}).validate(files); err == nil || !strings.Contains(err.Msg, "-cpu is empty") {
+		t.Errorf("nativeLibsValidator.validate(%s) returned error %v, want -cpu is empty", files, err)
+	}
+	if got, err := (nativeLibsValidator{}).validate(files); got != nil || err != nil {
+		t.Errorf("nativeLibsValidator.validate(%s) without output returned (%v, %v), want nothing", files, got, err)
+	}
+}
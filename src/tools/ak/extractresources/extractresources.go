@@ -12,29 +12,56 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package extractresources extracts resources from a jar and put them into a separate zip file.
+// Package extractresources extracts resources from jars and put them into a separate zip file.
 package extractresources
 
 import (
 	"archive/zip"
-	"io"
+	"flag"
+	"fmt"
 	"os"
 	"slices"
+	"sort"
 	"strings"
 	"sync"
 
 	"log"
 
+	"src/common/golang/flags"
+	"src/tools/ak/jarmerge"
 	"src/tools/ak/types"
 )
 
 var (
 	// Cmd defines the command.
 	Cmd = types.Command{
-		Init:  Init,
-		Run:   Run,
-		Desc:  desc,
+		Init: Init,
+		Run:  Run,
+		Desc: desc,
+		Flags: []string{
+			"in",
+			"out",
+			"include",
+			"exclude",
+			"drop_version_files",
+			"drop_signatures",
+			"duplicates",
+			"duplicate_rule",
+			"report",
+		},
 	}
+
+	// Variables to hold flag values.
+	in               flags.StringList
+	out              string
+	include          flags.MultiString
+	exclude          flags.MultiString
+	dropVersionFiles bool
+	dropSignatures   bool
+	duplicates       string
+	duplicateRules   flags.MultiString
+	report           string
+
 	initOnce           sync.Once
 	excludedExtensions = []string{
 		".aidl",                // Android interface definition files
@@ -71,52 +98,129 @@ var (
 )
 
 func desc() string {
-	return "Extracts resources from a jar and put them into a separate zip file."
+	return "Extracts resources from jars and put them into a separate zip file."
 }
 
 // Init initializes extractresources action.
 func Init() {
-	// Empty placeholder function. Will get segfaults in ak if this doesn't exist.
+	initOnce.Do(func() {
+		flag.Var(&in, "in", "Path to the input jar(s).")
+		flag.StringVar(&out, "out", "", "Path to the output zip.")
+		flag.Var(&include, "include", "Repeatable pattern of the entries to extract even if excluded by the built-in rules. Globs, where ** matches across directories, or regular expressions prefixed by re:.")
+		flag.Var(&exclude, "exclude", "Repeatable pattern of the entries not to extract, see -include.")
+		flag.BoolVar(&dropVersionFiles, "drop_version_files", true, "Whether to drop the META-INF/*.version files or not.")
+		flag.BoolVar(&dropSignatures, "drop_signatures", true, "Whether to drop the jar signatures in META-INF or not.")
+		flag.StringVar(&duplicates, "duplicates", jarmerge.First, "What to do with resources found in more than one jar: first, last, error, drop, or merge them.")
+		flag.Var(&duplicateRules, "duplicate_rule", "Repeatable -duplicates policy of the resources matching a pattern: {pattern}:{first|last|error|drop|merge}. META-INF/services files are merged by default.")
+		flag.StringVar(&report, "report", "", "(optional) Path to write the dropped entries, and why, to.")
+	})
+}
+
+// options select the entries to extract on top of the built-in rules.
+type options struct {
+	include          []*jarmerge.Pattern
+	exclude          []*jarmerge.Pattern
+	dropVersionFiles bool
+	dropSignatures   bool
+	policy           func(name string) string
+}
+
+func defaultOptions() *options {
+	policy, _ := jarmerge.DuplicatePolicy(nil, jarmerge.First)
+	return &options{dropVersionFiles: true, dropSignatures: true, policy: policy}
+}
+
+// reason returns why the entry is not extracted, or an empty string if it is.
+func (o *options) reason(name string) string {
+	if p := jarmerge.FirstMatch(o.exclude, name); p != nil {
+		return fmt.Sprintf("excluded by %s", p)
+	}
+	if jarmerge.FirstMatch(o.include, name) != nil {
+		return ""
+	}
+	if !o.dropVersionFiles && isVersionFile(name) || !o.dropSignatures && jarmerge.IsSignature(name) {
+		return ""
+	}
+	return ExclusionReason(name)
+}
+
+// isVersionFile reports whether name is a META-INF/*.version file, e.g. of an AndroidX library.
+func isVersionFile(name string) bool {
+	f, ok := strings.CutPrefix(strings.ToLower(name), "meta-inf/")
+	return ok && !strings.Contains(f, "/") && strings.HasSuffix(f, ".version")
 }
 
 // shouldExtractFile  checks if the provided path describes a resource, and should be extracted.
 func shouldExtractFile(path string) bool {
+	return ExclusionReason(path) == ""
+}
+
+// ExclusionReason returns why the provided path does not describe a Java resource, e.g. because
+// it is a source file or jar metadata, or an empty string if it does.
+func ExclusionReason(path string) string {
 	path = strings.ToLower(path)
 	for _, ext := range excludedExtensions {
 		if strings.HasSuffix(path, ext) {
-			return false
+			return fmt.Sprintf("excluded extension %s", ext)
 		}
 	}
 
 	segments := strings.Split(path, "/")
 	filename := segments[len(segments)-1]
-	if strings.HasPrefix(filename, ".") || slices.Contains(excludedFilenames, filename) {
-		return false
+	if strings.HasPrefix(filename, ".") {
+		return "hidden file"
+	}
+	if slices.Contains(excludedFilenames, filename) {
+		return fmt.Sprintf("excluded file name %s", filename)
 	}
 
 	dirs := segments[:len(segments)-1]
 	// allow META-INF/services at the root to support ServiceLoader
 	if len(dirs) >= 2 && dirs[0] == "meta-inf" && dirs[1] == "services" {
-		return true
+		return ""
 	}
 
 	// Check that no parts of the parent directory path should be excluded.
 	for _, dir := range excludedDirectories {
 		if slices.Contains(dirs, dir) {
-			return false
+			return fmt.Sprintf("excluded directory %s", dir)
 		}
 	}
 
-	return true
+	return ""
 }
 
 func extractResources(inputJarFilename string, outputZipFilename string) error {
-	// Input jar reader setup
-	inputJar, err := zip.OpenReader(inputJarFilename)
-	if err != nil {
-		return err
+	return doWork([]string{inputJarFilename}, outputZipFilename, "", defaultOptions())
+}
+
+func doWork(inputJars []string, outputZipFilename, reportFilename string, o *options) error {
+	// Resources are collected from all jars first, to resolve duplicates.
+	archive := jarmerge.NewArchive()
+	var dropped []string
+	for _, inputJarFilename := range inputJars {
+		inputJar, err := zip.OpenReader(inputJarFilename)
+		if err != nil {
+			return err
+		}
+		defer inputJar.Close()
+
+		for _, fileInZip := range inputJar.File {
+			// Directory entries are never extracted.
+			if strings.HasSuffix(fileInZip.Name, "/") {
+				continue
+			}
+			if reason := o.reason(fileInZip.Name); reason != "" {
+				dropped = append(dropped, fmt.Sprintf("%s: %s (%s)", fileInZip.Name, reason, inputJarFilename))
+				continue
+			}
+			// Don't use any compression, since the legacy tool did not.
+			src := jarmerge.Source{Origin: inputJarFilename, Open: fileInZip.Open}
+			if err := archive.Add(fileInZip.Name, zip.Store, o.policy(fileInZip.Name), src); err != nil {
+				return err
+			}
+		}
 	}
-	defer inputJar.Close()
 
 	// Output zip writer setup
 	outputZipFile, err := os.Create(outputZipFilename)
@@ -127,39 +231,57 @@ func extractResources(inputJarFilename string, outputZipFilename string) error {
 	outputZipWriter := zip.NewWriter(outputZipFile)
 	defer outputZipWriter.Close()
 
-	for _, fileInZip := range inputJar.File {
-		if shouldExtractFile(fileInZip.Name) {
-			// Write the file to the output zip file.
-			fileHandle, err := outputZipWriter.CreateHeader(&zip.FileHeader{
-				Name:   fileInZip.Name,
-				Method: zip.Store, // Don't use any compression, since the legacy tool did not.
-			})
-			if err != nil {
-				return err
-			}
-			fileInZipHandle, err := fileInZip.Open()
-			if err != nil {
-				return err
-			}
-			_, err = io.Copy(fileHandle, fileInZipHandle)
-			if err != nil {
-				return err
-			}
-		}
+	collisions, err := archive.Write(outputZipWriter)
+	if err != nil {
+		return err
+	}
+	if reportFilename == "" {
+		return nil
 	}
+	return writeReport(reportFilename, dropped, collisions)
+}
 
-	return nil
+// writeReport writes the dropped entries, sorted, followed by how duplicates were resolved.
+func writeReport(reportFilename string, dropped []string, collisions []jarmerge.Collision) error {
+	f, err := os.Create(reportFilename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sort.Strings(dropped)
+	for _, d := range dropped {
+		if _, err := fmt.Fprintln(f, d); err != nil {
+			return err
+		}
+	}
+	if err := jarmerge.WriteReport(f, collisions); err != nil {
+		return err
+	}
+	return f.Close()
 }
 
 // Run is the main entry point for the extractresources binary.
 func Run() {
-	// Args will be in the form of `ak extractresources input_jar output_zip`
-	if len(os.Args) != 4 {
-		log.Fatal("Usage: ak extractresources input_jar output_zip")
+	// Args used to be in the form of `ak extractresources input_jar output_zip`
+	if len(in) == 0 && out == "" && flag.NArg() == 2 {
+		in = flags.StringList{flag.Arg(0)}
+		out = flag.Arg(1)
+	}
+	if len(in) == 0 || out == "" {
+		log.Fatal("Usage: ak extractresources -in input_jar[,input_jar...] -out output_zip")
+	}
+	o := &options{dropVersionFiles: dropVersionFiles, dropSignatures: dropSignatures}
+	var err error
+	if o.include, err = jarmerge.ParsePatterns(include); err != nil {
+		log.Fatalf("invalid -include: %v", err)
+	}
+	if o.exclude, err = jarmerge.ParsePatterns(exclude); err != nil {
+		log.Fatalf("invalid -exclude: %v", err)
+	}
+	if o.policy, err = jarmerge.DuplicatePolicy(duplicateRules, duplicates); err != nil {
+		log.Fatalf("invalid -duplicate_rule or -duplicates: %v", err)
 	}
-	inputJarFilename := os.Args[2]
-	outputZipFilename := os.Args[3]
-	if err := extractResources(inputJarFilename, outputZipFilename); err != nil {
+	if err := doWork(in, out, report, o); err != nil {
 		log.Fatal(err)
 	}
 }
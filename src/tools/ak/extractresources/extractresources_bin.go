@@ -16,9 +16,14 @@
 package main
 
 import (
+	"flag"
+
+	_ "src/common/golang/flagfile"
 	"src/tools/ak/extractresources/extractresources"
 )
 
 func main() {
+	extractresources.Init()
+	flag.Parse()
 	extractresources.Run()
 }
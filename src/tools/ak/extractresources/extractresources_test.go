@@ -18,13 +18,17 @@ package extractresources
 
 import (
 	"archive/zip"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"src/common/golang/ziptest"
+	"src/tools/ak/jarmerge"
 )
 
 func TestJarWithEverything(t *testing.T) {
@@ -195,3 +199,135 @@ func TestTimestampsAreTheSame(t *testing.T) {
 		}
 	}
 }
+
+func readZip(t *testing.T, name string) map[string]string {
+	t.Helper()
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		t.Fatalf("Failed to open output zip: %v", err)
+	}
+	defer r.Close()
+	got := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		_, err = io.Copy(&b, rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[f.Name] = b.String()
+	}
+	return got
+}
+
+func TestDoWork(t *testing.T) {
+	tmp := t.TempDir()
+	a := filepath.Join(tmp, "a.jar")
+	b := filepath.Join(tmp, "b.jar")
+	ziptest.Write(t, a, map[string]string{
+		"res/a.txt":                           "a1",
+		"res/big.bin":                         "big",
+		"META-INF/services/x.Y":               "com.A\n",
+		"META-INF/androidx.core_core.version": "1.0",
+		"META-INF/CERT.SF":                    "sf",
+		"META-INF/proguard/a.pro":             "-keep class A",
+		"com/A.class":                         "class",
+	})
+	ziptest.Write(t, b, map[string]string{
+		"res/a.txt":             "a2",
+		"res/b.txt":             "b",
+		"META-INF/services/x.Y": "com.B\n",
+	})
+
+	tests := []struct {
+		name       string
+		include    []string
+		exclude    []string
+		keep       bool
+		rules      []string
+		duplicate  string
+		want       map[string]string
+		wantReport string
+		wantErr    bool
+	}{
+		{
+			name:      "defaults",
+			duplicate: jarmerge.First,
+			want: map[string]string{
+				"res/a.txt":             "a1",
+				"res/big.bin":           "big",
+				"res/b.txt":             "b",
+				"META-INF/services/x.Y": "com.A\ncom.B\n",
+			},
+			wantReport: "META-INF/CERT.SF: excluded directory meta-inf (" + a + ")\n" +
+				"META-INF/androidx.core_core.version: excluded directory meta-inf (" + a + ")\n" +
+				"META-INF/proguard/a.pro: excluded directory meta-inf (" + a + ")\n" +
+				"com/A.class: excluded extension .class (" + a + ")\n" +
+				"META-INF/services/x.Y: merge of " + a + ", " + b + "\n" +
+				"res/a.txt: first of " + a + ", " + b + "\n",
+		},
+		{
+			name:      "patterns",
+			include:   []string{"META-INF/proguard/**"},
+			exclude:   []string{"**.bin"},
+			keep:      true,
+			rules:     []string{"res/**:last"},
+			duplicate: jarmerge.First,
+			want: map[string]string{
+				"res/a.txt":                           "a2",
+				"res/b.txt":                           "b",
+				"META-INF/services/x.Y":               "com.A\ncom.B\n",
+				"META-INF/androidx.core_core.version": "1.0",
+				"META-INF/CERT.SF":                    "sf",
+				"META-INF/proguard/a.pro":             "-keep class A",
+			},
+			wantReport: "com/A.class: excluded extension .class (" + a + ")\n" +
+				"res/big.bin: excluded by **.bin (" + a + ")\n" +
+				"META-INF/services/x.Y: merge of " + a + ", " + b + "\n" +
+				"res/a.txt: last of " + a + ", " + b + "\n",
+		},
+		{
+			name:      "error",
+			duplicate: jarmerge.Error,
+			wantErr:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := &options{dropVersionFiles: !tc.keep, dropSignatures: !tc.keep}
+			var err error
+			if o.include, err = jarmerge.ParsePatterns(tc.include); err != nil {
+				t.Fatal(err)
+			}
+			if o.exclude, err = jarmerge.ParsePatterns(tc.exclude); err != nil {
+				t.Fatal(err)
+			}
+			if o.policy, err = jarmerge.DuplicatePolicy(tc.rules, tc.duplicate); err != nil {
+				t.Fatal(err)
+			}
+			out := filepath.Join(t.TempDir(), "out.zip")
+			report := filepath.Join(t.TempDir(), "report.txt")
+			err = doWork([]string{a, b}, out, report, o)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("doWork() got err: %v, want err: %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tc.want, readZip(t, out)); diff != "" {
+				t.Errorf("doWork() output diff (-want +got):\n%s", diff)
+			}
+			gotReport, err := os.ReadFile(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.wantReport, string(gotReport)); diff != "" {
+				t.Errorf("doWork() report diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ package finalrjar
 import (
 	"archive/zip"
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -30,6 +31,8 @@ import (
 	"sync"
 
 	"src/common/golang/ziputils"
+	"src/tools/ak/rclass/rclass"
+	"src/tools/ak/rtxt/rtxt"
 	"src/tools/ak/types"
 )
 
@@ -39,17 +42,20 @@ var (
 		Init:  Init,
 		Run:   Run,
 		Desc:  desc,
-		Flags: []string{"package", "r_txts", "out_r_java", "root_pkg", "jdk", "jartool", "target_label"},
+		Flags: []string{"package", "r_txts", "direct_r_txts", "mode", "check_conflicts", "out_r_java", "root_pkg", "jdk", "jartool", "target_label"},
 	}
 
 	// Variables to hold flag values.
-	pkg         string
-	rtxts       string
-	outputRJar  string
-	rootPackage string
-	jdk         string
-	jartool     string
-	targetLabel string
+	pkg            string
+	rtxts          string
+	directRtxts    string
+	mode           string
+	checkConflicts bool
+	outputRJar     string
+	rootPackage    string
+	jdk            string
+	jartool        string
+	targetLabel    string
 
 	initOnce sync.Once
 
@@ -81,76 +87,23 @@ var (
 		"transition",
 		"xml",
 	}
+)
 
-	javaReserved = map[string]bool{
-		"abstract":     true,
-		"assert":       true,
-		"boolean":      true,
-		"break":        true,
-		"byte":         true,
-		"case":         true,
-		"catch":        true,
-		"char":         true,
-		"class":        true,
-		"const":        true,
-		"continue":     true,
-		"default":      true,
-		"do":           true,
-		"double":       true,
-		"else":         true,
-		"enum":         true,
-		"extends":      true,
-		"false":        true,
-		"final":        true,
-		"finally":      true,
-		"float":        true,
-		"for":          true,
-		"goto":         true,
-		"if":           true,
-		"implements":   true,
-		"import":       true,
-		"instanceof":   true,
-		"int":          true,
-		"interface":    true,
-		"long":         true,
-		"native":       true,
-		"new":          true,
-		"null":         true,
-		"package":      true,
-		"private":      true,
-		"protected":    true,
-		"public":       true,
-		"return":       true,
-		"short":        true,
-		"static":       true,
-		"strictfp":     true,
-		"super":        true,
-		"switch":       true,
-		"synchronized": true,
-		"this":         true,
-		"throw":        true,
-		"throws":       true,
-		"transient":    true,
-		"true":         true,
-		"try":          true,
-		"void":         true,
-		"volatile":     true,
-		"while":        true}
+const (
+	// modeReference writes final fields initialized from a non-final root R class, which is
+	// stripped from the output and provided with the real resource IDs at runtime.
+	modeReference = "reference"
+	// modeFinal writes the resource IDs of the R.txt files as compile time constants, for binaries.
+	modeFinal = "final"
+	// modeNonFinal writes the resource IDs of the R.txt files as non-final fields, so that they are
+	// not inlined into the classes of libraries.
+	modeNonFinal = "nonfinal"
 )
 
 type rtxtFile interface {
 	io.Reader
 	io.Closer
-}
-
-type resource struct {
-	ID      string
-	resType string
-	varType string
-}
-
-func (r *resource) String() string {
-	return fmt.Sprintf("{%s %s %s}", r.varType, r.resType, r.ID)
+	Name() string
 }
 
 // Init initializes finalrjar action.
@@ -158,6 +111,9 @@ func Init() {
 	initOnce.Do(func() {
 		flag.StringVar(&pkg, "package", "", "Package for the R.jar")
 		flag.StringVar(&rtxts, "r_txts", "", "Comma separated list of R.txt files")
+		flag.StringVar(&directRtxts, "direct_r_txts", "", "(optional) Comma separated list of the R.txt files of the target and its direct dependencies. If set, only their resources are part of the R.jar")
+		flag.StringVar(&mode, "mode", modeReference, fmt.Sprintf("How the R fields are generated, one of: %s, %s, %s", modeReference, modeFinal, modeNonFinal))
+		flag.BoolVar(&checkConflicts, "check_conflicts", false, "(optional) Fail if the R.txt files declare a resource with different IDs, instead of keeping the first ID seen")
 		flag.StringVar(&outputRJar, "out_rjar", "", "Output R.jar path")
 		flag.StringVar(&rootPackage, "root_pkg", "mi.rjava", "Package to use for root R.java")
 		flag.StringVar(&jdk, "jdk", "", "Jdk path")
@@ -172,12 +128,12 @@ func desc() string {
 
 // Run is the entry point for finalrjar. Will exit on error.
 func Run() {
-	if err := doWork(pkg, rtxts, outputRJar, rootPackage, jdk, jartool, targetLabel); err != nil {
+	if err := doWork(pkg, rtxts, directRtxts, mode, checkConflicts, outputRJar, rootPackage, jdk, jartool, targetLabel); err != nil {
 		log.Fatalf("error creating final R.jar: %v", err)
 	}
 }
 
-func doWork(pkg, rtxts, outputRJar, rootPackage, jdk, jartool, targetLabel string) error {
+func doWork(pkg, rtxts, directRtxts, mode string, checkConflicts bool, outputRJar, rootPackage, jdk, jartool, targetLabel string) error {
 	pkgParts := strings.Split(pkg, ".")
 	// Check if the package is invalid.
 	if hasJavaReservedWord(pkgParts) {
@@ -188,10 +144,28 @@ func doWork(pkg, rtxts, outputRJar, rootPackage, jdk, jartool, targetLabel strin
 	if err != nil {
 		return err
 	}
+	var directFiles []rtxtFile
+	if directRtxts != "" {
+		if directFiles, err = openRtxts(strings.Split(directRtxts, ",")); err != nil {
+			return err
+		}
+	}
+
+	symbols, err := readResources(rtxtFiles, directFiles, checkConflicts)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case modeReference:
+	case modeFinal, modeNonFinal:
+		return writeRJar(rclass.New(pkg, symbols, mode == modeFinal), outputRJar)
+	default:
+		return fmt.Errorf("unknown mode %q, want one of: %s, %s, %s", mode, modeReference, modeFinal, modeNonFinal)
+	}
 
-	resC := getIds(rtxtFiles)
 	// Resources need to be grouped by type to write the R.java classes.
-	resMap := groupResByType(resC)
+	resMap := groupByType(symbols)
 
 	srcDir, err := os.MkdirTemp("", "rjar")
 	if err != nil {
@@ -224,64 +198,72 @@ func doWork(pkg, rtxts, outputRJar, rootPackage, jdk, jartool, targetLabel strin
 	return filterZip(fullRJar, outputRJar, filepath.Join(rootPkgParts...))
 }
 
-func getIds(rtxtFiles []rtxtFile) <-chan *resource {
-	// Sending all res to the same channel, even duplicates.
-	resC := make(chan *resource)
-	var wg sync.WaitGroup
-	wg.Add(len(rtxtFiles))
+// readResources returns the symbols of rtxtFiles. The first ID seen for a resource wins, unless
+// checkConflicts is set, in which case resources declared with different IDs are an error. If
+// directFiles is not empty, only the resources declared in them are kept.
+func readResources(rtxtFiles, directFiles []rtxtFile, checkConflicts bool) ([]*rtxt.Symbol, error) {
+	symbols, err := mergeSymbols(rtxtFiles)
+	if err != nil && (checkConflicts || !isConflict(err)) {
+		return nil, err
+	}
+	if len(directFiles) > 0 {
+		direct, err := mergeSymbols(directFiles)
+		if err != nil && !isConflict(err) {
+			return nil, err
+		}
+		symbols = rtxt.Restrict(symbols, direct)
+	}
+	return symbols, nil
+}
 
+func mergeSymbols(rtxtFiles []rtxtFile) ([]*rtxt.Symbol, error) {
+	var sets [][]*rtxt.Symbol
 	for _, file := range rtxtFiles {
-		go func(file rtxtFile) {
-			defer wg.Done()
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				line := scanner.Text()
-				// Each line is in the following format:
-				// [int|int[]] resType resID value
-				// Ex: int anim abc_fade_in 0
-				parts := strings.Split(line, " ")
-				if len(parts) < 3 {
-					continue
-				}
-				// Aapt2 will sometime add resources containing the char '$'.
-				// Those should be ignored - they are derived from an actual resource.
-				if strings.Contains(parts[2], "$") {
-					continue
-				}
-				resC <- &resource{ID: parts[2], resType: parts[1], varType: parts[0]}
-			}
-			file.Close()
-		}(file)
+		symbols, err := rtxt.Parse(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Name(), err)
+		}
+		sets = append(sets, symbols)
 	}
+	return rtxt.Merge(sets...)
+}
 
-	go func() {
-		wg.Wait()
-		close(resC)
-	}()
-
-	return resC
+func isConflict(err error) bool {
+	var conflicts *rtxt.ConflictError
+	return errors.As(err, &conflicts)
 }
 
-func groupResByType(resC <-chan *resource) map[string][]*resource {
-	// Set of resType.ID seen to ignore duplicates from different R.txt files.
-	// Resources of different types can have the same ID, so we merge the values
-	// to get a unique string. Ex: integer.btn_background_alpa
-	seen := make(map[string]bool)
-
-	// Map of resource type to list of resources.
-	resMap := make(map[string][]*resource)
-	for res := range resC {
-		uniqueID := fmt.Sprintf("%s.%s", res.resType, res.ID)
-		if _, ok := seen[uniqueID]; ok {
+// groupByType returns the symbols by resource type.
+func groupByType(symbols []*rtxt.Symbol) map[string][]*rtxt.Symbol {
+	resMap := make(map[string][]*rtxt.Symbol)
+	for _, s := range symbols {
+		// Aapt2 will sometime add resources containing the char '$'.
+		// Those should be ignored - they are derived from an actual resource.
+		if strings.Contains(s.Name, "$") {
 			continue
 		}
-		seen[uniqueID] = true
-		resMap[res.resType] = append(resMap[res.resType], res)
+		resMap[s.Type] = append(resMap[s.Type], s)
 	}
 	return resMap
 }
 
-func writeRJavas(outRJava, outRootRJava io.Writer, resMap map[string][]*resource, pkg, rootPackage string) error {
+func writeRJar(r *rclass.Class, output string) error {
+	if err := os.MkdirAll(filepath.Dir(output), 0777); err != nil {
+		return err
+	}
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := rclass.WriteJar(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func writeRJavas(outRJava, outRootRJava io.Writer, resMap map[string][]*rtxt.Symbol, pkg, rootPackage string) error {
 	// The R.java points to the same resources ID in the root R.java.
 	// The root R.java uses 0 or null for simplicity and does not use final fields to avoid inlining.
 	// That way we can strip it from the compiled R.jar later and replace it with the real one.
@@ -300,15 +282,15 @@ func writeRJavas(outRJava, outRootRJava io.Writer, resMap map[string][]*resource
 
 			// Sorting resources before writing to class
 			sort.Slice(resources, func(i, j int) bool {
-				return resources[i].ID < resources[j].ID
+				return resources[i].Name < resources[j].Name
 			})
 			for _, res := range resources {
-				defaultValue := "0"
-				if res.varType == "int[]" {
-					defaultValue = "null"
+				varType, defaultValue := "int", "0"
+				if res.Array {
+					varType, defaultValue = "int[]", "null"
 				}
-				rJavaWriter.WriteString(fmt.Sprintf("    public static final %s %s=%s%s;\n", res.varType, res.ID, rootID, res.ID))
-				rootRJavaWriter.WriteString(fmt.Sprintf("    public static %s %s=%s;\n", res.varType, res.ID, defaultValue))
+				rJavaWriter.WriteString(fmt.Sprintf("    public static final %s %s=%s%s;\n", varType, res.Name, rootID, res.Name))
+				rootRJavaWriter.WriteString(fmt.Sprintf("    public static %s %s=%s;\n", varType, res.Name, defaultValue))
 			}
 			rJavaWriter.WriteString("  }\n")
 			rootRJavaWriter.WriteString("  }\n")
@@ -443,7 +425,7 @@ func compileRJar(srcs []string, rjar, jdk, jartool string, targetLabel string) e
 
 func hasJavaReservedWord(parts []string) bool {
 	for _, p := range parts {
-		if javaReserved[p] {
+		if rclass.IsReserved(p) {
 			return true
 		}
 	}
@@ -17,10 +17,11 @@ package finalrjar
 
 import (
 	"bytes"
-	"sort"
 	"strings"
 	"testing"
 
+	"src/tools/ak/rclass/rclass"
+	"src/tools/ak/rtxt/rtxt"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -36,17 +37,28 @@ func (f fakeFile) Close() error {
 	return nil
 }
 
-func TestGetIds(t *testing.T) {
+func (f fakeFile) Name() string {
+	return "R.txt"
+}
+
+func fakeFiles(contents []string) []rtxtFile {
+	var files []rtxtFile
+	for _, c := range contents {
+		files = append(files, fakeFile{reader: strings.NewReader(c)})
+	}
+	return files
+}
+
+func TestGroupByType(t *testing.T) {
 	tests := []struct {
-		name              string
-		rtxtFiles         []*strings.Reader
-		expectedResources []*resource
+		name        string
+		rtxts       []string
+		direct      []string
+		expectedMap map[string][]string
 	}{
 		{
 			name: "one R.txt",
-			rtxtFiles: []*strings.Reader{
-				strings.NewReader(
-					`int anim abc_fade_in 0
+			rtxts: []string{`int anim abc_fade_in 0
 int anim abc_fade_out 0
 int attr actionBarDivider 0
 int bool abc_action_bar_embed_tabs 0
@@ -56,160 +68,73 @@ int color abc_btn_colored_borderless_text_material 0
 int dimen tooltip_y_offset_non_touch 0
 int dimen $avd_hide_password__0 0
 int[] dimen tooltip_y_offset_touch 0
-int drawable abc_ab_share_pack_mtrl_alpha 0`),
-			},
-			expectedResources: []*resource{
-				&resource{ID: "abc_ab_share_pack_mtrl_alpha", resType: "drawable", varType: "int"},
-				&resource{ID: "abc_action_bar_embed_tabs", resType: "bool", varType: "int"},
-				&resource{ID: "abc_background_cache_hint_selector_material_dark", resType: "color", varType: "int"},
-				&resource{ID: "abc_background_cache_hint_selector_material_light", resType: "color", varType: "int[]"},
-				&resource{ID: "abc_btn_colored_borderless_text_material", resType: "color", varType: "int"},
-				&resource{ID: "abc_fade_in", resType: "anim", varType: "int"},
-				&resource{ID: "abc_fade_out", resType: "anim", varType: "int"},
-				&resource{ID: "actionBarDivider", resType: "attr", varType: "int"},
-				&resource{ID: "tooltip_y_offset_non_touch", resType: "dimen", varType: "int"},
-				&resource{ID: "tooltip_y_offset_touch", resType: "dimen", varType: "int[]"},
+int drawable abc_ab_share_pack_mtrl_alpha 0`},
+			expectedMap: map[string][]string{
+				"anim":     {"abc_fade_in", "abc_fade_out"},
+				"attr":     {"actionBarDivider"},
+				"bool":     {"abc_action_bar_embed_tabs"},
+				"color":    {"abc_background_cache_hint_selector_material_dark", "abc_background_cache_hint_selector_material_light", "abc_btn_colored_borderless_text_material"},
+				"dimen":    {"tooltip_y_offset_non_touch", "tooltip_y_offset_touch"},
+				"drawable": {"abc_ab_share_pack_mtrl_alpha"},
 			},
 		},
 		{
 			name: "multiple R.txt files",
-			rtxtFiles: []*strings.Reader{
-				strings.NewReader(
-					`int styleable toolbar_logo 0
-int[] style widget_appcompat_dark 0`),
-				strings.NewReader(
-					`int layout custom_dialog 0
-int interpolator btn_checkbox 0`),
-				strings.NewReader(
-					`int id view_tree 0
-int integer cancel_button_image_alpha 0`),
-			},
-			expectedResources: []*resource{
-				&resource{ID: "btn_checkbox", resType: "interpolator", varType: "int"},
-				&resource{ID: "cancel_button_image_alpha", resType: "integer", varType: "int"},
-				&resource{ID: "custom_dialog", resType: "layout", varType: "int"},
-				&resource{ID: "toolbar_logo", resType: "styleable", varType: "int"},
-				&resource{ID: "view_tree", resType: "id", varType: "int"},
-				&resource{ID: "widget_appcompat_dark", resType: "style", varType: "int[]"},
-			},
-		},
-	}
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			rtxts := make([]rtxtFile, 0, len(tc.rtxtFiles))
-			for _, f := range tc.rtxtFiles {
-				file := fakeFile{reader: f}
-				file.reader.Seek(0, 0)
-				rtxts = append(rtxts, file)
-			}
-
-			resC := getIds(rtxts)
-			receivedResources := make([]*resource, 0)
-			for res := range resC {
-				receivedResources = append(receivedResources, res)
-			}
-			sort.Slice(receivedResources, func(i, j int) bool {
-				return receivedResources[i].ID < receivedResources[j].ID
-			})
-
-			if diff := cmp.Diff(tc.expectedResources, receivedResources, cmp.AllowUnexported(resource{})); diff != "" {
-				t.Errorf("getIds(%v) returned diff (-want, +got):\n%v", rtxts, diff)
-			}
-		})
-	}
-
-}
-
-func TestSortResByType(t *testing.T) {
-	tests := []struct {
-		name        string
-		resources   []*resource
-		expectedMap map[string][]*resource
-	}{
-		{
-			name: "simple list of resources",
-			resources: []*resource{
-				&resource{ID: "btn_checkbox", resType: "interpolator", varType: "int"},
-				&resource{ID: "cancel_button_image_alpha", resType: "integer", varType: "int"},
-				&resource{ID: "custom_dialog", resType: "id", varType: "int"},
-				&resource{ID: "toolbar_logo", resType: "interpolator", varType: "int"},
-				&resource{ID: "view_tree", resType: "id", varType: "int"},
-				&resource{ID: "widget_appcompat_dark", resType: "layout", varType: "int[]"},
+			rtxts: []string{
+				`int interpolator btn_checkbox 0
+int integer cancel_button_image_alpha 0
+int id custom_dialog 0`,
+				`int interpolator btn_checkbox 0
+int interpolator toolbar_logo 0
+int attr toolbar_logo 0`,
+				`int id view_tree 0
+int integer cancel_button_image_alpha 0
+int[] layout widget_appcompat_dark 0`,
 			},
-			expectedMap: map[string][]*resource{
-				"interpolator": []*resource{
-					&resource{ID: "btn_checkbox", resType: "interpolator", varType: "int"},
-					&resource{ID: "toolbar_logo", resType: "interpolator", varType: "int"},
-				},
-				"integer": []*resource{
-					&resource{ID: "cancel_button_image_alpha", resType: "integer", varType: "int"},
-				},
-				"id": []*resource{
-					&resource{ID: "custom_dialog", resType: "id", varType: "int"},
-					&resource{ID: "view_tree", resType: "id", varType: "int"},
-				},
-				"layout": []*resource{
-					&resource{ID: "widget_appcompat_dark", resType: "layout", varType: "int[]"},
-				},
+			expectedMap: map[string][]string{
+				"attr":         {"toolbar_logo"},
+				"interpolator": {"btn_checkbox", "toolbar_logo"},
+				"integer":      {"cancel_button_image_alpha"},
+				"id":           {"custom_dialog", "view_tree"},
+				"layout":       {"widget_appcompat_dark"},
 			},
 		},
 		{
-			name: "list of resources with duplicates",
-			resources: []*resource{
-				&resource{ID: "btn_checkbox", resType: "interpolator", varType: "int"},
-				&resource{ID: "btn_checkbox", resType: "interpolator", varType: "int"},
-				&resource{ID: "cancel_button_image_alpha", resType: "integer", varType: "int"},
-				&resource{ID: "custom_dialog", resType: "id", varType: "int"},
-				&resource{ID: "toolbar_logo", resType: "interpolator", varType: "int"},
-				&resource{ID: "toolbar_logo", resType: "attr", varType: "int"},
-				&resource{ID: "view_tree", resType: "id", varType: "int"},
-				&resource{ID: "cancel_button_image_alpha", resType: "integer", varType: "int"},
-				&resource{ID: "widget_appcompat_dark", resType: "layout", varType: "int[]"},
+			name: "restricted to direct dependencies",
+			rtxts: []string{
+				"int id custom_dialog 0\nint id view_tree 0\nint layout transitive_layout 0",
+				"int[] styleable View { 0 }\nint styleable View_attr 0",
 			},
-			expectedMap: map[string][]*resource{
-				"attr": []*resource{
-					&resource{ID: "toolbar_logo", resType: "attr", varType: "int"},
-				},
-				"interpolator": []*resource{
-					&resource{ID: "btn_checkbox", resType: "interpolator", varType: "int"},
-					&resource{ID: "toolbar_logo", resType: "interpolator", varType: "int"},
-				},
-				"integer": []*resource{
-					&resource{ID: "cancel_button_image_alpha", resType: "integer", varType: "int"},
-				},
-				"id": []*resource{
-					&resource{ID: "custom_dialog", resType: "id", varType: "int"},
-					&resource{ID: "view_tree", resType: "id", varType: "int"},
-				},
-				"layout": []*resource{
-					&resource{ID: "widget_appcompat_dark", resType: "layout", varType: "int[]"},
-				},
+			direct: []string{"int id view_tree 0\nint[] styleable View { 0 }\nint styleable View_attr 0\nint string app_name 0"},
+			expectedMap: map[string][]string{
+				"id":        {"view_tree"},
+				"styleable": {"View", "View_attr"},
 			},
 		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			resC := make(chan *resource)
-			go func() {
-				for _, res := range tc.resources {
-					resC <- res
+			symbols, err := readResources(fakeFiles(tc.rtxts), fakeFiles(tc.direct), false)
+			if err != nil {
+				t.Fatalf("readResources(%v, %v) unexpected error: %v", tc.rtxts, tc.direct, err)
+			}
+			resMap := make(map[string][]string)
+			for resType, resources := range groupByType(symbols) {
+				for _, res := range resources {
+					resMap[resType] = append(resMap[resType], res.Name)
 				}
-				close(resC)
-			}()
-			resMap := groupResByType(resC)
-
-			if diff := cmp.Diff(tc.expectedMap, resMap, cmp.AllowUnexported(resource{})); diff != "" {
-				t.Errorf("groupResByType(%v) returned diff (-want, +got):\n%v", tc.resources, diff)
+			}
+			if diff := cmp.Diff(tc.expectedMap, resMap); diff != "" {
+				t.Errorf("groupByType(%v) returned diff (-want, +got):\n%v", tc.rtxts, diff)
 			}
 		})
 	}
-
 }
 
 func TestWriteRJavas(t *testing.T) {
 	tests := []struct {
 		name              string
-		resMap            map[string][]*resource
+		resMap            map[string][]*rtxt.Symbol
 		pkg               string
 		rootPackage       string
 		expectedRJava     string
@@ -217,20 +142,20 @@ func TestWriteRJavas(t *testing.T) {
 	}{
 		{
 			name: "simple map of resources",
-			resMap: map[string][]*resource{
-				"interpolator": []*resource{
-					&resource{ID: "btn_checkbox", resType: "interpolator", varType: "int"},
-					&resource{ID: "toolbar_logo", resType: "interpolator", varType: "int"},
+			resMap: map[string][]*rtxt.Symbol{
+				"interpolator": []*rtxt.Symbol{
+					{Type: "interpolator", Name: "btn_checkbox"},
+					{Type: "interpolator", Name: "toolbar_logo"},
 				},
-				"integer": []*resource{
-					&resource{ID: "cancel_button_image_alpha", resType: "integer", varType: "int"},
+				"integer": []*rtxt.Symbol{
+					{Type: "integer", Name: "cancel_button_image_alpha"},
 				},
-				"id": []*resource{
-					&resource{ID: "view_tree", resType: "id", varType: "int"},
-					&resource{ID: "custom_dialog", resType: "id", varType: "int"},
+				"id": []*rtxt.Symbol{
+					{Type: "id", Name: "view_tree"},
+					{Type: "id", Name: "custom_dialog"},
 				},
-				"layout": []*resource{
-					&resource{ID: "widget_appcompat_dark", resType: "layout", varType: "int[]"},
+				"layout": []*rtxt.Symbol{
+					{Type: "layout", Name: "widget_appcompat_dark", Array: true},
 				},
 			},
 			pkg:         "com.google.android.apps.sample",
@@ -274,16 +199,16 @@ public class R {
 		},
 		{
 			name: "with empty class",
-			resMap: map[string][]*resource{
-				"interpolator": []*resource{
-					&resource{ID: "toolbar_logo", resType: "interpolator", varType: "int"},
-					&resource{ID: "btn_checkbox", resType: "interpolator", varType: "int"},
+			resMap: map[string][]*rtxt.Symbol{
+				"interpolator": []*rtxt.Symbol{
+					{Type: "interpolator", Name: "toolbar_logo"},
+					{Type: "interpolator", Name: "btn_checkbox"},
 				},
-				"integer": []*resource{
-					&resource{ID: "cancel_button_image_alpha", resType: "integer", varType: "int"},
+				"integer": []*rtxt.Symbol{
+					{Type: "integer", Name: "cancel_button_image_alpha"},
 				},
-				"layout": []*resource{
-					&resource{ID: "widget_appcompat_dark", resType: "layout", varType: "int[]"},
+				"layout": []*rtxt.Symbol{
+					{Type: "layout", Name: "widget_appcompat_dark", Array: true},
 				},
 			},
 			pkg:         "com.google.android.apps.empty",
@@ -336,6 +261,125 @@ public class R {
 
 }
 
+func TestNewRClass(t *testing.T) {
+	const (
+		libRTxt = `int id view_tree 0x7f080002
+int[] styleable View { 0x7f030001, 0x01010000 }
+int styleable View_attr 0
+int styleable View_android_text 1
+int string $derived 0x7f0b0009`
+		depRTxt = `int string app_name 0x7f0b0001
+int id view_tree 0x7f080003`
+		transitiveRTxt = `int layout transitive_layout 0x7f0a0001`
+	)
+	tests := []struct {
+		name   string
+		rtxts  []string
+		direct []string
+		final  bool
+		want   *rclass.Class
+	}{
+		{
+			name:  "final",
+			rtxts: []string{libRTxt, depRTxt, transitiveRTxt},
+			final: true,
+			want: &rclass.Class{
+				Name:  "com/google/android/apps/sample/R",
+				Final: true,
+				Nested: []*rclass.Class{
+					{
+						Name:   "com/google/android/apps/sample/R$id",
+						Final:  true,
+						Fields: []*rclass.Field{{Name: "view_tree", Values: []int32{0x7f080002}}},
+					},
+					{
+						Name:   "com/google/android/apps/sample/R$layout",
+						Final:  true,
+						Fields: []*rclass.Field{{Name: "transitive_layout", Values: []int32{0x7f0a0001}}},
+					},
+					{
+						Name:   "com/google/android/apps/sample/R$string",
+						Final:  true,
+						Fields: []*rclass.Field{{Name: "app_name", Values: []int32{0x7f0b0001}}},
+					},
+					{
+						Name:  "com/google/android/apps/sample/R$styleable",
+						Final: true,
+						Fields: []*rclass.Field{
+							{Name: "View", Array: true, Values: []int32{0x7f030001, 0x01010000}},
+							{Name: "View_android_text", Values: []int32{1}},
+							{Name: "View_attr", Values: []int32{0}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "non-final",
+			rtxts: []string{depRTxt, libRTxt},
+			want: &rclass.Class{
+				Name: "com/google/android/apps/sample/R",
+				Nested: []*rclass.Class{
+					{
+						Name:   "com/google/android/apps/sample/R$id",
+						Fields: []*rclass.Field{{Name: "view_tree", Values: []int32{0x7f080003}}},
+					},
+					{
+						Name:   "com/google/android/apps/sample/R$string",
+						Fields: []*rclass.Field{{Name: "app_name", Values: []int32{0x7f0b0001}}},
+					},
+					{
+						Name: "com/google/android/apps/sample/R$styleable",
+						Fields: []*rclass.Field{
+							{Name: "View", Array: true, Values: []int32{0x7f030001, 0x01010000}},
+							{Name: "View_android_text", Values: []int32{1}},
+							{Name: "View_attr", Values: []int32{0}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:   "restricted to direct dependencies",
+			rtxts:  []string{libRTxt, depRTxt, transitiveRTxt},
+			direct: []string{libRTxt, depRTxt},
+			want: &rclass.Class{
+				Name: "com/google/android/apps/sample/R",
+				Nested: []*rclass.Class{
+					{
+						Name:   "com/google/android/apps/sample/R$id",
+						Fields: []*rclass.Field{{Name: "view_tree", Values: []int32{0x7f080002}}},
+					},
+					{
+						Name:   "com/google/android/apps/sample/R$string",
+						Fields: []*rclass.Field{{Name: "app_name", Values: []int32{0x7f0b0001}}},
+					},
+					{
+						Name: "com/google/android/apps/sample/R$styleable",
+						Fields: []*rclass.Field{
+							{Name: "View", Array: true, Values: []int32{0x7f030001, 0x01010000}},
+							{Name: "View_android_text", Values: []int32{1}},
+							{Name: "View_attr", Values: []int32{0}},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			symbols, err := readResources(fakeFiles(tc.rtxts), fakeFiles(tc.direct), false)
+			if err != nil {
+				t.Fatalf("readResources(%v, %v) unexpected error: %v", tc.rtxts, tc.direct, err)
+			}
+			got := rclass.New("com.google.android.apps.sample", symbols, tc.final)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("rclass.New(%v, %v, %v) returned diff (-want, +got):\n%v", tc.rtxts, tc.direct, tc.final, diff)
+			}
+		})
+	}
+}
+
 func TestHasReservedKeywords(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -364,3 +408,28 @@ func TestHasReservedKeywords(t *testing.T) {
 	}
 
 }
+
+func TestReadResourcesConflicts(t *testing.T) {
+	lib := "int id view_tree 0\nint styleable toolbar_logo 0\n"
+	app := "int id view_tree 0x7f080001\n"
+	other := "int id view_tree 0x7f080002\n"
+
+	for _, checkConflicts := range []bool{false, true} {
+		if _, err := readResources(fakeFiles([]string{lib, app}), nil, checkConflicts); err != nil {
+			t.Errorf("readResources(%v, %v) unexpected error: %v", []string{lib, app}, checkConflicts, err)
+		}
+	}
+	symbols, err := readResources(fakeFiles([]string{lib, app, other}), nil, false)
+	if err != nil {
+		t.Fatalf("readResources(%v, false) unexpected error: %v", []string{lib, app, other}, err)
+	}
+	if got := symbols[0].String(); got != "int id view_tree 0x7f080001" {
+		t.Errorf("readResources(%v, false) kept %s, want the first assigned ID 0x7f080001", []string{lib, app, other}, got)
+	}
+	if _, err := readResources(fakeFiles([]string{lib, app, other}), nil, true); err == nil || !strings.Contains(err.Error(), "id.view_tree") {
+		t.Errorf("readResources(%v, true) returned error %v, want a conflict on id.view_tree", []string{lib, app, other}, err)
+	}
+	if _, err := readResources(fakeFiles([]string{"int id\n"}), nil, false); err == nil || !strings.Contains(err.Error(), "R.txt: line 1") {
+		t.Errorf("readResources of a malformed R.txt returned error %v, want a syntax error", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fixit provides fix suggestions for the rule attributes of build targets. ak commands
+// write them as a JSON side output, along with the buildozer commands applying them, for IDEs and
+// bots to apply.
+package fixit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Fix is a suggested change of a rule attribute of a target.
+type Fix struct {
+	// Label is the label of the target, e.g. //java/com/example:lib.
+	Label string
+	// Attr is the rule attribute, e.g. has_res.
+	Attr string
+	// Key is the key to change in dict attributes, e.g. minSdkVersion for manifest_values.
+	Key string
+	// OldValue is the current value, empty if unset or unknown.
+	OldValue string
+	// NewValue is the suggested value. The attribute, or key, is removed when empty.
+	NewValue string
+	// Message describes the problem fixed.
+	Message string
+}
+
+// Edit returns the buildozer edit applying f, e.g. set has_res True.
+func (f Fix) Edit() string {
+	switch {
+	case f.Key != "" && f.NewValue == "":
+		return fmt.Sprintf("dict_remove %s %s", f.Attr, f.Key)
+	case f.Key != "":
+		return fmt.Sprintf("dict_set %s %s:%s", f.Attr, f.Key, f.NewValue)
+	case f.NewValue == "":
+		return fmt.Sprintf("remove %s", f.Attr)
+	default:
+		return fmt.Sprintf("set %s %s", f.Attr, f.NewValue)
+	}
+}
+
+// Command returns the buildozer command applying f.
+func (f Fix) Command() string {
+	return command(f.Label, []string{f.Edit()})
+}
+
+// Commands returns the buildozer commands applying fixes, one per target in the order the targets
+// first appear.
+func Commands(fixes []Fix) []string {
+	var labels []string
+	edits := make(map[string][]string)
+	for _, f := range fixes {
+		if _, ok := edits[f.Label]; !ok {
+			labels = append(labels, f.Label)
+		}
+		edits[f.Label] = append(edits[f.Label], f.Edit())
+	}
+	var cmds []string
+	for _, l := range labels {
+		cmds = append(cmds, command(l, edits[l]))
+	}
+	return cmds
+}
+
+func command(label string, edits []string) string {
+	var cmd strings.Builder
+	cmd.WriteString("buildozer ")
+	for _, e := range edits {
+		cmd.WriteString(quote(e) + " ")
+	}
+	cmd.WriteString(label)
+	return cmd.String()
+}
+
+// quote single-quotes s for the shell.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+type jsonFix struct {
+	Label    string `json:"label"`
+	Attr     string `json:"attribute"`
+	Key      string `json:"key,omitempty"`
+	OldValue string `json:"old_value,omitempty"`
+	NewValue string `json:"new_value"`
+	Message  string `json:"message,omitempty"`
+	Command  string `json:"command"`
+}
+
+type jsonFixes struct {
+	Fixes []jsonFix `json:"fixes"`
+}
+
+// Encode writes fixes as JSON to w.
+func Encode(w io.Writer, fixes []Fix) error {
+	out := jsonFixes{Fixes: make([]jsonFix, 0, len(fixes))}
+	for _, f := range fixes {
+		out.Fixes = append(out.Fixes, jsonFix{
+			Label:    f.Label,
+			Attr:     f.Attr,
+			Key:      f.Key,
+			OldValue: f.OldValue,
+			NewValue: f.NewValue,
+			Message:  f.Message,
+			Command:  f.Command(),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// Decode reads fixes written by Encode.
+func Decode(r io.Reader) ([]Fix, error) {
+	var in jsonFixes
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+	var fixes []Fix
+	for _, f := range in.Fixes {
+		fixes = append(fixes, Fix{
+			Label:    f.Label,
+			Attr:     f.Attr,
+			Key:      f.Key,
+			OldValue: f.OldValue,
+			NewValue: f.NewValue,
+			Message:  f.Message,
+		})
+	}
+	return fixes, nil
+}
+
+// WriteFile writes fixes as JSON to the file name. It writes nothing if name is empty, for
+// commands whose fix-it output is optional.
+func WriteFile(name string, fixes []Fix) error {
+	if name == "" {
+		return nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := Encode(f, fixes); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		fix  Fix
+		want string
+	}{
+		{
+			name: "set",
+			fix:  Fix{Label: "//java:lib", Attr: "has_res", NewValue: "True"},
+			want: "buildozer 'set has_res True' //java:lib",
+		},
+		{
+			name: "remove",
+			fix:  Fix{Label: "//java:lib", Attr: "has_res"},
+			want: "buildozer 'remove has_res' //java:lib",
+		},
+		{
+			name: "dict set",
+			fix:  Fix{Label: "//java:app", Attr: "manifest_values", Key: "minSdkVersion", NewValue: "21"},
+			want: "buildozer 'dict_set manifest_values minSdkVersion:21' //java:app",
+		},
+		{
+			name: "dict remove",
+			fix:  Fix{Label: "//java:app", Attr: "manifest_values", Key: "minSdkVersion"},
+			want: "buildozer 'dict_remove manifest_values minSdkVersion' //java:app",
+		},
+		{
+			name: "quoted value",
+			fix:  Fix{Label: "//java:app", Attr: "manifest_values", Key: "appName", NewValue: "Bob's app"},
+			want: `buildozer 'dict_set manifest_values appName:Bob'\''s app' //java:app`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.fix.Command(); got != tc.want {
+				t.Errorf("Command() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	fixes := []Fix{
+		{Label: "//java:b", Attr: "has_res", NewValue: "True"},
+		{Label: "//java:a", Attr: "has_assets", NewValue: "False"},
+		{Label: "//java:b", Attr: "has_assets", NewValue: "True"},
+	}
+	want := []string{
+		"buildozer 'set has_res True' 'set has_assets True' //java:b",
+		"buildozer 'set has_assets False' //java:a",
+	}
+	if diff := cmp.Diff(want, Commands(fixes)); diff != "" {
+		t.Errorf("Commands(%v) returned diff (-want, +got):\n%v", fixes, diff)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	fixes := []Fix{
+		{Label: "//java:lib", Attr: "has_res", OldValue: "False", NewValue: "True", Message: "has_res attribute is False, but files were found"},
+	}
+	var b bytes.Buffer
+	if err := Encode(&b, fixes); err != nil {
+		t.Fatalf("Encode(%v) unexpected error: %v", fixes, err)
+	}
+	want := `{
+  "fixes": [
+    {
+      "label": "//java:lib",
+      "attribute": "has_res",
+      "old_value": "False",
+      "new_value": "True",
+      "message": "has_res attribute is False, but files were found",
+      "command": "buildozer 'set has_res True' //java:lib"
+    }
+  ]
+}
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("Encode(%v) returned diff (-want, +got):\n%v", fixes, diff)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		fixes []Fix
+	}{
+		{
+			name: "no fixes",
+		},
+		{
+			name: "fixes",
+			fixes: []Fix{
+				{Label: "//java:lib", Attr: "has_res", NewValue: "True"},
+				{Label: "//java:app", Attr: "manifest_values", Key: "minSdkVersion", OldValue: "14", NewValue: "21"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "fixes.json")
+			if err := WriteFile(name, tc.fixes); err != nil {
+				t.Fatalf("WriteFile(%v) unexpected error: %v", tc.fixes, err)
+			}
+			f, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			got, err := Decode(f)
+			if err != nil {
+				t.Fatalf("Decode() unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.fixes, got); diff != "" {
+				t.Errorf("WriteFile(%v) round trip returned diff (-want, +got):\n%v", tc.fixes, diff)
+			}
+		})
+	}
+}
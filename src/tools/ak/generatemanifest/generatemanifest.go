@@ -17,40 +17,38 @@ package generatemanifest
 
 import (
 	"bufio"
-	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
-	"strconv"
+	"strings"
 	"sync"
 
 	"src/common/golang/flags"
+	"src/tools/ak/manifestutils"
+	"src/tools/ak/sdklevel"
 	"src/tools/ak/types"
 )
 
-// Structs used for reading the manifest xml file
-type manifestTag struct {
-	XMLName xml.Name   `xml:"manifest"`
-	UsesSdk usesSdkTag `xml:"uses-sdk"`
-}
-
-type usesSdkTag struct {
-	XMLName xml.Name `xml:"uses-sdk"`
-	MinSdk  string   `xml:"minSdkVersion,attr"`
+type result struct {
+	// minSdk and targetSdk are unset when the manifest does not set them, or uses placeholders.
+	minSdk, targetSdk sdklevel.Level
+	err               error
 }
 
-type result struct {
-	minSdk int
-	err    error
+// sdkVersions are the SDK versions of the generated manifest.
+type sdkVersions struct {
+	minSdk sdklevel.Level
+	// targetSdk is unset when neither the manifests nor the targetsdk flag set one.
+	targetSdk sdklevel.Level
 }
 
 const manifestContent string = `<?xml version="1.0" encoding="utf-8"?>
 <manifest xmlns:android="http://schemas.android.com/apk/res/android"
     package="%s">
-    <uses-sdk android:minSdkVersion="%d" />
+    <uses-sdk android:minSdkVersion="%s"%s />
     <application/>
 </manifest>
 `
@@ -66,13 +64,15 @@ var (
 			"java_package",
 			"manifests",
 			"minsdk",
+			"targetsdk",
+			"log",
 		},
 	}
 
 	// Flag variables
-	out, javaPackage string
-	minSdk           int
-	manifests        flags.StringList
+	out, javaPackage, logFile string
+	minSdk, targetSdk         int
+	manifests                 flags.StringList
 
 	initOnce sync.Once
 )
@@ -83,13 +83,16 @@ func Init() {
 		flag.StringVar(&out, "out", "", "Path to output manifest generated with the max min sdk value found from --manifests.")
 		flag.StringVar(&javaPackage, "java_package", "com.default", "(optional) Java package to use for the manifest.")
 		flag.IntVar(&minSdk, "minsdk", 14, "(optional) Default min sdk to support.")
+		flag.IntVar(&targetSdk, "targetsdk", 0, "(optional) Default target sdk to support.")
 		flag.Var(&manifests, "manifests", "(optional) Manifests(s) to get min sdk from.")
+		flag.StringVar(&logFile, "log", "", "(optional) Path to write the log of the selected sdk values to.")
 	})
 }
 
 func desc() string {
 	return "Generates an empty AndroidManifest.xml with a minSdk value. The min sdk is selected " +
-		"by taking the max value found between the manifests and the minsdk flag."
+		"by taking the max value found between the manifests and the minsdk flag, and the target sdk " +
+		"likewise with the targetsdk flag."
 }
 
 // Run is the main entry point
@@ -112,9 +115,15 @@ func Run() {
 		}
 	}(manifestFiles)
 
-	extractedMinSdk, err := extractMinSdk(manifestFiles, minSdk)
+	sdks, messages, err := extractSdkVersions(manifestFiles, minSdk, targetSdk)
 	if err != nil {
-		log.Fatalf("error extracting min sdk from manifests: %v", err)
+		log.Fatalf("error extracting sdk versions from manifests: %v", err)
+	}
+
+	if logFile != "" {
+		if err := os.WriteFile(logFile, []byte(strings.Join(messages, "\n")), 0644); err != nil {
+			log.Fatalf("error writing log: %v", err)
+		}
 	}
 
 	outFile, err := os.Create(out)
@@ -122,15 +131,16 @@ func Run() {
 		log.Fatalf("error opening output manifest: %v", err)
 	}
 	defer outFile.Close()
-	if err := writeManifest(outFile, javaPackage, extractedMinSdk); err != nil {
+	if err := writeManifest(outFile, javaPackage, sdks); err != nil {
 		log.Fatalf("error writing output manifest: %v", err)
 	}
 }
 
-// The min sdk is selected by taking the max value found
-// between the manifests and the minsdk flag
-func extractMinSdk(manifests []io.ReadCloser, defaultSdk int) (int, error) {
-	// Extracting minSdk values in goroutines
+// The min sdk is selected by taking the max value found between the manifests and the
+// defaultMinSdk, and the target sdk by taking the max value found between the manifests and the
+// defaultTargetSdk. The target sdk is raised to the min sdk if lower.
+func extractSdkVersions(manifests []io.ReadCloser, defaultMinSdk, defaultTargetSdk int) (sdkVersions, []string, error) {
+	// Extracting sdk values in goroutines
 	results := make(chan result, len(manifests))
 	var wg sync.WaitGroup
 	wg.Add(len(manifests))
@@ -144,45 +154,78 @@ func extractMinSdk(manifests []io.ReadCloser, defaultSdk int) (int, error) {
 	wg.Wait()
 	close(results)
 
-	// Finding max value from channel
-	minSdk := defaultSdk
+	// Finding max values from channel. Preview and stable levels do not mix, so any preview
+	// library fails the merge.
+	sdks := sdkVersions{minSdk: sdklevel.API(defaultMinSdk), targetSdk: sdklevel.API(defaultTargetSdk)}
 	for result := range results {
 		if result.err != nil {
-			return 0, result.err
+			return sdkVersions{}, nil, result.err
+		}
+		var err error
+		if sdks.minSdk, err = sdklevel.Max(sdks.minSdk, result.minSdk); err != nil {
+			return sdkVersions{}, nil, fmt.Errorf("merging minSdkVersion: %v", err)
+		}
+		if sdks.targetSdk, err = sdklevel.Max(sdks.targetSdk, result.targetSdk); err != nil {
+			return sdkVersions{}, nil, fmt.Errorf("merging targetSdkVersion: %v", err)
 		}
-		minSdk = max(minSdk, result.minSdk)
 	}
-	return minSdk, nil
+
+	messages := []string{fmt.Sprintf("Max minSdkVersion of %d manifests and the default (%d) is %s.", len(manifests), defaultMinSdk, sdks.minSdk)}
+	if sdks.targetSdk.IsZero() {
+		return sdks, append(messages, "No targetSdkVersion found in the manifests nor default specified. Target SDK not set."), nil
+	}
+	c, err := sdks.targetSdk.Compare(sdks.minSdk)
+	if err != nil {
+		return sdkVersions{}, nil, fmt.Errorf("merging targetSdkVersion: %v", err)
+	}
+	if c < 0 {
+		messages = append(messages, fmt.Sprintf("Max targetSdkVersion of %d manifests and the default (%d) is %s, less than the minSdkVersion (%s). Target SDK replaced.", len(manifests), defaultTargetSdk, sdks.targetSdk, sdks.minSdk))
+		sdks.targetSdk = sdks.minSdk
+	} else {
+		messages = append(messages, fmt.Sprintf("Max targetSdkVersion of %d manifests and the default (%d) is %s.", len(manifests), defaultTargetSdk, sdks.targetSdk))
+	}
+	return sdks, messages, nil
 }
 
 func extractMinSdkFromManifest(reader io.Reader) result {
-	manifestBytes, err := ioutil.ReadAll(reader)
+	manifest, err := manifestutils.ReadManifest(reader)
 	if err != nil {
-		return result{minSdk: 0, err: err}
+		return result{err: err}
 	}
-	usesSdk := usesSdkTag{MinSdk: ""}
-	manifest := manifestTag{UsesSdk: usesSdk}
-	if err := xml.Unmarshal(manifestBytes, &manifest); err != nil {
-		return result{minSdk: 0, err: err}
+	if manifest.UsesSdk == nil {
+		return result{}
 	}
 
-	// MinSdk value could be a placeholder, we ignore it if that's the case
-	value, err := strconv.Atoi(manifest.UsesSdk.MinSdk)
+	minSdk, err := parseSdkVersion(manifest.UsesSdk.MinSdkVersion)
 	if err != nil {
-		return result{minSdk: 0, err: nil}
+		return result{err: fmt.Errorf("minSdkVersion attribute: %v", err)}
 	}
-	return result{minSdk: value, err: nil}
+	targetSdk, err := parseSdkVersion(manifest.UsesSdk.TargetSdkVersion)
+	if err != nil {
+		return result{err: fmt.Errorf("targetSdkVersion attribute: %v", err)}
+	}
+	return result{minSdk: minSdk, targetSdk: targetSdk}
 }
 
-func writeManifest(outManifest io.Writer, javaPackage string, minSdk int) error {
-	manifestWriter := bufio.NewWriter(outManifest)
-	manifestWriter.WriteString(fmt.Sprintf(manifestContent, javaPackage, minSdk))
-	return manifestWriter.Flush()
+// parseSdkVersion parses an SDK version attribute. Values could be unset or placeholders, we
+// ignore them if that's the case.
+func parseSdkVersion(v string) (sdklevel.Level, error) {
+	if v == "" {
+		return sdklevel.Level{}, nil
+	}
+	l, err := sdklevel.Parse(v)
+	if errors.Is(err, sdklevel.ErrPlaceholder) {
+		return sdklevel.Level{}, nil
+	}
+	return l, err
 }
 
-func max(a, b int) int {
-	if a < b {
-		return b
+func writeManifest(outManifest io.Writer, javaPackage string, sdks sdkVersions) error {
+	targetSdkAttr := ""
+	if !sdks.targetSdk.IsZero() {
+		targetSdkAttr = fmt.Sprintf(` android:targetSdkVersion="%s"`, sdks.targetSdk)
 	}
-	return a
+	manifestWriter := bufio.NewWriter(outManifest)
+	manifestWriter.WriteString(fmt.Sprintf(manifestContent, javaPackage, sdks.minSdk, targetSdkAttr))
+	return manifestWriter.Flush()
 }
@@ -16,11 +16,13 @@
 package generatemanifest
 
 import (
+	"bytes"
 	"io"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"src/tools/ak/sdklevel"
 )
 
 type fakeFile struct {
@@ -125,11 +127,11 @@ func TestExtractMinSdk(t *testing.T) {
 				file.reader.Seek(0, 0)
 				files = append(files, file)
 			}
-			minSdk, err := extractMinSdk(files, tc.defaultMinSdk)
+			sdks, _, err := extractSdkVersions(files, tc.defaultMinSdk, 0)
 			if err != nil {
-				t.Fatalf("extractMinSdk(%v, %d) failed with err: %v", files, tc.defaultMinSdk, err)
+				t.Fatalf("extractSdkVersions(%v, %d, 0) failed with err: %v", files, tc.defaultMinSdk, err)
 			}
-			if diff := cmp.Diff(tc.expectedMinSdk, minSdk); diff != "" {
+			if diff := cmp.Diff(sdklevel.API(tc.expectedMinSdk), sdks.minSdk); diff != "" {
 				t.Errorf("extractMinSdkFromManifest(%v) returned diff (-want, +got):\n%v", files, diff)
 			}
 		})
@@ -211,10 +213,165 @@ func TestExtractMinSdkFromManifest(t *testing.T) {
 			if result.err != nil {
 				t.Fatalf("extractMinSdkFromManifest(%v) failed with err: %v", file, result.err)
 			}
-			if diff := cmp.Diff(tc.expectedMinSdk, result.minSdk); diff != "" {
+			if diff := cmp.Diff(sdklevel.API(tc.expectedMinSdk), result.minSdk); diff != "" {
 				t.Errorf("extractMinSdkFromManifest(%v) returned diff (-want, +got):\n%v", file, diff)
 			}
 		})
 	}
 
 }
+
+func TestExtractTargetSdk(t *testing.T) {
+	manifest := func(usesSdk string) string {
+		return `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android"
+		package="com.default">
+		` + usesSdk + `
+</manifest>`
+	}
+	tests := []struct {
+		name             string
+		manifests        []string
+		defaultTargetSdk int
+		expected         sdkVersions
+		expectedLog      string
+	}{
+		{
+			name:             "no target sdk",
+			manifests:        []string{manifest(`<uses-sdk android:minSdkVersion="21" />`)},
+			defaultTargetSdk: 0,
+			expected:         sdkVersions{minSdk: sdklevel.API(21)},
+			expectedLog:      "No targetSdkVersion found in the manifests nor default specified. Target SDK not set.",
+		},
+		{
+			name: "max of manifests",
+			manifests: []string{
+				manifest(`<uses-sdk android:minSdkVersion="21" android:targetSdkVersion="33" />`),
+				manifest(`<uses-sdk android:minSdkVersion="19" android:targetSdkVersion="${targetSdkVersion}" />`),
+				manifest(`<uses-sdk android:targetSdkVersion="34" />`),
+			},
+			defaultTargetSdk: 30,
+			expected:         sdkVersions{minSdk: sdklevel.API(21), targetSdk: sdklevel.API(34)},
+			expectedLog:      "Max targetSdkVersion of 3 manifests and the default (30) is 34.",
+		},
+		{
+			name: "raised to min sdk",
+			manifests: []string{
+				manifest(`<uses-sdk android:minSdkVersion="24" />`),
+				manifest(`<uses-sdk android:minSdkVersion="19" android:targetSdkVersion="21" />`),
+			},
+			expected:    sdkVersions{minSdk: sdklevel.API(24), targetSdk: sdklevel.API(24)},
+			expectedLog: "Max targetSdkVersion of 2 manifests and the default (0) is 21, less than the minSdkVersion (24). Target SDK replaced.",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			files := make([]io.ReadCloser, 0, len(tc.manifests))
+			for _, m := range tc.manifests {
+				files = append(files, fakeFile{reader: strings.NewReader(m)})
+			}
+			sdks, messages, err := extractSdkVersions(files, 14, tc.defaultTargetSdk)
+			if err != nil {
+				t.Fatalf("extractSdkVersions failed with err: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, sdks, cmp.AllowUnexported(sdkVersions{})); diff != "" {
+				t.Errorf("extractSdkVersions returned diff (-want, +got):\n%v", diff)
+			}
+			if diff := cmp.Diff(tc.expectedLog, messages[len(messages)-1]); diff != "" {
+				t.Errorf("extractSdkVersions returned log diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		sdks     sdkVersions
+		expected string
+	}{
+		{
+			name: "min sdk",
+			sdks: sdkVersions{minSdk: sdklevel.API(21)},
+			expected: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android"
+    package="com.example">
+    <uses-sdk android:minSdkVersion="21" />
+    <application/>
+</manifest>
+`,
+		},
+		{
+			name: "min and target sdk",
+			sdks: sdkVersions{minSdk: sdklevel.API(21), targetSdk: sdklevel.API(34)},
+			expected: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android"
+    package="com.example">
+    <uses-sdk android:minSdkVersion="21" android:targetSdkVersion="34" />
+    <application/>
+</manifest>
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := writeManifest(&b, "com.example", tc.sdks); err != nil {
+				t.Fatalf("writeManifest failed with err: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, b.String()); diff != "" {
+				t.Errorf("writeManifest returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestExtractSdkVersionsPreview(t *testing.T) {
+	manifest := func(usesSdk string) io.ReadCloser {
+		return fakeFile{reader: strings.NewReader(`<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.default">` + usesSdk + `</manifest>`)}
+	}
+	tests := []struct {
+		name      string
+		manifests []io.ReadCloser
+		expected  string
+		wantErr   string
+	}{
+		{
+			name:      "minor version",
+			manifests: []io.ReadCloser{manifest(`<uses-sdk android:minSdkVersion="36.1" />`), manifest(`<uses-sdk android:minSdkVersion="36" />`)},
+			expected:  "36.1",
+		},
+		{
+			name:      "preview library",
+			manifests: []io.ReadCloser{manifest(`<uses-sdk android:minSdkVersion="21" />`), manifest(`<uses-sdk android:minSdkVersion="VanillaIceCream" />`)},
+			wantErr:   "merging minSdkVersion: cannot mix the preview SDK VanillaIceCream with the stable SDK",
+		},
+		{
+			name:      "preview target",
+			manifests: []io.ReadCloser{manifest(`<uses-sdk android:minSdkVersion="21" android:targetSdkVersion="Baklava" />`)},
+			wantErr:   "merging targetSdkVersion: cannot mix the preview SDK Baklava with the stable SDK",
+		},
+		{
+			name:      "invalid value",
+			manifests: []io.ReadCloser{manifest(`<uses-sdk android:minSdkVersion="twenty" />`)},
+			wantErr:   `minSdkVersion attribute: invalid SDK level "twenty"`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sdks, _, err := extractSdkVersions(tc.manifests, 14, 0)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("extractSdkVersions returned error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractSdkVersions failed with err: %v", err)
+			}
+			if got := sdks.minSdk.String(); got != tc.expected {
+				t.Errorf("extractSdkVersions returned min sdk %s, want %s", got, tc.expected)
+			}
+		})
+	}
+}
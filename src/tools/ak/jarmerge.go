@@ -0,0 +1,524 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jarmerge combines the entries of several archives into one, resolving the names found
+// in more than one of them. Duplicates are kept once, dropped, rejected, or merged according to
+// their content: service loader files, Kotlin module files and jar manifests are understood,
+// other files are concatenated.
+package jarmerge
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Policies for the entries found more than once.
+const (
+	// First keeps the first entry.
+	First = "first"
+	// Last keeps the last entry, at the position of the first.
+	Last = "last"
+	// Error fails on the duplicate.
+	Error = "error"
+	// Merge combines the entries, see Merger.
+	Merge = "merge"
+	// Drop removes all the entries.
+	Drop = "drop"
+)
+
+const manifestName = "META-INF/MANIFEST.MF"
+
+// Policies lists the valid policies.
+var Policies = []string{First, Last, Error, Merge, Drop}
+
+// Source is the content of an entry in one of the inputs.
+type Source struct {
+	// Origin names the input, for reports.
+	Origin string
+	// Open opens the content.
+	Open func() (io.ReadCloser, error)
+}
+
+type entry struct {
+	name    string
+	method  uint16
+	policy  string
+	sources []Source
+}
+
+// Collision describes how an entry found more than once was resolved.
+type Collision struct {
+	Name    string
+	Policy  string
+	Origins []string
+	// Note explains deviations from the policy, e.g. a merge which was not possible.
+	Note string
+}
+
+func (c Collision) String() string {
+	s := fmt.Sprintf("%s: %s of %s", c.Name, c.Policy, strings.Join(c.Origins, ", "))
+	if c.Note != "" {
+		s += " (" + c.Note + ")"
+	}
+	return s
+}
+
+// Archive collects entries in the order their names were first seen.
+type Archive struct {
+	entries []*entry
+	byName  map[string]*entry
+}
+
+// NewArchive returns an empty Archive.
+func NewArchive() *Archive {
+	return &Archive{byName: make(map[string]*entry)}
+}
+
+// Add adds an entry. The method and policy of the first entry of a name apply to all of them.
+// Directories found more than once are kept once.
+func (a *Archive) Add(name string, method uint16, policy string, src Source) error {
+	e, ok := a.byName[name]
+	if !ok {
+		e = &entry{name: name, method: method, policy: policy, sources: []Source{src}}
+		a.entries = append(a.entries, e)
+		a.byName[name] = e
+		return nil
+	}
+	if strings.HasSuffix(name, "/") {
+		return nil
+	}
+	if e.policy == Error {
+		return fmt.Errorf("duplicate entry %s in %s and %s", name, e.sources[0].Origin, src.Origin)
+	}
+	e.sources = append(e.sources, src)
+	return nil
+}
+
+// Writer is implemented by zip.Writer and ziputils.AlignedWriter.
+type Writer interface {
+	CreateHeader(fh *zip.FileHeader) (io.Writer, error)
+}
+
+// Write writes the entries to out and returns how the duplicates were resolved. If the manifests
+// are merged, the signature files no longer match the manifest and are dropped.
+func (a *Archive) Write(out Writer) ([]Collision, error) {
+	type resolved struct {
+		e       *entry
+		content []byte
+		c       *Collision
+	}
+	var rs []resolved
+	mergedManifest := false
+	for _, e := range a.entries {
+		content, c, err := e.resolve()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.name, err)
+		}
+		// Merged content is only returned by successful merges.
+		if e.name == manifestName && content != nil {
+			mergedManifest = true
+		}
+		rs = append(rs, resolved{e, content, c})
+	}
+	var collisions []Collision
+	for _, r := range rs {
+		e, content, c := r.e, r.content, r.c
+		if mergedManifest && IsSignature(e.name) {
+			dropped := Collision{Name: e.name, Policy: Drop, Note: "signature of a merged manifest"}
+			for _, s := range e.sources {
+				dropped.Origins = append(dropped.Origins, s.Origin)
+			}
+			collisions = append(collisions, dropped)
+			continue
+		}
+		if c != nil {
+			collisions = append(collisions, *c)
+			if c.Policy == Drop {
+				continue
+			}
+		}
+		w, err := out.CreateHeader(&zip.FileHeader{
+			Name:   e.name,
+			Method: e.method,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Only files have data, header entry is required for both.
+		if strings.HasSuffix(e.name, "/") {
+			continue
+		}
+		if content != nil {
+			_, err = w.Write(content)
+		} else {
+			err = copySource(w, e.sources[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.name, err)
+		}
+	}
+	return collisions, nil
+}
+
+// resolve applies the policy of e. It returns the content to write, nil to copy the first
+// source, and the collision if e was found more than once.
+func (e *entry) resolve() ([]byte, *Collision, error) {
+	if len(e.sources) == 1 {
+		return nil, nil, nil
+	}
+	c := &Collision{Name: e.name, Policy: e.policy}
+	for _, s := range e.sources {
+		c.Origins = append(c.Origins, s.Origin)
+	}
+	switch e.policy {
+	case Last:
+		e.sources = e.sources[len(e.sources)-1:]
+	case Merge:
+		contents, err := readAll(e.sources)
+		if err != nil {
+			return nil, nil, err
+		}
+		merged, err := Merger(e.name)(contents)
+		if err == nil {
+			return merged, c, nil
+		}
+		c.Note = fmt.Sprintf("kept the first, cannot merge: %v", err)
+	}
+	return nil, c, nil
+}
+
+func readAll(srcs []Source) ([][]byte, error) {
+	var contents [][]byte
+	for _, s := range srcs {
+		var b bytes.Buffer
+		if err := copySource(&b, s); err != nil {
+			return nil, err
+		}
+		contents = append(contents, b.Bytes())
+	}
+	return contents, nil
+}
+
+func copySource(w io.Writer, s Source) error {
+	r, err := s.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// IsSignature reports whether name is a file of a jar signature.
+func IsSignature(name string) bool {
+	f, ok := strings.CutPrefix(strings.ToLower(name), "meta-inf/")
+	if !ok || strings.Contains(f, "/") {
+		return false
+	}
+	for _, ext := range []string{".sf", ".rsa", ".dsa", ".ec"} {
+		if strings.HasSuffix(f, ext) {
+			return true
+		}
+	}
+	return strings.HasPrefix(f, "sig-")
+}
+
+// Merger returns the function merging the contents of the entries with the given name.
+func Merger(name string) func([][]byte) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(name, "META-INF/services/"):
+		return MergeServices
+	case strings.HasSuffix(name, ".kotlin_module"):
+		return MergeKotlinModules
+	case name == manifestName:
+		return MergeManifests
+	}
+	return concat
+}
+
+// concat concatenates contents, separated by newlines.
+func concat(contents [][]byte) ([]byte, error) {
+	var b bytes.Buffer
+	for _, c := range contents {
+		if b.Len() > 0 && !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+			b.WriteByte('\n')
+		}
+		b.Write(c)
+	}
+	return b.Bytes(), nil
+}
+
+// MergeServices merges service loader provider files, keeping the first occurrence of each
+// provider and dropping comments and blank lines.
+func MergeServices(contents [][]byte) ([]byte, error) {
+	var b bytes.Buffer
+	seen := make(map[string]bool)
+	for _, c := range contents {
+		for _, line := range strings.Split(string(c), "\n") {
+			if i := strings.IndexByte(line, '#'); i >= 0 {
+				line = line[:i]
+			}
+			line = strings.TrimSpace(line)
+			if line == "" || seen[line] {
+				continue
+			}
+			seen[line] = true
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// Fields of the Kotlin Module message which can be merged by concatenation: package_parts and
+// metadata_parts. The others index into tables which would have to be renumbered.
+var kotlinMergeableFields = map[uint64]bool{1: true, 2: true}
+
+// MergeKotlinModules merges Kotlin module files, the version of the metadata followed by a
+// Module protocol buffer listing the package parts. Concatenating serialized messages merges
+// their repeated fields, so modules with the same version are merged by appending their bodies
+// as long as they only hold package parts.
+func MergeKotlinModules(contents [][]byte) ([]byte, error) {
+	header, _, err := splitKotlinModule(contents[0])
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.Write(header)
+	seen := make(map[string]bool)
+	for _, c := range contents {
+		h, body, err := splitKotlinModule(c)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(h, header) {
+			return nil, errors.New("different metadata versions")
+		}
+		if seen[string(body)] {
+			continue
+		}
+		seen[string(body)] = true
+		fields, err := protoFields(body)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			if !kotlinMergeableFields[f] {
+				return nil, fmt.Errorf("unsupported field %d", f)
+			}
+		}
+		b.Write(body)
+	}
+	return b.Bytes(), nil
+}
+
+// splitKotlinModule splits a Kotlin module file into its header, the metadata version and from
+// 1.4 on flags, and its body.
+func splitKotlinModule(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, errors.New("truncated kotlin module")
+	}
+	n := int(int32(binary.BigEndian.Uint32(b)))
+	if n < 0 || n > 16 || len(b) < 4+4*n {
+		return nil, nil, errors.New("invalid kotlin module version")
+	}
+	v := make([]int32, n)
+	for i := range v {
+		v[i] = int32(binary.BigEndian.Uint32(b[4+4*i:]))
+	}
+	l := 4 + 4*n
+	// Since Kotlin 1.4, flags are written between the version and the body.
+	if n > 0 && (v[0] > 1 || v[0] == 1 && n > 1 && v[1] >= 4) {
+		l += 4
+	}
+	if len(b) < l {
+		return nil, nil, errors.New("truncated kotlin module")
+	}
+	return b[:l], b[l:], nil
+}
+
+// protoFields returns the numbers of the top level fields of a serialized protocol buffer.
+func protoFields(b []byte) ([]uint64, error) {
+	var fields []uint64
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 || tag>>3 == 0 {
+			return nil, errors.New("invalid protocol buffer")
+		}
+		b = b[n:]
+		var l uint64
+		switch tag & 7 {
+		case 0:
+			if _, n = binary.Uvarint(b); n <= 0 {
+				return nil, errors.New("invalid protocol buffer")
+			}
+			l = uint64(n)
+		case 1:
+			l = 8
+		case 2:
+			size, n := binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errors.New("invalid protocol buffer")
+			}
+			b = b[n:]
+			l = size
+		case 5:
+			l = 4
+		default:
+			return nil, errors.New("invalid protocol buffer")
+		}
+		if l > uint64(len(b)) {
+			return nil, errors.New("truncated protocol buffer")
+		}
+		b = b[l:]
+		fields = append(fields, tag>>3)
+	}
+	return fields, nil
+}
+
+// manifestSection is a section of a jar manifest, its attributes in order.
+type manifestSection struct {
+	keys   []string
+	values map[string]string
+}
+
+func (s *manifestSection) add(key, value string) {
+	k := strings.ToLower(key)
+	if _, ok := s.values[k]; ok {
+		return
+	}
+	s.keys = append(s.keys, key)
+	s.values[k] = value
+}
+
+func (s *manifestSection) get(key string) string {
+	return s.values[strings.ToLower(key)]
+}
+
+// MergeManifests merges jar manifests. The main attributes and the sections of the first
+// manifest win, attributes and sections missing from it are added from the others. The digests of
+// signed jars no longer match the merged entries and are dropped, with the sections holding
+// nothing else.
+func MergeManifests(contents [][]byte) ([]byte, error) {
+	var sections []*manifestSection
+	byName := make(map[string]*manifestSection)
+	for _, c := range contents {
+		ss, err := parseManifest(c)
+		if err != nil {
+			return nil, err
+		}
+		for i, s := range ss {
+			name := s.get("Name")
+			if i > 0 && name == "" {
+				return nil, errors.New("manifest section without Name")
+			}
+			if i > 0 {
+				if s.keys = slices.DeleteFunc(s.keys, isDigest); len(s.keys) == 1 {
+					continue
+				}
+			}
+			dst, ok := byName[name]
+			if !ok {
+				dst = &manifestSection{values: make(map[string]string)}
+				sections = append(sections, dst)
+				byName[name] = dst
+			}
+			for _, k := range s.keys {
+				dst.add(k, s.get(k))
+			}
+		}
+	}
+	var b bytes.Buffer
+	for _, s := range sections {
+		for _, k := range s.keys {
+			writeManifestLine(&b, k+": "+s.get(k))
+		}
+		b.WriteString("\r\n")
+	}
+	return b.Bytes(), nil
+}
+
+// isDigest reports whether key is the digest of an entry of a signed jar, e.g. SHA-256-Digest.
+func isDigest(key string) bool {
+	return strings.HasSuffix(strings.ToLower(key), "-digest")
+}
+
+func parseManifest(b []byte) ([]*manifestSection, error) {
+	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
+	var sections []*manifestSection
+	var s *manifestSection
+	var key string
+	for _, line := range lines {
+		switch {
+		case line == "":
+			s = nil
+		case strings.HasPrefix(line, " "):
+			if s == nil || key == "" {
+				return nil, fmt.Errorf("invalid manifest continuation line %q", line)
+			}
+			s.values[strings.ToLower(key)] += line[1:]
+		default:
+			k, v, ok := strings.Cut(line, ": ")
+			if !ok {
+				return nil, fmt.Errorf("invalid manifest line %q", line)
+			}
+			if s == nil {
+				s = &manifestSection{values: make(map[string]string)}
+				sections = append(sections, s)
+			}
+			key = k
+			s.add(k, v)
+		}
+	}
+	if len(sections) == 0 {
+		sections = append(sections, &manifestSection{values: make(map[string]string)})
+	}
+	return sections, nil
+}
+
+// writeManifestLine writes line wrapped at 72 bytes, the limit of the jar specification, without
+// splitting characters. Continuation lines start with a space.
+func writeManifestLine(b *bytes.Buffer, line string) {
+	const max = 72
+	n := max
+	for len(line) > n {
+		i := n
+		for !utf8.RuneStart(line[i]) {
+			i--
+		}
+		b.WriteString(line[:i] + "\r\n ")
+		line = line[i:]
+		n = max - 1
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// WriteReport writes the collisions, one per line, sorted by name.
+func WriteReport(w io.Writer, collisions []Collision) error {
+	cs := append([]Collision(nil), collisions...)
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].Name < cs[j].Name })
+	for _, c := range cs {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
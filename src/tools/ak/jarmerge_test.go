@@ -0,0 +1,339 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jarmerge
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func source(origin, content string) Source {
+	return Source{Origin: origin, Open: func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(content)), nil
+	}}
+}
+
+func TestArchive(t *testing.T) {
+	a := NewArchive()
+	for _, e := range []struct {
+		name, policy, origin, content string
+	}{
+		{"a.txt", First, "1.jar", "a1"},
+		{"b.txt", Last, "1.jar", "b1"},
+		{"dir/", Error, "1.jar", ""},
+		{"META-INF/services/x.Y", Merge, "1.jar", "com.A\n# comment\n"},
+		{"c.txt", Drop, "1.jar", "c1"},
+		{"d.txt", Drop, "1.jar", "d1"},
+		{"a.txt", First, "2.jar", "a2"},
+		{"b.txt", Last, "2.jar", "b2"},
+		{"dir/", Error, "2.jar", ""},
+		{"META-INF/services/x.Y", Merge, "2.jar", "com.B\ncom.A"},
+		{"c.txt", Drop, "2.jar", "c2"},
+	} {
+		if err := a.Add(e.name, zip.Store, e.policy, source(e.origin, e.content)); err != nil {
+			t.Fatalf("Add(%q) failed: %v", e.name, err)
+		}
+	}
+
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	collisions, err := a.Write(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	var names []string
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+		got[f.Name] = string(data)
+	}
+	wantNames := []string{"a.txt", "b.txt", "dir/", "META-INF/services/x.Y", "d.txt"}
+	if diff := cmp.Diff(wantNames, names); diff != "" {
+		t.Errorf("Write() entries diff (-want +got):\n%s", diff)
+	}
+	want := map[string]string{
+		"a.txt":                 "a1",
+		"b.txt":                 "b2",
+		"dir/":                  "",
+		"META-INF/services/x.Y": "com.A\ncom.B\n",
+		"d.txt":                 "d1",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Write() contents diff (-want +got):\n%s", diff)
+	}
+	wantCollisions := []Collision{
+		{Name: "a.txt", Policy: First, Origins: []string{"1.jar", "2.jar"}},
+		{Name: "b.txt", Policy: Last, Origins: []string{"1.jar", "2.jar"}},
+		{Name: "META-INF/services/x.Y", Policy: Merge, Origins: []string{"1.jar", "2.jar"}},
+		{Name: "c.txt", Policy: Drop, Origins: []string{"1.jar", "2.jar"}},
+	}
+	if diff := cmp.Diff(wantCollisions, collisions); diff != "" {
+		t.Errorf("Write() collisions diff (-want +got):\n%s", diff)
+	}
+
+	var report bytes.Buffer
+	if err := WriteReport(&report, collisions); err != nil {
+		t.Fatal(err)
+	}
+	wantReport := `META-INF/services/x.Y: merge of 1.jar, 2.jar
+a.txt: first of 1.jar, 2.jar
+b.txt: last of 1.jar, 2.jar
+c.txt: drop of 1.jar, 2.jar
+`
+	if diff := cmp.Diff(wantReport, report.String()); diff != "" {
+		t.Errorf("WriteReport() diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestArchiveSignatures(t *testing.T) {
+	tests := []struct {
+		name           string
+		jars           []string
+		wantNames      []string
+		wantCollisions []Collision
+	}{
+		{
+			name:      "merged manifest",
+			jars:      []string{"1.jar", "2.jar"},
+			wantNames: []string{"META-INF/MANIFEST.MF", "a.txt"},
+			wantCollisions: []Collision{
+				{Name: "META-INF/MANIFEST.MF", Policy: Merge, Origins: []string{"1.jar", "2.jar"}},
+				{Name: "META-INF/CERT.SF", Policy: Drop, Origins: []string{"1.jar", "2.jar"}, Note: "signature of a merged manifest"},
+				{Name: "META-INF/CERT.RSA", Policy: Drop, Origins: []string{"1.jar", "2.jar"}, Note: "signature of a merged manifest"},
+				{Name: "a.txt", Policy: First, Origins: []string{"1.jar", "2.jar"}},
+			},
+		},
+		{
+			name:      "single manifest",
+			jars:      []string{"1.jar"},
+			wantNames: []string{"META-INF/MANIFEST.MF", "META-INF/CERT.SF", "META-INF/CERT.RSA", "a.txt"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewArchive()
+			for _, jar := range tc.jars {
+				for _, e := range []struct {
+					name, policy, content string
+				}{
+					{"META-INF/MANIFEST.MF", Merge, "Manifest-Version: 1.0\n\nName: a.txt\nSHA-256-Digest: YQ==\n"},
+					{"META-INF/CERT.SF", First, "sf"},
+					{"META-INF/CERT.RSA", First, "rsa"},
+					{"a.txt", First, "a"},
+				} {
+					if err := a.Add(e.name, zip.Store, e.policy, source(jar, e.content)); err != nil {
+						t.Fatalf("Add(%q) failed: %v", e.name, err)
+					}
+				}
+			}
+			var b bytes.Buffer
+			w := zip.NewWriter(&b)
+			collisions, err := a.Write(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, f := range r.File {
+				names = append(names, f.Name)
+			}
+			if diff := cmp.Diff(tc.wantNames, names); diff != "" {
+				t.Errorf("Write() entries diff (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantCollisions, collisions); diff != "" {
+				t.Errorf("Write() collisions diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestArchiveError(t *testing.T) {
+	a := NewArchive()
+	if err := a.Add("a.txt", zip.Store, Error, source("1.jar", "a1")); err != nil {
+		t.Fatal(err)
+	}
+	err := a.Add("a.txt", zip.Store, Error, source("2.jar", "a2"))
+	if err == nil || !strings.Contains(err.Error(), "1.jar and 2.jar") {
+		t.Errorf("Add() got err: %v, want a duplicate error naming 1.jar and 2.jar", err)
+	}
+}
+
+func TestMergeManifests(t *testing.T) {
+	long := "com.example." + strings.Repeat("a", 150)
+	contents := [][]byte{
+		[]byte("Manifest-Version: 1.0\r\nCreated-By: a\r\n\r\nName: com/A.class\r\nX: 1\r\n\r\n"),
+		[]byte("Manifest-Version: 2.0\nmain-class: " + long[:50] + "\n " + long[50:] + "\n\nName: com/A.class\nY: 2\n\nName: com/B.class\nX: 3\n"),
+	}
+	got, err := MergeManifests(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainClass := "main-class: " + long
+	want := "Manifest-Version: 1.0\r\nCreated-By: a\r\n" +
+		mainClass[:72] + "\r\n " + mainClass[72:143] + "\r\n " + mainClass[143:] + "\r\n\r\n" +
+		"Name: com/A.class\r\nX: 1\r\nY: 2\r\n\r\n" +
+		"Name: com/B.class\r\nX: 3\r\n\r\n"
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("MergeManifests() diff (-want +got):\n%s", diff)
+	}
+
+	if _, err := MergeManifests([][]byte{[]byte("Manifest-Version 1.0\n")}); err == nil {
+		t.Error("MergeManifests() of an invalid manifest succeeded, want an error")
+	}
+}
+
+func TestMergeManifestsSigned(t *testing.T) {
+	contents := [][]byte{
+		[]byte("Manifest-Version: 1.0\n\nName: com/A.class\nSHA-256-Digest: YQ==\n\nName: com/\nSealed: true\nSHA1-Digest: Yg==\n"),
+		[]byte("Manifest-Version: 1.0\n\nName: com/B.class\nSHA-256-Digest: Yw==\n"),
+	}
+	got, err := MergeManifests(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Manifest-Version: 1.0\r\n\r\nName: com/\r\nSealed: true\r\n\r\n"
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("MergeManifests() diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteManifestLine(t *testing.T) {
+	// é is the 72nd and 73rd bytes, split by wrapping at 72 bytes.
+	line := "Implementation-Title: " + strings.Repeat("a", 49) + "é" + strings.Repeat("b", 80)
+	var b bytes.Buffer
+	writeManifestLine(&b, line)
+	want := line[:71] + "\r\n " + line[71:142] + "\r\n " + line[142:] + "\r\n"
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("writeManifestLine() diff (-want +got):\n%s", diff)
+	}
+}
+
+// kotlinModule returns a Kotlin module file of the version holding the raw fields.
+func kotlinModule(version []int32, fields ...[]byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, int32(len(version)))
+	binary.Write(&b, binary.BigEndian, version)
+	if version[0] > 1 || version[1] >= 4 {
+		binary.Write(&b, binary.BigEndian, int32(0))
+	}
+	for _, f := range fields {
+		b.Write(f)
+	}
+	return b.Bytes()
+}
+
+// packageParts returns the package_parts field of a Module message for pkg.
+func packageParts(pkg string) []byte {
+	parts := append([]byte{0x0a, byte(len(pkg))}, pkg...)
+	return append([]byte{0x0a, byte(len(parts))}, parts...)
+}
+
+func TestMergeKotlinModules(t *testing.T) {
+	v19 := []int32{1, 9, 0}
+	tests := []struct {
+		name     string
+		contents [][]byte
+		want     []byte
+		wantErr  bool
+	}{
+		{
+			name:     "package_parts",
+			contents: [][]byte{kotlinModule(v19, packageParts("com.a")), kotlinModule(v19, packageParts("com.b"))},
+			want:     kotlinModule(v19, packageParts("com.a"), packageParts("com.b")),
+		},
+		{
+			name:     "identical",
+			contents: [][]byte{kotlinModule(v19, packageParts("com.a")), kotlinModule(v19, packageParts("com.a"))},
+			want:     kotlinModule(v19, packageParts("com.a")),
+		},
+		{
+			name:     "before_flags",
+			contents: [][]byte{kotlinModule([]int32{1, 1, 16}, packageParts("com.a")), kotlinModule([]int32{1, 1, 16}, packageParts("com.b"))},
+			want:     kotlinModule([]int32{1, 1, 16}, packageParts("com.a"), packageParts("com.b")),
+		},
+		{
+			name:     "different_versions",
+			contents: [][]byte{kotlinModule(v19, packageParts("com.a")), kotlinModule([]int32{2, 0, 0}, packageParts("com.b"))},
+			wantErr:  true,
+		},
+		{
+			name:     "jvm_package_name",
+			contents: [][]byte{kotlinModule(v19, packageParts("com.a")), kotlinModule(v19, []byte{0x1a, 0x01, 'x'})},
+			wantErr:  true,
+		},
+		{
+			name:     "truncated",
+			contents: [][]byte{kotlinModule(v19, packageParts("com.a")), kotlinModule(v19, []byte{0x0a, 0x09, 'x'})},
+			wantErr:  true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := MergeKotlinModules(tc.contents)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("MergeKotlinModules() got err: %v, want err: %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); !tc.wantErr && diff != "" {
+				t.Errorf("MergeKotlinModules() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMergeFallback(t *testing.T) {
+	a := NewArchive()
+	name := "META-INF/lib.kotlin_module"
+	for i, v := range [][]int32{{1, 9, 0}, {2, 0, 0}} {
+		content := string(kotlinModule(v, packageParts("com.a")))
+		if err := a.Add(name, zip.Store, Merge, source([]string{"1.jar", "2.jar"}[i], content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collisions, err := a.Write(zip.NewWriter(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collisions) != 1 || !strings.HasPrefix(collisions[0].Note, "kept the first") {
+		t.Errorf("Write() got collisions: %v, want one keeping the first", collisions)
+	}
+}
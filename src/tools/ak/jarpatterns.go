@@ -0,0 +1,151 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jarmerge
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultDuplicateRules are the policies applying after those given by users: service loader
+// files and Kotlin modules are merged, which keeps the first entry when they cannot be.
+var DefaultDuplicateRules = []string{
+	"META-INF/services/**:" + Merge,
+	"META-INF/*.kotlin_module:" + Merge,
+}
+
+// Pattern matches entry names with a glob, where ** also matches across directories, or with a
+// regular expression prefixed by "re:". Both must match the whole name.
+type Pattern struct {
+	expr string
+	re   *regexp.Regexp
+}
+
+// ParsePattern parses a glob or a regular expression prefixed by "re:".
+func ParsePattern(s string) (*Pattern, error) {
+	expr, ok := strings.CutPrefix(s, "re:")
+	if !ok {
+		expr = globToRegexp(s)
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", s, err)
+	}
+	return &Pattern{expr: s, re: re}, nil
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Match reports whether name matches p.
+func (p *Pattern) Match(name string) bool {
+	return p.re.MatchString(name)
+}
+
+func (p *Pattern) String() string {
+	return p.expr
+}
+
+// ParsePatterns parses each of vals, see ParsePattern.
+func ParsePatterns(vals []string) ([]*Pattern, error) {
+	var ps []*Pattern
+	for _, v := range vals {
+		p, err := ParsePattern(v)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// FirstMatch returns the first of ps matching name, or nil.
+func FirstMatch(ps []*Pattern, name string) *Pattern {
+	for _, p := range ps {
+		if p.Match(name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Rule maps the entries matching a pattern to a value, parsed from "pattern:value".
+type Rule struct {
+	*Pattern
+	Value string
+}
+
+// ParseRules parses each of vals, whose values must be one of valid.
+func ParseRules(vals []string, valid []string) ([]Rule, error) {
+	var rs []Rule
+	for _, v := range vals {
+		i := strings.LastIndex(v, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("%q is not of the form pattern:value", v)
+		}
+		p, err := ParsePattern(v[:i])
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(valid, v[i+1:]) {
+			return nil, fmt.Errorf("%q has an invalid value, want one of %s", v, strings.Join(valid, ", "))
+		}
+		rs = append(rs, Rule{Pattern: p, Value: v[i+1:]})
+	}
+	return rs, nil
+}
+
+// Lookup returns the value of the first of rs matching name, or def.
+func Lookup(rs []Rule, name, def string) string {
+	for _, r := range rs {
+		if r.Match(name) {
+			return r.Value
+		}
+	}
+	return def
+}
+
+// DuplicatePolicy returns the policy of the duplicates of an entry: the first of rules, of the
+// form "pattern:policy", matching it, else the first of DefaultDuplicateRules, else def.
+func DuplicatePolicy(rules []string, def string) (func(name string) string, error) {
+	if !slices.Contains(Policies, def) {
+		return nil, fmt.Errorf("invalid policy %q, want one of %s", def, strings.Join(Policies, ", "))
+	}
+	rs, err := ParseRules(append(append([]string(nil), rules...), DefaultDuplicateRules...), Policies)
+	if err != nil {
+		return nil, err
+	}
+	return func(name string) string { return Lookup(rs, name, def) }, nil
+}
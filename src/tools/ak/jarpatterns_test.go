@@ -0,0 +1,81 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jarmerge
+
+import "testing"
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "*.class", name: "Foo.class", want: true},
+		{pattern: "*.class", name: "com/Foo.class", want: false},
+		{pattern: "**.class", name: "com/Foo.class", want: true},
+		{pattern: "**/*.class", name: "Foo.class", want: true},
+		{pattern: "**/*.class", name: "com/google/Foo.class", want: true},
+		{pattern: "META-INF/services/**", name: "META-INF/services/a.B", want: true},
+		{pattern: "META-INF/services/**", name: "META-INF/MANIFEST.MF", want: false},
+		{pattern: "lib/?/*.so", name: "lib/x/liba.so", want: true},
+		{pattern: "a.b", name: "axb", want: false},
+		{pattern: "re:.*/R(\\$.*)?\\.class", name: "com/R$string.class", want: true},
+		{pattern: "re:.*/R(\\$.*)?\\.class", name: "com/Rx.class", want: false},
+		{pattern: "re:R", name: "com/R", want: false},
+	}
+	for _, tc := range tests {
+		p, err := ParsePattern(tc.pattern)
+		if err != nil {
+			t.Fatalf("ParsePattern(%q) failed: %v", tc.pattern, err)
+		}
+		if got := p.Match(tc.name); got != tc.want {
+			t.Errorf("%q.Match(%q) got: %v want: %v", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+	if _, err := ParsePattern("re:("); err == nil {
+		t.Errorf("ParsePattern(%q) succeeded, want an error", "re:(")
+	}
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	policy, err := DuplicatePolicy([]string{"META-INF/services/a.*:first", "**.txt:drop"}, Error)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"META-INF/services/a.B":       First,
+		"META-INF/services/b.C":       Merge,
+		"META-INF/main.kotlin_module": Merge,
+		"res/a.txt":                   Drop,
+		"a.png":                       Error,
+	} {
+		if got := policy(name); got != want {
+			t.Errorf("policy(%q) got: %q want: %q", name, got, want)
+		}
+	}
+
+	for _, tc := range []struct {
+		rules []string
+		def   string
+	}{
+		{rules: []string{"**:keep"}, def: First},
+		{rules: []string{"**"}, def: First},
+		{def: "keep"},
+	} {
+		if _, err := DuplicatePolicy(tc.rules, tc.def); err == nil {
+			t.Errorf("DuplicatePolicy(%v, %q) succeeded, want an error", tc.rules, tc.def)
+		}
+	}
+}
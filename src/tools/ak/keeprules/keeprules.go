@@ -0,0 +1,264 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keeprules generates proguard keep rules and a resources.keep file for resources which
+// are accessed reflectively, e.g. through Resources.getIdentifier.
+package keeprules
+
+import (
+	"bufio"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"src/common/golang/flags"
+	rdpb "src/tools/ak/res/proto/res_data_go_proto"
+	"src/tools/ak/res/res"
+	"src/tools/ak/rtxt/rtxt"
+	"src/tools/ak/types"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// Cmd defines the command to run keeprules.
+	Cmd = types.Command{
+		Init:  Init,
+		Run:   Run,
+		Desc:  desc,
+		Flags: []string{"res_pbs", "r_txt", "allowlist", "pkg", "target_label", "proguard_out", "resources_keep_out"},
+	}
+
+	// Variables to hold flag values.
+	resPbs           flags.StringList
+	rTxt             string
+	allowlist        string
+	pkg              string
+	targetLabel      string
+	proguardOut      string
+	resourcesKeepOut string
+
+	initOnce sync.Once
+)
+
+// Init initializes keeprules.
+func Init() {
+	initOnce.Do(func() {
+		flag.Var(&resPbs, "res_pbs", "R.pb files produced by liteparse for the resources of the target.")
+		flag.StringVar(&rTxt, "r_txt", "", "R.txt produced by the resource link.")
+		flag.StringVar(&allowlist, "allowlist", "", "File listing the reflectively accessed resources, one type/name glob per line, e.g. drawable/flag_*.")
+		flag.StringVar(&pkg, "pkg", "", "(optional) Package of the R class, defaults to the package of the first R.pb.")
+		flag.StringVar(&targetLabel, "target_label", "", "The target label.")
+		flag.StringVar(&proguardOut, "proguard_out", "", "Output path for the proguard keep rules.")
+		flag.StringVar(&resourcesKeepOut, "resources_keep_out", "", "Output path for the resources.keep XML file.")
+	})
+}
+
+func desc() string {
+	return "keeprules generates keep rules for reflectively accessed resources"
+}
+
+// Run is the entry point for keeprules. Will exit on error.
+func Run() {
+	if resPbs == nil || rTxt == "" || allowlist == "" || proguardOut == "" || resourcesKeepOut == "" {
+		log.Fatal("Flags -res_pbs -r_txt -allowlist -proguard_out and -resources_keep_out must be specified.")
+	}
+	if err := doWork(resPbs, rTxt, allowlist, pkg, targetLabel, proguardOut, resourcesKeepOut); err != nil {
+		log.Fatalf("error generating keep rules: %v", err)
+	}
+}
+
+func doWork(resPbs []string, rTxt, allowlist, pkg, targetLabel, proguardOut, resourcesKeepOut string) error {
+	var resources []*rdpb.Resource
+	for _, p := range resPbs {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rscs := &rdpb.Resources{}
+		if err := proto.Unmarshal(b, rscs); err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+		if pkg == "" {
+			pkg = rscs.GetPkg()
+		}
+		resources = append(resources, rscs.GetResource()...)
+	}
+	if pkg == "" {
+		return fmt.Errorf("no R class package given by -pkg nor by the R.pb files")
+	}
+	symbols, err := rtxt.ReadFile(rTxt)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(allowlist)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	patterns, err := readAllowlist(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", allowlist, err)
+	}
+
+	kept := keptSymbols(resources, symbols, patterns)
+	if err := writeFile(proguardOut, func(w io.Writer) error {
+		return writeProguard(w, pkg, targetLabel, kept)
+	}); err != nil {
+		return err
+	}
+	return writeFile(resourcesKeepOut, func(w io.Writer) error {
+		return writeResourcesKeep(w, kept)
+	})
+}
+
+func writeFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readAllowlist parses the allowlist, which holds one type/name glob per line. Empty lines and
+// lines starting with '#' are ignored, a leading '@' is allowed for readability.
+func readAllowlist(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := strings.TrimPrefix(line, "@")
+		t, _, ok := strings.Cut(p, "/")
+		if !ok {
+			return nil, fmt.Errorf("line %d: %q is not of the form type/name", n, line)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("line %d: %q: %v", n, line, err)
+		}
+		if !strings.ContainsAny(t, "*?[") {
+			if _, err := res.ParseType(t); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+		}
+		patterns = append(patterns, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// keptSymbols returns the R.txt symbols of the resources of the target which match one of the
+// patterns, sorted by type and name. Resources missing from the R.txt were not linked and are
+// skipped. A kept styleable also keeps the indices of its attributes.
+func keptSymbols(resources []*rdpb.Resource, symbols []*rtxt.Symbol, patterns []string) []*rtxt.Symbol {
+	byKey := make(map[string]*rtxt.Symbol)
+	children := make(map[string][]*rtxt.Symbol)
+	for _, s := range symbols {
+		byKey[s.Key()] = s
+		if s.Parent != "" {
+			children[s.Parent] = append(children[s.Parent], s)
+		}
+	}
+	seen := make(map[string]bool)
+	var kept []*rtxt.Symbol
+	keep := func(s *rtxt.Symbol) {
+		if !seen[s.Key()] {
+			seen[s.Key()] = true
+			kept = append(kept, s)
+		}
+	}
+	for _, r := range resources {
+		t := res.Type(r.GetResourceType()).String()
+		if !matches(patterns, t+"/"+r.GetName()) {
+			continue
+		}
+		s, ok := byKey[t+"."+r.GetName()]
+		if !ok {
+			continue
+		}
+		keep(s)
+		if s.Type == rtxt.Styleable {
+			for _, c := range children[s.Name] {
+				keep(c)
+			}
+		}
+	}
+	rtxt.Sort(kept)
+	return kept
+}
+
+func matches(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeProguard writes one -keep rule per R class nested type holding kept fields.
+func writeProguard(w io.Writer, pkg, targetLabel string, kept []*rtxt.Symbol) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Keep rules for reflectively accessed resources of %s.\n", targetLabel)
+	for i := 0; i < len(kept); {
+		t := kept[i].Type
+		fmt.Fprintf(bw, "-keep class %s.R$%s {\n", pkg, t)
+		for ; i < len(kept) && kept[i].Type == t; i++ {
+			varType := "int"
+			if kept[i].Array {
+				varType = "int[]"
+			}
+			fmt.Fprintf(bw, "  %s %s;\n", varType, kept[i].Name)
+		}
+		fmt.Fprintln(bw, "}")
+	}
+	return bw.Flush()
+}
+
+// writeResourcesKeep writes a resources XML file whose tools:keep attribute lists the kept
+// resources, for the resource shrinker. Styleables are not resources and are left out.
+func writeResourcesKeep(w io.Writer, kept []*rtxt.Symbol) error {
+	var refs []string
+	for _, s := range kept {
+		if s.Type != rtxt.Styleable {
+			refs = append(refs, fmt.Sprintf("@%s/%s", s.Type, s.Name))
+		}
+	}
+	sort.Strings(refs)
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<resources xmlns:tools="http://schemas.android.com/tools"`)
+	if len(refs) > 0 {
+		b.WriteString("\n    tools:keep=\"")
+		xml.EscapeText(&b, []byte(strings.Join(refs, ",")))
+		b.WriteString(`"`)
+	}
+	b.WriteString(" />\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,208 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keeprules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rdpb "src/tools/ak/res/proto/res_data_go_proto"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+)
+
+const testRTxt = `int drawable flag_ca 0x7f070001
+int drawable flag_us 0x7f070002
+int drawable icon 0x7f070003
+int drawable lib_flag_fr 0x7f070004
+int string label_home 0x7f0b0001
+int string title 0x7f0b0002
+int style Theme_App 0x7f0c0001
+int[] styleable Flag { 0x7f030001, 0x7f030002 }
+int styleable Flag_country 0
+int styleable Flag_size 1
+`
+
+const allowlistTxt = `# Flags are loaded by country code.
+drawable/flag_*
+@string/label_*
+style/Theme_*
+styleable/Flag
+layout/unused
+`
+
+func TestReadAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			input: allowlistTxt + "\n*/reflected\n",
+			want:  []string{"drawable/flag_*", "string/label_*", "style/Theme_*", "styleable/Flag", "layout/unused", "*/reflected"},
+		},
+		{
+			name:    "missing type",
+			input:   "flag_*\n",
+			wantErr: "line 1: \"flag_*\" is not of the form type/name",
+		},
+		{
+			name:    "unknown type",
+			input:   "# comment\ndrawables/flag_*\n",
+			wantErr: "line 2: drawables: unknown type",
+		},
+		{
+			name:    "bad pattern",
+			input:   "drawable/flag_[\n",
+			wantErr: "syntax error in pattern",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readAllowlist(strings.NewReader(tc.input))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("readAllowlist(%q) returned error %v, want error containing %q", tc.input, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readAllowlist(%q) failed: %v", tc.input, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("readAllowlist(%q) returned diff (-want, +got):\n%v", tc.input, diff)
+			}
+		})
+	}
+}
+
+func TestDoWork(t *testing.T) {
+	resources := []*rdpb.Resource{
+		{Name: "title", ResourceType: rdpb.Resource_STRING},
+		{Name: "flag_us", ResourceType: rdpb.Resource_DRAWABLE},
+		{Name: "Flag", ResourceType: rdpb.Resource_STYLEABLE},
+		{Name: "label_home", ResourceType: rdpb.Resource_STRING},
+		{Name: "flag_ca", ResourceType: rdpb.Resource_DRAWABLE},
+		{Name: "icon", ResourceType: rdpb.Resource_DRAWABLE},
+		{Name: "Theme_App", ResourceType: rdpb.Resource_STYLE},
+		// Not linked, e.g. removed by a resource filter.
+		{Name: "flag_de", ResourceType: rdpb.Resource_DRAWABLE},
+	}
+	wantProguard := `# Keep rules for reflectively accessed resources of //java/com/example:app.
+-keep class com.example.R$drawable {
+  int flag_ca;
+  int flag_us;
+}
+-keep class com.example.R$string {
+  int label_home;
+}
+-keep class com.example.R$style {
+  int Theme_App;
+}
+-keep class com.example.R$styleable {
+  int[] Flag;
+  int Flag_country;
+  int Flag_size;
+}
+`
+	wantKeep := `<?xml version="1.0" encoding="UTF-8"?>
+<resources xmlns:tools="http://schemas.android.com/tools"
+    tools:keep="@drawable/flag_ca,@drawable/flag_us,@string/label_home,@style/Theme_App" />
+`
+
+	var outputs []string
+	// The output only depends on the set of resources, not on their order.
+	for _, rs := range [][]*rdpb.Resource{resources, reversed(resources)} {
+		tmp := t.TempDir()
+		resPb := writeTemp(t, tmp, "R.pb", string(mustMarshal(t, &rdpb.Resources{Pkg: "com.example", Resource: rs})))
+		proguardOut := filepath.Join(tmp, "proguard.txt")
+		keepOut := filepath.Join(tmp, "keep.xml")
+		if err := doWork([]string{resPb}, writeTemp(t, tmp, "R.txt", testRTxt), writeTemp(t, tmp, "allowlist.txt", allowlistTxt), "", "//java/com/example:app", proguardOut, keepOut); err != nil {
+			t.Fatalf("doWork() failed: %v", err)
+		}
+		gotProguard, gotKeep := readFile(t, proguardOut), readFile(t, keepOut)
+		if diff := cmp.Diff(wantProguard, gotProguard); diff != "" {
+			t.Errorf("doWork() returned proguard rules diff (-want, +got):\n%v", diff)
+		}
+		if diff := cmp.Diff(wantKeep, gotKeep); diff != "" {
+			t.Errorf("doWork() returned resources.keep diff (-want, +got):\n%v", diff)
+		}
+		outputs = append(outputs, gotProguard+gotKeep)
+	}
+	if outputs[0] != outputs[1] {
+		t.Error("doWork() output depends on the order of the resources")
+	}
+}
+
+func TestDoWorkNothingKept(t *testing.T) {
+	tmp := t.TempDir()
+	resPb := writeTemp(t, tmp, "R.pb", string(mustMarshal(t, &rdpb.Resources{
+		Pkg:      "com.example",
+		Resource: []*rdpb.Resource{{Name: "icon", ResourceType: rdpb.Resource_DRAWABLE}},
+	})))
+	proguardOut := filepath.Join(tmp, "proguard.txt")
+	keepOut := filepath.Join(tmp, "keep.xml")
+	if err := doWork([]string{resPb}, writeTemp(t, tmp, "R.txt", testRTxt), writeTemp(t, tmp, "allowlist.txt", allowlistTxt), "com.example.override", "//:lib", proguardOut, keepOut); err != nil {
+		t.Fatalf("doWork() failed: %v", err)
+	}
+	if diff := cmp.Diff("# Keep rules for reflectively accessed resources of //:lib.\n", readFile(t, proguardOut)); diff != "" {
+		t.Errorf("doWork() returned proguard rules diff (-want, +got):\n%v", diff)
+	}
+	wantKeep := `<?xml version="1.0" encoding="UTF-8"?>
+<resources xmlns:tools="http://schemas.android.com/tools" />
+`
+	if diff := cmp.Diff(wantKeep, readFile(t, keepOut)); diff != "" {
+		t.Errorf("doWork() returned resources.keep diff (-want, +got):\n%v", diff)
+	}
+}
+
+func reversed(rs []*rdpb.Resource) []*rdpb.Resource {
+	var out []*rdpb.Resource
+	for i := len(rs) - 1; i >= 0; i-- {
+		out = append(out, rs[i])
+	}
+	return out
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatalf("proto.Marshal(%v) failed: %v", m, err)
+	}
+	return b
+}
+
+func writeTemp(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile(%s) failed: %v", p, err)
+	}
+	return p
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile(%s) failed: %v", name, err)
+	}
+	return string(b)
+}
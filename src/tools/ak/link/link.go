@@ -17,14 +17,19 @@ package link
 
 import (
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"sync"
 
 	"src/common/golang/flags"
 	"src/common/golang/walk"
 	"src/common/golang/ziputils"
+	"src/tools/ak/rclass/rclass"
+	"src/tools/ak/rtxt/rtxt"
 	"src/tools/ak/types"
 )
 
@@ -42,6 +47,8 @@ var (
 			"asset_dirs",
 			"pkg",
 			"src_jar",
+			"r_txt",
+			"r_jar",
 			"out",
 		},
 	}
@@ -53,6 +60,8 @@ var (
 	assetDirs flags.StringList
 	pkg       string
 	srcJar    string
+	rTxt      string
+	rJar      string
 	out       string
 
 	initOnce sync.Once
@@ -68,6 +77,8 @@ func Init() {
 		flag.Var(&assetDirs, "asset_dirs", "Paths to asset directories..")
 		flag.StringVar(&pkg, "pkg", "", "Package for R.java.")
 		flag.StringVar(&srcJar, "src_jar", "", "R java source jar path.")
+		flag.StringVar(&rTxt, "r_txt", "", "(optional) R.txt output path.")
+		flag.StringVar(&rJar, "r_jar", "", "(optional) Compiled R.jar output path, generated without a Java compiler.")
 		flag.StringVar(&out, "out", "", "Output path for linked archive.")
 	})
 }
@@ -83,9 +94,9 @@ func Run() {
 		manifest == "" ||
 		resDirs == nil ||
 		pkg == "" ||
-		srcJar == "" ||
+		(srcJar == "" && rJar == "") ||
 		out == "" {
-		log.Fatal("Flags -aapt2 -sdk_jar -manifest -res_dirs -pkg -src_jar and -out must be specified.")
+		log.Fatal("Flags -aapt2 -sdk_jar -manifest -res_dirs -pkg -src_jar|-r_jar and -out must be specified.")
 	}
 
 	// Note that relative order between directories needs to be respected by traversal function.
@@ -99,10 +110,26 @@ func Run() {
 	if err != nil {
 		log.Fatalf("error creating temp dir: %v", err)
 	}
+	defer os.RemoveAll(rjavaDir)
 
 	args := []string{
 		"link", "--manifest", manifest, "--auto-add-overlay", "--no-static-lib-packages",
-		"--java", rjavaDir, "--custom-package", pkg, "-I", sdkJar}
+		"--custom-package", pkg, "-I", sdkJar}
+	if srcJar != "" {
+		args = append(args, "--java", rjavaDir)
+	}
+	symbols := rTxt
+	if symbols == "" && rJar != "" {
+		symbolsDir, err := ioutil.TempDir("", "rtxt")
+		if err != nil {
+			log.Fatalf("error creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(symbolsDir)
+		symbols = filepath.Join(symbolsDir, "R.txt")
+	}
+	if symbols != "" {
+		args = append(args, "--output-text-symbols", symbols)
+	}
 
 	for _, r := range resArchives {
 		args = append(args, "-R", r)
@@ -117,7 +144,37 @@ func Run() {
 	if out, err := exec.Command(aapt2, args...).CombinedOutput(); err != nil {
 		log.Fatalf("error linking Android resources: %v\n %s", err, string(out))
 	}
-	if err := ziputils.Zip(rjavaDir, srcJar); err != nil {
-		log.Fatalf("error unable to create resources src jar: %v", err)
+	if srcJar != "" {
+		if err := ziputils.Zip(rjavaDir, srcJar); err != nil {
+			log.Fatalf("error unable to create resources src jar: %v", err)
+		}
+	}
+	if rJar != "" {
+		if err := writeRJar(symbols, pkg, rJar); err != nil {
+			log.Fatalf("error unable to create R.jar: %v", err)
+		}
+	}
+}
+
+// writeRJar compiles the R.txt symbols of the linked resources into an R.jar for pkg.
+// The fields are final, as the IDs assigned by the link are the final ones.
+func writeRJar(rTxt, pkg, rJar string) error {
+	in, err := os.Open(rTxt)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	symbols, err := rtxt.Parse(in)
+	if err != nil {
+		return fmt.Errorf("%s: %v", rTxt, err)
+	}
+	out, err := os.Create(rJar)
+	if err != nil {
+		return err
+	}
+	if err := rclass.WriteJar(out, rclass.New(pkg, symbols, true)); err != nil {
+		out.Close()
+		return err
 	}
+	return out.Close()
 }
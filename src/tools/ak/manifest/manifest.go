@@ -57,12 +57,18 @@ var (
 			"res",
 			"attr",
 			"feature_flags",
+			"placeholder",
+			"placeholders_file",
+			"edit",
 		},
 	}
 
 	// Flag variables
 	aapt2, manifest, out, sdkJar, res, feature_flags string
 	attr                                             flags.StringList
+	placeholders                                     flags.MultiString
+	placeholdersFile                                 string
+	edits                                            flags.MultiString
 	forceDebuggable                                  bool
 
 	initOnce sync.Once
@@ -79,6 +85,9 @@ func Init() {
 		flag.BoolVar(&forceDebuggable, "force_debuggable", false, "Whether to force set android:debuggable=true.")
 		flag.Var(&attr, "attr", "(optional) attr(s) to set. {element}:{attr}:{value}.")
 		flag.StringVar(&feature_flags, "feature_flags", "", "Feature flags to pass to aapt2.")
+		flag.Var(&placeholders, "placeholder", "(optional) Repeatable placeholder value to substitute. {name}={value}.")
+		flag.StringVar(&placeholdersFile, "placeholders_file", "", "(optional) Path to a file of {name}={value} placeholder values.")
+		flag.Var(&edits, "edit", "(optional) Repeatable edit applied in order: set:{path}@{attr}={value}, add:{path}@{attr}={value}, remove:{path}[@{attr}] or insert:{path}={xml}, where {path} selects elements, e.g. application/activity[@android:name=.Main].")
 	})
 }
 
@@ -98,14 +107,28 @@ func Run() {
 	}
 	defer os.Remove(aaptOut.Name())
 
+	b, err := ioutil.ReadFile(manifest)
+	if err != nil {
+		log.Fatalf("Failed to read manifest: %v", err)
+	}
+	values, err := manifestutils.LoadPlaceholders(placeholdersFile, placeholders)
+	if err != nil {
+		log.Fatalf("Failed to read placeholders: %v", err)
+	}
+	// Placeholders left unresolved fail, whether or not values are given.
+	expanded, err := manifestutils.ExpandManifest(b, values)
+	if err != nil {
+		log.Fatalf("Failed to expand placeholders: %v", err)
+	}
+
 	manifestPath := manifest
-	if len(attr) > 0 {
+	if len(attr) > 0 || len(edits) > 0 || !bytes.Equal(expanded, b) {
 		patchedManifest, err := ioutil.TempFile("", "AndroidManifest_patched.xml")
 		if err != nil {
 			log.Fatalf("Creating temp file failed: %v", err)
 		}
 		defer os.Remove(patchedManifest.Name())
-		manifestPath = patchManifest(manifest, patchedManifest, attr)
+		manifestPath = patchManifest(expanded, patchedManifest, attr)
 	}
 	args := []string{"link", "-o", aaptOut.Name(), "--manifest", manifestPath, "-I", sdkJar, "-I", res}
 	if feature_flags != "" {
@@ -155,13 +178,14 @@ func Run() {
 	}
 }
 
-func patchManifest(manifest string, patchedManifest *os.File, attrs []string) string {
-	b, err := ioutil.ReadFile(manifest)
-	if err != nil {
-		log.Fatalf("Failed to read manifest: %v", err)
+func patchManifest(b []byte, patchedManifest *os.File, attrs []string) string {
+	if len(edits) > 0 {
+		var err error
+		if b, err = manifestutils.EditManifest(b, edits); err != nil {
+			log.Fatalf("Failed to edit manifest: %v", err)
+		}
 	}
-	err = manifestutils.WriteManifest(patchedManifest, bytes.NewReader(b), manifestutils.CreatePatchElements(attrs))
-	if err != nil {
+	if err := manifestutils.WriteManifest(patchedManifest, bytes.NewReader(b), manifestutils.CreatePatchElements(attrs)); err != nil {
 		log.Fatalf("Failed to update manifest: %v", err)
 	}
 	return patchedManifest.Name()
@@ -0,0 +1,234 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestdiff
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"src/common/golang/xml2"
+	"src/tools/ak/manifestutils"
+	"src/tools/ak/res/binres/binres"
+)
+
+const (
+	apkManifest = "AndroidManifest.xml"
+	apkTable    = "resources.arsc"
+)
+
+// readManifest reads the manifest of the file, which is either an AndroidManifest.xml, compiled
+// or not, or an APK.
+func readManifest(name string) (*manifestutils.Manifest, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseManifest(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return m, nil
+}
+
+func parseManifest(b []byte) (*manifestutils.Manifest, error) {
+	var names map[uint32]string
+	if bytes.HasPrefix(b, []byte("PK\x03\x04")) {
+		var err error
+		if b, names, err = readAPK(b); err != nil {
+			return nil, err
+		}
+	}
+	if binres.IsXML(b) {
+		root, err := binres.ReadXML(b)
+		if err != nil {
+			return nil, err
+		}
+		if b, err = decompile(root, names); err != nil {
+			return nil, err
+		}
+	}
+	return manifestutils.ReadManifest(bytes.NewReader(b))
+}
+
+// readAPK returns the compiled manifest of the APK, along with the names of its resources by ID.
+func readAPK(b []byte) ([]byte, map[uint32]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := readZipFile(zr, apkManifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("APK has no %s", apkManifest)
+	}
+	names := make(map[uint32]string)
+	tb, err := readZipFile(zr, apkTable)
+	if err != nil || tb == nil {
+		return manifest, names, err
+	}
+	t, err := binres.ReadTable(tb)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range t.Resources() {
+		names[r.ID] = r.Type + "/" + r.Name
+	}
+	return manifest, names, nil
+}
+
+// readZipFile returns the content of the named file of the zip, or nil if missing.
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		var b bytes.Buffer
+		if _, err := b.ReadFrom(rc); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return b.Bytes(), nil
+	}
+	return nil, nil
+}
+
+// decompile turns the compiled XML tree rooted at root back into XML. References are written by
+// resource name when known.
+func decompile(root *binres.Element, names map[uint32]string) ([]byte, error) {
+	var b bytes.Buffer
+	enc := xml2.NewEncoder(&b)
+	enc.SelfCloseEmpty()
+	var encode func(e *binres.Element) error
+	encode = func(e *binres.Element) error {
+		start := xml.StartElement{Name: xml.Name{Space: e.NS, Local: e.Name}}
+		for _, ns := range e.Namespaces {
+			name := xml.Name{Space: "xmlns", Local: ns.Prefix}
+			if ns.Prefix == "" {
+				name = xml.Name{Local: "xmlns"}
+			}
+			start.Attr = append(start.Attr, xml.Attr{Name: name, Value: ns.URI})
+		}
+		for _, a := range e.Attrs {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Space: a.NS, Local: a.Name}, Value: formatValue(a, names)})
+		}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		if e.Text != "" {
+			if err := enc.EncodeToken(xml.CharData(e.Text)); err != nil {
+				return err
+			}
+		}
+		for _, c := range e.Children {
+			if err := encode(c); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	}
+	if err := encode(root); err != nil {
+		return nil, fmt.Errorf("compiled XML: %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// protectionLevelAttr is the resource ID of android:protectionLevel, which aapt2 compiles to flags.
+const protectionLevelAttr = 0x01010009
+
+// protectionLevels are the base protection levels, by value.
+var protectionLevels = []string{"normal", "dangerous", "signature", "signatureOrSystem", "internal"}
+
+// protectionFlags are the flags of protection levels, by bit.
+var protectionFlags = []struct {
+	bit  uint32
+	name string
+}{
+	{0x10, "privileged"},
+	{0x20, "development"},
+	{0x40, "appop"},
+	{0x80, "pre23"},
+	{0x100, "installer"},
+	{0x200, "verifier"},
+	{0x400, "preinstalled"},
+	{0x800, "setup"},
+	{0x1000, "instant"},
+	{0x2000, "runtime"},
+}
+
+// formatProtectionLevel returns the flags of a compiled protection level as written in source
+// manifests, e.g. signature|privileged.
+func formatProtectionLevel(v uint32) string {
+	base := v & 0xf
+	if int(base) >= len(protectionLevels) {
+		return fmt.Sprintf("0x%x", v)
+	}
+	parts := []string{protectionLevels[base]}
+	v &^= 0xf
+	for _, f := range protectionFlags {
+		if v&f.bit != 0 {
+			parts = append(parts, f.name)
+			v &^= f.bit
+		}
+	}
+	if v != 0 {
+		parts = append(parts, fmt.Sprintf("0x%x", v))
+	}
+	return strings.Join(parts, "|")
+}
+
+// formatValue returns the value of a compiled attribute as written in source manifests.
+func formatValue(a *binres.Attr, names map[uint32]string) string {
+	v := a.Value
+	if a.ResID == protectionLevelAttr && (v.Type == binres.TypeIntHex || v.Type == binres.TypeIntDec) {
+		return formatProtectionLevel(v.Data)
+	}
+	switch v.Type {
+	case binres.TypeString:
+		return a.Raw
+	case binres.TypeIntBoolean:
+		return strconv.FormatBool(v.Data != 0)
+	case binres.TypeIntDec:
+		return strconv.Itoa(int(int32(v.Data)))
+	case binres.TypeIntHex:
+		return fmt.Sprintf("0x%x", v.Data)
+	case binres.TypeReference, binres.TypeDynamicReference, binres.TypeAttribute, binres.TypeDynamicAttribute:
+		prefix := "@"
+		if v.Type == binres.TypeAttribute || v.Type == binres.TypeDynamicAttribute {
+			prefix = "?"
+		}
+		if name, ok := names[v.Data]; ok {
+			return prefix + name
+		}
+		return fmt.Sprintf("%s0x%08x", prefix, v.Data)
+	}
+	if a.Raw != "" {
+		return a.Raw
+	}
+	return fmt.Sprintf("0x%08x", v.Data)
+}
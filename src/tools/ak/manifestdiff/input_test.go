@@ -0,0 +1,191 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestdiff
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"src/tools/ak/res/binres/binres"
+	"github.com/google/go-cmp/cmp"
+)
+
+const androidNS = "http://schemas.android.com/apk/res/android"
+
+// compiledManifest is oldManifest as compiled by aapt2, with @string/app_name as 0x7f010000.
+func compiledManifest(t *testing.T) []byte {
+	t.Helper()
+	str := func(name string, resID uint32, value string) *binres.Attr {
+		return &binres.Attr{NS: androidNS, Name: name, ResID: resID, Raw: value, Value: binres.Value{Type: binres.TypeString}}
+	}
+	named := func(elem, name string, attrs ...*binres.Attr) *binres.Element {
+		return &binres.Element{Name: elem, Attrs: append([]*binres.Attr{str("name", 0x01010003, name)}, attrs...)}
+	}
+	exported := func(v bool) *binres.Attr {
+		a := &binres.Attr{NS: androidNS, Name: "exported", ResID: 0x01010010, Value: binres.Value{Type: binres.TypeIntBoolean}}
+		if v {
+			a.Value.Data = 0xffffffff
+		}
+		return a
+	}
+	sdk := func(name string, resID, v uint32) *binres.Attr {
+		return &binres.Attr{NS: androidNS, Name: name, ResID: resID, Value: binres.Value{Type: binres.TypeIntDec, Data: v}}
+	}
+	root := &binres.Element{
+		Name:       "manifest",
+		Namespaces: []binres.Namespace{{Prefix: "android", URI: androidNS}},
+		Attrs: []*binres.Attr{
+			sdk("versionCode", 0x0101021b, 1),
+			{Name: "package", Raw: "com.example", Value: binres.Value{Type: binres.TypeString}},
+		},
+		Children: []*binres.Element{
+			{Name: "uses-sdk", Attrs: []*binres.Attr{sdk("minSdkVersion", 0x0101020c, 21), sdk("targetSdkVersion", 0x01010270, 33)}},
+			named("uses-permission", "android.permission.INTERNET"),
+			named("permission", "com.example.READ", &binres.Attr{NS: androidNS, Name: "protectionLevel", ResID: 0x01010009, Value: binres.Value{Type: binres.TypeIntHex, Data: 2}}),
+			{
+				Name:  "application",
+				Attrs: []*binres.Attr{{NS: androidNS, Name: "label", ResID: 0x01010001, Value: binres.Value{Type: binres.TypeReference, Data: 0x7f010000}}},
+				Children: []*binres.Element{
+					{
+						Name:  "activity",
+						Attrs: []*binres.Attr{str("name", 0x01010003, "com.example.Main"), exported(true)},
+						Children: []*binres.Element{{Name: "intent-filter", Children: []*binres.Element{
+							named("action", "android.intent.action.MAIN"),
+							named("category", "android.intent.category.LAUNCHER"),
+						}}},
+					},
+					named("activity", "com.example.Settings"),
+					named("service", "com.example.Sync", exported(true)),
+					named("provider", "com.example.Files", str("authorities", 0x01010018, "com.example.files")),
+				},
+			},
+		},
+	}
+	b, err := binres.EncodeXML(root)
+	if err != nil {
+		t.Fatalf("EncodeXML() failed: %v", err)
+	}
+	return b
+}
+
+func testTable(t *testing.T) []byte {
+	t.Helper()
+	table := &binres.Table{
+		Strings: &binres.StringPool{UTF8: true, Strings: []string{"Example"}},
+		Packages: []*binres.Package{{
+			ID:   0x7f,
+			Name: "com.example",
+			Types: []*binres.Type{{ID: 1, Name: "string", Specs: []uint32{0}, Configs: []*binres.Config{{
+				Entries: []*binres.Entry{{Key: "app_name", Value: binres.Value{Type: binres.TypeString}}},
+			}}}},
+		}},
+	}
+	b, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %v", err)
+	}
+	return b
+}
+
+func testAPK(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, f := range []struct {
+		name    string
+		content []byte
+	}{
+		{apkManifest, compiledManifest(t)},
+		{apkTable, testTable(t)},
+		{"classes.dex", []byte("dex")},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(f.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name    string
+		content []byte
+		label   string
+	}{
+		{"AndroidManifest.xml", []byte(oldManifest), "@string/app_name"},
+		{"compiled.xml", compiledManifest(t), "@0x7f010000"},
+		{"app.apk", testAPK(t), "@string/app_name"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(dir, tc.name)
+			if err := os.WriteFile(file, tc.content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			m, err := readManifest(file)
+			if err != nil {
+				t.Fatalf("readManifest(%s) failed: %v", tc.name, err)
+			}
+			if got := m.Application.Label; got != tc.label {
+				t.Errorf("readManifest(%s) read label %q, want %q", tc.name, got, tc.label)
+			}
+			if diff := cmp.Diff([]Change(nil), Diff(readString(t, oldManifest), m)); diff != "" {
+				t.Errorf("Diff with the source manifest returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestFormatProtectionLevel(t *testing.T) {
+	for _, tc := range []struct {
+		v    uint32
+		want string
+	}{
+		{0, "normal"},
+		{2, "signature"},
+		{0x12, "signature|privileged"},
+		{0x10042, "signature|appop|0x10000"},
+		{0xf, "0xf"},
+	} {
+		if got := formatProtectionLevel(tc.v); got != tc.want {
+			t.Errorf("formatProtectionLevel(0x%x) = %q, want %q", tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestReadManifestErrors(t *testing.T) {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	if _, err := zw.Create("classes.dex"); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	if _, err := parseManifest(b.Bytes()); err == nil {
+		t.Errorf("parseManifest of an APK without manifest succeeded, want error")
+	}
+	if _, err := parseManifest(compiledManifest(t)[:40]); err == nil {
+		t.Errorf("parseManifest of a truncated compiled manifest succeeded, want error")
+	}
+}
@@ -0,0 +1,432 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package manifestdiff reports the semantic changes between two AndroidManifest.xml files, e.g.
+// added permissions or newly exported components, for release review.
+package manifestdiff
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"src/tools/ak/manifestutils"
+	"src/tools/ak/sdklevel"
+	"src/tools/ak/types"
+)
+
+var (
+	// Cmd defines the command to run manifestdiff.
+	Cmd = types.Command{
+		Init:  Init,
+		Run:   Run,
+		Desc:  desc,
+		Flags: []string{"out"},
+	}
+
+	// Variables to hold flag values.
+	out string
+
+	initOnce sync.Once
+)
+
+// Init initializes manifestdiff.
+func Init() {
+	initOnce.Do(func() {
+		flag.StringVar(&out, "out", "", "(optional) Path to write the report to, defaults to stdout.")
+	})
+}
+
+func desc() string {
+	return "manifestdiff reports the semantic changes between two manifests: ak manifestdiff <old> <new>"
+}
+
+// Run is the entry point for manifestdiff. Will exit on error.
+func Run() {
+	if flag.NArg() != 2 {
+		log.Fatal("The old and new manifests must be specified, e.g. ak manifestdiff old.xml new.xml.")
+	}
+	if err := doWork(flag.Arg(0), flag.Arg(1), out); err != nil {
+		log.Fatalf("error diffing manifests: %v", err)
+	}
+}
+
+func doWork(oldFile, newFile, out string) error {
+	o, err := readManifest(oldFile)
+	if err != nil {
+		return err
+	}
+	n, err := readManifest(newFile)
+	if err != nil {
+		return err
+	}
+	changes := Diff(o, n)
+	if out == "" {
+		return WriteText(os.Stdout, changes)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := WriteText(f, changes); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Kind is the kind of a change.
+type Kind string
+
+// Kinds of changes.
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Change is a semantic difference between two manifests.
+type Change struct {
+	Kind Kind
+	// Subject is what changed, e.g. "uses-permission android.permission.CAMERA" or
+	// "activity com.example.Main".
+	Subject string
+	// Detail describes the change, e.g. "now exported", or is empty.
+	Detail string
+}
+
+// String formats the change as a report line, prefixed by +, - or ~.
+func (c Change) String() string {
+	prefix := map[Kind]string{Added: "+", Removed: "-", Changed: "~"}[c.Kind]
+	if c.Detail == "" {
+		return prefix + " " + c.Subject
+	}
+	return prefix + " " + c.Subject + ": " + c.Detail
+}
+
+// WriteText writes the changes to w, one per line.
+func WriteText(w io.Writer, changes []Change) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "no semantic changes")
+		return err
+	}
+	for _, c := range changes {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Diff returns the semantic changes from the manifest o to the manifest n: changes to the package
+// and version, the SDK levels, the requested and declared permissions, and the components.
+//
+// Components are matched by their fully qualified name, providers by their authorities too. The
+// order of elements and attributes, formatting and relative class names are ignored.
+func Diff(o, n *manifestutils.Manifest) []Change {
+	d := &differ{}
+	d.attr("package", o.Package, n.Package)
+	d.attr("versionCode", o.VersionCode, n.VersionCode)
+	d.attr("versionName", o.VersionName, n.VersionName)
+	d.sdk(o, n)
+	d.permissions(o, n)
+	d.components(o, n)
+	return d.changes
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(kind Kind, subject, format string, args ...any) {
+	d.changes = append(d.changes, Change{Kind: kind, Subject: subject, Detail: fmt.Sprintf(format, args...)})
+}
+
+// attr reports a changed attribute value, unset values shown as "unset".
+func (d *differ) attr(subject, o, n string) {
+	if o == n {
+		return
+	}
+	d.add(Changed, subject, "%s -> %s", orUnset(o), orUnset(n))
+}
+
+func orUnset(v string) string {
+	if v == "" {
+		return "unset"
+	}
+	return v
+}
+
+func (d *differ) sdk(o, n *manifestutils.Manifest) {
+	ov, nv := sdkVersions(o), sdkVersions(n)
+	for i, name := range []string{"minSdkVersion", "targetSdkVersion", "maxSdkVersion"} {
+		d.attr(name, ov[i], nv[i])
+	}
+}
+
+// sdkVersions returns the effective min, target and max SDK versions of m, normalized.
+func sdkVersions(m *manifestutils.Manifest) [3]string {
+	var v [3]string
+	if m.UsesSdk != nil {
+		v = [3]string{m.UsesSdk.MinSdkVersion, m.UsesSdk.TargetSdkVersion, m.UsesSdk.MaxSdkVersion}
+	}
+	if v[0] == "" {
+		v[0] = "1"
+	}
+	if v[1] == "" {
+		v[1] = v[0]
+	}
+	for i, s := range v {
+		if l, err := sdklevel.Parse(s); err == nil && !l.IsZero() {
+			v[i] = l.String()
+		}
+	}
+	return v
+}
+
+func (d *differ) permissions(o, n *manifestutils.Manifest) {
+	for _, kind := range []struct {
+		name     string
+		old, new []*manifestutils.UsesPermission
+	}{
+		{"uses-permission", o.UsesPermissions, n.UsesPermissions},
+		{"uses-permission-sdk-23", o.UsesPermissionsSdk23, n.UsesPermissionsSdk23},
+	} {
+		nameOf := func(p *manifestutils.UsesPermission) string { return p.Name }
+		om, nm := byName(kind.old, nameOf), byName(kind.new, nameOf)
+		for _, name := range keys(om, nm) {
+			subject := kind.name + " " + name
+			op, nop := om[name], nm[name]
+			switch {
+			case op == nil:
+				d.add(Added, subject, "")
+			case nop == nil:
+				d.add(Removed, subject, "")
+			default:
+				d.attr(subject+" maxSdkVersion", op.MaxSdkVersion, nop.MaxSdkVersion)
+			}
+		}
+	}
+	nameOf := func(p *manifestutils.Permission) string { return p.Name }
+	om, nm := byName(o.Permissions, nameOf), byName(n.Permissions, nameOf)
+	for _, name := range keys(om, nm) {
+		subject := "permission " + name
+		op, nop := om[name], nm[name]
+		switch {
+		case op == nil:
+			d.add(Added, subject, "protectionLevel %s", orUnset(nop.ProtectionLevel))
+		case nop == nil:
+			d.add(Removed, subject, "")
+		default:
+			d.attr(subject+" protectionLevel", op.ProtectionLevel, nop.ProtectionLevel)
+		}
+	}
+}
+
+// byName maps the elements by their name.
+func byName[T any](elems []T, name func(T) string) map[string]T {
+	m := make(map[string]T)
+	for _, e := range elems {
+		m[name(e)] = e
+	}
+	return m
+}
+
+// keys returns the sorted keys of both maps.
+func keys[T any](a, b map[string]T) []string {
+	var r []string
+	for k := range a {
+		r = append(r, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			r = append(r, k)
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+func (d *differ) components(o, n *manifestutils.Manifest) {
+	oa, na := o.Application, n.Application
+	if oa == nil {
+		oa = &manifestutils.Application{}
+	}
+	if na == nil {
+		na = &manifestutils.Application{}
+	}
+	for _, kind := range []struct {
+		name     string
+		old, new []*manifestutils.Component
+	}{
+		{"activity", oa.Activities, na.Activities},
+		{"activity-alias", oa.ActivityAliases, na.ActivityAliases},
+		{"service", oa.Services, na.Services},
+		{"receiver", oa.Receivers, na.Receivers},
+		{"provider", oa.Providers, na.Providers},
+	} {
+		om, nm := components(o.Package, kind.old), components(n.Package, kind.new)
+		if kind.name == "provider" {
+			matchAuthorities(om, nm)
+		}
+		for _, name := range keys(om, nm) {
+			subject := kind.name + " " + name
+			oc, nc := om[name], nm[name]
+			switch {
+			case oc == nil:
+				if exported(nc) {
+					d.add(Added, subject, "exported")
+				} else {
+					d.add(Added, subject, "")
+				}
+			case nc == nil:
+				d.add(Removed, subject, "")
+			default:
+				d.component(subject, o.Package, n.Package, oc, nc)
+			}
+		}
+	}
+}
+
+func (d *differ) component(subject, oldPkg, newPkg string, oc, nc *manifestutils.Component) {
+	if on, nn := className(oldPkg, oc.Name), className(newPkg, nc.Name); on != nn {
+		d.attr(subject+" name", on, nn)
+	}
+	switch oe, ne := exported(oc), exported(nc); {
+	case !oe && ne:
+		d.add(Changed, subject, "now exported")
+	case oe && !ne:
+		d.add(Changed, subject, "no longer exported")
+	}
+	d.attr(subject+" permission", oc.Permission, nc.Permission)
+	d.attr(subject+" authorities", authorities(oc), authorities(nc))
+	if oc.TargetActivity != "" || nc.TargetActivity != "" {
+		d.attr(subject+" targetActivity", className(oldPkg, oc.TargetActivity), className(newPkg, nc.TargetActivity))
+	}
+	of, nf := intentFilters(oc), intentFilters(nc)
+	for _, f := range keys(of, nf) {
+		for i := nf[f]; i < of[f]; i++ {
+			d.add(Changed, subject, "removed intent-filter %s", f)
+		}
+		for i := of[f]; i < nf[f]; i++ {
+			d.add(Changed, subject, "added intent-filter %s", f)
+		}
+	}
+}
+
+// components maps the components by their fully qualified class name.
+func components(pkg string, comps []*manifestutils.Component) map[string]*manifestutils.Component {
+	m := make(map[string]*manifestutils.Component)
+	for _, c := range comps {
+		m[className(pkg, c.Name)] = c
+	}
+	return m
+}
+
+// matchAuthorities keys the providers of n that only match a provider of o by their authorities,
+// e.g. renamed providers, like the provider of o.
+func matchAuthorities(o, n map[string]*manifestutils.Component) {
+	byAuthorities := make(map[string]string)
+	for name, c := range o {
+		if _, ok := n[name]; !ok && authorities(c) != "" {
+			byAuthorities[authorities(c)] = name
+		}
+	}
+	for name, c := range n {
+		if _, ok := o[name]; ok {
+			continue
+		}
+		if oldName, ok := byAuthorities[authorities(c)]; ok {
+			delete(n, name)
+			n[oldName] = c
+			delete(byAuthorities, authorities(c))
+		}
+	}
+}
+
+// className returns the fully qualified name of the class, which may be relative to the package.
+func className(pkg, name string) string {
+	switch {
+	case strings.HasPrefix(name, "."):
+		return pkg + name
+	case name != "" && !strings.Contains(name, "."):
+		return pkg + "." + name
+	}
+	return name
+}
+
+// exported reports whether the component is exported, which defaults to whether it has intent
+// filters.
+func exported(c *manifestutils.Component) bool {
+	if c.Exported != "" {
+		return c.Exported == "true"
+	}
+	return len(c.IntentFilters) > 0
+}
+
+// authorities returns the sorted authorities of a provider, separated by semicolons.
+func authorities(c *manifestutils.Component) string {
+	if c.Authorities == "" {
+		return ""
+	}
+	a := strings.Split(c.Authorities, ";")
+	sort.Strings(a)
+	return strings.Join(a, ";")
+}
+
+// intentFilters counts the intent filters of the component by their canonical form, in which
+// actions, categories and data are sorted.
+func intentFilters(c *manifestutils.Component) map[string]int {
+	m := make(map[string]int)
+	for _, f := range c.IntentFilters {
+		var parts []string
+		for _, a := range f.Actions {
+			parts = append(parts, "action="+a.Name)
+		}
+		for _, cat := range f.Categories {
+			parts = append(parts, "category="+cat.Name)
+		}
+		for _, data := range f.Data {
+			var attrs []string
+			for _, kv := range [][2]string{
+				{"scheme", data.Scheme},
+				{"host", data.Host},
+				{"port", data.Port},
+				{"path", data.Path},
+				{"pathPrefix", data.PathPrefix},
+				{"pathPattern", data.PathPattern},
+				{"mimeType", data.MimeType},
+			} {
+				if kv[1] != "" {
+					attrs = append(attrs, kv[0]+"="+kv[1])
+				}
+			}
+			parts = append(parts, "data("+strings.Join(attrs, ",")+")")
+		}
+		sort.Strings(parts)
+		if f.Priority != "" {
+			parts = append(parts, "priority="+f.Priority)
+		}
+		if f.AutoVerify != "" {
+			parts = append(parts, "autoVerify="+f.AutoVerify)
+		}
+		m["["+strings.Join(parts, " ")+"]"]++
+	}
+	return m
+}
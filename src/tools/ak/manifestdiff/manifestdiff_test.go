@@ -0,0 +1,184 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestdiff
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"src/tools/ak/manifestutils"
+	"github.com/google/go-cmp/cmp"
+)
+
+const oldManifest = `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" android:versionCode="1">
+    <uses-sdk android:minSdkVersion="21" android:targetSdkVersion="33"/>
+    <uses-permission android:name="android.permission.INTERNET"/>
+    <permission android:name="com.example.READ" android:protectionLevel="signature"/>
+    <application android:label="@string/app_name">
+        <activity android:name=".Main" android:exported="true">
+            <intent-filter>
+                <action android:name="android.intent.action.MAIN"/>
+                <category android:name="android.intent.category.LAUNCHER"/>
+            </intent-filter>
+        </activity>
+        <activity android:name=".Settings"/>
+        <service android:name=".Sync" android:exported="true"/>
+        <provider android:name=".Files" android:authorities="com.example.files"/>
+    </application>
+</manifest>
+`
+
+func readString(t *testing.T, s string) *manifestutils.Manifest {
+	t.Helper()
+	m, err := manifestutils.ReadManifest(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	return m
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		new  string
+		want []Change
+	}{
+		{
+			name: "formatting and ordering",
+			new: `<manifest package="com.example" android:versionCode="1" xmlns:android="http://schemas.android.com/apk/res/android">
+  <permission android:protectionLevel="signature" android:name="com.example.READ"></permission>
+  <uses-permission android:name="android.permission.INTERNET"></uses-permission>
+  <uses-sdk android:targetSdkVersion="33" android:minSdkVersion="21"/>
+  <application android:label="@string/app_name">
+    <provider android:authorities="com.example.files" android:name="com.example.Files"/>
+    <service android:exported="true" android:name="com.example.Sync"/>
+    <activity android:name="com.example.Settings"/>
+    <activity android:exported="true" android:name="com.example.Main">
+      <intent-filter><category android:name="android.intent.category.LAUNCHER"/><action android:name="android.intent.action.MAIN"/></intent-filter>
+    </activity>
+  </application>
+</manifest>`,
+		},
+		{
+			name: "version and sdk",
+			new: strings.NewReplacer(
+				`android:versionCode="1"`, `android:versionCode="2" android:versionName="2.0"`,
+				`android:targetSdkVersion="33"`, `android:targetSdkVersion="34" android:maxSdkVersion="35"`,
+			).Replace(oldManifest),
+			want: []Change{
+				{Changed, "versionCode", "1 -> 2"},
+				{Changed, "versionName", "unset -> 2.0"},
+				{Changed, "targetSdkVersion", "33 -> 34"},
+				{Changed, "maxSdkVersion", "unset -> 35"},
+			},
+		},
+		{
+			name: "permissions",
+			new: strings.NewReplacer(
+				`android.permission.INTERNET"/>`, `android.permission.CAMERA"/>`,
+				`android:protectionLevel="signature"`, `android:protectionLevel="normal"`,
+			).Replace(oldManifest),
+			want: []Change{
+				{Added, "uses-permission android.permission.CAMERA", ""},
+				{Removed, "uses-permission android.permission.INTERNET", ""},
+				{Changed, "permission com.example.READ protectionLevel", "signature -> normal"},
+			},
+		},
+		{
+			name: "exported components",
+			new: strings.NewReplacer(
+				`<activity android:name=".Settings"/>`, `<activity android:name=".Settings"><intent-filter><action android:name="com.example.SETTINGS"/></intent-filter></activity>`,
+				`<service android:name=".Sync" android:exported="true"/>`, `<service android:name=".Sync" android:exported="false"/><receiver android:name=".Boot" android:exported="true"/>`,
+			).Replace(oldManifest),
+			want: []Change{
+				{Changed, "activity com.example.Settings", "now exported"},
+				{Changed, "activity com.example.Settings", "added intent-filter [action=com.example.SETTINGS]"},
+				{Changed, "service com.example.Sync", "no longer exported"},
+				{Added, "receiver com.example.Boot", "exported"},
+			},
+		},
+		{
+			name: "intent filters",
+			new: strings.Replace(oldManifest, `<category android:name="android.intent.category.LAUNCHER"/>`,
+				`<category android:name="android.intent.category.DEFAULT"/><data android:scheme="https" android:host="example.com"/>`, 1),
+			want: []Change{
+				{Changed, "activity com.example.Main", "added intent-filter [action=android.intent.action.MAIN category=android.intent.category.DEFAULT data(scheme=https,host=example.com)]"},
+				{Changed, "activity com.example.Main", "removed intent-filter [action=android.intent.action.MAIN category=android.intent.category.LAUNCHER]"},
+			},
+		},
+		{
+			name: "renamed provider",
+			new:  strings.Replace(oldManifest, `android:name=".Files"`, `android:name=".FileProvider" android:permission="com.example.READ"`, 1),
+			want: []Change{
+				{Changed, "provider com.example.Files name", "com.example.Files -> com.example.FileProvider"},
+				{Changed, "provider com.example.Files permission", "unset -> com.example.READ"},
+			},
+		},
+		{
+			name: "removed components",
+			new:  strings.Replace(oldManifest, `<activity android:name=".Settings"/>`, "", 1),
+			want: []Change{{Removed, "activity com.example.Settings", ""}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Diff(readString(t, oldManifest), readString(t, tc.new))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Diff returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestSdkVersionsDefaults(t *testing.T) {
+	o := readString(t, `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="p"><uses-sdk android:minSdkVersion="21"/></manifest>`)
+	n := readString(t, `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="p"><uses-sdk android:minSdkVersion="21" android:targetSdkVersion="21"/></manifest>`)
+	if got := Diff(o, n); len(got) != 0 {
+		t.Errorf("Diff of implicit and explicit targetSdkVersion = %v, want no changes", got)
+	}
+}
+
+func TestDoWork(t *testing.T) {
+	dir := t.TempDir()
+	oldFile, newFile, out := filepath.Join(dir, "old.xml"), filepath.Join(dir, "new.xml"), filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(oldFile, []byte(oldManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	newManifest := strings.Replace(oldManifest, `<uses-permission android:name="android.permission.INTERNET"/>`, `<uses-permission android:name="android.permission.INTERNET"/><uses-permission android:name="android.permission.CAMERA"/>`, 1)
+	if err := os.WriteFile(newFile, []byte(newManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		old, new string
+		want     string
+	}{
+		{oldFile, newFile, "+ uses-permission android.permission.CAMERA\n"},
+		{oldFile, oldFile, "no semantic changes\n"},
+	} {
+		if err := doWork(tc.old, tc.new, out); err != nil {
+			t.Fatalf("doWork(%s, %s) failed: %v", tc.old, tc.new, err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+			t.Errorf("doWork(%s, %s) wrote diff (-want, +got):\n%v", tc.old, tc.new, diff)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package manifestlint checks an AndroidManifest.xml against build policies, e.g. no debuggable
+// release builds or no unreviewed dangerous permissions.
+package manifestlint
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"src/common/golang/flags"
+	"src/tools/ak/fixit"
+	"src/tools/ak/manifestutils"
+	"src/tools/ak/sdklevel"
+	"src/tools/ak/types"
+)
+
+var (
+	// Cmd defines the command to run manifestlint.
+	Cmd = types.Command{
+		Init:  Init,
+		Run:   Run,
+		Desc:  desc,
+		Flags: []string{"manifest", "release", "min_sdk_floor", "allowed_permissions", "disable", "placeholder", "placeholders_file", "out", "sarif_out", "label", "fixes_out"},
+	}
+
+	// Variables to hold flag values.
+	manifest           string
+	release            bool
+	minSdkFloor        int
+	allowedPermissions flags.StringList
+	disable            flags.StringList
+	placeholders       flags.MultiString
+	placeholdersFile   string
+	out                string
+	sarifOut           string
+	label              string
+	fixesOut           string
+
+	initOnce sync.Once
+)
+
+// Init initializes manifestlint.
+func Init() {
+	initOnce.Do(func() {
+		flag.StringVar(&manifest, "manifest", "", "AndroidManifest.xml to check.")
+		flag.BoolVar(&release, "release", false, "Whether the manifest is built for a release.")
+		flag.IntVar(&minSdkFloor, "min_sdk_floor", 0, "(optional) Lowest minSdkVersion allowed, as enforced by minsdkfloor.")
+		flag.Var(&allowedPermissions, "allowed_permissions", "(optional) Dangerous permissions the manifest may request.")
+		flag.Var(&disable, "disable", "(optional) Rules to skip, e.g. cleartext_traffic.")
+		flag.Var(&placeholders, "placeholder", "Repeatable placeholder value to substitute: {name}={value}")
+		flag.StringVar(&placeholdersFile, "placeholders_file", "", "Path to a file of {name}={value} placeholder values")
+		flag.StringVar(&out, "out", "", "(optional) Path to write the text report to, defaults to stderr.")
+		flag.StringVar(&sarifOut, "sarif_out", "", "(optional) Path to write the SARIF report to.")
+		flag.StringVar(&label, "label", "", "(optional) Label of the target, for fix-it suggestions.")
+		flag.StringVar(&fixesOut, "fixes_out", "", "(optional) Path to write the JSON fix-it suggestions to.")
+	})
+}
+
+func desc() string {
+	return "manifestlint checks an AndroidManifest.xml against build policies"
+}
+
+// Run is the entry point for manifestlint. Will exit on error or if the manifest violates a policy.
+func Run() {
+	if manifest == "" {
+		log.Fatal("Flag -manifest must be specified.")
+	}
+	p := Policy{
+		Release:            release,
+		MinSdkFloor:        minSdkFloor,
+		AllowedPermissions: allowedPermissions,
+		Disabled:           disable,
+	}
+	findings, err := doWork(manifest, p, placeholders, placeholdersFile, out, sarifOut, label, fixesOut)
+	if err != nil {
+		log.Fatalf("error linting manifest: %v", err)
+	}
+	if n := errorCount(findings); n > 0 {
+		log.Fatalf("%s violates %d manifest policies", manifest, n)
+	}
+}
+
+func doWork(manifest string, p Policy, placeholders []string, placeholdersFile, out, sarifOut, label, fixesOut string) ([]Finding, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if len(placeholders) > 0 || placeholdersFile != "" {
+		values, err := manifestutils.LoadPlaceholders(placeholdersFile, placeholders)
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		// Expansion only rewrites attribute values, line numbers are kept.
+		if b, err = manifestutils.ExpandManifest(b, values); err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	m, err := manifestutils.ReadManifest(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", manifest, err)
+	}
+	findings, err := Lint(m, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if out == "" {
+		err = WriteText(os.Stderr, manifest, findings)
+	} else {
+		err = writeFile(out, func(w io.Writer) error { return WriteText(w, manifest, findings) })
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sarifOut != "" {
+		if err := writeFile(sarifOut, func(w io.Writer) error { return WriteSARIF(w, manifest, findings) }); err != nil {
+			return nil, err
+		}
+	}
+	fixes := Fixes(m, p)
+	for i := range fixes {
+		fixes[i].Label = label
+	}
+	if err := fixit.WriteFile(fixesOut, fixes); err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+func writeFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Severity is the severity of a finding.
+type Severity string
+
+// Severities of findings. Only errors fail the build.
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+)
+
+// Finding is a policy violation found in a manifest.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Message  string
+	// Line and Column locate the offending element, starting at 1.
+	Line, Column int
+}
+
+// Policy configures the rules checked by Lint.
+type Policy struct {
+	// Release forbids debuggable applications.
+	Release bool
+	// MinSdkFloor is the lowest minSdkVersion allowed, or 0 for none.
+	MinSdkFloor int
+	// AllowedPermissions are the dangerous permissions the manifest may request.
+	AllowedPermissions []string
+	// Disabled are the rules to skip.
+	Disabled []string
+}
+
+// Rule is a manifest policy.
+type Rule struct {
+	ID          string
+	Description string
+	check       func(m *manifestutils.Manifest, p Policy, report func(manifestutils.Node, Severity, string, ...any))
+}
+
+// Rules are the policies checked by Lint, by ID.
+var Rules = []Rule{
+	{"debuggable", "Release builds must not be debuggable.", checkDebuggable},
+	{"exported", "Components with intent filters must set android:exported when targeting API 31 or higher.", checkExported},
+	{"dangerous_permission", "Dangerous permissions must be allowlisted.", checkPermissions},
+	{"cleartext_traffic", "Applications must set android:usesCleartextTraffic to false.", checkCleartextTraffic},
+	{"min_sdk", "minSdkVersion must not be lower than the min SDK floor.", checkMinSdk},
+}
+
+// Lint checks m against the rules of p and returns the findings in document order.
+func Lint(m *manifestutils.Manifest, p Policy) ([]Finding, error) {
+	disabled := make(map[string]bool)
+	for _, id := range p.Disabled {
+		disabled[id] = true
+	}
+	var findings []Finding
+	for _, r := range Rules {
+		if disabled[r.ID] {
+			delete(disabled, r.ID)
+			continue
+		}
+		r.check(m, p, func(n manifestutils.Node, s Severity, format string, args ...any) {
+			f := Finding{Rule: r.ID, Severity: s, Message: fmt.Sprintf(format, args...)}
+			if e := n.Element(); e != nil {
+				f.Line, f.Column = e.Line, e.Column
+			}
+			findings = append(findings, f)
+		})
+	}
+	for id := range disabled {
+		return nil, fmt.Errorf("cannot disable unknown rule %q", id)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+	return findings, nil
+}
+
+// fixers suggest fixes of the rule attributes of the target for the findings of rules, by ID.
+var fixers = map[string]func(m *manifestutils.Manifest, p Policy) []fixit.Fix{
+	"min_sdk": fixMinSdk,
+}
+
+// Fixes returns the fixes of the findings of Lint fixable with a buildozer command. The fixes have
+// no label.
+func Fixes(m *manifestutils.Manifest, p Policy) []fixit.Fix {
+	disabled := make(map[string]bool)
+	for _, id := range p.Disabled {
+		disabled[id] = true
+	}
+	var fixes []fixit.Fix
+	for _, r := range Rules {
+		if fix, ok := fixers[r.ID]; ok && !disabled[r.ID] {
+			fixes = append(fixes, fix(m, p)...)
+		}
+	}
+	return fixes
+}
+
+func errorCount(findings []Finding) int {
+	n := 0
+	for _, f := range findings {
+		if f.Severity == Error {
+			n++
+		}
+	}
+	return n
+}
+
+func checkDebuggable(m *manifestutils.Manifest, p Policy, report func(manifestutils.Node, Severity, string, ...any)) {
+	if !p.Release || m.Application == nil {
+		return
+	}
+	if d := m.Application.Debuggable; d != "" && d != "false" {
+		report(m.Application.Node, Error, "android:debuggable is %q in a release build, remove the attribute", d)
+	}
+}
+
+func checkExported(m *manifestutils.Manifest, p Policy, report func(manifestutils.Node, Severity, string, ...any)) {
+	if m.Application == nil {
+		return
+	}
+	target, ok := targetSdk(m)
+	if !ok || !atLeast(target, 31) {
+		return
+	}
+	a := m.Application
+	for _, kind := range []struct {
+		name  string
+		comps []*manifestutils.Component
+	}{
+		{"activity", a.Activities},
+		{"activity-alias", a.ActivityAliases},
+		{"service", a.Services},
+		{"receiver", a.Receivers},
+		{"provider", a.Providers},
+	} {
+		for _, c := range kind.comps {
+			if len(c.IntentFilters) > 0 && c.Exported == "" {
+				report(c.Node, Error, "%s %s has intent filters but no android:exported, required when targeting API %s", kind.name, c.Name, target)
+			}
+		}
+	}
+}
+
+func checkPermissions(m *manifestutils.Manifest, p Policy, report func(manifestutils.Node, Severity, string, ...any)) {
+	allowed := make(map[string]bool)
+	for _, perm := range p.AllowedPermissions {
+		allowed[perm] = true
+	}
+	for _, perms := range [][]*manifestutils.UsesPermission{m.UsesPermissions, m.UsesPermissionsSdk23} {
+		for _, perm := range perms {
+			if dangerousPermissions[perm.Name] && !allowed[perm.Name] {
+				report(perm.Node, Error, "dangerous permission %s is not allowlisted", perm.Name)
+			}
+		}
+	}
+}
+
+func checkCleartextTraffic(m *manifestutils.Manifest, p Policy, report func(manifestutils.Node, Severity, string, ...any)) {
+	if m.Application == nil {
+		return
+	}
+	switch c := m.Application.UsesCleartextTraffic; c {
+	case "false":
+	case "":
+		report(m.Application.Node, Error, `android:usesCleartextTraffic is not set, set it to "false"`)
+	default:
+		report(m.Application.Node, Error, `android:usesCleartextTraffic is %q, set it to "false"`, c)
+	}
+}
+
+func checkMinSdk(m *manifestutils.Manifest, p Policy, report func(manifestutils.Node, Severity, string, ...any)) {
+	min, below, err := belowMinSdkFloor(m, p)
+	switch {
+	case err != nil:
+		report(m.UsesSdk.Node, Error, "minSdkVersion %s does not compare with the floor of %d: %v", m.UsesSdk.MinSdkVersion, p.MinSdkFloor, err)
+	case !below:
+	case min == "":
+		report(m.Node, Error, "minSdkVersion is not set, set it to at least %d", p.MinSdkFloor)
+	default:
+		report(m.UsesSdk.Node, Error, "minSdkVersion %s is lower than the floor of %d", min, p.MinSdkFloor)
+	}
+}
+
+func fixMinSdk(m *manifestutils.Manifest, p Policy) []fixit.Fix {
+	min, below, err := belowMinSdkFloor(m, p)
+	if err != nil || !below {
+		return nil
+	}
+	return []fixit.Fix{{
+		Attr:     "manifest_values",
+		Key:      "minSdkVersion",
+		OldValue: min,
+		NewValue: strconv.Itoa(p.MinSdkFloor),
+		Message:  fmt.Sprintf("minSdkVersion must not be lower than the floor of %d", p.MinSdkFloor),
+	}}
+}
+
+// belowMinSdkFloor returns the minSdkVersion of m, and whether it is unset or lower than the
+// floor of p. Placeholders are not reported, while previews do not compare with the floor.
+func belowMinSdkFloor(m *manifestutils.Manifest, p Policy) (string, bool, error) {
+	if p.MinSdkFloor == 0 {
+		return "", false, nil
+	}
+	if m.UsesSdk == nil || m.UsesSdk.MinSdkVersion == "" {
+		return "", true, nil
+	}
+	min, err := sdklevel.Parse(m.UsesSdk.MinSdkVersion)
+	if errors.Is(err, sdklevel.ErrPlaceholder) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	c, err := min.Compare(sdklevel.API(p.MinSdkFloor))
+	if err != nil {
+		return "", false, err
+	}
+	return min.String(), c < 0, nil
+}
+
+// targetSdk returns the targetSdkVersion of m, which defaults to the minSdkVersion. Placeholders
+// are not reported.
+func targetSdk(m *manifestutils.Manifest) (sdklevel.Level, bool) {
+	if m.UsesSdk == nil {
+		return sdklevel.API(1), true
+	}
+	v := m.UsesSdk.TargetSdkVersion
+	if v == "" {
+		v = m.UsesSdk.MinSdkVersion
+	}
+	if v == "" {
+		return sdklevel.API(1), true
+	}
+	l, err := sdklevel.Parse(v)
+	return l, err == nil
+}
+
+// atLeast reports whether l is the API level n or higher. Previews of unknown codenames are newer
+// than any released API level.
+func atLeast(l sdklevel.Level, n int) bool {
+	if l.IsPreview() && l.Major == 0 {
+		return true
+	}
+	return l.Major >= n
+}
+
+// dangerousPermissions are the permissions of the dangerous protection level.
+var dangerousPermissions = map[string]bool{
+	"android.permission.ACCEPT_HANDOVER":                 true,
+	"android.permission.ACCESS_BACKGROUND_LOCATION":      true,
+	"android.permission.ACCESS_COARSE_LOCATION":          true,
+	"android.permission.ACCESS_FINE_LOCATION":            true,
+	"android.permission.ACCESS_MEDIA_LOCATION":           true,
+	"android.permission.ACTIVITY_RECOGNITION":            true,
+	"android.permission.ADD_VOICEMAIL":                   true,
+	"android.permission.ANSWER_PHONE_CALLS":              true,
+	"android.permission.BLUETOOTH_ADVERTISE":             true,
+	"android.permission.BLUETOOTH_CONNECT":               true,
+	"android.permission.BLUETOOTH_SCAN":                  true,
+	"android.permission.BODY_SENSORS":                    true,
+	"android.permission.BODY_SENSORS_BACKGROUND":         true,
+	"android.permission.CALL_PHONE":                      true,
+	"android.permission.CAMERA":                          true,
+	"android.permission.GET_ACCOUNTS":                    true,
+	"android.permission.NEARBY_WIFI_DEVICES":             true,
+	"android.permission.POST_NOTIFICATIONS":              true,
+	"android.permission.PROCESS_OUTGOING_CALLS":          true,
+	"android.permission.READ_CALENDAR":                   true,
+	"android.permission.READ_CALL_LOG":                   true,
+	"android.permission.READ_CONTACTS":                   true,
+	"android.permission.READ_EXTERNAL_STORAGE":           true,
+	"android.permission.READ_MEDIA_AUDIO":                true,
+	"android.permission.READ_MEDIA_IMAGES":               true,
+	"android.permission.READ_MEDIA_VIDEO":                true,
+	"android.permission.READ_MEDIA_VISUAL_USER_SELECTED": true,
+	"android.permission.READ_PHONE_NUMBERS":              true,
+	"android.permission.READ_PHONE_STATE":                true,
+	"android.permission.READ_SMS":                        true,
+	"android.permission.RECEIVE_MMS":                     true,
+	"android.permission.RECEIVE_SMS":                     true,
+	"android.permission.RECEIVE_WAP_PUSH":                true,
+	"android.permission.RECORD_AUDIO":                    true,
+	"android.permission.SEND_SMS":                        true,
+	"android.permission.USE_SIP":                         true,
+	"android.permission.UWB_RANGING":                     true,
+	"android.permission.WRITE_CALENDAR":                  true,
+	"android.permission.WRITE_CALL_LOG":                  true,
+	"android.permission.WRITE_CONTACTS":                  true,
+	"android.permission.WRITE_EXTERNAL_STORAGE":          true,
+	"com.android.voicemail.permission.ADD_VOICEMAIL":     true,
+}
@@ -0,0 +1,275 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestlint
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"src/tools/ak/fixit"
+	"src/tools/ak/manifestutils"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+const lintManifest = `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <uses-sdk android:minSdkVersion="19" android:targetSdkVersion="33"/>
+    <uses-permission android:name="android.permission.INTERNET"/>
+    <uses-permission android:name="android.permission.CAMERA"/>
+    <uses-permission-sdk-23 android:name="android.permission.READ_CONTACTS"/>
+    <application android:debuggable="true">
+        <activity android:name=".Main">
+            <intent-filter>
+                <action android:name="android.intent.action.MAIN"/>
+            </intent-filter>
+        </activity>
+        <activity android:name=".Exported" android:exported="true">
+            <intent-filter>
+                <action android:name="android.intent.action.VIEW"/>
+            </intent-filter>
+        </activity>
+        <service android:name=".Internal"/>
+        <receiver android:name=".Boot">
+            <intent-filter>
+                <action android:name="android.intent.action.BOOT_COMPLETED"/>
+            </intent-filter>
+        </receiver>
+    </application>
+</manifest>
+`
+
+func readManifest(t *testing.T, s string) *manifestutils.Manifest {
+	t.Helper()
+	m, err := manifestutils.ReadManifest(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	return m
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		policy   Policy
+		want     []Finding
+	}{
+		{
+			name:     "all rules",
+			manifest: lintManifest,
+			policy:   Policy{Release: true, MinSdkFloor: 21, AllowedPermissions: []string{"android.permission.CAMERA"}},
+			want: []Finding{
+				{"min_sdk", Error, "minSdkVersion 19 is lower than the floor of 21", 3, 5},
+				{"dangerous_permission", Error, "dangerous permission android.permission.READ_CONTACTS is not allowlisted", 6, 5},
+				{"debuggable", Error, `android:debuggable is "true" in a release build, remove the attribute`, 7, 5},
+				{"cleartext_traffic", Error, `android:usesCleartextTraffic is not set, set it to "false"`, 7, 5},
+				{"exported", Error, "activity .Main has intent filters but no android:exported, required when targeting API 33", 8, 9},
+				{"exported", Error, "receiver .Boot has intent filters but no android:exported, required when targeting API 33", 19, 9},
+			},
+		},
+		{
+			name:     "not release",
+			manifest: lintManifest,
+			policy:   Policy{AllowedPermissions: []string{"android.permission.CAMERA", "android.permission.READ_CONTACTS"}, Disabled: []string{"exported", "cleartext_traffic"}},
+		},
+		{
+			name: "old target",
+			manifest: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+  <uses-sdk android:minSdkVersion="21" android:targetSdkVersion="30"/>
+  <application android:usesCleartextTraffic="false" android:debuggable="false">
+    <activity android:name=".Main"><intent-filter/></activity>
+  </application>
+</manifest>`,
+			policy: Policy{Release: true, MinSdkFloor: 21},
+		},
+		{
+			name: "target defaults to min",
+			manifest: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+  <uses-sdk android:minSdkVersion="31"/>
+  <application android:usesCleartextTraffic="true">
+    <service android:name=".S"><intent-filter/></service>
+  </application>
+</manifest>`,
+			policy: Policy{MinSdkFloor: 21},
+			want: []Finding{
+				{"cleartext_traffic", Error, `android:usesCleartextTraffic is "true", set it to "false"`, 3, 3},
+				{"exported", Error, "service .S has intent filters but no android:exported, required when targeting API 31", 4, 5},
+			},
+		},
+		{
+			name:     "missing min sdk",
+			manifest: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example"/>`,
+			policy:   Policy{MinSdkFloor: 21},
+			want: []Finding{
+				{"min_sdk", Error, "minSdkVersion is not set, set it to at least 21", 1, 1},
+			},
+		},
+		{
+			name: "codename min sdk",
+			manifest: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+  <uses-sdk android:minSdkVersion="Baklava" android:targetSdkVersion="Baklava"/>
+  <application android:usesCleartextTraffic="false">
+    <service android:name=".S"><intent-filter/></service>
+  </application>
+</manifest>`,
+			policy: Policy{MinSdkFloor: 21},
+			want: []Finding{
+				{"min_sdk", Error, "minSdkVersion Baklava does not compare with the floor of 21: cannot mix the preview SDK Baklava with the stable SDK 21", 2, 3},
+				{"exported", Error, "service .S has intent filters but no android:exported, required when targeting API Baklava", 4, 5},
+			},
+		},
+		{
+			name: "minor version target",
+			manifest: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+  <uses-sdk android:minSdkVersion="21.1" android:targetSdkVersion="36.1"/>
+  <application android:usesCleartextTraffic="false">
+    <service android:name=".S"><intent-filter/></service>
+  </application>
+</manifest>`,
+			policy: Policy{MinSdkFloor: 22},
+			want: []Finding{
+				{"min_sdk", Error, "minSdkVersion 21.1 is lower than the floor of 22", 2, 3},
+				{"exported", Error, "service .S has intent filters but no android:exported, required when targeting API 36.1", 4, 5},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Lint(readManifest(t, tc.manifest), tc.policy)
+			if err != nil {
+				t.Fatalf("Lint returned unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Lint returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestLintUnknownRule(t *testing.T) {
+	_, err := Lint(readManifest(t, lintManifest), Policy{Disabled: []string{"debuggable", "exportd"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown rule "exportd"`) {
+		t.Errorf("Lint returned error %v, want unknown rule error", err)
+	}
+}
+
+func TestFixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		policy   Policy
+		want     []fixit.Fix
+	}{
+		{
+			name:     "min sdk below floor",
+			manifest: lintManifest,
+			policy:   Policy{MinSdkFloor: 21},
+			want: []fixit.Fix{
+				{Attr: "manifest_values", Key: "minSdkVersion", OldValue: "19", NewValue: "21"},
+			},
+		},
+		{
+			name:     "missing min sdk",
+			manifest: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example"/>`,
+			policy:   Policy{MinSdkFloor: 21},
+			want: []fixit.Fix{
+				{Attr: "manifest_values", Key: "minSdkVersion", NewValue: "21"},
+			},
+		},
+		{
+			name:     "codename min sdk",
+			manifest: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example"><uses-sdk android:minSdkVersion="Baklava"/></manifest>`,
+			policy:   Policy{MinSdkFloor: 21},
+		},
+		{
+			name:     "min sdk rule disabled",
+			manifest: lintManifest,
+			policy:   Policy{MinSdkFloor: 21, Disabled: []string{"min_sdk"}},
+		},
+		{
+			name:     "no floor",
+			manifest: lintManifest,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Fixes(readManifest(t, tc.manifest), tc.policy)
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreFields(fixit.Fix{}, "Message")); diff != "" {
+				t.Errorf("Fixes returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+var reportFindings = []Finding{
+	{"min_sdk", Error, "minSdkVersion 19 is lower than the floor of 21", 3, 5},
+	{"exported", Warning, "activity .Main has intent filters but no android:exported", 8, 9},
+}
+
+func TestWriteText(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteText(&b, "AndroidManifest.xml", reportFindings); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	want := `AndroidManifest.xml:3:5: error: minSdkVersion 19 is lower than the floor of 21 [min_sdk]
+AndroidManifest.xml:8:9: warning: activity .Main has intent filters but no android:exported [exported]
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("WriteText returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteSARIF(&b, "AndroidManifest.xml", reportFindings); err != nil {
+		t.Fatalf("WriteSARIF failed: %v", err)
+	}
+	var got sarifLog
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("WriteSARIF wrote invalid JSON: %v\n%s", err, b.String())
+	}
+	if got.Version != "2.1.0" || len(got.Runs) != 1 {
+		t.Fatalf("WriteSARIF wrote version %q with %d runs, want 2.1.0 with 1 run", got.Version, len(got.Runs))
+	}
+	if n := len(got.Runs[0].Tool.Driver.Rules); n != len(Rules) {
+		t.Errorf("WriteSARIF wrote %d rules, want %d", n, len(Rules))
+	}
+	want := []sarifResult{
+		{
+			RuleID:  "min_sdk",
+			Level:   Error,
+			Message: sarifMessage{"minSdkVersion 19 is lower than the floor of 21"},
+			Locations: []sarifLocation{{sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{"AndroidManifest.xml"},
+				Region:           sarifRegion{3, 5},
+			}}},
+		},
+		{
+			RuleID:  "exported",
+			Level:   Warning,
+			Message: sarifMessage{"activity .Main has intent filters but no android:exported"},
+			Locations: []sarifLocation{{sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{"AndroidManifest.xml"},
+				Region:           sarifRegion{8, 9},
+			}}},
+		},
+	}
+	if diff := cmp.Diff(want, got.Runs[0].Results); diff != "" {
+		t.Errorf("WriteSARIF returned diff (-want, +got):\n%v", diff)
+	}
+}
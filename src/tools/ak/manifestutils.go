@@ -16,17 +16,23 @@
 package manifestutils
 
 import (
+	"bytes"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"log"
+	"regexp"
 	"strings"
 
+	"src/common/golang/ini"
 	"src/common/golang/xml2"
 )
 
 // Constant attribute names used in an AndroidManifest.
 const (
 	NameSpace           = "http://schemas.android.com/apk/res/android"
+	ToolsNameSpace      = "http://schemas.android.com/tools"
+	DistNameSpace       = "http://schemas.android.com/apk/distribution"
 	ElemManifest        = "manifest"
 	AttrPackage         = "package"
 	AttrSplit           = "split"
@@ -38,6 +44,16 @@ const (
 )
 
 var (
+	// DefaultNamespaces maps the prefixes commonly used in manifests to their namespace URIs, which
+	// they resolve to when not declared.
+	DefaultNamespaces = map[string]string{
+		androidPrefix: NameSpace,
+		"dist":        DistNameSpace,
+		"tools":       ToolsNameSpace,
+	}
+
+	placeholderRE = regexp.MustCompile(`\$\{([^}]*)\}`)
+
 	// NoNSAttrs contains attributes that are not namespaced.
 	NoNSAttrs = map[string]bool{
 		AttrPackage:     true,
@@ -45,23 +61,6 @@ var (
 		AttrFeatureName: true}
 )
 
-// Manifest is the XML root that we want to parse.
-type Manifest struct {
-	XMLName         xml.Name    `xml:"manifest"`
-	Package         string      `xml:"package,attr"`
-	SharedUserID    string      `xml:"sharedUserId,attr"`
-	SharedUserLabel string      `xml:"sharedUserLabel,attr"`
-	VersionCode     string      `xml:"versionCode,attr"`
-	VersionName     string      `xml:"versionName,attr"`
-	Application     Application `xml:"application"`
-}
-
-// Application is the XML tag that we want to parse.
-type Application struct {
-	XMLName xml.Name `xml:"application"`
-	Name    string   `xml:"http://schemas.android.com/apk/res/android name,attr"`
-}
-
 // Encoder takes the xml.Token and encodes it, interface allows us to use xml2.Encoder.
 type Encoder interface {
 	EncodeToken(xml.Token) error
@@ -70,47 +69,27 @@ type Encoder interface {
 // Patch updates an AndroidManifest by patching the attributes of existing elements.
 //
 // Attributes that are already defined on the element are updated, while missing
-// attributes are added to the element's attributes. Elements in patchElems that are
+// attributes are added to the element's attributes. Attributes are matched by namespace
+// URI, whatever their prefix in the manifest. Elements in patchElems that are
 // missing from the manifest are ignored.
 func Patch(dec *xml.Decoder, enc Encoder, patchElems map[string]map[string]xml.Attr) error {
-	for {
-		t, err := dec.Token()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-		switch tt := t.(type) {
-		case xml.StartElement:
-			elem := tt.Name.Local
-			if attrs, ok := patchElems[elem]; ok {
-				found := make(map[string]bool)
-				for i, a := range tt.Attr {
-					if attr, ok := attrs[a.Name.Local]; a.Name.Space == attr.Name.Space && ok {
-						found[a.Name.Local] = true
-						tt.Attr[i] = attr
-					}
-				}
-				for _, attr := range attrs {
-					if found[attr.Name.Local] {
-						continue
-					}
-
-					tt.Attr = append(tt.Attr, attr)
-				}
-			}
-			enc.EncodeToken(tt)
-		default:
-			enc.EncodeToken(tt)
-		}
+	doc, err := ReadDocument(dec)
+	if err != nil {
+		return err
 	}
-	return nil
+	doc.Root().Walk(func(e *Element) {
+		for _, attr := range patchElems[e.Name.Local] {
+			e.SetAttr(attr.Name, attr.Value)
+		}
+	})
+	declareNamespaces(doc.Root(), DefaultNamespaces)
+	return doc.Encode(enc)
 }
 
 // WriteManifest writes an AndroidManifest with updates to patched elements.
 func WriteManifest(dst io.Writer, src io.Reader, patchElems map[string]map[string]xml.Attr) error {
 	e := xml2.NewEncoder(dst)
+	e.SelfCloseEmpty()
 	if err := Patch(xml.NewDecoder(src), e, patchElems); err != nil {
 		return err
 	}
@@ -146,3 +125,144 @@ func CreatePatchElements(attr []string) map[string]map[string]xml.Attr {
 	}
 	return patchElems
 }
+
+// Placeholders maps the names of the ${name} placeholders of a manifest to their values.
+type Placeholders map[string]string
+
+// LoadPlaceholders reads the placeholders of the optional key=value file, followed by those of the
+// key=value entries, which take precedence.
+func LoadPlaceholders(file string, entries []string) (Placeholders, error) {
+	p := make(Placeholders)
+	if file != "" {
+		m, err := ini.Read(file)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range m {
+			p[k] = v
+		}
+	}
+	for _, e := range entries {
+		k, v, ok := strings.Cut(e, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("placeholder %q is not of the form {name}={value}", e)
+		}
+		p[k] = v
+	}
+	return p, nil
+}
+
+// Expand substitutes the placeholders of s, returning the placeholders without value.
+func (p Placeholders) Expand(s string) (string, []string) {
+	var missing []string
+	s = placeholderRE.ReplaceAllStringFunc(s, func(m string) string {
+		if v, ok := p[m[2:len(m)-1]]; ok {
+			return v
+		}
+		missing = append(missing, m)
+		return m
+	})
+	return s, missing
+}
+
+// ElementPath returns the path of an element from the path of its parent, e.g.
+// manifest/application/activity[@android:name=.Main]. Elements are told apart by their
+// android:name attribute.
+func ElementPath(parent string, e *Element) string {
+	p := e.Name.Local
+	if parent != "" {
+		p = parent + "/" + p
+	}
+	if name, ok := e.AttrValue(xml.Name{Space: NameSpace, Local: "name"}); ok {
+		return fmt.Sprintf("%s[@android:name=%s]", p, name)
+	}
+	return p
+}
+
+// ExpandPlaceholders substitutes the placeholders of the attribute values of a manifest.
+//
+// applicationId and packageName default to the package of the manifest. Placeholders without value
+// fail the expansion, which reports the path of the attributes using them, e.g.
+// manifest/uses-sdk@minSdkVersion.
+func ExpandPlaceholders(dec *xml.Decoder, enc Encoder, p Placeholders) error {
+	doc, err := ReadDocument(dec)
+	if err != nil {
+		return err
+	}
+	if _, err := expandDocument(doc, p); err != nil {
+		return err
+	}
+	return doc.Encode(enc)
+}
+
+// expandDocument substitutes the placeholders of the attribute values of doc, returning whether any
+// value changed.
+func expandDocument(doc *Document, p Placeholders) (bool, error) {
+	root := doc.Root()
+	if root.Name.Local == ElemManifest {
+		p = withPackage(p, root)
+	}
+	changed := false
+	var errs []string
+	var expand func(parent string, e *Element)
+	expand = func(parent string, e *Element) {
+		path := ElementPath(parent, e)
+		for i, a := range e.Attr {
+			v, missing := p.Expand(a.Value)
+			for _, m := range missing {
+				errs = append(errs, fmt.Sprintf("%s@%s: undefined placeholder %s", path, a.Name.Local, m))
+			}
+			changed = changed || v != a.Value
+			e.Attr[i].Value = v
+		}
+		for _, c := range e.Elements("") {
+			expand(path, c)
+		}
+	}
+	expand("", root)
+	if len(errs) > 0 {
+		return false, fmt.Errorf("unresolved placeholders:\n%s", strings.Join(errs, "\n"))
+	}
+	return changed, nil
+}
+
+// withPackage returns the placeholders with applicationId and packageName defaulting to the
+// package of the manifest element.
+func withPackage(p Placeholders, manifest *Element) Placeholders {
+	pkg, _ := manifest.AttrValue(xml.Name{Local: AttrPackage})
+	if pkg == "" {
+		return p
+	}
+	r := Placeholders{"applicationId": pkg, "packageName": pkg}
+	for k, v := range p {
+		r[k] = v
+	}
+	return r
+}
+
+// ExpandManifest returns the manifest with the placeholders of its attribute values substituted.
+// The manifest is returned as is if it has no placeholders, so that tools can always call it to
+// fail on unresolved placeholders.
+func ExpandManifest(manifest []byte, p Placeholders) ([]byte, error) {
+	doc, err := ReadDocument(xml.NewDecoder(bytes.NewReader(manifest)))
+	if err != nil {
+		return nil, err
+	}
+	changed, err := expandDocument(doc, p)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return manifest, nil
+	}
+	var b bytes.Buffer
+	e := xml2.NewEncoder(&b)
+	e.SelfCloseEmpty()
+	if err := doc.Encode(e); err != nil {
+		return nil, err
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
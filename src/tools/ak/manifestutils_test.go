@@ -0,0 +1,288 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestutils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"src/common/golang/xml2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadPlaceholders(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "placeholders.ini")
+	if err := os.WriteFile(file, []byte("# Values\nhost = www.example.com\nlabel=File\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		file    string
+		entries []string
+		want    Placeholders
+		wantErr string
+	}{
+		{
+			name:    "entries",
+			entries: []string{"a=b", "url=https://x?y=z", "empty="},
+			want:    Placeholders{"a": "b", "url": "https://x?y=z", "empty": ""},
+		},
+		{
+			name:    "file",
+			file:    file,
+			entries: []string{"label=Flag"},
+			want:    Placeholders{"host": "www.example.com", "label": "Flag"},
+		},
+		{
+			name:    "malformed entry",
+			entries: []string{"novalue"},
+			wantErr: `placeholder "novalue" is not of the form {name}={value}`,
+		},
+		{
+			name:    "missing file",
+			file:    filepath.Join(t.TempDir(), "missing"),
+			wantErr: "no such file",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := LoadPlaceholders(tc.file, tc.entries)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("LoadPlaceholders returned error %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadPlaceholders returned unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("LoadPlaceholders returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	p := Placeholders{"a": "1", "b": "${a}"}
+	got, missing := p.Expand("${a}.${b}.${c}.${}")
+	if want := "1.${a}.${c}.${}"; got != want {
+		t.Errorf("Expand returned %q, want %q", got, want)
+	}
+	if diff := cmp.Diff([]string{"${c}", "${}"}, missing); diff != "" {
+		t.Errorf("Expand returned missing diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestExpandManifest(t *testing.T) {
+	manifest := `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+  <uses-sdk android:minSdkVersion="${minSdk}"/>
+  <application android:label="${label}">
+    <provider android:name=".Files" android:authorities="${applicationId}.files"/>
+  </application>
+</manifest>`
+	tests := []struct {
+		name    string
+		p       Placeholders
+		want    string
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			p:    Placeholders{"minSdk": "21", "label": "App"},
+			want: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+  <uses-sdk android:minSdkVersion="21"/>
+  <application android:label="App">
+    <provider android:name=".Files" android:authorities="com.example.files"/>
+  </application>
+</manifest>`,
+		},
+		{
+			name: "applicationId",
+			p:    Placeholders{"minSdk": "21", "label": "App", "applicationId": "com.example.debug"},
+			want: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+  <uses-sdk android:minSdkVersion="21"/>
+  <application android:label="App">
+    <provider android:name=".Files" android:authorities="com.example.debug.files"/>
+  </application>
+</manifest>`,
+		},
+		{
+			name:    "unresolved",
+			p:       Placeholders{"label": "App"},
+			wantErr: "unresolved placeholders:\nmanifest/uses-sdk@minSdkVersion: undefined placeholder ${minSdk}",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ExpandManifest([]byte(manifest), tc.p)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("ExpandManifest returned error %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandManifest returned unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("ExpandManifest returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestExpandManifestUnchanged(t *testing.T) {
+	// Not re-encoded, the empty application element stays as written.
+	manifest := []byte(`<manifest package="com.example"><application></application></manifest>`)
+	got, err := ExpandManifest(manifest, nil)
+	if err != nil {
+		t.Fatalf("ExpandManifest returned unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(string(manifest), string(got)); diff != "" {
+		t.Errorf("ExpandManifest returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestExpandPlaceholdersElementPath(t *testing.T) {
+	manifest := `<manifest xmlns:android="http://schemas.android.com/apk/res/android">
+  <application>
+    <activity android:name=".Main">
+      <meta-data android:name="host" android:value="${host}"/>
+    </activity>
+  </application>
+</manifest>`
+	var b bytes.Buffer
+	err := ExpandPlaceholders(xml.NewDecoder(strings.NewReader(manifest)), xml2.NewEncoder(&b), nil)
+	want := "manifest/application/activity[@android:name=.Main]/meta-data[@android:name=host]@value: undefined placeholder ${host}"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("ExpandPlaceholders returned error %v, want error containing %q", err, want)
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	patch := CreatePatchElements([]string{"application:name:.App"})
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{
+			name:     "non-standard prefix",
+			manifest: `<manifest xmlns:a="http://schemas.android.com/apk/res/android" package="com.example"><application a:name=".Old" a:label="App"></application></manifest>`,
+			want:     `<manifest xmlns:a="http://schemas.android.com/apk/res/android" package="com.example"><application a:name=".App" a:label="App"/></manifest>`,
+		},
+		{
+			name:     "undeclared prefix",
+			manifest: `<manifest package="com.example"><application android:name=".Old"/></manifest>`,
+			want:     `<manifest package="com.example" xmlns:android="http://schemas.android.com/apk/res/android"><application android:name=".App"/></manifest>`,
+		},
+		{
+			name:     "added attribute",
+			manifest: `<manifest package="com.example"><application><activity/></application></manifest>`,
+			want:     `<manifest package="com.example" xmlns:android="http://schemas.android.com/apk/res/android"><application android:name=".App"><activity/></application></manifest>`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := WriteManifest(&b, strings.NewReader(tc.manifest), patch); err != nil {
+				t.Fatalf("WriteManifest failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+				t.Errorf("WriteManifest returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+// FuzzDocumentRoundTrip checks that written documents read and write back unchanged.
+func FuzzDocumentRoundTrip(f *testing.F) {
+	for _, s := range []string{
+		`<manifest package="com.example"><application android:name=".App"></application></manifest>`,
+		`<manifest xmlns:a="http://schemas.android.com/apk/res/android"><uses-sdk a:minSdkVersion="21"/></manifest>`,
+		"<?xml version=\"1.0\"?>\n<!-- c -->\n<manifest xmlns:dist=\"http://schemas.android.com/apk/distribution\">\n  <dist:module dist:onDemand=\"true\"></dist:module>\n</manifest>\n",
+	} {
+		f.Add(s)
+	}
+	read := func(in string) (*Document, error) {
+		return ReadDocument(xml.NewDecoder(strings.NewReader(in)))
+	}
+	write := func(doc *Document) (string, error) {
+		var b bytes.Buffer
+		err := doc.Write(&b)
+		return b.String(), err
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		doc, err := read(in)
+		if err != nil {
+			return
+		}
+		out, err := write(doc)
+		if err != nil {
+			return
+		}
+		outDoc, err := read(out)
+		if err != nil {
+			t.Fatalf("reading back %q, written from %q, failed: %v", out, in, err)
+		}
+		if diff := cmp.Diff(resolvedNodes(doc), resolvedNodes(outDoc)); diff != "" {
+			t.Errorf("writing %q as %q changed the resolved names or values (-in, +out):\n%v", in, out, diff)
+		}
+		again, err := write(outDoc)
+		if err != nil {
+			t.Fatalf("writing back %q, written from %q, failed: %v", out, in, err)
+		}
+		if again != out {
+			t.Errorf("writing back %q, written from %q, got %q", out, in, again)
+		}
+	})
+}
+
+// resolvedNodes lists the elements of doc in document order, with their namespace URI, local name,
+// attributes and text. Namespace declarations are left out, as prefixes may be declared anew.
+func resolvedNodes(doc *Document) []string {
+	var nodes []string
+	var walk func(e *Element)
+	walk = func(e *Element) {
+		nodes = append(nodes, "<{"+e.Name.Space+"}"+e.Name.Local)
+		for _, a := range e.Attr {
+			if a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" {
+				continue
+			}
+			nodes = append(nodes, "@{"+a.Name.Space+"}"+a.Name.Local+"="+a.Value)
+		}
+		var text []byte
+		for _, t := range e.Children {
+			switch t := t.(type) {
+			case xml.CharData:
+				text = append(text, t...)
+			case *Element:
+				nodes = append(nodes, "text="+string(text))
+				text = nil
+				walk(t)
+			}
+		}
+		nodes = append(nodes, "text="+string(text), ">")
+	}
+	if root := doc.Root(); root != nil {
+		walk(root)
+	}
+	return nodes
+}
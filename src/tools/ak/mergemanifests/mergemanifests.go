@@ -0,0 +1,192 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mergemanifests merges the manifests of libraries into the manifest of an app the way the
+// Android Gradle plugin does: elements are matched by key, conflicts are resolved with the tools:
+// markers of the higher priority manifest, ${placeholders} are substituted and each decision is
+// recorded in a log.
+package mergemanifests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"src/common/golang/flags"
+	"src/common/golang/xml2"
+	"src/tools/ak/manifestutils"
+	"src/tools/ak/types"
+)
+
+const (
+	xmlHeader = `<?xml version="1.0" encoding="utf-8"?>` + "\n"
+	indent    = "    "
+)
+
+var (
+	// Cmd defines the command to run mergemanifests.
+	Cmd = types.Command{
+		Init:  Init,
+		Run:   Run,
+		Desc:  desc,
+		Flags: []string{"manifest", "libraries", "placeholder", "placeholders_file", "out", "log"},
+	}
+
+	// Variables to hold flag values.
+	manifest         string
+	libraries        flags.StringList
+	placeholders     flags.MultiString
+	placeholdersFile string
+	out              string
+	logOut           string
+
+	initOnce sync.Once
+)
+
+// Init initializes mergemanifests.
+func Init() {
+	initOnce.Do(func() {
+		flag.StringVar(&manifest, "manifest", "", "Manifest of the app.")
+		flag.Var(&libraries, "libraries", "(optional) Manifests of the libraries, in decreasing priority.")
+		flag.Var(&placeholders, "placeholder", "(optional) Repeatable placeholder value to substitute. {name}={value}. applicationId and packageName default to the package of the app.")
+		flag.StringVar(&placeholdersFile, "placeholders_file", "", "(optional) Path to a file of {name}={value} placeholder values.")
+		flag.StringVar(&out, "out", "", "Path to the merged manifest.")
+		flag.StringVar(&logOut, "log", "", "(optional) Path to the log of the merge decisions.")
+	})
+}
+
+func desc() string {
+	return "Mergemanifests merges library manifests into the manifest of an app."
+}
+
+// Run is the entry point for mergemanifests.
+func Run() {
+	if manifest == "" || out == "" {
+		log.Fatal("Flags -manifest and -out must be specified.")
+	}
+	values, err := manifestutils.LoadPlaceholders(placeholdersFile, placeholders)
+	if err != nil {
+		log.Fatalf("Error reading placeholders: %v", err)
+	}
+	if err := doWork(manifest, libraries, values, out, logOut); err != nil {
+		log.Fatalf("Error merging manifests: %v", err)
+	}
+}
+
+func doWork(mainPath string, libPaths []string, values manifestutils.Placeholders, outPath, logPath string) error {
+	main, err := load(mainPath)
+	if err != nil {
+		return err
+	}
+	var libs []*manifestFile
+	for _, p := range libPaths {
+		lib, err := load(p)
+		if err != nil {
+			return err
+		}
+		libs = append(libs, lib)
+	}
+	var merged bytes.Buffer
+	blame, err := merge(main, libs, values, &merged)
+	if logPath != "" {
+		// The log helps understanding failed merges too.
+		if err := os.WriteFile(logPath, []byte(blame), 0644); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, merged.Bytes(), 0644)
+}
+
+func load(path string) (*manifestFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(path, f)
+}
+
+// merge merges the libraries, in decreasing priority, into main and writes the result to w. It
+// returns the log of the merge decisions.
+func merge(main *manifestFile, libs []*manifestFile, values manifestutils.Placeholders, w io.Writer) (string, error) {
+	placeholders := manifestutils.Placeholders{"applicationId": main.pkg, "packageName": main.pkg}
+	for k, v := range values {
+		placeholders[k] = v
+	}
+	for _, f := range append([]*manifestFile{main}, libs...) {
+		if err := f.substitute(placeholders); err != nil {
+			return "", err
+		}
+		f.expandClassNames()
+		if err := f.checkDuplicates(); err != nil {
+			return "", err
+		}
+	}
+	m := newMerger(main)
+	for _, lib := range libs {
+		m.merge(lib)
+	}
+	if err := m.finish(); err != nil {
+		return m.log.String(), err
+	}
+	return m.log.String(), write(w, main.root)
+}
+
+// write writes the manifest rooted at root, indenting each element on its own line.
+func write(w io.Writer, root *node) error {
+	if _, err := io.WriteString(w, xmlHeader); err != nil {
+		return err
+	}
+	enc := xml2.NewEncoder(w)
+	enc.SelfCloseEmpty()
+	if err := writeNode(enc, root, 0); err != nil {
+		return err
+	}
+	if err := enc.EncodeToken(xml.CharData("\n")); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func writeNode(enc *xml2.Encoder, n *node, depth int) error {
+	if n.isComment() {
+		return enc.EncodeToken(n.comment)
+	}
+	start := xml.StartElement{Name: n.name, Attr: append(append([]xml.Attr(nil), n.ns...), n.attrs...)}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, c := range n.children {
+		if err := enc.EncodeToken(xml.CharData("\n" + strings.Repeat(indent, depth+1))); err != nil {
+			return err
+		}
+		if err := writeNode(enc, c, depth+1); err != nil {
+			return err
+		}
+	}
+	if len(n.children) > 0 {
+		if err := enc.EncodeToken(xml.CharData("\n" + strings.Repeat(indent, depth))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
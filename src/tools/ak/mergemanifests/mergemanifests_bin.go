@@ -0,0 +1,29 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// mergemanifests_bin is a command line tool to merge Android manifests.
+package main
+
+import (
+	"flag"
+
+	_ "src/common/golang/flagfile"
+	"src/tools/ak/mergemanifests/mergemanifests"
+)
+
+func main() {
+	mergemanifests.Init()
+	flag.Parse()
+	mergemanifests.Run()
+}
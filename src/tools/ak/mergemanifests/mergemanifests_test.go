@@ -0,0 +1,497 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mergemanifests
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"src/tools/ak/manifestutils"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	header   = `<manifest xmlns:android="http://schemas.android.com/apk/res/android" xmlns:tools="http://schemas.android.com/tools" package="com.example.app">`
+	outStart = xmlHeader + `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example.app">`
+)
+
+func lib(pkg, body string) string {
+	return fmt.Sprintf(`<manifest xmlns:android="http://schemas.android.com/apk/res/android" xmlns:tools="http://schemas.android.com/tools" package="%s">%s</manifest>`, pkg, body)
+}
+
+func mergeStrings(values manifestutils.Placeholders, mainXML string, libXMLs ...string) (string, string, error) {
+	main, err := parse("main.xml", strings.NewReader(mainXML))
+	if err != nil {
+		return "", "", err
+	}
+	var libs []*manifestFile
+	for i, l := range libXMLs {
+		f, err := parse(fmt.Sprintf("lib%d.xml", i+1), strings.NewReader(l))
+		if err != nil {
+			return "", "", err
+		}
+		libs = append(libs, f)
+	}
+	var b bytes.Buffer
+	blame, err := merge(main, libs, values, &b)
+	return b.String(), blame, err
+}
+
+// The expectations follow the examples of the "Merge multiple manifest files" Android developer
+// guide.
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		values manifestutils.Placeholders
+		main   string
+		libs   []string
+		want   string
+	}{
+		{
+			name: "tools:replace",
+			main: header + `
+    <application>
+        <activity android:name="com.example.ActivityOne" android:theme="@oldtheme" android:exported="false" android:windowSoftInputMode="stateUnchanged" tools:replace="android:theme,android:exported"/>
+    </application>
+</manifest>`,
+			libs: []string{lib("com.example.lib1", `<application><activity android:name="com.example.ActivityOne" android:theme="@newtheme" android:exported="true" android:screenOrientation="portrait"/></application>`)},
+			want: outStart + `
+    <application>
+        <activity android:name="com.example.ActivityOne" android:theme="@oldtheme" android:exported="false" android:windowSoftInputMode="stateUnchanged" android:screenOrientation="portrait"/>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name: "tools:remove",
+			main: header + `
+    <application>
+        <activity android:name="com.example.ActivityOne" android:screenOrientation="portrait" tools:remove="android:windowSoftInputMode"/>
+    </application>
+</manifest>`,
+			libs: []string{lib("com.example.lib1", `<application><activity android:name="com.example.ActivityOne" android:windowSoftInputMode="stateUnchanged"/></application>`)},
+			want: outStart + `
+    <application>
+        <activity android:name="com.example.ActivityOne" android:screenOrientation="portrait"/>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name: "tools:node merge-only-attributes",
+			main: header + `
+    <application>
+        <activity android:name="com.example.ActivityOne" android:windowSoftInputMode="stateUnchanged" tools:node="merge-only-attributes"/>
+    </application>
+</manifest>`,
+			libs: []string{lib("com.example.lib1", `<application><activity android:name="com.example.ActivityOne" android:screenOrientation="portrait"><intent-filter><action android:name="android.intent.action.SEND"/></intent-filter></activity></application>`)},
+			want: outStart + `
+    <application>
+        <activity android:name="com.example.ActivityOne" android:windowSoftInputMode="stateUnchanged" android:screenOrientation="portrait"/>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name: "tools:node remove",
+			main: header + `
+    <application>
+        <activity-alias android:name="com.example.alias" tools:node="remove"/>
+    </application>
+</manifest>`,
+			libs: []string{lib("com.example.lib1", `<application><activity-alias android:name="com.example.alias" android:targetActivity="com.example.ActivityOne"/><activity android:name="com.example.ActivityOne"/></application>`)},
+			want: outStart + `
+    <application>
+        <activity android:name="com.example.ActivityOne"/>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name: "tools:node removeAll",
+			main: header + `
+    <application>
+        <activity android:name="com.example.ActivityOne">
+            <meta-data tools:node="removeAll"/>
+        </activity>
+    </application>
+</manifest>`,
+			libs: []string{lib("com.example.lib1", `<application><activity android:name="com.example.ActivityOne"><meta-data android:name="cow" android:value="@string/moo"/><meta-data android:name="duck" android:value="@string/quack"/></activity></application>`)},
+			want: outStart + `
+    <application>
+        <activity android:name="com.example.ActivityOne"/>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name: "tools:node replace",
+			main: header + `
+    <application>
+        <activity-alias android:name="com.example.alias" tools:node="replace">
+            <meta-data android:name="fox" android:value="@string/dingeringeding"/>
+        </activity-alias>
+    </application>
+</manifest>`,
+			libs: []string{lib("com.example.lib1", `<application><activity-alias android:name="com.example.alias"><meta-data android:name="cow" android:value="@string/moo"/></activity-alias></application>`)},
+			want: outStart + `
+    <application>
+        <activity-alias android:name="com.example.alias">
+            <meta-data android:name="fox" android:value="@string/dingeringeding"/>
+        </activity-alias>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name: "tools:selector",
+			main: header + `
+    <permission android:name="permissionOne" tools:node="remove" tools:selector="com.example.lib1"/>
+</manifest>`,
+			libs: []string{
+				lib("com.example.lib1", `<permission android:name="permissionOne" android:protectionLevel="signature"/>`),
+				lib("com.example.lib2", `<permission android:name="permissionOne" android:protectionLevel="normal"/>`),
+			},
+			want: xmlHeader + `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example.app"/>
+`,
+		},
+		{
+			name: "tools:overrideLibrary",
+			main: header + `
+    <uses-sdk android:minSdkVersion="2" android:targetSdkVersion="22" tools:overrideLibrary="com.example.lib1, com.example.lib2"/>
+</manifest>`,
+			libs: []string{
+				lib("com.example.lib1", `<uses-sdk android:minSdkVersion="4" android:targetSdkVersion="22"/>`),
+				lib("com.example.lib2", `<uses-sdk android:minSdkVersion="5" android:targetSdkVersion="22"/>`),
+			},
+			want: outStart + `
+    <uses-sdk android:minSdkVersion="2" android:targetSdkVersion="22"/>
+</manifest>
+`,
+		},
+		{
+			name:   "placeholders",
+			values: manifestutils.Placeholders{"hostName": "www.example.com"},
+			main: header + `
+    <application>
+        <activity android:name=".Main">
+            <intent-filter>
+                <data android:scheme="https" android:host="${hostName}"/>
+            </intent-filter>
+        </activity>
+    </application>
+</manifest>`,
+			libs: []string{lib("com.example.lib1", `<application><provider android:name="Files" android:authorities="${applicationId}.files"/></application>`)},
+			want: outStart + `
+    <application>
+        <activity android:name="com.example.app.Main">
+            <intent-filter>
+                <data android:scheme="https" android:host="www.example.com"/>
+            </intent-filter>
+        </activity>
+        <provider android:name="com.example.lib1.Files" android:authorities="com.example.app.files"/>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name: "intent filters",
+			main: header + `
+    <application>
+        <activity android:name=".Main">
+            <intent-filter>
+                <action android:name="android.intent.action.MAIN"/>
+                <category android:name="android.intent.category.LAUNCHER"/>
+            </intent-filter>
+        </activity>
+    </application>
+</manifest>`,
+			libs: []string{lib("com.example.lib1", `<application><activity android:name="com.example.app.Main"><intent-filter><category android:name="android.intent.category.LAUNCHER"/><action android:name="android.intent.action.MAIN"/></intent-filter><intent-filter><action android:name="android.intent.action.VIEW"/></intent-filter></activity></application>`)},
+			want: outStart + `
+    <application>
+        <activity android:name="com.example.app.Main">
+            <intent-filter>
+                <action android:name="android.intent.action.MAIN"/>
+                <category android:name="android.intent.category.LAUNCHER"/>
+            </intent-filter>
+            <intent-filter>
+                <action android:name="android.intent.action.VIEW"/>
+            </intent-filter>
+        </activity>
+    </application>
+</manifest>
+`,
+		},
+		{
+			name: "features and permissions",
+			main: header + `
+    <uses-feature android:name="android.hardware.camera" android:required="false"/>
+    <uses-feature android:glEsVersion="0x00020000"/>
+    <uses-permission android:name="android.permission.CAMERA"/>
+</manifest>`,
+			libs: []string{
+				lib("com.example.lib1", `<uses-feature android:name="android.hardware.camera" android:required="true"/><uses-feature android:glEsVersion="0x00030000"/><uses-permission android:name="android.permission.CAMERA"/><permission android:name="com.example.lib1.READ" android:protectionLevel="signature"/>`),
+				lib("com.example.lib2", `<uses-feature android:glEsVersion="0x00020001"/><uses-permission android:name="android.permission.INTERNET"/>`),
+			},
+			want: outStart + `
+    <uses-feature android:name="android.hardware.camera" android:required="true"/>
+    <uses-feature android:glEsVersion="0x00030000"/>
+    <uses-permission android:name="android.permission.CAMERA"/>
+    <permission android:name="com.example.lib1.READ" android:protectionLevel="signature"/>
+    <uses-permission android:name="android.permission.INTERNET"/>
+</manifest>
+`,
+		},
+		{
+			name: "implied permissions",
+			main: header + `
+    <uses-sdk android:minSdkVersion="21" android:targetSdkVersion="34"/>
+</manifest>`,
+			libs: []string{lib("com.example.lib1", `<uses-sdk android:minSdkVersion="9" android:targetSdkVersion="15"/><uses-permission android:name="android.permission.READ_CONTACTS"/><uses-permission android:name="android.permission.WRITE_EXTERNAL_STORAGE"/>`)},
+			want: outStart + `
+    <uses-sdk android:minSdkVersion="21" android:targetSdkVersion="34"/>
+    <uses-permission android:name="android.permission.READ_CONTACTS"/>
+    <uses-permission android:name="android.permission.WRITE_EXTERNAL_STORAGE"/>
+    <uses-permission android:name="android.permission.READ_EXTERNAL_STORAGE"/>
+    <uses-permission android:name="android.permission.READ_CALL_LOG"/>
+</manifest>
+`,
+		},
+		{
+			name: "preview application",
+			main: header + `
+    <uses-sdk android:minSdkVersion="Tiramisu" android:targetSdkVersion="Tiramisu"/>
+</manifest>`,
+			libs: []string{lib("com.example.lib1", `<uses-sdk android:minSdkVersion="33" android:targetSdkVersion="15"/><uses-permission android:name="android.permission.READ_CONTACTS"/>`)},
+			want: outStart + `
+    <uses-sdk android:minSdkVersion="Tiramisu" android:targetSdkVersion="Tiramisu"/>
+    <uses-permission android:name="android.permission.READ_CONTACTS"/>
+    <uses-permission android:name="android.permission.READ_CALL_LOG"/>
+</manifest>
+`,
+		},
+		{
+			name: "library namespaces and comments",
+			main: header + `
+    <!-- Keep me. -->
+    <application/>
+</manifest>`,
+			libs: []string{`<manifest xmlns:android="http://schemas.android.com/apk/res/android" xmlns:dist="http://schemas.android.com/apk/distribution" package="com.example.lib1"><!-- Drop me. --><dist:module dist:instant="true"/></manifest>`},
+			want: xmlHeader + `<manifest xmlns:android="http://schemas.android.com/apk/res/android" xmlns:dist="http://schemas.android.com/apk/distribution" package="com.example.app">
+    <!-- Keep me. -->
+    <application/>
+    <dist:module dist:instant="true"/>
+</manifest>
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := mergeStrings(tc.values, tc.main, tc.libs...)
+			if err != nil {
+				t.Fatalf("merge returned unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("merge returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		main    string
+		libs    []string
+		wantErr string
+	}{
+		{
+			name: "attribute conflict",
+			main: header + `
+    <application android:label="App"/>
+</manifest>`,
+			libs:    []string{lib("com.example.lib1", `<application android:label="Lib"/>`)},
+			wantErr: "Attribute application@android:label value=(App) from main.xml:2:5\n\tis also present at lib1.xml:1:144 value=(Lib).\n\tSuggestion: add 'tools:replace=\"android:label\"' to <application> element at main.xml:2:5 to override.",
+		},
+		{
+			name: "strict",
+			main: header + `
+    <uses-permission android:name="android.permission.CAMERA" android:maxSdkVersion="28" tools:node="strict"/>
+</manifest>`,
+			libs:    []string{lib("com.example.lib1", `<uses-permission android:name="android.permission.CAMERA"/>`)},
+			wantErr: `tools:node="strict" requires them to be identical`,
+		},
+		{
+			name: "minSdkVersion",
+			main: header + `
+    <uses-sdk android:minSdkVersion="21"/>
+</manifest>`,
+			libs:    []string{lib("com.example.lib1", `<uses-sdk android:minSdkVersion="24"/>`)},
+			wantErr: "uses-sdk:minSdkVersion 21 cannot be smaller than version 24 declared in library lib1.xml:1:144",
+		},
+		{
+			name: "minSdkVersion minor",
+			main: header + `
+    <uses-sdk android:minSdkVersion="36"/>
+</manifest>`,
+			libs:    []string{lib("com.example.lib1", `<uses-sdk android:minSdkVersion="36.1"/>`)},
+			wantErr: "uses-sdk:minSdkVersion 36 cannot be smaller than version 36.1",
+		},
+		{
+			name: "minSdkVersion preview application",
+			main: header + `
+    <uses-sdk android:minSdkVersion="Tiramisu"/>
+</manifest>`,
+			libs:    []string{lib("com.example.lib1", `<uses-sdk android:minSdkVersion="34"/>`)},
+			wantErr: "uses-sdk:minSdkVersion Tiramisu cannot be smaller than version 34",
+		},
+		{
+			name: "minSdkVersion codename",
+			main: header + `
+    <uses-sdk android:minSdkVersion="34"/>
+</manifest>`,
+			libs:    []string{lib("com.example.lib1", `<uses-sdk android:minSdkVersion="VanillaIceCream"/>`)},
+			wantErr: "uses-sdk:minSdkVersion 34 cannot be different than version VanillaIceCream",
+		},
+		{
+			name: "duplicate",
+			main: header + `
+    <uses-permission android:name="android.permission.CAMERA"/>
+    <uses-permission android:name="android.permission.CAMERA"/>
+</manifest>`,
+			wantErr: "Element uses-permission#android.permission.CAMERA at main.xml:3:5 duplicated with element declared at main.xml:2:5",
+		},
+		{
+			name: "undefined placeholder",
+			main: header + `
+    <application android:label="${appName}"/>
+</manifest>`,
+			wantErr: "main.xml:2:5: attribute label of <application> uses undefined placeholder ${appName}",
+		},
+		{
+			name:    "not a manifest",
+			main:    `<resources/>`,
+			wantErr: "root element is not <manifest>",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := mergeStrings(nil, tc.main, tc.libs...)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("merge returned error %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlameLog(t *testing.T) {
+	main := header + `
+    <uses-sdk android:minSdkVersion="21" android:targetSdkVersion="34"/>
+    <application android:label="App">
+        <activity android:name=".Main" android:theme="@style/A" tools:replace="android:theme"/>
+    </application>
+</manifest>`
+	libs := []string{
+		lib("com.example.lib1", `
+<uses-sdk android:minSdkVersion="3"/>
+<application><activity android:name="com.example.app.Main" android:theme="@style/B" android:exported="true"/></application>`),
+	}
+	_, got, err := mergeStrings(nil, main, libs...)
+	if err != nil {
+		t.Fatalf("merge returned unexpected error: %v", err)
+	}
+	want := `-- Merging decision tree log ---
+manifest
+ADDED from main.xml:1:1
+	package
+		ADDED from main.xml:1:1
+MERGED from lib1.xml:1:1
+uses-sdk
+ADDED from main.xml:2:5
+	android:minSdkVersion
+		ADDED from main.xml:2:5
+	android:targetSdkVersion
+		ADDED from main.xml:2:5
+MERGED from lib1.xml:2:1
+application
+ADDED from main.xml:3:5
+	android:label
+		ADDED from main.xml:3:5
+MERGED from lib1.xml:3:1
+application>activity#com.example.app.Main
+ADDED from main.xml:4:9
+	android:name
+		ADDED from main.xml:4:9
+	android:theme
+		ADDED from main.xml:4:9
+MERGED from lib1.xml:3:14
+	android:name
+		MERGED from lib1.xml:3:14
+	android:theme
+		REJECTED from lib1.xml:3:14
+	android:exported
+		ADDED from lib1.xml:3:14
+uses-permission#android.permission.WRITE_EXTERNAL_STORAGE
+IMPLIED from lib1.xml to main.xml reason: com.example.lib1 has a targetSdkVersion < 4
+uses-permission#android.permission.READ_PHONE_STATE
+IMPLIED from lib1.xml to main.xml reason: com.example.lib1 has a targetSdkVersion < 4
+uses-permission#android.permission.READ_EXTERNAL_STORAGE
+IMPLIED from lib1.xml to main.xml reason: com.example.lib1 requested WRITE_EXTERNAL_STORAGE
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("merge returned log diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestDoWork(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	main := write("main.xml", header+`<application android:label="${label}"/></manifest>`)
+	l := write("lib.xml", lib("com.example.lib1", `<uses-permission android:name="android.permission.INTERNET"/>`))
+	out := filepath.Join(dir, "out.xml")
+	logPath := filepath.Join(dir, "log.txt")
+	values, err := manifestutils.LoadPlaceholders("", []string{"label=App"})
+	if err != nil {
+		t.Fatalf("LoadPlaceholders returned unexpected error: %v", err)
+	}
+	if err := doWork(main, []string{l}, values, out, logPath); err != nil {
+		t.Fatalf("doWork returned unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := outStart + `
+    <application android:label="App"/>
+    <uses-permission android:name="android.permission.INTERNET"/>
+</manifest>
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("doWork returned diff (-want, +got):\n%v", diff)
+	}
+	if b, err := os.ReadFile(logPath); err != nil || !strings.Contains(string(b), "uses-permission#android.permission.INTERNET\nADDED from "+l) {
+		t.Errorf("doWork wrote log %q (%v), want the added permission", b, err)
+	}
+}
@@ -0,0 +1,485 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mergemanifests
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"src/tools/ak/manifestutils"
+	"src/tools/ak/sdklevel"
+)
+
+const (
+	// Values of tools:node.
+	nodeMerge      = "merge"
+	nodeReplace    = "replace"
+	nodeRemove     = "remove"
+	nodeRemoveAll  = "removeAll"
+	nodeStrict     = "strict"
+	nodeAttrsOnly  = "merge-only-attributes"
+	permissionPkg  = "android.permission."
+	usesPermission = "uses-permission"
+	usesSdk        = "uses-sdk"
+)
+
+var (
+	minSdkAttr    = xml.Name{Space: manifestutils.NameSpace, Local: "minSdkVersion"}
+	targetSdkAttr = xml.Name{Space: manifestutils.NameSpace, Local: "targetSdkVersion"}
+	nameAttr      = xml.Name{Space: manifestutils.NameSpace, Local: "name"}
+)
+
+// blameLog records the decisions taken while merging, in the format of the decision tree log of
+// the Android Gradle plugin merger.
+type blameLog struct {
+	ids     []string
+	records map[string][]string
+}
+
+func newBlameLog() *blameLog {
+	return &blameLog{records: make(map[string][]string)}
+}
+
+func (l *blameLog) add(id, format string, a ...any) {
+	if _, ok := l.records[id]; !ok {
+		l.ids = append(l.ids, id)
+	}
+	l.records[id] = append(l.records[id], fmt.Sprintf(format, a...))
+}
+
+func (l *blameLog) String() string {
+	var b strings.Builder
+	b.WriteString("-- Merging decision tree log ---\n")
+	for _, id := range l.ids {
+		b.WriteString(id + "\n")
+		for _, r := range l.records[id] {
+			b.WriteString(r + "\n")
+		}
+	}
+	return b.String()
+}
+
+// merger merges library manifests, in decreasing priority, into the main manifest.
+type merger struct {
+	main *manifestFile
+	log  *blameLog
+	errs []string
+	// prefixes maps namespace URIs to the prefixes declared for them in any of the manifests.
+	prefixes map[string]string
+}
+
+func newMerger(main *manifestFile) *merger {
+	m := &merger{main: main, log: newBlameLog(), prefixes: map[string]string{manifestutils.NameSpace: "android"}}
+	m.declare(main)
+	m.logAdded(main.root.name.Local, main.root, main)
+	return m
+}
+
+func (m *merger) declare(f *manifestFile) {
+	for _, ns := range f.root.ns {
+		if _, ok := m.prefixes[ns.Value]; !ok && ns.Name.Space == xmlnsPrefix {
+			m.prefixes[ns.Value] = ns.Name.Local
+		}
+	}
+}
+
+func (m *merger) errorf(format string, a ...any) {
+	m.errs = append(m.errs, fmt.Sprintf(format, a...))
+}
+
+// attrName returns the name of the attribute as written in manifests, e.g. android:name.
+func (m *merger) attrName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	if p, ok := m.prefixes[name.Space]; ok {
+		return p + ":" + name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// applies reports whether a tools marker of n applies to elements of the library lib, which is
+// not the case when tools:selector names another package.
+func applies(n *node, lib *manifestFile) bool {
+	sel, ok := n.tools["selector"]
+	return !ok || sel == lib.pkg
+}
+
+func listed(list, name string) bool {
+	for _, s := range strings.Split(list, ",") {
+		if strings.TrimSpace(s) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *merger) logAdded(id string, n *node, f *manifestFile) {
+	m.log.add(id, "ADDED from %s", n.pos)
+	for _, a := range n.attrs {
+		m.log.add(id, "\t%s\n\t\tADDED from %s", m.attrName(a.Name), n.origins[a.Name])
+	}
+	for _, c := range n.children {
+		if !c.isComment() {
+			m.logAdded(childID(id, c), c, f)
+		}
+	}
+}
+
+func childID(parent string, c *node) string {
+	if parent == manifestutils.ElemManifest {
+		return c.id()
+	}
+	return parent + ">" + c.id()
+}
+
+// merge merges the library lib into the main manifest.
+func (m *merger) merge(lib *manifestFile) {
+	m.declare(lib)
+	m.log.add(m.main.root.name.Local, "MERGED from %s", lib.root.pos)
+	m.mergeChildren(manifestutils.ElemManifest, m.main.root, lib.root, lib)
+	m.implyPermissions(lib)
+}
+
+func (m *merger) mergeChildren(id string, hi, lo *node, lib *manifestFile) {
+	for _, c := range lo.children {
+		if c.isComment() {
+			continue
+		}
+		cid := childID(id, c)
+		if hi == m.main.root && c.name.Local == usesSdk {
+			m.checkUsesSdk(cid, c, lib)
+			continue
+		}
+		if r := removesAll(hi, c.name.Local, lib); r != nil {
+			m.log.add(cid, "REJECTED from %s, removed by tools:node=\"removeAll\" at %s", c.pos, r.pos)
+			continue
+		}
+		existing := hi.child(c.id())
+		if existing == nil {
+			hi.children = append(hi.children, c.clone())
+			m.logAdded(cid, c, lib)
+			continue
+		}
+		m.mergeElement(cid, existing, c, lib)
+	}
+}
+
+// removesAll returns the child of parent removing all lower priority elements of the given name.
+func removesAll(parent *node, name string, lib *manifestFile) *node {
+	for _, c := range parent.children {
+		if !c.isComment() && c.name.Local == name && c.tools["node"] == nodeRemoveAll && applies(c, lib) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (m *merger) mergeElement(id string, hi, lo *node, lib *manifestFile) {
+	if applies(hi, lib) {
+		switch hi.tools["node"] {
+		case nodeRemove, nodeRemoveAll:
+			m.log.add(id, "REJECTED from %s, removed by tools:node=%q at %s", lo.pos, hi.tools["node"], hi.pos)
+			return
+		case nodeReplace:
+			m.log.add(id, "REJECTED from %s, replaced by %s", lo.pos, hi.pos)
+			return
+		case nodeStrict:
+			if !equal(hi, lo) {
+				m.errorf("Element %s at %s conflicts with element declared at %s: tools:node=\"strict\" requires them to be identical", id, lo.pos, hi.pos)
+			}
+			return
+		}
+	}
+	switch lo.tools["node"] {
+	case nodeRemove, nodeRemoveAll:
+		// Removal markers only remove elements of even lower priority.
+		return
+	}
+	m.log.add(id, "MERGED from %s", lo.pos)
+	m.mergeAttrs(id, hi, lo, lib)
+	if hi.tools["node"] == nodeAttrsOnly && applies(hi, lib) {
+		return
+	}
+	m.mergeChildren(id, hi, lo, lib)
+}
+
+func (m *merger) mergeAttrs(id string, hi, lo *node, lib *manifestFile) {
+	for _, a := range lo.attrs {
+		name := m.attrName(a.Name)
+		origin := lo.origins[a.Name]
+		if listed(hi.tools["remove"], name) && applies(hi, lib) {
+			m.log.add(id, "\t%s\n\t\tREMOVED from %s", name, origin)
+			continue
+		}
+		v, ok := hi.attr(a.Name)
+		switch {
+		case !ok:
+			hi.setAttr(a.Name, a.Value, origin)
+			m.log.add(id, "\t%s\n\t\tADDED from %s", name, origin)
+		case v == a.Value:
+			m.log.add(id, "\t%s\n\t\tMERGED from %s", name, origin)
+		case listed(hi.tools["replace"], name) && applies(hi, lib):
+			m.log.add(id, "\t%s\n\t\tREJECTED from %s", name, origin)
+		default:
+			if merged, ok := mergeValues(hi.name.Local, a.Name, v, a.Value); ok {
+				hi.setAttr(a.Name, merged, hi.origins[a.Name])
+				m.log.add(id, "\t%s\n\t\tMERGED from %s", name, origin)
+				continue
+			}
+			m.errorf("Attribute %s@%s value=(%s) from %s\n\tis also present at %s value=(%s).\n\tSuggestion: add 'tools:replace=\"%s\"' to <%s> element at %s to override.",
+				id, name, v, hi.origins[a.Name], origin, a.Value, name, hi.name.Local, hi.pos)
+		}
+	}
+}
+
+// mergeValues merges conflicting values of the attributes which are not simply overridden.
+func mergeValues(elem string, name xml.Name, hi, lo string) (string, bool) {
+	if name.Space != manifestutils.NameSpace {
+		return "", false
+	}
+	switch {
+	case (elem == "uses-feature" || elem == "uses-library") && name.Local == "required":
+		// The element is required if any manifest requires it.
+		if hi == "true" || lo == "true" {
+			return "true", true
+		}
+		return "", false
+	case elem == "uses-feature" && name.Local == "glEsVersion":
+		h, err := strconv.ParseUint(strings.TrimPrefix(hi, "0x"), 16, 32)
+		if err != nil {
+			return "", false
+		}
+		l, err := strconv.ParseUint(strings.TrimPrefix(lo, "0x"), 16, 32)
+		if err != nil {
+			return "", false
+		}
+		if l > h {
+			return lo, true
+		}
+		return hi, true
+	}
+	return "", false
+}
+
+func equal(a, b *node) bool {
+	if a.name != b.name || len(a.attrs) != len(b.attrs) {
+		return false
+	}
+	for _, attr := range b.attrs {
+		if v, ok := a.attr(attr.Name); !ok || v != attr.Value {
+			return false
+		}
+	}
+	var ac, bc []*node
+	for _, c := range a.children {
+		if !c.isComment() {
+			ac = append(ac, c)
+		}
+	}
+	for _, c := range b.children {
+		if !c.isComment() {
+			bc = append(bc, c)
+		}
+	}
+	if len(ac) != len(bc) {
+		return false
+	}
+	for i := range ac {
+		if !equal(ac[i], bc[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// minSdk returns the minSdkVersion of the uses-sdk element n, which defaults to 1.
+func minSdk(n *node) string {
+	if n != nil {
+		if v, ok := n.attr(minSdkAttr); ok {
+			return v
+		}
+	}
+	return "1"
+}
+
+// targetSdk returns the targetSdkVersion of the uses-sdk element n, which defaults to its
+// minSdkVersion.
+func targetSdk(n *node) string {
+	if n != nil {
+		if v, ok := n.attr(targetSdkAttr); ok {
+			return v
+		}
+	}
+	return minSdk(n)
+}
+
+// checkUsesSdk verifies that the main manifest does not support older platforms than the library,
+// unless the library is listed in tools:overrideLibrary. The uses-sdk element of libraries is
+// never merged.
+func (m *merger) checkUsesSdk(id string, lib *node, f *manifestFile) {
+	app := m.main.root.child(usesSdk)
+	m.log.add(id, "MERGED from %s", lib.pos)
+	if app != nil && listed(app.tools["overrideLibrary"], f.pkg) {
+		return
+	}
+	appMin, libMin := minSdk(app), minSdk(lib)
+	if appMin == libMin {
+		return
+	}
+	different := func() {
+		m.errorf("uses-sdk:minSdkVersion %s cannot be different than version %s declared in library %s\n\tSuggestion: use tools:overrideLibrary=\"%s\" to force usage", appMin, libMin, lib.pos, f.pkg)
+	}
+	l, err := sdklevel.Parse(libMin)
+	if err != nil {
+		different()
+		return
+	}
+	a, err := sdklevel.Parse(appMin)
+	if err != nil {
+		return
+	}
+	if a.IsPreview() && !l.IsPreview() {
+		// A preview application compares with a stable library as the API level its codename is
+		// released as. Unknown codenames are newer than any API level.
+		if a.Major == 0 {
+			return
+		}
+		a = sdklevel.API(a.Major)
+	}
+	c, err := a.Compare(l)
+	switch {
+	case err != nil:
+		different()
+	case c < 0:
+		m.errorf("uses-sdk:minSdkVersion %s cannot be smaller than version %s declared in library %s as the library might be using APIs not available in %s\n\tSuggestion: use a compatible library with a minSdk of at most %s,\n\t\tor increase this project's minSdk version to at least %s,\n\t\tor use tools:overrideLibrary=\"%s\" to force usage (may lead to runtime failures)",
+			appMin, libMin, lib.pos, appMin, appMin, libMin, f.pkg)
+	}
+}
+
+// implyPermissions adds the permissions which the platform grants implicitly to applications
+// targeting old platforms, when a library declaring such an old targetSdkVersion is merged into an
+// application targeting a newer one. Libraries without uses-sdk element imply nothing.
+func (m *merger) implyPermissions(lib *manifestFile) {
+	libSdk := lib.root.child(usesSdk)
+	if libSdk == nil {
+		return
+	}
+	libTarget, err := sdklevel.Parse(targetSdk(libSdk))
+	if err != nil || libTarget.IsPreview() {
+		// Preview platforms are newer than those implying permissions.
+		return
+	}
+	appTarget, err := sdklevel.Parse(targetSdk(m.main.root.child(usesSdk)))
+	switch {
+	case err != nil, appTarget.IsPreview() && appTarget.Major == 0:
+		// An unknown codename targets a platform newer than any numbered one.
+		appTarget = sdklevel.API(math.MaxInt)
+	case appTarget.IsPreview():
+		appTarget = sdklevel.API(appTarget.Major)
+	}
+	below := func(l sdklevel.Level, api int) bool {
+		c, _ := l.Compare(sdklevel.API(api))
+		return c < 0
+	}
+	declared := func(perm string) bool {
+		return lib.root.child(usesPermission+"#"+permissionPkg+perm) != nil
+	}
+	imply := func(perm, reason string) {
+		id := usesPermission + "#" + permissionPkg + perm
+		if m.main.root.child(id) != nil || removesAll(m.main.root, usesPermission, lib) != nil {
+			return
+		}
+		n := &node{
+			name:    xml.Name{Local: usesPermission},
+			attrs:   []xml.Attr{{Name: nameAttr, Value: permissionPkg + perm}},
+			origins: map[xml.Name]string{nameAttr: libSdk.pos},
+			tools:   make(map[string]string),
+			pos:     libSdk.pos,
+		}
+		m.main.root.children = append(m.main.root.children, n)
+		m.log.add(id, "IMPLIED from %s to %s reason: %s %s", lib.name, m.main.name, lib.pkg, reason)
+	}
+	if below(libTarget, 4) && !below(appTarget, 4) {
+		imply("WRITE_EXTERNAL_STORAGE", "has a targetSdkVersion < 4")
+		imply("READ_PHONE_STATE", "has a targetSdkVersion < 4")
+	}
+	if below(libTarget, 16) && !below(appTarget, 16) {
+		if declared("WRITE_EXTERNAL_STORAGE") || below(libTarget, 4) && !below(appTarget, 4) {
+			imply("READ_EXTERNAL_STORAGE", "requested WRITE_EXTERNAL_STORAGE")
+		}
+		if declared("READ_CONTACTS") {
+			imply("READ_CALL_LOG", "declared READ_CONTACTS and has a targetSdkVersion < 16")
+		}
+		if declared("WRITE_CONTACTS") {
+			imply("WRITE_CALL_LOG", "declared WRITE_CONTACTS and has a targetSdkVersion < 16")
+		}
+	}
+}
+
+// finish removes the elements and attributes marked for removal along with all tools markers, and
+// declares on the manifest element the namespaces used by merged library elements.
+func (m *merger) finish() error {
+	if len(m.errs) > 0 {
+		return fmt.Errorf("manifest merger failed:\n%s", strings.Join(m.errs, "\n"))
+	}
+	m.prune(m.main.root)
+	declared := make(map[string]bool)
+	for _, ns := range m.main.root.ns {
+		declared[ns.Value] = true
+	}
+	var uris []string
+	m.main.root.walk(func(n *node) error {
+		for _, a := range n.attrs {
+			if a.Name.Space != "" && !declared[a.Name.Space] {
+				declared[a.Name.Space] = true
+				uris = append(uris, a.Name.Space)
+			}
+		}
+		return nil
+	})
+	sort.Strings(uris)
+	for _, uri := range uris {
+		if p, ok := m.prefixes[uri]; ok {
+			m.main.root.ns = append(m.main.root.ns, xml.Attr{Name: xml.Name{Space: xmlnsPrefix, Local: p}, Value: uri})
+		}
+	}
+	return nil
+}
+
+func (m *merger) prune(n *node) {
+	var kept []*node
+	for _, c := range n.children {
+		if !c.isComment() {
+			switch c.tools["node"] {
+			case nodeRemove, nodeRemoveAll:
+				continue
+			}
+			m.prune(c)
+		}
+		kept = append(kept, c)
+	}
+	n.children = kept
+	for _, a := range append([]xml.Attr(nil), n.attrs...) {
+		if listed(n.tools["remove"], m.attrName(a.Name)) {
+			n.removeAttr(a.Name)
+		}
+	}
+	n.tools = make(map[string]string)
+}
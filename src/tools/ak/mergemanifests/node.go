@@ -0,0 +1,313 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mergemanifests
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"src/tools/ak/manifestutils"
+)
+
+const (
+	toolsPrefix = "tools"
+	xmlnsPrefix = "xmlns"
+)
+
+var (
+	// singletons are the elements of which a parent holds at most one, which therefore need no key.
+	singletons = map[string]bool{
+		"manifest":           true,
+		"application":        true,
+		"uses-sdk":           true,
+		"supports-screens":   true,
+		"uses-configuration": true,
+		"compatible-screens": true,
+		"queries":            true,
+	}
+
+	// classAttrs lists the attributes holding class names, which may be relative to the package of
+	// the manifest.
+	classAttrs = map[string][]string{
+		"application":     {"name", "backupAgent"},
+		"activity":        {"name", "parentActivityName"},
+		"activity-alias":  {"name", "targetActivity"},
+		"service":         {"name"},
+		"receiver":        {"name"},
+		"provider":        {"name"},
+		"instrumentation": {"name"},
+	}
+)
+
+// node is an element of a manifest, or a comment.
+type node struct {
+	name xml.Name
+	// attrs holds the attributes other than namespace declarations and tools markers.
+	attrs []xml.Attr
+	// origins holds where the value of each attribute comes from, keyed by attribute name.
+	origins map[xml.Name]string
+	// ns holds the namespace declarations of the element.
+	ns []xml.Attr
+	// tools holds the tools:* markers of the element, keyed by local name.
+	tools    map[string]string
+	children []*node
+	// comment is set for comment nodes, which have no name.
+	comment xml.Comment
+	// pos is the location of the element, as file:line:column.
+	pos string
+}
+
+func (n *node) isComment() bool {
+	return n.comment != nil
+}
+
+func (n *node) attr(name xml.Name) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *node) androidAttr(local string) string {
+	v, _ := n.attr(xml.Name{Space: manifestutils.NameSpace, Local: local})
+	return v
+}
+
+func (n *node) setAttr(name xml.Name, value, origin string) {
+	n.origins[name] = origin
+	for i, a := range n.attrs {
+		if a.Name == name {
+			n.attrs[i].Value = value
+			return
+		}
+	}
+	n.attrs = append(n.attrs, xml.Attr{Name: name, Value: value})
+}
+
+func (n *node) removeAttr(name xml.Name) {
+	for i, a := range n.attrs {
+		if a.Name == name {
+			n.attrs = append(n.attrs[:i], n.attrs[i+1:]...)
+			delete(n.origins, name)
+			return
+		}
+	}
+}
+
+// key distinguishes the element from its siblings of the same name, following the element keys
+// of the Android Gradle plugin merger.
+func (n *node) key() string {
+	switch n.name.Local {
+	case "intent-filter", "intent":
+		var parts []string
+		for _, c := range n.children {
+			if !c.isComment() {
+				parts = append(parts, c.name.Local+":"+c.key())
+			}
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, "+")
+	case "data":
+		var parts []string
+		for _, a := range n.attrs {
+			parts = append(parts, a.Name.Local+"="+a.Value)
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, "+")
+	case "screen":
+		return n.androidAttr("screenSize") + "+" + n.androidAttr("screenDensity")
+	case "uses-feature":
+		if name := n.androidAttr("name"); name != "" {
+			return name
+		}
+		return "glEsVersion"
+	}
+	if singletons[n.name.Local] {
+		return ""
+	}
+	return n.androidAttr("name")
+}
+
+// id identifies the element among its siblings, e.g. activity#com.example.MainActivity.
+func (n *node) id() string {
+	if k := n.key(); k != "" {
+		return n.name.Local + "#" + k
+	}
+	return n.name.Local
+}
+
+func (n *node) child(id string) *node {
+	for _, c := range n.children {
+		if !c.isComment() && c.id() == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *node) clone() *node {
+	c := *n
+	c.attrs = append([]xml.Attr(nil), n.attrs...)
+	c.origins = make(map[xml.Name]string)
+	for k, v := range n.origins {
+		c.origins[k] = v
+	}
+	c.ns = append([]xml.Attr(nil), n.ns...)
+	c.tools = make(map[string]string)
+	for k, v := range n.tools {
+		c.tools[k] = v
+	}
+	c.children = nil
+	for _, ch := range n.children {
+		c.children = append(c.children, ch.clone())
+	}
+	return &c
+}
+
+// walk calls f for n and each of its descendant elements, parents first.
+func (n *node) walk(f func(*node) error) error {
+	if n.isComment() {
+		return nil
+	}
+	if err := f(n); err != nil {
+		return err
+	}
+	for _, c := range n.children {
+		if err := c.walk(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manifestFile is a parsed manifest.
+type manifestFile struct {
+	name string
+	root *node
+	// pkg is the package attribute of the manifest element.
+	pkg string
+}
+
+// parse reads the manifest r, whose name is used to report locations.
+func parse(name string, r io.Reader) (*manifestFile, error) {
+	doc, err := manifestutils.ReadDocument(xml.NewDecoder(r))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	root := newNode(name, doc.Root())
+	if root.name.Local != manifestutils.ElemManifest {
+		return nil, fmt.Errorf("%s: root element is not <manifest>", name)
+	}
+	pkg, _ := root.attr(xml.Name{Local: manifestutils.AttrPackage})
+	return &manifestFile{name: name, root: root, pkg: pkg}, nil
+}
+
+// newNode turns the element e of the manifest file into a node, splitting its namespace
+// declarations and tools markers from its attributes.
+func newNode(file string, e *manifestutils.Element) *node {
+	pos := fmt.Sprintf("%s:%d:%d", file, e.Line, e.Column)
+	n := &node{name: e.Name, origins: make(map[xml.Name]string), tools: make(map[string]string), pos: pos}
+	for _, a := range e.Attr {
+		switch {
+		case a.Name.Space == xmlnsPrefix || (a.Name.Space == "" && a.Name.Local == xmlnsPrefix):
+			if a.Value != manifestutils.ToolsNameSpace {
+				n.ns = append(n.ns, a)
+			}
+		case a.Name.Space == manifestutils.ToolsNameSpace || a.Name.Space == toolsPrefix:
+			n.tools[a.Name.Local] = a.Value
+		default:
+			n.attrs = append(n.attrs, a)
+			n.origins[a.Name] = pos
+		}
+	}
+	for _, t := range e.Children {
+		switch c := t.(type) {
+		case *manifestutils.Element:
+			n.children = append(n.children, newNode(file, c))
+		case xml.Comment:
+			n.children = append(n.children, &node{comment: c})
+		}
+	}
+	return n
+}
+
+// substitute replaces the ${name} placeholders of all attribute values.
+func (f *manifestFile) substitute(placeholders manifestutils.Placeholders) error {
+	var missing []string
+	f.root.walk(func(n *node) error {
+		for i, a := range n.attrs {
+			v, undefined := placeholders.Expand(a.Value)
+			for _, p := range undefined {
+				missing = append(missing, fmt.Sprintf("%s: attribute %s of <%s> uses undefined placeholder %s", n.pos, a.Name.Local, n.name.Local, p))
+			}
+			n.attrs[i].Value = v
+		}
+		return nil
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("%s", strings.Join(missing, "\n"))
+	}
+	return nil
+}
+
+// expandClassNames turns the class names relative to the package of the manifest, e.g.
+// .MainActivity, into fully qualified ones.
+func (f *manifestFile) expandClassNames() {
+	if f.pkg == "" {
+		return
+	}
+	f.root.walk(func(n *node) error {
+		for _, local := range classAttrs[n.name.Local] {
+			name := xml.Name{Space: manifestutils.NameSpace, Local: local}
+			v, ok := n.attr(name)
+			switch {
+			case !ok || v == "":
+			case strings.HasPrefix(v, "."):
+				n.setAttr(name, f.pkg+v, n.origins[name])
+			case !strings.Contains(v, "."):
+				n.setAttr(name, f.pkg+"."+v, n.origins[name])
+			}
+		}
+		return nil
+	})
+}
+
+// checkDuplicates reports keyed elements declared twice under the same parent.
+func (f *manifestFile) checkDuplicates() error {
+	var errs []string
+	f.root.walk(func(n *node) error {
+		seen := make(map[string]*node)
+		for _, c := range n.children {
+			if c.isComment() || c.key() == "" && !singletons[c.name.Local] {
+				continue
+			}
+			if prev, ok := seen[c.id()]; ok {
+				errs = append(errs, fmt.Sprintf("Element %s at %s duplicated with element declared at %s", c.id(), c.pos, prev.pos))
+				continue
+			}
+			seen[c.id()] = c
+		}
+		return nil
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mergemanifests
+
+import (
+	"strings"
+	"testing"
+)
+
+func parseNode(t *testing.T, s string) *node {
+	t.Helper()
+	f, err := parse("test.xml", strings.NewReader(`<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">`+s+`</manifest>`))
+	if err != nil {
+		t.Fatalf("parse(%q) failed: %v", s, err)
+	}
+	return f.root.children[0]
+}
+
+func TestID(t *testing.T) {
+	tests := []struct {
+		xml  string
+		want string
+	}{
+		{`<application android:name="App"/>`, "application"},
+		{`<uses-sdk android:minSdkVersion="21"/>`, "uses-sdk"},
+		{`<activity android:name="com.example.Main"/>`, "activity#com.example.Main"},
+		{`<uses-feature android:glEsVersion="0x00020000"/>`, "uses-feature#glEsVersion"},
+		{`<uses-feature android:name="android.hardware.camera"/>`, "uses-feature#android.hardware.camera"},
+		{`<intent-filter><category android:name="c"/><action android:name="a"/><data android:scheme="s" android:host="h"/></intent-filter>`, "intent-filter#action:a+category:c+data:host=h+scheme=s"},
+		{`<compatible-screens><screen android:screenSize="small" android:screenDensity="ldpi"/></compatible-screens>`, "compatible-screens"},
+	}
+	for _, tc := range tests {
+		if got := parseNode(t, tc.xml).id(); got != tc.want {
+			t.Errorf("id(%s) = %q, want %q", tc.xml, got, tc.want)
+		}
+	}
+	screen := parseNode(t, `<compatible-screens><screen android:screenSize="small" android:screenDensity="ldpi"/></compatible-screens>`).children[0]
+	if got, want := screen.id(), "screen#small+ldpi"; got != want {
+		t.Errorf("id(screen) = %q, want %q", got, want)
+	}
+}
+
+func TestExpandClassNames(t *testing.T) {
+	f, err := parse("test.xml", strings.NewReader(`<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+<application android:name=".App" android:backupAgent="Backup">
+<activity android:name="com.other.Main" android:parentActivityName=".Parent"/>
+<meta-data android:name=".NotAClass"/>
+</application>
+</manifest>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.expandClassNames()
+	app := f.root.children[0]
+	for _, tc := range []struct {
+		n     *node
+		local string
+		want  string
+	}{
+		{app, "name", "com.example.App"},
+		{app, "backupAgent", "com.example.Backup"},
+		{app.children[0], "name", "com.other.Main"},
+		{app.children[0], "parentActivityName", "com.example.Parent"},
+		{app.children[1], "name", ".NotAClass"},
+	} {
+		if got := tc.n.androidAttr(tc.local); got != tc.want {
+			t.Errorf("<%s> android:%s = %q, want %q", tc.n.name.Local, tc.local, got, tc.want)
+		}
+	}
+}
@@ -14,21 +14,27 @@
 
 // Package minsdkfloor is a AndroidManifest tool to enforce a floor on the
 // minSdkVersion attribute.
+//
+// The floors, and defaults, of the targetSdkVersion attribute are enforced alike. The resulting
+// manifest is then checked for minSdkVersion <= targetSdkVersion and minSdkVersion <= maxSdkVersion.
 package minsdkfloor
 
 import (
 	"bytes"
-	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path"
 	"strconv"
+	"strings"
 	"sync"
 
-	"src/common/golang/xml2"
+	"src/common/golang/flags"
+	"src/tools/ak/fixit"
+	"src/tools/ak/manifestutils"
+	"src/tools/ak/sdklevel"
 	"src/tools/ak/types"
 )
 
@@ -45,20 +51,28 @@ var (
 
 	initOnce sync.Once
 
-	actionFlag      string
-	manifestFlag    string
-	minSdkFloorFlag int
+	actionFlag         string
+	manifestFlag       string
+	minSdkFloorFlag    int
+	targetSdkFloorFlag int
 	// Needed for SET_DEFAULT
-	defaultMinSdkFlag string
+	defaultMinSdkFlag    string
+	defaultTargetSdkFlag string
 	// Needed for BUMP and SET_DEFAULT
 	outputFlag string
 	logFlag    string
+	// Needed for fix-it suggestions
+	labelFlag    string
+	fixesOutFlag string
+	// Placeholder values substituted before enforcing the floor
+	placeholdersFlag     flags.MultiString
+	placeholdersFileFlag string
 )
 
 const (
-	// action flag option: bump - update minSdkVersion if it's missing or has smaller value
+	// action flag option: bump - update minSdkVersion and targetSdkVersion if missing or smaller
 	bump = "bump"
-	// action flag option: set_default - update minSdkVersion if it's missing
+	// action flag option: set_default - update minSdkVersion and targetSdkVersion if missing
 	setDefault = "set_default"
 )
 
@@ -68,14 +82,20 @@ func Init() {
 		flag.StringVar(&actionFlag, "action", "", "Action to perform: either bump or set_default")
 		flag.StringVar(&manifestFlag, "manifest", "", "AndroidManifest.xml of the instrumentation APK")
 		flag.IntVar(&minSdkFloorFlag, "min_sdk_floor", 0, "Min SDK floor")
+		flag.IntVar(&targetSdkFloorFlag, "target_sdk_floor", 0, "Target SDK floor")
 		flag.StringVar(&defaultMinSdkFlag, "default_min_sdk", "", "Default min SDK")
+		flag.StringVar(&defaultTargetSdkFlag, "default_target_sdk", "", "Default target SDK")
 		flag.StringVar(&outputFlag, "output", "", "Output AndroidManifest.xml to generate.")
 		flag.StringVar(&logFlag, "log", "", "Path to write the log to")
+		flag.StringVar(&labelFlag, "label", "", "Label of the target, for fix-it suggestions")
+		flag.StringVar(&fixesOutFlag, "fixes_out", "", "(optional) Path to write the JSON fix-it suggestions to")
+		flag.Var(&placeholdersFlag, "placeholder", "Repeatable placeholder value to substitute: {name}={value}")
+		flag.StringVar(&placeholdersFileFlag, "placeholders_file", "", "Path to a file of {name}={value} placeholder values")
 	})
 }
 
 func desc() string {
-	return "Enforce minSdkVersion floor in generated manifest output"
+	return "Enforce minSdkVersion and targetSdkVersion floors in generated manifest output"
 }
 
 // Run is the entry point for mindex.
@@ -94,25 +114,53 @@ func Run() {
 	if err != nil {
 		log.Fatalf("Error reading manifest: %v\n", err)
 	}
+	placeholders, err := manifestutils.LoadPlaceholders(placeholdersFileFlag, placeholdersFlag)
+	if err != nil {
+		log.Fatalf("Error reading placeholders: %v\n", err)
+	}
+	// Placeholders left unresolved fail, whether or not values are given.
+	if manifest, err = manifestutils.ExpandManifest(manifest, placeholders); err != nil {
+		log.Fatalf("Error expanding placeholders: %v\n", err)
+	}
 
-	updatedManifest := manifest
-	logMessage := ""
+	var messages []string
+	var message string
 	if actionFlag == bump {
-		updatedManifest, logMessage, err = BumpMinSdk(manifest, minSdkFloorFlag)
+		manifest, message, err = BumpMinSdk(manifest, minSdkFloorFlag)
 	} else {
-		updatedManifest, logMessage, err = SetDefaultMinSdk(manifest, defaultMinSdkFlag)
+		manifest, message, err = SetDefaultMinSdk(manifest, defaultMinSdkFlag)
 	}
 	if err != nil {
-		log.Fatal(fmt.Printf("Error modifying minSdkVersion: %v\n", err))
+		log.Fatalf("Error modifying minSdkVersion: %v\n", err)
 	}
-
-	err = os.MkdirAll(path.Dir(outputFlag), 0755)
-	if err != nil && !os.IsExist(err) {
-		log.Fatal(err)
+	messages = append(messages, message)
+
+	// The SDK versions are only required to be in order when the targetSdkVersion is enforced too,
+	// otherwise manifests which were accepted before only get a warning.
+	enforceTarget := (actionFlag == bump && targetSdkFloorFlag != 0) || (actionFlag == setDefault && defaultTargetSdkFlag != "")
+	if enforceTarget {
+		if actionFlag == bump {
+			manifest, message, err = BumpTargetSdk(manifest, targetSdkFloorFlag)
+		} else {
+			manifest, message, err = SetDefaultTargetSdk(manifest, defaultTargetSdkFlag)
+		}
+		if err != nil {
+			log.Fatalf("Error modifying targetSdkVersion: %v\n", err)
+		}
+		messages = append(messages, message)
 	}
-	err = os.WriteFile(outputFlag, updatedManifest, 0644)
-	if err != nil {
-		log.Fatal(fmt.Printf("Error writing output manifest: %v\n", err))
+	validation, fixes, validationErr := validateSdkVersions(manifest)
+	if enforceTarget {
+		messages = append(messages, validation...)
+	} else if validationErr != nil {
+		log.Printf("Warning: invalid SDK versions in %s: %v\n", manifestFlag, validationErr)
+		validationErr = nil
+	}
+	for i := range fixes {
+		fixes[i].Label = labelFlag
+	}
+	if err := fixit.WriteFile(fixesOutFlag, fixes); err != nil {
+		log.Fatalf("Error writing fix-it suggestions: %v\n", err)
 	}
 
 	if logFlag != "" {
@@ -125,161 +173,169 @@ func Run() {
 			log.Fatalf("Error creating log file: %v\n", err)
 		}
 		defer file.Close()
-		_, err = file.WriteString(logMessage)
+		_, err = file.WriteString(strings.Join(messages, "\n"))
 		if err != nil {
 			log.Fatalf("Error writing to log: %v\n", err)
 			return
 		}
 	}
+	if validationErr != nil {
+		log.Fatalf("Invalid SDK versions in %s: %v\n", manifestFlag, validationErr)
+	}
+
+	err = os.MkdirAll(path.Dir(outputFlag), 0755)
+	if err != nil && !os.IsExist(err) {
+		log.Fatal(err)
+	}
+	err = os.WriteFile(outputFlag, manifest, 0644)
+	if err != nil {
+		log.Fatalf("Error writing output manifest: %v\n", err)
+	}
 }
 
-// addMinSdkVersionAttr adds the minSdkVersion attribute
-func addMinSdkVersionAttr(elem xml.StartElement, minSdk string, sdkType string) (xml.Token, string) {
-	elem.Attr = append(elem.Attr, xml.Attr{Name: xml.Name{Space: "android", Local: "minSdkVersion"}, Value: minSdk})
-	message := fmt.Sprintf("No minSdkVersion attribute found while %s is specified (%s). Min SDK added.", sdkType, minSdk)
-	return elem, message
+// sdkAttr is an SDK version attribute of the uses-sdk element.
+type sdkAttr struct {
+	// name is the attribute name, e.g. minSdkVersion.
+	name string
+	// title names the attribute in log messages, e.g. Min SDK.
+	title string
+	value func(*manifestutils.UsesSdk) *string
+	// implicit is the attribute the value defaults to when unset.
+	implicit *sdkAttr
+}
+
+// lowerTitle returns the title for use mid-sentence, e.g. min SDK.
+func (a sdkAttr) lowerTitle() string {
+	return strings.ToLower(a.title[:1]) + a.title[1:]
+}
+
+var (
+	minSdkAttr = sdkAttr{
+		name:  "minSdkVersion",
+		title: "Min SDK",
+		value: func(u *manifestutils.UsesSdk) *string { return &u.MinSdkVersion },
+	}
+	targetSdkAttr = sdkAttr{
+		name:     "targetSdkVersion",
+		title:    "Target SDK",
+		value:    func(u *manifestutils.UsesSdk) *string { return &u.TargetSdkVersion },
+		implicit: &minSdkAttr,
+	}
+	maxSdkAttr = sdkAttr{
+		name:  "maxSdkVersion",
+		title: "Max SDK",
+		value: func(u *manifestutils.UsesSdk) *string { return &u.MaxSdkVersion },
+	}
+)
+
+// addSdkVersionAttr adds the SDK version attribute
+func addSdkVersionAttr(usesSdk *manifestutils.UsesSdk, attr sdkAttr, sdk string, sdkType string) string {
+	*attr.value(usesSdk) = sdk
+	return fmt.Sprintf("No %s attribute found while %s is specified (%s). %s added.", attr.name, sdkType, sdk, attr.title)
 }
 
 // addUsesSdkElement creates an uses-sdk element
-func addUsesSdkElement(minSdk string, sdkType string) (string, xml.StartElement, xml.EndElement) {
-	usesSdkStart := xml.StartElement{
-		Name: xml.Name{Space: "", Local: "uses-sdk"},
-		Attr: []xml.Attr{{Name: xml.Name{Space: "android", Local: "minSdkVersion"}, Value: minSdk}},
-	}
-	usesSdkEnd := xml.EndElement{Name: xml.Name{Space: "", Local: "uses-sdk"}}
-	message := fmt.Sprintf("No uses-sdk element found while %s is specified (%s). Min SDK added.", sdkType, minSdk)
-	return message, usesSdkStart, usesSdkEnd
+func addUsesSdkElement(manifest *manifestutils.Manifest, attr sdkAttr, sdk string, sdkType string) string {
+	manifest.UsesSdk = &manifestutils.UsesSdk{}
+	*attr.value(manifest.UsesSdk) = sdk
+	return fmt.Sprintf("No uses-sdk element found while %s is specified (%s). %s added.", sdkType, sdk, attr.title)
 }
 
-// updateMinSdkVersion updates the minSdkVersion attribute if its value is numeric and less than input
-func updateMinSdkVersion(attr xml.Attr, minSdk string, sdkType string) (xml.Attr, string, bool, error) {
-	minSdkInt, err := strconv.Atoi(minSdk)
+// updateSdkVersion updates the SDK version attribute if it is less than input
+func updateSdkVersion(usesSdk *manifestutils.UsesSdk, attr sdkAttr, sdk string, sdkType string) (string, bool, error) {
+	sdkLevel, err := sdklevel.Parse(sdk)
 	if err != nil {
-		message := fmt.Sprintf("Input minSdk (%s) should be numeric", minSdk)
-		return attr, message, false, err
+		message := fmt.Sprintf("Input %s (%s) should be an SDK level", attr.name, sdk)
+		return message, false, err
 	}
-	sdkInt, err := strconv.Atoi(attr.Value)
+	value := attr.value(usesSdk)
+	attrLevel, err := sdklevel.Parse(*value)
 	message := ""
 	if err != nil {
-		message = fmt.Sprintf("Placeholder used for the minSdkVersion attribute (%s). Manifest unchanged.", attr.Value)
-		return attr, message, false, nil
+		return fmt.Sprintf("Invalid %s attribute: %v", attr.name, err), false, err
+	}
+	c, err := attrLevel.Compare(sdkLevel)
+	if err != nil {
+		return fmt.Sprintf("Cannot compare the %s attribute with the %s: %v", attr.name, sdkType, err), false, err
 	}
-	if sdkInt < minSdkInt {
-		attr.Value = minSdk
-		message = fmt.Sprintf("minSdkVersion attribute specified in the manifest (%d) is less than the %s (%s). Min SDK replaced.", sdkInt, sdkType, minSdk)
-		return attr, message, true, nil
+	if c < 0 {
+		*value = sdk
+		message = fmt.Sprintf("%s attribute specified in the manifest (%s) is less than the %s (%s). %s replaced.", attr.name, attrLevel, sdkType, sdk, attr.title)
+		return message, true, nil
 	}
-	message = fmt.Sprintf("minSdkVersion attribute specified in the manifest (%d) is no less than the %s (%s). Min SDK unchanged.", sdkInt, sdkType, minSdk)
-	return attr, message, false, nil
+	message = fmt.Sprintf("%s attribute specified in the manifest (%s) is no less than the %s (%s). %s unchanged.", attr.name, attrLevel, sdkType, sdk, attr.title)
+	return message, false, nil
 }
 
 // BumpMinSdk ensures that the minSdkVersion attribute is >= than the specified floor,
 // and if the attribute is either not specified or less than the floor,
 // sets it to the floor.
 func BumpMinSdk(manifest []byte, newMinSdk int) ([]byte, string, error) {
-	if newMinSdk == 0 {
-		return manifest, "No min SDK floor specified. Manifest unchanged.", nil
+	return bumpSdk(manifest, minSdkAttr, newMinSdk)
+}
+
+// BumpTargetSdk ensures that the targetSdkVersion attribute is >= than the specified floor. If the
+// attribute is not specified, the minSdkVersion it defaults to is checked instead, and the
+// attribute set to the floor if that is less.
+func BumpTargetSdk(manifest []byte, newTargetSdk int) ([]byte, string, error) {
+	return bumpSdk(manifest, targetSdkAttr, newTargetSdk)
+}
+
+func bumpSdk(manifest []byte, attr sdkAttr, floor int) ([]byte, string, error) {
+	if floor == 0 {
+		return manifest, fmt.Sprintf("No %s floor specified. Manifest unchanged.", attr.lowerTitle()), nil
 	}
-	minSdkStr := fmt.Sprintf("%d", newMinSdk)
-	return enforceMinSDKVersion(manifest, minSdkStr, "floor", true)
+	return enforceSdkVersion(manifest, attr, strconv.Itoa(floor), "floor", true)
 }
 
 // SetDefaultMinSdk set minSdkVersion if it's undefined
 func SetDefaultMinSdk(manifest []byte, defaultMinSdk string) ([]byte, string, error) {
-	if defaultMinSdk == "" {
-		return manifest, "No default min SDK floor specified. Manifest unchanged.", nil
-	}
+	return setDefaultSdk(manifest, minSdkAttr, defaultMinSdk)
+}
 
-	return enforceMinSDKVersion(manifest, defaultMinSdk, "default", false)
+// SetDefaultTargetSdk set targetSdkVersion if it's undefined
+func SetDefaultTargetSdk(manifest []byte, defaultTargetSdk string) ([]byte, string, error) {
+	return setDefaultSdk(manifest, targetSdkAttr, defaultTargetSdk)
 }
 
-func enforceMinSDKVersion(
+func setDefaultSdk(manifest []byte, attr sdkAttr, defaultSdk string) ([]byte, string, error) {
+	if defaultSdk == "" {
+		return manifest, fmt.Sprintf("No default %s floor specified. Manifest unchanged.", attr.lowerTitle()), nil
+	}
+	return enforceSdkVersion(manifest, attr, defaultSdk, "default", false)
+}
+
+func enforceSdkVersion(
 	manifest []byte,
-	minSdk string,
+	attr sdkAttr,
+	sdk string,
 	sdkType string,
-	shouldUpdateMinSdkVersion bool) ([]byte, string, error) {
+	shouldUpdateSdkVersion bool) ([]byte, string, error) {
 
-	decoder := xml.NewDecoder(bytes.NewReader(manifest))
-	var elements []xml.Token
+	m, err := manifestutils.ReadManifest(bytes.NewReader(manifest))
+	if err != nil {
+		return nil, "", err
+	}
 	var message string
-	var xmlUpdated bool = false
-	usesSdkAdded := false
-
-	for {
-		token, err := decoder.Token()
-		if err == io.EOF {
+	xmlUpdated := false
+	switch {
+	case m.UsesSdk == nil:
+		message = addUsesSdkElement(m, attr, sdk, sdkType)
+		xmlUpdated = true
+	case *attr.value(m.UsesSdk) == "":
+		if implicit := implicitSdkVersion(m.UsesSdk, attr, sdk); shouldUpdateSdkVersion && implicit != "" {
+			message = fmt.Sprintf("No %s attribute found, it defaults to the %s (%s) which is no less than the %s (%s). %s unchanged.", attr.name, attr.implicit.name, implicit, sdkType, sdk, attr.title)
 			break
-		} else if err != nil {
-			return nil, "", err
 		}
-
-		switch token.(type) {
-		case xml.ProcInst:
-			// For some reason the []byte part need to be cloned, otherwise encoder produces nonsense
-			elements = append(elements, token.(xml.ProcInst).Copy())
-			continue
-
-		case xml.Comment:
-			// For some reason the []byte part need to be cloned, otherwise encoder produces nonsense
-			elements = append(elements, token.(xml.Comment).Copy())
-			continue
-
-		case xml.CharData:
-			// For some reason the []byte part need to be cloned, otherwise encoder produces nonsense
-			elements = append(elements, token.(xml.CharData).Copy())
-			continue
-
-		case xml.StartElement:
-			if token.(xml.StartElement).Name.Local == "uses-sdk" {
-				minSdkVersionExists := false
-				elem := token.(xml.StartElement)
-				for i, attr := range elem.Attr {
-					if attr.Name.Local == "minSdkVersion" {
-						minSdkVersionExists = true
-						if shouldUpdateMinSdkVersion {
-							updated := false
-							attr, message, updated, err = updateMinSdkVersion(attr, minSdk, sdkType)
-							if err != nil {
-								return nil, "", err
-							}
-							xmlUpdated = xmlUpdated || updated
-							elem.Attr[i] = attr
-						}
-					}
-				}
-				if !minSdkVersionExists {
-					token, message = addMinSdkVersionAttr(elem, minSdk, sdkType)
-					xmlUpdated = true
-				}
-				usesSdkAdded = true
-			}
-			// Create android namespace if not presents,
-			// minSdkVersion requires android namepsace, so it also implies the missing minSdkVersion attr
-			if token.(xml.StartElement).Name.Local == "manifest" {
-				elem := token.(xml.StartElement)
-				nsDefined := false
-				for _, attr := range elem.Attr {
-					if attr.Name.Space == "xmlns" && attr.Name.Local == "android" {
-						nsDefined = true
-					}
-				}
-				if !nsDefined {
-					elem.Attr = append(elem.Attr, xml.Attr{Name: xml.Name{Space: "xmlns", Local: "android"}, Value: "http://schemas.android.com/apk/res/android"})
-					token = elem
-				}
-			}
-
-		case xml.EndElement:
-			// Create 'uses-sdk' if not already presents
-			if token.(xml.EndElement).Name.Local == "manifest" && !usesSdkAdded {
-				msg, usesSdkStart, usesSdkEnd := addUsesSdkElement(minSdk, sdkType)
-				message = msg
-				elements = append(elements, usesSdkStart, usesSdkEnd, xml.CharData{'\n'})
-				xmlUpdated = true
-				usesSdkAdded = true
-			}
+		message = addSdkVersionAttr(m.UsesSdk, attr, sdk, sdkType)
+		xmlUpdated = true
+	case shouldUpdateSdkVersion && !isPlaceholder(*attr.value(m.UsesSdk)):
+		message, xmlUpdated, err = updateSdkVersion(m.UsesSdk, attr, sdk, sdkType)
+		if err != nil {
+			return nil, "", err
 		}
-		elements = append(elements, token)
+	default:
+		message = fmt.Sprintf("%s attribute specified in the manifest (%s). %s unchanged.", attr.name, *attr.value(m.UsesSdk), attr.title)
 	}
 	// If no changes to XML content, skips encode and returns input
 	if !xmlUpdated {
@@ -288,16 +344,119 @@ func enforceMinSDKVersion(
 
 	// Re-encode the modified XML
 	buffer := bytes.Buffer{}
-	encoder := xml2.NewEncoder(&buffer)
+	if err := m.Write(&buffer); err != nil {
+		return nil, "", err
+	}
+	return buffer.Bytes(), message, nil
+}
 
-	for _, token := range elements {
-		err := encoder.EncodeToken(token)
+// isPlaceholder reports whether v is a placeholder. Run expands them first, so only callers of
+// the exported functions see one, and the attribute is left unchanged.
+func isPlaceholder(v string) bool {
+	_, err := sdklevel.Parse(v)
+	return errors.Is(err, sdklevel.ErrPlaceholder)
+}
+
+// implicitSdkVersion returns the value of the attribute the unset attr defaults to, if it is no
+// less than sdk.
+func implicitSdkVersion(usesSdk *manifestutils.UsesSdk, attr sdkAttr, sdk string) string {
+	if attr.implicit == nil {
+		return ""
+	}
+	v := *attr.implicit.value(usesSdk)
+	vLevel, err := sdklevel.Parse(v)
+	if err != nil {
+		return ""
+	}
+	sdkLevel, err := sdklevel.Parse(sdk)
+	if err != nil {
+		return ""
+	}
+	if c, err := vLevel.Compare(sdkLevel); err != nil || c < 0 {
+		return ""
+	}
+	return v
+}
+
+// ValidateSdkVersions checks that the minSdkVersion of the manifest is no more than its
+// targetSdkVersion and maxSdkVersion, returning a message for each attribute checked. Unset and
+// placeholder values are not checked, while preview values only compare with preview values,
+// except that a preview minSdkVersion compares with maxSdkVersion as the API level it is released
+// as.
+func ValidateSdkVersions(manifest []byte) ([]string, error) {
+	messages, _, err := validateSdkVersions(manifest)
+	return messages, err
+}
+
+// validateSdkVersions is ValidateSdkVersions, also returning the fixes of the manifest_values
+// attribute raising the attributes lower than minSdkVersion. The fixes have no label.
+func validateSdkVersions(manifest []byte) ([]string, []fixit.Fix, error) {
+	m, err := manifestutils.ReadManifest(bytes.NewReader(manifest))
+	if err != nil {
+		return nil, nil, err
+	}
+	if m.UsesSdk == nil {
+		return nil, nil, nil
+	}
+	minLevel, err := parseSdkVersion(m.UsesSdk, minSdkAttr)
+	if err != nil || minLevel.IsZero() {
+		return nil, nil, err
+	}
+	var messages []string
+	var fixes []fixit.Fix
+	var errs []string
+	for _, attr := range []sdkAttr{targetSdkAttr, maxSdkAttr} {
+		v, err := parseSdkVersion(m.UsesSdk, attr)
 		if err != nil {
-			return nil, "", err
+			return messages, fixes, err
 		}
+		if v.IsZero() {
+			continue
+		}
+		min := minLevel
+		if attr.name == maxSdkAttr.name && min.IsPreview() && !v.IsPreview() {
+			// maxSdkVersion is always an API level, so a preview minSdkVersion compares as the API
+			// level its codename is released as. Unknown codenames are not checked.
+			if min.Major == 0 {
+				messages = append(messages, fmt.Sprintf("%s attribute (%s) is not checked against the unknown preview minSdkVersion attribute (%s).", attr.name, v, minLevel))
+				continue
+			}
+			min = sdklevel.API(min.Major)
+		}
+		var message string
+		c, err := v.Compare(min)
+		switch {
+		case err != nil:
+			message = fmt.Sprintf("%s attribute (%s) does not compare with the minSdkVersion attribute (%s): %v.", attr.name, v, minLevel, err)
+			errs = append(errs, message)
+		case c < 0:
+			message = fmt.Sprintf("%s attribute (%s) is less than the minSdkVersion attribute (%s).", attr.name, v, minLevel)
+			errs = append(errs, message)
+			fixes = append(fixes, fixit.Fix{Attr: "manifest_values", Key: attr.name, OldValue: v.String(), NewValue: min.String(), Message: message})
+		default:
+			message = fmt.Sprintf("%s attribute (%s) is no less than the minSdkVersion attribute (%s).", attr.name, v, minLevel)
+		}
+		messages = append(messages, message)
+	}
+	if len(errs) > 0 {
+		return messages, fixes, errors.New(strings.Join(errs, " "))
 	}
+	return messages, fixes, nil
+}
 
-	encoder.Flush()
-	// Make uses-sdk a self-closing element
-	return bytes.ReplaceAll(buffer.Bytes(), []byte("></uses-sdk>"), []byte("/>")), message, nil
+// parseSdkVersion returns the level of the attribute, or the zero Level if it is unset or a
+// placeholder.
+func parseSdkVersion(usesSdk *manifestutils.UsesSdk, attr sdkAttr) (sdklevel.Level, error) {
+	v := *attr.value(usesSdk)
+	if v == "" {
+		return sdklevel.Level{}, nil
+	}
+	l, err := sdklevel.Parse(v)
+	if errors.Is(err, sdklevel.ErrPlaceholder) {
+		return sdklevel.Level{}, nil
+	}
+	if err != nil {
+		return sdklevel.Level{}, fmt.Errorf("%s attribute: %v", attr.name, err)
+	}
+	return l, nil
 }
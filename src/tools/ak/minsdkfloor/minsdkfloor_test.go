@@ -18,7 +18,12 @@ package minsdkfloor
 
 import (
 	"bytes"
+	"strings"
 	"testing"
+
+	"src/tools/ak/fixit"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 var (
@@ -92,6 +97,30 @@ External comment
 <manifest package="com.example" xmlns:android="http://schemas.android.com/apk/res/android">
 <uses-sdk android:minSdkVersion="24"/>
 </manifest>
+`)
+
+	ManifestTargetSdk = []byte(`<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+<uses-sdk android:minSdkVersion="12" android:targetSdkVersion="28"/>
+</manifest>
+`)
+
+	ManifestTargetSdkUpdated = []byte(`<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+<uses-sdk android:minSdkVersion="12" android:targetSdkVersion="34"/>
+</manifest>
+`)
+
+	ManifestTargetSdkOnly = []byte(`<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+<uses-sdk android:targetSdkVersion="34"/>
+</manifest>
+`)
+
+	ManifestMinSdkHigh = []byte(`<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+<uses-sdk android:minSdkVersion="35"/>
+</manifest>
 `)
 
 	ManifestManifestElementOnly = []byte(`
@@ -172,3 +201,245 @@ func TestSetDefaultMinSdkFloor(t *testing.T) {
 		})
 	}
 }
+
+func TestBumpTargetSdkFloor(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		sdk      int
+		input    []byte
+		expected []byte
+	}{
+		{"Add uses-sdk tag when missing", 34, ManifestNoUsesSdk, ManifestTargetSdkOnly},
+		{"Add targetSdkVersion attribute when missing and minSdkVersion is lower", 34, ManifestMinSdk, ManifestTargetSdkUpdated},
+		{"No change when targetSdkVersion is missing and minSdkVersion is greater", 34, ManifestMinSdkHigh, ManifestMinSdkHigh},
+		{"No change when newSdk is not specified", 0, ManifestTargetSdk, ManifestTargetSdk},
+		{"Bump targetSdkVersion", 34, ManifestTargetSdk, ManifestTargetSdkUpdated},
+		{"Noop when targetSdkVersion is greater and given sdk", 21, ManifestTargetSdk, ManifestTargetSdk},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			manifest, _, err := BumpTargetSdk(tc.input, tc.sdk)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(manifest, tc.expected) {
+				t.Errorf("Updated XML doesn't match expected:\nGot:\n%s\nExpected:\n%s", manifest, tc.expected)
+			}
+		})
+	}
+}
+
+func TestSetDefaultTargetSdk(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		sdk      string
+		input    []byte
+		expected []byte
+	}{
+		{"Add uses-sdk tag when missing", "34", ManifestNoUsesSdk, ManifestTargetSdkOnly},
+		{"Add targetSdkVersion attribute when missing", "34", ManifestMinSdk, ManifestTargetSdkUpdated},
+		{"No change when newSdk is not specified", "", ManifestMinSdk, ManifestMinSdk},
+		{"No change when targetSdkVersion is defined", "34", ManifestTargetSdk, ManifestTargetSdk},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			manifest, _, err := SetDefaultTargetSdk(tc.input, tc.sdk)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(manifest, tc.expected) {
+				t.Errorf("Updated XML doesn't match expected:\nGot:\n%s\nExpected:\n%s", manifest, tc.expected)
+			}
+		})
+	}
+}
+
+func TestLogMessages(t *testing.T) {
+	testCases := []struct {
+		name     string
+		enforce  func() ([]byte, string, error)
+		expected string
+	}{
+		{
+			name:     "Bump minSdkVersion",
+			enforce:  func() ([]byte, string, error) { return BumpMinSdk(ManifestMinSdk, 24) },
+			expected: "minSdkVersion attribute specified in the manifest (12) is less than the floor (24). Min SDK replaced.",
+		},
+		{
+			name:     "Add targetSdkVersion",
+			enforce:  func() ([]byte, string, error) { return BumpTargetSdk(ManifestNoUsesSdk, 34) },
+			expected: "No uses-sdk element found while floor is specified (34). Target SDK added.",
+		},
+		{
+			name:     "Implicit targetSdkVersion",
+			enforce:  func() ([]byte, string, error) { return BumpTargetSdk(ManifestMinSdkHigh, 34) },
+			expected: "No targetSdkVersion attribute found, it defaults to the minSdkVersion (35) which is no less than the floor (34). Target SDK unchanged.",
+		},
+		{
+			name:     "No default",
+			enforce:  func() ([]byte, string, error) { return SetDefaultMinSdk(ManifestMinSdk, "") },
+			expected: "No default min SDK floor specified. Manifest unchanged.",
+		},
+		{
+			name:     "Default targetSdkVersion defined",
+			enforce:  func() ([]byte, string, error) { return SetDefaultTargetSdk(ManifestTargetSdk, "34") },
+			expected: "targetSdkVersion attribute specified in the manifest (28). Target SDK unchanged.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, message, err := tc.enforce()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if message != tc.expected {
+				t.Errorf("Log message is %q, want %q", message, tc.expected)
+			}
+		})
+	}
+}
+
+func TestValidateSdkVersions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		usesSdk  string
+		expected []string
+		wantErr  string
+	}{
+		{"No uses-sdk", "", nil, ""},
+		{"Valid", `<uses-sdk android:minSdkVersion="21" android:targetSdkVersion="34" android:maxSdkVersion="34"/>`, []string{
+			"targetSdkVersion attribute (34) is no less than the minSdkVersion attribute (21).",
+			"maxSdkVersion attribute (34) is no less than the minSdkVersion attribute (21).",
+		}, ""},
+		{"Placeholders are not checked", `<uses-sdk android:minSdkVersion="${min}" android:targetSdkVersion="19"/>`, nil, ""},
+		{"Target less than min", `<uses-sdk android:minSdkVersion="21" android:targetSdkVersion="19"/>`, []string{
+			"targetSdkVersion attribute (19) is less than the minSdkVersion attribute (21).",
+		}, "targetSdkVersion attribute (19) is less than the minSdkVersion attribute (21)."},
+		{"Max less than min", `<uses-sdk android:minSdkVersion="21" android:maxSdkVersion="19"/>`, []string{
+			"maxSdkVersion attribute (19) is less than the minSdkVersion attribute (21).",
+		}, "maxSdkVersion attribute (19) is less than the minSdkVersion attribute (21)."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			manifest := `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">` + tc.usesSdk + `</manifest>`
+			got, err := ValidateSdkVersions([]byte(manifest))
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("ValidateSdkVersions returned unexpected error: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("ValidateSdkVersions returned error %v, want %q", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("ValidateSdkVersions returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestValidateSdkVersionsFixes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		usesSdk  string
+		expected []fixit.Fix
+	}{
+		{"Valid", `<uses-sdk android:minSdkVersion="21" android:targetSdkVersion="34"/>`, nil},
+		{"Target and max less than min", `<uses-sdk android:minSdkVersion="21" android:targetSdkVersion="19" android:maxSdkVersion="20"/>`, []fixit.Fix{
+			{Attr: "manifest_values", Key: "targetSdkVersion", OldValue: "19", NewValue: "21"},
+			{Attr: "manifest_values", Key: "maxSdkVersion", OldValue: "20", NewValue: "21"},
+		}},
+		{"Incomparable preview", `<uses-sdk android:minSdkVersion="VanillaIceCream" android:targetSdkVersion="34"/>`, nil},
+		{"Preview min with max", `<uses-sdk android:minSdkVersion="Baklava" android:maxSdkVersion="35"/>`, []fixit.Fix{
+			{Attr: "manifest_values", Key: "maxSdkVersion", OldValue: "35", NewValue: "36"},
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			manifest := `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">` + tc.usesSdk + `</manifest>`
+			_, fixes, _ := validateSdkVersions([]byte(manifest))
+			if diff := cmp.Diff(tc.expected, fixes, cmpopts.IgnoreFields(fixit.Fix{}, "Message")); diff != "" {
+				t.Errorf("validateSdkVersions returned fixes diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestBumpMinSdkPreview(t *testing.T) {
+	manifest := func(minSdk string) []byte {
+		return []byte(`<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+<uses-sdk android:minSdkVersion="` + minSdk + `"/>
+</manifest>
+`)
+	}
+
+	testCases := []struct {
+		name     string
+		minSdk   string
+		sdk      int
+		expected []byte
+		wantErr  string
+	}{
+		{"Minor version above the floor", "36.1", 36, manifest("36.1"), ""},
+		{"Minor version below the floor", "35.1", 36, manifest("36"), ""},
+		{"Preview codename", "VanillaIceCream", 24, nil, "cannot mix the preview SDK VanillaIceCream with the stable SDK 24"},
+		{"Invalid value", "twenty", 24, nil, `invalid SDK level "twenty"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := BumpMinSdk(manifest(tc.minSdk), tc.sdk)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("BumpMinSdk returned error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tc.expected) {
+				t.Errorf("Updated XML doesn't match expected:\nGot:\n%s\nExpected:\n%s", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestValidateSdkVersionsPreview(t *testing.T) {
+	testCases := []struct {
+		name    string
+		usesSdk string
+		wantErr string
+	}{
+		{"Same preview", `<uses-sdk android:minSdkVersion="Baklava" android:targetSdkVersion="Baklava"/>`, ""},
+		{"Minor versions", `<uses-sdk android:minSdkVersion="36" android:targetSdkVersion="36.1"/>`, ""},
+		{"Minor version less than min", `<uses-sdk android:minSdkVersion="36.1" android:targetSdkVersion="36"/>`, "targetSdkVersion attribute (36) is less than the minSdkVersion attribute (36.1)."},
+		{"Preview min with stable target", `<uses-sdk android:minSdkVersion="Baklava" android:targetSdkVersion="35"/>`, "cannot mix the preview SDK Baklava with the stable SDK 35"},
+		{"Preview min with max", `<uses-sdk android:minSdkVersion="Baklava" android:maxSdkVersion="36"/>`, ""},
+		{"Preview min with max less than its API level", `<uses-sdk android:minSdkVersion="Baklava" android:maxSdkVersion="35"/>`, "maxSdkVersion attribute (35) is less than the minSdkVersion attribute (Baklava)."},
+		{"Unknown preview min with max", `<uses-sdk android:minSdkVersion="Zucchini" android:maxSdkVersion="35"/>`, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			manifest := `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">` + tc.usesSdk + `</manifest>`
+			_, err := ValidateSdkVersions([]byte(manifest))
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("ValidateSdkVersions returned unexpected error: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("ValidateSdkVersions returned error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,610 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestutils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"src/common/golang/xml2"
+)
+
+const androidPrefix = "android"
+
+// Element is an element of a Document. It keeps everything needed to write the element back as it
+// was read: attribute order, namespace declarations, comments and whitespace.
+type Element struct {
+	Name xml.Name
+	Attr []xml.Attr
+	// Line and Column locate the start tag of elements read from a document, starting at 1.
+	Line, Column int
+	// Children holds the child elements, as *Element, along with the xml.CharData, xml.Comment,
+	// xml.ProcInst and xml.Directive tokens between them.
+	Children []xml.Token
+}
+
+// Elements returns the child elements with the given local name, or all of them for "".
+func (e *Element) Elements(local string) []*Element {
+	var r []*Element
+	for _, t := range e.Children {
+		if c, ok := t.(*Element); ok && (local == "" || c.Name.Local == local) {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+// attrIndex returns the index of the attribute, or -1 if the element does not have it.
+func (e *Element) attrIndex(name xml.Name) int {
+	for i, a := range e.Attr {
+		if a.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AttrValue returns the value of the attribute and whether the element has it.
+func (e *Element) AttrValue(name xml.Name) (string, bool) {
+	if i := e.attrIndex(name); i >= 0 {
+		return e.Attr[i].Value, true
+	}
+	return "", false
+}
+
+// SetAttr sets the value of the attribute in place, or appends the attribute if missing.
+func (e *Element) SetAttr(name xml.Name, value string) {
+	if i := e.attrIndex(name); i >= 0 {
+		e.Attr[i].Value = value
+		return
+	}
+	e.Attr = append(e.Attr, xml.Attr{Name: name, Value: value})
+}
+
+// RemoveAttr removes the attribute, reporting whether the element had it.
+func (e *Element) RemoveAttr(name xml.Name) bool {
+	i := e.attrIndex(name)
+	if i < 0 {
+		return false
+	}
+	e.Attr = append(e.Attr[:i], e.Attr[i+1:]...)
+	return true
+}
+
+// Append adds c after the last child element of the same name, or else after all the other
+// children, indenting it like its siblings.
+func (e *Element) Append(c *Element) {
+	last, lastSame := -1, -1
+	for i, t := range e.Children {
+		if ce, ok := t.(*Element); ok {
+			last = i
+			if ce.Name == c.Name {
+				lastSame = i
+			}
+		}
+	}
+	at := len(e.Children)
+	trailing := at > 0 && isSpace(e.Children[at-1])
+	if trailing {
+		at--
+	}
+	var indent xml.Token
+	switch {
+	case lastSame >= 0:
+		at = lastSame + 1
+		indent = e.spaceBefore(lastSame)
+	case last >= 0:
+		indent = e.spaceBefore(last)
+	case trailing:
+		indent = e.Children[at]
+	}
+	add := []xml.Token{c}
+	if indent != nil {
+		add = []xml.Token{xml.CopyToken(indent), c}
+	}
+	e.Children = append(e.Children[:at], append(add, e.Children[at:]...)...)
+}
+
+// Remove removes the child element c along with the whitespace indenting it, reporting whether c
+// was a child of e.
+func (e *Element) Remove(c *Element) bool {
+	for i, t := range e.Children {
+		if t != xml.Token(c) {
+			continue
+		}
+		from := i
+		if i > 0 && isSpace(e.Children[i-1]) {
+			from--
+		}
+		e.Children = append(e.Children[:from], e.Children[i+1:]...)
+		return true
+	}
+	return false
+}
+
+func (e *Element) spaceBefore(i int) xml.Token {
+	if i > 0 && isSpace(e.Children[i-1]) {
+		return e.Children[i-1]
+	}
+	return nil
+}
+
+func isSpace(t xml.Token) bool {
+	cd, ok := t.(xml.CharData)
+	return ok && len(bytes.TrimSpace(cd)) == 0
+}
+
+// Walk calls f for e and each of its descendant elements, parents first.
+func (e *Element) Walk(f func(*Element)) {
+	f(e)
+	for _, c := range e.Elements("") {
+		c.Walk(f)
+	}
+}
+
+func (e *Element) encode(enc Encoder) error {
+	if err := enc.EncodeToken(xml.StartElement{Name: e.Name, Attr: e.Attr}); err != nil {
+		return err
+	}
+	for _, t := range e.Children {
+		var err error
+		if c, ok := t.(*Element); ok {
+			err = c.encode(enc)
+		} else {
+			err = enc.EncodeToken(t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(xml.EndElement{Name: e.Name})
+}
+
+// Document is a parsed XML document which encodes back to the tokens it was read from.
+type Document struct {
+	// Tokens holds the root *Element along with the tokens around it, e.g. the XML declaration.
+	Tokens []xml.Token
+}
+
+// ReadDocument reads a document from dec. The names of elements and attributes hold namespace URIs,
+// the undeclared prefixes of DefaultNamespaces included.
+func ReadDocument(dec *xml.Decoder) (*Document, error) {
+	d := &Document{}
+	var stack []*Element
+	rd := xml2.WrapDecoder(dec, DefaultNamespaces)
+	for {
+		line, col := rd.InputPos()
+		t, err := rd.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var tok xml.Token
+		switch tt := t.(type) {
+		case xml.StartElement:
+			e := &Element{Name: tt.Name, Attr: append([]xml.Attr(nil), tt.Attr...), Line: line, Column: col}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, e)
+			} else {
+				d.Tokens = append(d.Tokens, e)
+			}
+			stack = append(stack, e)
+			continue
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			continue
+		default:
+			tok = xml.CopyToken(tt)
+		}
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, tok)
+		} else {
+			d.Tokens = append(d.Tokens, tok)
+		}
+	}
+	if d.Root() == nil {
+		return nil, fmt.Errorf("document has no root element")
+	}
+	return d, nil
+}
+
+// Root returns the root element of the document.
+func (d *Document) Root() *Element {
+	for _, t := range d.Tokens {
+		if e, ok := t.(*Element); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// Encode encodes the tokens of the document.
+func (d *Document) Encode(enc Encoder) error {
+	for _, t := range d.Tokens {
+		var err error
+		if e, ok := t.(*Element); ok {
+			err = e.encode(enc)
+		} else {
+			err = enc.EncodeToken(t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write writes the document to w, with empty elements self-closed.
+func (d *Document) Write(w io.Writer) error {
+	e := xml2.NewEncoder(w)
+	e.SelfCloseEmpty()
+	if err := d.Encode(e); err != nil {
+		return err
+	}
+	return e.Flush()
+}
+
+// Node links an element of the typed manifest model to the Element it was read from. Elements added
+// to the model have no Element until the manifest is written.
+type Node struct {
+	elem *Element
+}
+
+// Element returns the Element of the node, which is nil for elements not written yet.
+func (n Node) Element() *Element {
+	return n.elem
+}
+
+// Manifest is the typed model of an AndroidManifest.xml, backed by the Document it was read from.
+//
+// Attribute values are kept as written, e.g. "true" or "@string/app_name", and unset attributes are
+// empty. Writing the manifest updates the attributes in place, adds the elements added to the model
+// and removes those removed from it, leaving everything else untouched.
+type Manifest struct {
+	Node
+	Package            string `attr:"package"`
+	SharedUserID       string `attr:"android:sharedUserId"`
+	SharedUserLabel    string `attr:"android:sharedUserLabel"`
+	VersionCode        string `attr:"android:versionCode"`
+	VersionName        string `attr:"android:versionName"`
+	InstallLocation    string `attr:"android:installLocation"`
+	CompileSdkVersion  string `attr:"android:compileSdkVersion"`
+	Split              string `attr:"split"`
+	FeatureName        string `attr:"featureName"`
+	ConfigForSplit     string `attr:"configForSplit"`
+	IsFeatureSplit     string `attr:"android:isFeatureSplit"`
+	IsSplitRequired    string `attr:"android:isSplitRequired"`
+	RequiredSplitTypes string `attr:"android:requiredSplitTypes"`
+	SplitTypes         string `attr:"android:splitTypes"`
+
+	UsesSdk              *UsesSdk           `elem:"uses-sdk"`
+	UsesPermissions      []*UsesPermission  `elem:"uses-permission"`
+	UsesPermissionsSdk23 []*UsesPermission  `elem:"uses-permission-sdk-23"`
+	Permissions          []*Permission      `elem:"permission"`
+	UsesFeatures         []*UsesFeature     `elem:"uses-feature"`
+	Queries              []*Queries         `elem:"queries"`
+	Application          *Application       `elem:"application"`
+	Instrumentations     []*Instrumentation `elem:"instrumentation"`
+
+	doc *Document
+	// owned holds the elements bound to the model. Other elements, like a second uses-sdk element
+	// which has no field of its own, are left as they are.
+	owned map[*Element]bool
+}
+
+// UsesSdk is the uses-sdk element.
+type UsesSdk struct {
+	Node
+	MinSdkVersion    string `attr:"android:minSdkVersion"`
+	TargetSdkVersion string `attr:"android:targetSdkVersion"`
+	MaxSdkVersion    string `attr:"android:maxSdkVersion"`
+}
+
+// UsesPermission is the uses-permission or uses-permission-sdk-23 element.
+type UsesPermission struct {
+	Node
+	Name                string `attr:"android:name"`
+	MaxSdkVersion       string `attr:"android:maxSdkVersion"`
+	UsesPermissionFlags string `attr:"android:usesPermissionFlags"`
+}
+
+// Permission is the permission element.
+type Permission struct {
+	Node
+	Name            string `attr:"android:name"`
+	Label           string `attr:"android:label"`
+	Description     string `attr:"android:description"`
+	Icon            string `attr:"android:icon"`
+	PermissionGroup string `attr:"android:permissionGroup"`
+	ProtectionLevel string `attr:"android:protectionLevel"`
+}
+
+// UsesFeature is the uses-feature element.
+type UsesFeature struct {
+	Node
+	Name        string `attr:"android:name"`
+	Required    string `attr:"android:required"`
+	GlEsVersion string `attr:"android:glEsVersion"`
+}
+
+// Queries is the queries element.
+type Queries struct {
+	Node
+	Packages  []*Package      `elem:"package"`
+	Intents   []*IntentFilter `elem:"intent"`
+	Providers []*Component    `elem:"provider"`
+}
+
+// Package is the package element of queries.
+type Package struct {
+	Node
+	Name string `attr:"android:name"`
+}
+
+// Application is the application element.
+type Application struct {
+	Node
+	Name                  string `attr:"android:name"`
+	Label                 string `attr:"android:label"`
+	Icon                  string `attr:"android:icon"`
+	RoundIcon             string `attr:"android:roundIcon"`
+	Theme                 string `attr:"android:theme"`
+	Debuggable            string `attr:"android:debuggable"`
+	AllowBackup           string `attr:"android:allowBackup"`
+	HasCode               string `attr:"android:hasCode"`
+	TestOnly              string `attr:"android:testOnly"`
+	UsesCleartextTraffic  string `attr:"android:usesCleartextTraffic"`
+	NetworkSecurityConfig string `attr:"android:networkSecurityConfig"`
+	ExtractNativeLibs     string `attr:"android:extractNativeLibs"`
+	AppComponentFactory   string `attr:"android:appComponentFactory"`
+	SupportsRtl           string `attr:"android:supportsRtl"`
+
+	Activities      []*Component   `elem:"activity"`
+	ActivityAliases []*Component   `elem:"activity-alias"`
+	Services        []*Component   `elem:"service"`
+	Receivers       []*Component   `elem:"receiver"`
+	Providers       []*Component   `elem:"provider"`
+	UsesLibraries   []*UsesLibrary `elem:"uses-library"`
+	MetaData        []*MetaData    `elem:"meta-data"`
+}
+
+// Component is an activity, activity-alias, service, receiver or provider element.
+type Component struct {
+	Node
+	Name       string `attr:"android:name"`
+	Label      string `attr:"android:label"`
+	Enabled    string `attr:"android:enabled"`
+	Exported   string `attr:"android:exported"`
+	Permission string `attr:"android:permission"`
+	Process    string `attr:"android:process"`
+	Theme      string `attr:"android:theme"`
+	// TargetActivity is set for activity-alias elements.
+	TargetActivity string `attr:"android:targetActivity"`
+	// Authorities and GrantURIPermissions are set for provider elements.
+	Authorities         string `attr:"android:authorities"`
+	GrantURIPermissions string `attr:"android:grantUriPermissions"`
+
+	IntentFilters []*IntentFilter `elem:"intent-filter"`
+	MetaData      []*MetaData     `elem:"meta-data"`
+}
+
+// IntentFilter is the intent-filter element, or the intent element of queries.
+type IntentFilter struct {
+	Node
+	Priority   string `attr:"android:priority"`
+	AutoVerify string `attr:"android:autoVerify"`
+
+	Actions    []*Named `elem:"action"`
+	Categories []*Named `elem:"category"`
+	Data       []*Data  `elem:"data"`
+}
+
+// Named is an element only identified by its name, e.g. action or category.
+type Named struct {
+	Node
+	Name string `attr:"android:name"`
+}
+
+// Data is the data element of intent filters.
+type Data struct {
+	Node
+	Scheme      string `attr:"android:scheme"`
+	Host        string `attr:"android:host"`
+	Port        string `attr:"android:port"`
+	Path        string `attr:"android:path"`
+	PathPrefix  string `attr:"android:pathPrefix"`
+	PathPattern string `attr:"android:pathPattern"`
+	MimeType    string `attr:"android:mimeType"`
+}
+
+// MetaData is the meta-data element.
+type MetaData struct {
+	Node
+	Name     string `attr:"android:name"`
+	Value    string `attr:"android:value"`
+	Resource string `attr:"android:resource"`
+}
+
+// UsesLibrary is the uses-library element.
+type UsesLibrary struct {
+	Node
+	Name     string `attr:"android:name"`
+	Required string `attr:"android:required"`
+}
+
+// Instrumentation is the instrumentation element.
+type Instrumentation struct {
+	Node
+	Name            string `attr:"android:name"`
+	TargetPackage   string `attr:"android:targetPackage"`
+	TargetProcesses string `attr:"android:targetProcesses"`
+	FunctionalTest  string `attr:"android:functionalTest"`
+	HandleProfiling string `attr:"android:handleProfiling"`
+	Label           string `attr:"android:label"`
+}
+
+// ReadManifest reads the typed model of the manifest r.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	doc, err := ReadDocument(xml.NewDecoder(r))
+	if err != nil {
+		return nil, err
+	}
+	root := doc.Root()
+	if root.Name.Local != ElemManifest {
+		return nil, fmt.Errorf("root element is <%s>, want <%s>", root.Name.Local, ElemManifest)
+	}
+	m := &Manifest{doc: doc, owned: make(map[*Element]bool)}
+	bind(reflect.ValueOf(m), root, m.owned)
+	return m, nil
+}
+
+// Document returns the document of the manifest, updated with the changes to the model.
+func (m *Manifest) Document() *Document {
+	if m.doc == nil {
+		m.doc = &Document{}
+	}
+	root := m.doc.Root()
+	if root == nil {
+		root = &Element{Name: xml.Name{Local: ElemManifest}}
+		m.doc.Tokens = append(m.doc.Tokens, root)
+		m.elem = root
+	}
+	if m.owned == nil {
+		m.owned = make(map[*Element]bool)
+	}
+	sync(reflect.ValueOf(m), nil, ElemManifest, m.owned)
+	declareNamespaces(root, DefaultNamespaces)
+	return m.doc
+}
+
+// Encode encodes the manifest, updated with the changes to the model.
+func (m *Manifest) Encode(enc Encoder) error {
+	return m.Document().Encode(enc)
+}
+
+// Write writes the manifest, updated with the changes to the model, to w.
+func (m *Manifest) Write(w io.Writer) error {
+	return m.Document().Write(w)
+}
+
+// attrName turns the attr tag of a model field into an attribute name.
+func attrName(tag string) xml.Name {
+	if local, ok := strings.CutPrefix(tag, androidPrefix+":"); ok {
+		return xml.Name{Space: NameSpace, Local: local}
+	}
+	return xml.Name{Local: tag}
+}
+
+// bind reads the element e into v, a pointer to a model struct, adding the bound elements to owned.
+// A pointer field binds the first of its elements only.
+func bind(v reflect.Value, e *Element, owned map[*Element]bool) {
+	s := v.Elem()
+	s.FieldByName("Node").Set(reflect.ValueOf(Node{elem: e}))
+	owned[e] = true
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), s.Field(i)
+		if tag, ok := f.Tag.Lookup("attr"); ok {
+			value, _ := e.AttrValue(attrName(tag))
+			fv.SetString(value)
+			continue
+		}
+		name, ok := f.Tag.Lookup("elem")
+		if !ok {
+			continue
+		}
+		for _, c := range e.Elements(name) {
+			if f.Type.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					p := reflect.New(f.Type.Elem())
+					bind(p, c, owned)
+					fv.Set(p)
+				}
+				continue
+			}
+			p := reflect.New(f.Type.Elem().Elem())
+			bind(p, c, owned)
+			fv.Set(reflect.Append(fv, p))
+		}
+	}
+}
+
+// sync writes v, a pointer to a model struct, back into its element, which is created under parent
+// if needed. Owned elements no longer in the model are removed, while elements the model does not
+// own are kept. It returns the element.
+func sync(v reflect.Value, parent *Element, name string, owned map[*Element]bool) *Element {
+	s := v.Elem()
+	n := s.FieldByName("Node").Addr().Interface().(*Node)
+	if n.elem == nil {
+		n.elem = &Element{Name: xml.Name{Local: name}}
+		parent.Append(n.elem)
+		owned[n.elem] = true
+	}
+	e := n.elem
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), s.Field(i)
+		if tag, ok := f.Tag.Lookup("attr"); ok {
+			attr := attrName(tag)
+			old, _ := e.AttrValue(attr)
+			switch value := fv.String(); {
+			case value == old:
+			case value == "":
+				e.RemoveAttr(attr)
+			default:
+				e.SetAttr(attr, value)
+			}
+			continue
+		}
+		childName, ok := f.Tag.Lookup("elem")
+		if !ok {
+			continue
+		}
+		var children []reflect.Value
+		if f.Type.Kind() == reflect.Ptr {
+			if !fv.IsNil() {
+				children = append(children, fv)
+			}
+		} else {
+			for j := 0; j < fv.Len(); j++ {
+				if !fv.Index(j).IsNil() {
+					children = append(children, fv.Index(j))
+				}
+			}
+		}
+		keep := make(map[*Element]bool)
+		for _, c := range children {
+			keep[c.Elem().FieldByName("Node").Interface().(Node).elem] = true
+		}
+		for _, c := range e.Elements(childName) {
+			if owned[c] && !keep[c] {
+				e.Remove(c)
+				delete(owned, c)
+			}
+		}
+		for _, c := range children {
+			sync(c, e, childName, owned)
+		}
+	}
+	return e
+}
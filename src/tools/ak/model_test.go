@@ -0,0 +1,301 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestutils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+const fullManifest = `<?xml version="1.0" encoding="utf-8"?>
+<!-- Header comment. -->
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" android:versionCode="3" android:versionName="1.2" split="feature" android:isFeatureSplit="true">
+    <uses-sdk android:targetSdkVersion="34" android:minSdkVersion="21"/>
+    <uses-permission android:name="android.permission.INTERNET"/>
+    <uses-permission android:name="android.permission.READ_EXTERNAL_STORAGE" android:maxSdkVersion="32"/>
+    <uses-permission-sdk-23 android:name="android.permission.CAMERA"/>
+    <permission android:name="com.example.READ" android:protectionLevel="signature"/>
+    <uses-feature android:name="android.hardware.camera" android:required="false"/>
+    <queries>
+        <package android:name="com.other"/>
+        <intent>
+            <action android:name="android.intent.action.SEND"/>
+            <data android:mimeType="image/*"/>
+        </intent>
+        <provider android:authorities="com.other.files"/>
+    </queries>
+    <application android:name=".App" android:label="@string/app" android:debuggable="false" android:usesCleartextTraffic="false">
+        <!-- The launcher activity. -->
+        <activity android:name=".Main" android:exported="true">
+            <intent-filter android:autoVerify="true">
+                <action android:name="android.intent.action.MAIN"/>
+                <category android:name="android.intent.category.LAUNCHER"/>
+                <data android:scheme="https" android:host="example.com"/>
+            </intent-filter>
+            <meta-data android:name="shortcuts" android:resource="@xml/shortcuts"/>
+        </activity>
+        <activity-alias android:name=".Alias" android:targetActivity=".Main"/>
+        <service android:name=".Sync" android:exported="false" android:process=":sync"/>
+        <receiver android:name=".Boot"/>
+        <provider android:name=".Files" android:authorities="com.example.files" android:grantUriPermissions="true"/>
+        <uses-library android:name="org.apache.http.legacy" android:required="false"/>
+        <meta-data android:name="key" android:value="value"/>
+    </application>
+    <instrumentation android:name=".Runner" android:targetPackage="com.example"/>
+</manifest>
+`
+
+func TestManifestRoundTrip(t *testing.T) {
+	m, err := ReadManifest(strings.NewReader(fullManifest))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	var b bytes.Buffer
+	if err := m.Write(&b); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if diff := cmp.Diff(fullManifest, b.String()); diff != "" {
+		t.Errorf("Write returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestManifestRoundTripDuplicates(t *testing.T) {
+	in := `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <uses-sdk android:minSdkVersion="21"/>
+    <uses-sdk android:targetSdkVersion="34"/>
+    <queries>
+        <package android:name="com.first"/>
+    </queries>
+    <queries>
+        <package android:name="com.second"/>
+    </queries>
+</manifest>`
+	m, err := ReadManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if got := len(m.Queries); got != 2 {
+		t.Errorf("ReadManifest returned %d queries, want 2", got)
+	}
+	var b bytes.Buffer
+	if err := m.Write(&b); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if diff := cmp.Diff(in, b.String()); diff != "" {
+		t.Errorf("Write returned diff (-want, +got):\n%v", diff)
+	}
+
+	// Updating and removing the modeled uses-sdk leaves the second one in place.
+	m.UsesSdk.MinSdkVersion = "24"
+	m.Queries = m.Queries[1:]
+	b.Reset()
+	if err := m.Write(&b); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	want := `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <uses-sdk android:minSdkVersion="24"/>
+    <uses-sdk android:targetSdkVersion="34"/>
+    <queries>
+        <package android:name="com.second"/>
+    </queries>
+</manifest>`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("Write returned diff (-want, +got):\n%v", diff)
+	}
+	m.UsesSdk = nil
+	b.Reset()
+	if err := m.Write(&b); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !strings.Contains(b.String(), `<uses-sdk android:targetSdkVersion="34"/>`) || strings.Contains(b.String(), "minSdkVersion") {
+		t.Errorf("Write returned %s, want only the unmodeled uses-sdk element", b.String())
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	m, err := ReadManifest(strings.NewReader(fullManifest))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	want := &Manifest{
+		Package:        "com.example",
+		VersionCode:    "3",
+		VersionName:    "1.2",
+		Split:          "feature",
+		IsFeatureSplit: "true",
+		UsesSdk:        &UsesSdk{MinSdkVersion: "21", TargetSdkVersion: "34"},
+		UsesPermissions: []*UsesPermission{
+			{Name: "android.permission.INTERNET"},
+			{Name: "android.permission.READ_EXTERNAL_STORAGE", MaxSdkVersion: "32"},
+		},
+		UsesPermissionsSdk23: []*UsesPermission{{Name: "android.permission.CAMERA"}},
+		Permissions:          []*Permission{{Name: "com.example.READ", ProtectionLevel: "signature"}},
+		UsesFeatures:         []*UsesFeature{{Name: "android.hardware.camera", Required: "false"}},
+		Queries: []*Queries{{
+			Packages: []*Package{{Name: "com.other"}},
+			Intents: []*IntentFilter{{
+				Actions: []*Named{{Name: "android.intent.action.SEND"}},
+				Data:    []*Data{{MimeType: "image/*"}},
+			}},
+			Providers: []*Component{{Authorities: "com.other.files"}},
+		}},
+		Application: &Application{
+			Name:                 ".App",
+			Label:                "@string/app",
+			Debuggable:           "false",
+			UsesCleartextTraffic: "false",
+			Activities: []*Component{{
+				Name:     ".Main",
+				Exported: "true",
+				IntentFilters: []*IntentFilter{{
+					AutoVerify: "true",
+					Actions:    []*Named{{Name: "android.intent.action.MAIN"}},
+					Categories: []*Named{{Name: "android.intent.category.LAUNCHER"}},
+					Data:       []*Data{{Scheme: "https", Host: "example.com"}},
+				}},
+				MetaData: []*MetaData{{Name: "shortcuts", Resource: "@xml/shortcuts"}},
+			}},
+			ActivityAliases: []*Component{{Name: ".Alias", TargetActivity: ".Main"}},
+			Services:        []*Component{{Name: ".Sync", Exported: "false", Process: ":sync"}},
+			Receivers:       []*Component{{Name: ".Boot"}},
+			Providers:       []*Component{{Name: ".Files", Authorities: "com.example.files", GrantURIPermissions: "true"}},
+			UsesLibraries:   []*UsesLibrary{{Name: "org.apache.http.legacy", Required: "false"}},
+			MetaData:        []*MetaData{{Name: "key", Value: "value"}},
+		},
+		Instrumentations: []*Instrumentation{{Name: ".Runner", TargetPackage: "com.example"}},
+	}
+	opts := []cmp.Option{cmpopts.IgnoreTypes(Node{}), cmpopts.IgnoreUnexported(Manifest{})}
+	if diff := cmp.Diff(want, m, opts...); diff != "" {
+		t.Errorf("ReadManifest returned diff (-want, +got):\n%v", diff)
+	}
+	if e := m.Application.Activities[0].Element(); e == nil || e.Name.Local != "activity" || e.Line != 20 || e.Column != 9 {
+		t.Errorf("Element() = %v, want the activity element at 20:9", e)
+	}
+}
+
+func TestManifestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		update func(m *Manifest)
+		want   string
+	}{
+		{
+			name: "update attributes in place",
+			in: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <uses-sdk android:targetSdkVersion="34" android:minSdkVersion="21" android:maxSdkVersion="35"/>
+</manifest>`,
+			update: func(m *Manifest) {
+				m.Package = "com.example.debug"
+				m.UsesSdk.MinSdkVersion = "24"
+				m.UsesSdk.MaxSdkVersion = ""
+				m.VersionCode = "7"
+			},
+			want: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example.debug" android:versionCode="7">
+    <uses-sdk android:targetSdkVersion="34" android:minSdkVersion="24"/>
+</manifest>`,
+		},
+		{
+			name: "add elements",
+			in: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <!-- Permissions. -->
+    <uses-permission android:name="android.permission.INTERNET"/>
+    <application>
+        <activity android:name=".Main"/>
+    </application>
+</manifest>`,
+			update: func(m *Manifest) {
+				m.UsesPermissions = append(m.UsesPermissions, &UsesPermission{Name: "android.permission.CAMERA"})
+				m.Application.Services = append(m.Application.Services, &Component{Name: ".Sync"})
+				m.Application.Activities[0].IntentFilters = []*IntentFilter{{Actions: []*Named{{Name: "android.intent.action.VIEW"}}}}
+			},
+			want: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <!-- Permissions. -->
+    <uses-permission android:name="android.permission.INTERNET"/>
+    <uses-permission android:name="android.permission.CAMERA"/>
+    <application>
+        <activity android:name=".Main"><intent-filter><action android:name="android.intent.action.VIEW"/></intent-filter></activity>
+        <service android:name=".Sync"/>
+    </application>
+</manifest>`,
+		},
+		{
+			name: "remove elements",
+			in: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <uses-permission android:name="android.permission.INTERNET"/>
+    <uses-permission android:name="android.permission.CAMERA"/>
+    <uses-sdk android:minSdkVersion="21"/>
+</manifest>`,
+			update: func(m *Manifest) {
+				m.UsesPermissions = m.UsesPermissions[1:]
+				m.UsesSdk = nil
+			},
+			want: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+    <uses-permission android:name="android.permission.CAMERA"/>
+</manifest>`,
+		},
+		{
+			name: "declare android namespace",
+			in: `<manifest package="com.example">
+</manifest>`,
+			update: func(m *Manifest) {
+				m.UsesSdk = &UsesSdk{MinSdkVersion: "21"}
+			},
+			want: `<manifest package="com.example" xmlns:android="http://schemas.android.com/apk/res/android">
+<uses-sdk android:minSdkVersion="21"/>
+</manifest>`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := ReadManifest(strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("ReadManifest failed: %v", err)
+			}
+			tc.update(m)
+			var b bytes.Buffer
+			if err := m.Write(&b); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+				t.Errorf("Write returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestNewManifest(t *testing.T) {
+	m := &Manifest{Package: "com.example", UsesSdk: &UsesSdk{MinSdkVersion: "21"}, Application: &Application{}}
+	var b bytes.Buffer
+	if err := m.Write(&b); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	want := `<manifest package="com.example" xmlns:android="http://schemas.android.com/apk/res/android"><uses-sdk android:minSdkVersion="21"/><application/></manifest>`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("Write returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestReadManifestErrors(t *testing.T) {
+	for _, in := range []string{``, `<resources/>`, `<manifest>`} {
+		if _, err := ReadManifest(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadManifest(%q) succeeded, want error", in)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nativelib
+
+import (
+	"debug/elf"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// pageSize is the page size LOAD segments of 64-bit libraries must be aligned to, for devices
+// with 16 KB pages.
+const pageSize = 16384
+
+// abis maps the Android ABIs to the ELF machine and class of their libraries.
+var abis = map[string]struct {
+	machine elf.Machine
+	class   elf.Class
+}{
+	"armeabi":     {elf.EM_ARM, elf.ELFCLASS32},
+	"armeabi-v7a": {elf.EM_ARM, elf.ELFCLASS32},
+	"arm64-v8a":   {elf.EM_AARCH64, elf.ELFCLASS64},
+	"x86":         {elf.EM_386, elf.ELFCLASS32},
+	"x86_64":      {elf.EM_X86_64, elf.ELFCLASS64},
+	"riscv64":     {elf.EM_RISCV, elf.ELFCLASS64},
+}
+
+// systemLibs are the libraries of the NDK stable APIs, provided by the platform.
+var systemLibs = map[string]bool{
+	"libaaudio.so":         true,
+	"libamidi.so":          true,
+	"libandroid.so":        true,
+	"libbinder_ndk.so":     true,
+	"libc.so":              true,
+	"libcamera2ndk.so":     true,
+	"libdl.so":             true,
+	"libEGL.so":            true,
+	"libGLESv1_CM.so":      true,
+	"libGLESv2.so":         true,
+	"libGLESv3.so":         true,
+	"libicu.so":            true,
+	"libjnigraphics.so":    true,
+	"liblog.so":            true,
+	"libm.so":              true,
+	"libmediandk.so":       true,
+	"libnativehelper.so":   true,
+	"libnativewindow.so":   true,
+	"libneuralnetworks.so": true,
+	"libOpenMAXAL.so":      true,
+	"libOpenSLES.so":       true,
+	"libstdc++.so":         true,
+	"libsync.so":           true,
+	"libvulkan.so":         true,
+	"libz.so":              true,
+}
+
+// nativeLib is the ELF information of a native library.
+type nativeLib struct {
+	path   string
+	soname string
+	needed []string
+}
+
+// name returns the name the library is loaded as, its soname or else its file name.
+func (l nativeLib) name() string {
+	if l.soname != "" {
+		return l.soname
+	}
+	return filepath.Base(l.path)
+}
+
+// checkLibs checks the native libraries for architecture, and returns the violations found: wrong
+// ABIs, LOAD segments not aligned to 16 KB pages, DT_NEEDED dependencies neither in the set nor
+// provided by the platform, and duplicate sonames or file names.
+func checkLibs(paths []string, architecture string) []string {
+	var violations []string
+	var libs []nativeLib
+	for _, path := range paths {
+		lib, vs := readLib(path, architecture)
+		violations = append(violations, vs...)
+		if lib != nil {
+			libs = append(libs, *lib)
+		}
+	}
+
+	provided := make(map[string]bool)
+	byName := make(map[string][]string)
+	byBase := make(map[string][]string)
+	for _, lib := range libs {
+		provided[lib.name()] = true
+		provided[filepath.Base(lib.path)] = true
+		byName[lib.name()] = append(byName[lib.name()], lib.path)
+	}
+	for _, path := range paths {
+		byBase[filepath.Base(path)] = append(byBase[filepath.Base(path)], path)
+	}
+	for _, lib := range libs {
+		for _, n := range lib.needed {
+			if !provided[n] && !systemLibs[n] {
+				violations = append(violations, fmt.Sprintf("%s: needed library %s is not packaged", lib.path, n))
+			}
+		}
+	}
+	for _, dups := range []struct {
+		kind  string
+		paths map[string][]string
+	}{{"soname", byName}, {"file name", byBase}} {
+		for _, name := range sortedKeys(dups.paths) {
+			if ps := dups.paths[name]; len(ps) > 1 {
+				violations = append(violations, fmt.Sprintf("duplicate %s %s: %v", dups.kind, name, ps))
+			}
+		}
+	}
+	return violations
+}
+
+// readLib reads the ELF information of the library at path, and returns the violations of its
+// header and segments. It returns no library if path is not an ELF file.
+func readLib(path, architecture string) (*nativeLib, []string) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("%s: not an ELF file: %v", path, err)}
+	}
+	defer f.Close()
+
+	var violations []string
+	abi, known := abis[architecture]
+	if known && (f.Machine != abi.machine || f.Class != abi.class) {
+		violations = append(violations, fmt.Sprintf("%s: %s %s library, want %s %s for %s", path, f.Class, f.Machine, abi.class, abi.machine, architecture))
+	}
+	if f.Class == elf.ELFCLASS64 {
+		for _, p := range f.Progs {
+			if p.Type == elf.PT_LOAD && p.Align < pageSize {
+				violations = append(violations, fmt.Sprintf("%s: LOAD segment at offset 0x%x is aligned to %d bytes, want %d for 16 KB pages", path, p.Off, p.Align, pageSize))
+			}
+		}
+	}
+
+	lib := &nativeLib{path: path}
+	if sonames, err := f.DynString(elf.DT_SONAME); err == nil && len(sonames) > 0 {
+		lib.soname = sonames[0]
+	}
+	if needed, err := f.DynString(elf.DT_NEEDED); err == nil {
+		lib.needed = needed
+	}
+	return lib, violations
+}
+
+func sortedKeys(m map[string][]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,315 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nativelib
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// elfSpec describes a minimal shared library: its header, a LOAD segment and a dynamic section.
+type elfSpec struct {
+	class   elf.Class
+	machine elf.Machine
+	align   uint64
+	soname  string
+	needed  []string
+	// debug adds .debug_info, .strtab and .symtab sections.
+	debug bool
+}
+
+func arm64Lib(soname string, needed ...string) elfSpec {
+	return elfSpec{class: elf.ELFCLASS64, machine: elf.EM_AARCH64, align: pageSize, soname: soname, needed: needed}
+}
+
+// writeELF writes the library s to dir/name, and returns its path. The file holds the ELF header,
+// the program header, .dynstr and .dynamic, loaded by the LOAD segment, then .shstrtab, the debug
+// sections and the section headers.
+func writeELF(t *testing.T, dir, name string, s elfSpec) string {
+	t.Helper()
+	is64 := s.class == elf.ELFCLASS64
+	ehsize, phentsize, shentsize := 52, 32, 40
+	if is64 {
+		ehsize, phentsize, shentsize = 64, 56, 64
+	}
+	var b bytes.Buffer
+	u16 := func(v int) { binary.Write(&b, binary.LittleEndian, uint16(v)) }
+	u32 := func(v int) { binary.Write(&b, binary.LittleEndian, uint32(v)) }
+	word := func(v uint64) {
+		if is64 {
+			binary.Write(&b, binary.LittleEndian, v)
+		} else {
+			binary.Write(&b, binary.LittleEndian, uint32(v))
+		}
+	}
+
+	dynstr := "\x00"
+	addStr := func(s string) uint64 {
+		off := len(dynstr)
+		dynstr += s + "\x00"
+		return uint64(off)
+	}
+	type dyn struct {
+		tag elf.DynTag
+		val uint64
+	}
+	var dyns []dyn
+	for _, n := range s.needed {
+		dyns = append(dyns, dyn{elf.DT_NEEDED, addStr(n)})
+	}
+	if s.soname != "" {
+		dyns = append(dyns, dyn{elf.DT_SONAME, addStr(s.soname)})
+	}
+	dyns = append(dyns, dyn{elf.DT_NULL, 0})
+	dynentsize := 8
+	if is64 {
+		dynentsize = 16
+	}
+	shstrtab := "\x00.dynstr\x00.dynamic\x00.shstrtab\x00.debug_info\x00.strtab\x00.symtab\x00"
+	debugInfo := bytes.Repeat([]byte{0xdb}, 100)
+	strtab := "\x00main\x00"
+	symentsize := 16
+	if is64 {
+		symentsize = 24
+	}
+	symtab := make([]byte, 2*symentsize)
+	shnum := 4
+	if s.debug {
+		shnum = 7
+	}
+
+	align8 := func(n int) int { return (n + 7) &^ 7 }
+	phoff := ehsize
+	dynstrOff := phoff + phentsize
+	dynOff := align8(dynstrOff + len(dynstr))
+	shstrOff := dynOff + len(dyns)*dynentsize
+	debugOff := shstrOff + len(shstrtab)
+	strtabOff := debugOff + len(debugInfo)
+	symtabOff := align8(strtabOff + len(strtab))
+	shoff := align8(shstrOff + len(shstrtab))
+	if s.debug {
+		shoff = align8(symtabOff + len(symtab))
+	}
+
+	// ELF header.
+	b.Write([]byte{0x7f, 'E', 'L', 'F', byte(s.class), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)})
+	b.Write(make([]byte, 9))
+	u16(int(elf.ET_DYN))
+	u16(int(s.machine))
+	u32(int(elf.EV_CURRENT))
+	word(0)
+	word(uint64(phoff))
+	word(uint64(shoff))
+	u32(0)
+	u16(ehsize)
+	u16(phentsize)
+	u16(1)
+	u16(shentsize)
+	u16(shnum)
+	u16(3)
+
+	// LOAD segment of .dynstr and .dynamic.
+	u32(int(elf.PT_LOAD))
+	if is64 {
+		u32(int(elf.PF_R))
+	}
+	word(0)
+	word(0)
+	word(0)
+	word(uint64(shstrOff))
+	word(uint64(shstrOff))
+	if !is64 {
+		u32(int(elf.PF_R))
+	}
+	word(s.align)
+
+	b.WriteString(dynstr)
+	b.Write(make([]byte, dynOff-b.Len()))
+	for _, d := range dyns {
+		word(uint64(d.tag))
+		word(d.val)
+	}
+	b.WriteString(shstrtab)
+	if s.debug {
+		b.Write(debugInfo)
+		b.WriteString(strtab)
+		b.Write(make([]byte, symtabOff-b.Len()))
+		b.Write(symtab)
+	}
+	b.Write(make([]byte, shoff-b.Len()))
+
+	section := func(name int, typ elf.SectionType, flags elf.SectionFlag, off, size, link, entsize int) {
+		u32(name)
+		u32(int(typ))
+		word(uint64(flags))
+		word(0)
+		word(uint64(off))
+		word(uint64(size))
+		u32(link)
+		u32(0)
+		word(1)
+		word(uint64(entsize))
+	}
+	section(0, elf.SHT_NULL, 0, 0, 0, 0, 0)
+	section(1, elf.SHT_STRTAB, elf.SHF_ALLOC, dynstrOff, len(dynstr), 0, 0)
+	section(9, elf.SHT_DYNAMIC, elf.SHF_ALLOC, dynOff, len(dyns)*dynentsize, 1, dynentsize)
+	section(18, elf.SHT_STRTAB, 0, shstrOff, len(shstrtab), 0, 0)
+	if s.debug {
+		section(28, elf.SHT_PROGBITS, 0, debugOff, len(debugInfo), 0, 0)
+		section(40, elf.SHT_STRTAB, 0, strtabOff, len(strtab), 0, 0)
+		section(48, elf.SHT_SYMTAB, 0, symtabOff, len(symtab), 5, symentsize)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadLib(t *testing.T) {
+	dir := t.TempDir()
+	path := writeELF(t, dir, "libfoo.so", arm64Lib("libfoo.so", "libbar.so", "liblog.so"))
+	lib, violations := readLib(path, "arm64-v8a")
+	if len(violations) != 0 {
+		t.Errorf("readLib(%s) returned violations %q, want none", path, violations)
+	}
+	want := &nativeLib{path: path, soname: "libfoo.so", needed: []string{"libbar.so", "liblog.so"}}
+	if diff := cmp.Diff(want, lib, cmp.AllowUnexported(nativeLib{})); diff != "" {
+		t.Errorf("readLib(%s) returned diff (-want, +got):\n%v", path, diff)
+	}
+}
+
+func TestCheckLibs(t *testing.T) {
+	tests := []struct {
+		name         string
+		architecture string
+		libs         map[string]elfSpec
+		// notELF are files of the set which are not ELF files.
+		notELF []string
+		want   []string
+	}{
+		{
+			name:         "valid",
+			architecture: "arm64-v8a",
+			libs: map[string]elfSpec{
+				"libfoo.so": arm64Lib("libfoo.so", "libbar.so", "libc.so"),
+				"libbar.so": arm64Lib("", "liblog.so"),
+			},
+		},
+		{
+			name:         "32-bit libraries are not checked for 16 KB pages",
+			architecture: "armeabi-v7a",
+			libs: map[string]elfSpec{
+				"libfoo.so": {class: elf.ELFCLASS32, machine: elf.EM_ARM, align: 4096, soname: "libfoo.so"},
+			},
+		},
+		{
+			name:         "wrong ABI",
+			architecture: "x86_64",
+			libs: map[string]elfSpec{
+				"libfoo.so": arm64Lib("libfoo.so"),
+			},
+			want: []string{"libfoo.so: ELFCLASS64 EM_AARCH64 library, want ELFCLASS64 EM_X86_64 for x86_64"},
+		},
+		{
+			name:         "4 KB pages",
+			architecture: "arm64-v8a",
+			libs: map[string]elfSpec{
+				"libfoo.so": {class: elf.ELFCLASS64, machine: elf.EM_AARCH64, align: 4096, soname: "libfoo.so"},
+			},
+			want: []string{"libfoo.so: LOAD segment at offset 0x0 is aligned to 4096 bytes, want 16384 for 16 KB pages"},
+		},
+		{
+			name:         "missing dependency",
+			architecture: "arm64-v8a",
+			libs: map[string]elfSpec{
+				"libfoo.so": arm64Lib("libfoo.so", "libbar.so", "libm.so"),
+			},
+			want: []string{"libfoo.so: needed library libbar.so is not packaged"},
+		},
+		{
+			name:         "duplicate soname",
+			architecture: "arm64-v8a",
+			libs: map[string]elfSpec{
+				"libfoo.so":    arm64Lib("libfoo.so"),
+				"libfoo_v2.so": arm64Lib("libfoo.so"),
+			},
+			want: []string{"duplicate soname libfoo.so"},
+		},
+		{
+			name:         "duplicate file name",
+			architecture: "arm64-v8a",
+			libs: map[string]elfSpec{
+				"a/libfoo.so": arm64Lib("liba.so"),
+				"b/libfoo.so": arm64Lib("libb.so"),
+			},
+			want: []string{"duplicate file name libfoo.so"},
+		},
+		{
+			name:         "not an ELF file",
+			architecture: "arm64-v8a",
+			notELF:       []string{"libfoo.so"},
+			want:         []string{"libfoo.so: not an ELF file"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var paths []string
+			for name, s := range tc.libs {
+				paths = append(paths, writeELF(t, dir, name, s))
+			}
+			for _, name := range tc.notELF {
+				path := filepath.Join(dir, name)
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+				paths = append(paths, path)
+			}
+			violations := checkLibs(paths, tc.architecture)
+			if len(violations) != len(tc.want) {
+				t.Fatalf("checkLibs() = %q, want %d violations", violations, len(tc.want))
+			}
+			for i, want := range tc.want {
+				if !strings.Contains(violations[i], want) {
+					t.Errorf("checkLibs() violation %q, want it to contain %q", violations[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDoWorkPolicy(t *testing.T) {
+	dir := t.TempDir()
+	libs := []abiLib{{abi: "arm64-v8a", path: writeELF(t, dir, "libfoo.so", arm64Lib("libfoo.so", "libbar.so"))}}
+	out := filepath.Join(dir, "libs.zip")
+	if err := doWork(libs, out, "", options{policy: warn}); err != nil {
+		t.Errorf("doWork() with policy %s unexpected error: %v", warn, err)
+	}
+	if err := doWork(libs, out, "", options{policy: fail}); err == nil || !strings.Contains(err.Error(), "libbar.so is not packaged") {
+		t.Errorf("doWork() with policy %s returned error %v, want missing dependency error", fail, err)
+	}
+}
@@ -17,35 +17,52 @@ package nativelib
 
 import (
 	"archive/zip"
-	"bufio"
+	"bytes"
+	"compress/flate"
 	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
-	"src/common/golang/fileutils"
 	"src/common/golang/flags"
 	"src/common/golang/ziputils"
 	"src/tools/ak/types"
 )
 
+// Policies for the violations found in the native libs.
+const (
+	warn = "warn"
+	fail = "error"
+)
+
 var (
 	// Cmd defines the command to run nativelib.
 	Cmd = types.Command{
 		Init:  Init,
 		Run:   Run,
 		Desc:  desc,
-		Flags: []string{"lib", "native_libs_zip", "out"},
+		Flags: []string{"lib", "abi_lib", "native_libs_zip", "out", "out_symbols", "policy", "strip", "deflate"},
 	}
 
 	// Variables to hold flag values
 	architecture  string
 	nativeLibs    flags.StringList
+	abiLibs       flags.MultiString
 	nativeLibsZip flags.StringList
 	out           string
+	outSymbols    string
+	policy        string
+	strip         bool
+	deflate       flags.StringList
 
 	initOnce sync.Once
 )
@@ -53,10 +70,15 @@ var (
 // Init initializes nativelib.
 func Init() {
 	initOnce.Do(func() {
-		flag.StringVar(&architecture, "architecture", "", "CPU architecture of the native libs.")
+		flag.StringVar(&architecture, "architecture", "", "CPU architecture of the native libs of -lib, and of the -native_libs_zip entries outside lib/<abi>/.")
 		flag.Var(&nativeLibs, "lib", "Path to native lib.")
-		flag.Var(&nativeLibsZip, "native_libs_zip", "Zip(s) containing native libs.")
+		flag.Var(&abiLibs, "abi_lib", "Repeatable native lib of an architecture: {abi}:{path}")
+		flag.Var(&nativeLibsZip, "native_libs_zip", "Zip(s) containing native libs, the architecture of lib/<abi>/ entries is <abi>.")
 		flag.StringVar(&out, "out", "", "Native libraries files.")
+		flag.StringVar(&outSymbols, "out_symbols", "", "(optional) Zip of the unstripped native libs, as <abi>/<name>, for crash symbolication.")
+		flag.StringVar(&policy, "policy", warn, "Policy for the violations found in the native libs, e.g. a wrong ABI: warn or error.")
+		flag.BoolVar(&strip, "strip", false, "Whether to strip the debug sections and symbol table of the native libs.")
+		flag.Var(&deflate, "deflate", "(optional) Patterns of the entries to deflate, e.g. lib/*/*.so, the others are stored.")
 	})
 }
 
@@ -64,28 +86,80 @@ func desc() string {
 	return "Nativelib creates the native lib zip."
 }
 
+// abiLib is a native library to package for an architecture.
+type abiLib struct {
+	abi  string
+	path string
+}
+
+// options configures the native lib zip.
+type options struct {
+	policy string
+	strip  bool
+	// deflate are the patterns of the entries to deflate.
+	deflate []string
+}
+
 // Run is the entry point for nativelib.
 func Run() {
+	if policy != warn && policy != fail {
+		log.Fatalf("Flag -policy must be %s or %s, got %q", warn, fail, policy)
+	}
+	for _, p := range deflate {
+		if _, err := path.Match(p, ""); err != nil {
+			log.Fatalf("Invalid -deflate pattern %q: %v", p, err)
+		}
+	}
+
+	var libs []abiLib
+	for _, lib := range nativeLibs {
+		libs = append(libs, abiLib{abi: architecture, path: lib})
+	}
+	for _, l := range abiLibs {
+		abi, lib, ok := strings.Cut(l, ":")
+		if !ok || abi == "" || lib == "" {
+			log.Fatalf("Invalid -abi_lib %q, want {abi}:{path}", l)
+		}
+		libs = append(libs, abiLib{abi: abi, path: lib})
+	}
 	if nativeLibsZip != nil {
 		dstDir, err := ioutil.TempDir("", "ziplibs")
 		if err != nil {
 			log.Fatalf("Error creating native lib zip: %v", err)
 		}
+		defer os.RemoveAll(dstDir)
 
-		for _, native := range nativeLibsZip {
-			libs, err := extractLibs(native, dstDir)
+		for i, native := range nativeLibsZip {
+			zipDir := filepath.Join(dstDir, strconv.Itoa(i))
+			paths, err := extractLibs(native, zipDir)
 			if err != nil {
 				log.Fatalf("Error creating native lib zip: %v", err)
 			}
-			nativeLibs = append(nativeLibs, libs...)
+			for _, p := range paths {
+				libs = append(libs, abiLib{abi: abiOf(zipDir, p, architecture), path: p})
+			}
 		}
 	}
 
-	if err := doWork(nativeLibs, architecture, out); err != nil {
+	if err := doWork(libs, out, outSymbols, options{policy: policy, strip: strip, deflate: deflate}); err != nil {
 		log.Fatalf("Error creating native lib zip: %v", err)
 	}
 }
 
+// abiOf returns the architecture of the lib extracted under dir: <abi> for lib/<abi>/ entries,
+// else def.
+func abiOf(dir, lib, def string) string {
+	rel, err := filepath.Rel(dir, lib)
+	if err != nil {
+		return def
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) == 3 && parts[0] == "lib" {
+		return parts[1]
+	}
+	return def
+}
+
 func extractLibs(libZip, dstDir string) ([]string, error) {
 	zr, err := zip.OpenReader(libZip)
 	if err != nil {
@@ -106,46 +180,119 @@ func extractLibs(libZip, dstDir string) ([]string, error) {
 	return libs, nil
 }
 
-func doWork(nativeLibs []string, architecture, out string) error {
-	nativeDir, err := ioutil.TempDir("", "nativelib")
-	if err != nil {
-		return err
+// entry is a file of the native lib zip.
+type entry struct {
+	name     string
+	contents []byte
+}
+
+func doWork(libs []abiLib, out, outSymbols string, o options) error {
+	byABI := make(map[string][]string)
+	for _, lib := range libs {
+		byABI[lib.abi] = append(byABI[lib.abi], lib.path)
 	}
-	defer os.RemoveAll(nativeDir)
-	nativePaths, err := copyNativeLibs(nativeLibs, architecture, nativeDir)
-	if err != nil {
+	var violations []string
+	for _, abi := range sortedKeys(byABI) {
+		violations = append(violations, checkLibs(byABI[abi], abi)...)
+	}
+	if len(violations) > 0 {
+		if o.policy == fail {
+			return fmt.Errorf("invalid native libs:\n\t%s", strings.Join(violations, "\n\t"))
+		}
+		for _, v := range violations {
+			log.Printf("Warning: %s", v)
+		}
+	}
+
+	// Libraries of the same name and architecture must be the same library, packaged once.
+	entries := make(map[string]entry)
+	symbols := make(map[string]entry)
+	sources := make(map[string]string)
+	for _, lib := range libs {
+		contents, err := os.ReadFile(lib.path)
+		if err != nil {
+			return err
+		}
+		base := filepath.Base(lib.path)
+		name := path.Join("lib", lib.abi, base)
+		if src, ok := sources[name]; ok {
+			other, err := os.ReadFile(src)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(contents, other) {
+				return fmt.Errorf("%s and %s differ but are both packaged as %s", src, lib.path, name)
+			}
+			continue
+		}
+		sources[name] = lib.path
+		isELF := bytes.HasPrefix(contents, []byte("\x7fELF"))
+		if isELF && outSymbols != "" {
+			symbols[name] = entry{name: path.Join(lib.abi, base), contents: contents}
+		}
+		if isELF && o.strip {
+			if contents, err = stripELF(contents); err != nil {
+				return fmt.Errorf("%s: stripping: %v", lib.path, err)
+			}
+		}
+		entries[name] = entry{name: name, contents: contents}
+	}
+
+	if err := writeZip(out, entries, func(name string) bool { return matchAny(o.deflate, name) }); err != nil {
 		return err
 	}
-	zipFile, err := os.Create(out)
+	if outSymbols != "" {
+		return writeZip(outSymbols, symbols, func(string) bool { return true })
+	}
+	return nil
+}
+
+// writeZip writes the entries to the zip out deterministically: sorted, with zero timestamps.
+func writeZip(out string, entries map[string]entry, deflated func(name string) bool) error {
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	f, err := os.Create(out)
 	if err != nil {
 		return err
 	}
-	writer := bufio.NewWriter(zipFile)
-	zipWriter := zip.NewWriter(writer)
-	sort.Strings(nativePaths)
-	for _, f := range nativePaths {
-		p, err := filepath.Rel(nativeDir, f)
+	zw := zip.NewWriter(f)
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, flate.BestCompression)
+	})
+	for _, name := range names {
+		e := entries[name]
+		method := zip.Store
+		if deflated(e.name) {
+			method = zip.Deflate
+		}
+		// It's important to set timestamps to zero, otherwise we would break caching for unchanged files
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: method, Modified: time.Unix(0, 0)})
 		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := w.Write(e.contents); err != nil {
+			f.Close()
 			return err
 		}
-		ziputils.WriteFile(zipWriter, f, p)
 	}
-	zipWriter.Close()
-	return nil
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
-func copyNativeLibs(nativeLibs []string, architecture, dir string) ([]string, error) {
-	var paths []string
-	for _, nativeLib := range nativeLibs {
-		libOutDir := filepath.Join(dir, "lib", architecture)
-		if err := os.MkdirAll(libOutDir, 0777); err != nil && !os.IsExist(err) {
-			return nil, err
-		}
-		outNativeLibPath := filepath.Join(libOutDir, filepath.Base(nativeLib))
-		if err := fileutils.Copy(nativeLib, outNativeLibPath); err != nil {
-			return nil, err
+// matchAny reports whether name matches any of the patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
 		}
-		paths = append(paths, outNativeLibPath)
 	}
-	return paths, nil
+	return false
 }
@@ -16,12 +16,17 @@ package nativelib
 
 import (
 	"archive/zip"
+	"bytes"
+	"debug/elf"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"src/common/golang/runfilelocation"
 )
@@ -64,8 +69,8 @@ func TestCreateNativeLibZip(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error finding dummy lib runfile: %v", err)
 	}
-	in := []string{dummyLibPath}
-	if err := doWork(in, "x86", out); err != nil {
+	in := []abiLib{{abi: "x86", path: dummyLibPath}}
+	if err := doWork(in, out, "", options{policy: warn}); err != nil {
 		t.Errorf("Error creating native lib zip: %v", err)
 	}
 
@@ -121,3 +126,136 @@ func TestExtractLibs(t *testing.T) {
 	}
 
 }
+
+func readZip(t *testing.T, name string) map[string]*zip.File {
+	t.Helper()
+	z, err := zip.OpenReader(name)
+	if err != nil {
+		t.Fatalf("Error opening zip: %v", err)
+	}
+	t.Cleanup(func() { z.Close() })
+	files := make(map[string]*zip.File)
+	var names []string
+	for _, f := range z.File {
+		files[f.Name] = f
+		names = append(names, f.Name)
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("%s entries %v are not sorted", name, names)
+	}
+	return files
+}
+
+func TestCreateMultiABIZip(t *testing.T) {
+	tmpDir := t.TempDir()
+	debugLib := func(s elfSpec) elfSpec {
+		s.debug = true
+		return s
+	}
+	libs := []abiLib{
+		{abi: "x86_64", path: writeELF(t, tmpDir, "x86_64/libfoo.so", debugLib(elfSpec{class: elf.ELFCLASS64, machine: elf.EM_X86_64, align: pageSize, soname: "libfoo.so"}))},
+		{abi: "arm64-v8a", path: writeELF(t, tmpDir, "arm64/libfoo.so", debugLib(arm64Lib("libfoo.so")))},
+		{abi: "armeabi-v7a", path: writeELF(t, tmpDir, "arm/libfoo.so", debugLib(elfSpec{class: elf.ELFCLASS32, machine: elf.EM_ARM, align: 4096, soname: "libfoo.so"}))},
+	}
+	out := filepath.Join(tmpDir, "libs.zip")
+	symbols := filepath.Join(tmpDir, "symbols.zip")
+	o := options{policy: fail, strip: true, deflate: []string{"lib/arm*/*.so"}}
+	if err := doWork(libs, out, symbols, o); err != nil {
+		t.Fatalf("doWork() unexpected error: %v", err)
+	}
+
+	files := readZip(t, out)
+	for name, method := range map[string]uint16{
+		"lib/arm64-v8a/libfoo.so":   zip.Deflate,
+		"lib/armeabi-v7a/libfoo.so": zip.Deflate,
+		"lib/x86_64/libfoo.so":      zip.Store,
+	} {
+		f, ok := files[name]
+		if !ok {
+			t.Errorf("%s has no %s entry", out, name)
+			continue
+		}
+		if f.Method != method {
+			t.Errorf("%s method = %d, want %d", name, f.Method, method)
+		}
+		if !f.Modified.Equal(time.Unix(0, 0)) {
+			t.Errorf("%s modified = %v, want the zero timestamp", name, f.Modified)
+		}
+	}
+	if len(files) != 3 {
+		t.Errorf("%s has %d entries, want 3", out, len(files))
+	}
+
+	symbolFiles := readZip(t, symbols)
+	for _, lib := range libs {
+		name := lib.abi + "/libfoo.so"
+		f, ok := symbolFiles[name]
+		if !ok {
+			t.Errorf("%s has no %s entry", symbols, name)
+			continue
+		}
+		fi, err := os.Stat(lib.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.UncompressedSize64 != uint64(fi.Size()) {
+			t.Errorf("%s size = %d, want the unstripped size %d", name, f.UncompressedSize64, fi.Size())
+		}
+		if stripped := files["lib/"+name]; stripped.UncompressedSize64 >= f.UncompressedSize64 {
+			t.Errorf("lib/%s size = %d, want less than the unstripped size %d", name, stripped.UncompressedSize64, f.UncompressedSize64)
+		}
+	}
+
+	// The zip is deterministic.
+	first, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doWork(libs, out, "", o); err != nil {
+		t.Fatalf("doWork() unexpected error: %v", err)
+	}
+	second, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("doWork() output differs between runs")
+	}
+}
+
+func TestCreateZipDuplicateNames(t *testing.T) {
+	tmpDir := t.TempDir()
+	foo := writeELF(t, tmpDir, "a/libfoo.so", arm64Lib("libfoo.so"))
+	fooCopy := writeELF(t, tmpDir, "b/libfoo.so", arm64Lib("libfoo.so"))
+	other := writeELF(t, tmpDir, "c/libfoo.so", arm64Lib("libother.so"))
+	out := filepath.Join(tmpDir, "libs.zip")
+
+	libs := []abiLib{{abi: "arm64-v8a", path: foo}, {abi: "arm64-v8a", path: fooCopy}}
+	if err := doWork(libs, out, "", options{policy: warn}); err != nil {
+		t.Fatalf("doWork() with identical libraries unexpected error: %v", err)
+	}
+	if files := readZip(t, out); len(files) != 1 {
+		t.Errorf("%s has %d entries, want 1", out, len(files))
+	}
+
+	libs = []abiLib{{abi: "arm64-v8a", path: foo}, {abi: "arm64-v8a", path: other}}
+	if err := doWork(libs, out, "", options{policy: warn}); err == nil || !strings.Contains(err.Error(), "packaged as lib/arm64-v8a/libfoo.so") {
+		t.Errorf("doWork() with different libraries returned error %v, want packaged as lib/arm64-v8a/libfoo.so", err)
+	}
+}
+
+func TestAbiOf(t *testing.T) {
+	tests := []struct {
+		lib  string
+		want string
+	}{
+		{"/tmp/0/lib/arm64-v8a/libfoo.so", "arm64-v8a"},
+		{"/tmp/0/jni/arm64-v8a/libfoo.so", "x86"},
+		{"/tmp/0/libfoo.so", "x86"},
+	}
+	for _, tc := range tests {
+		if got := abiOf("/tmp/0", tc.lib, "x86"); got != tc.want {
+			t.Errorf("abiOf(%s) = %s, want %s", tc.lib, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nativelib
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// headerLayout holds the offsets of the fields of the ELF header locating the program and section
+// headers, for 32-bit or 64-bit files.
+type headerLayout struct {
+	phoff, shoff                                         int
+	ehsize, phentsize, phnum, shentsize, shnum, shstrndx int
+	wordSize                                             int
+}
+
+var (
+	header32 = headerLayout{phoff: 0x1C, shoff: 0x20, ehsize: 0x28, phentsize: 0x2A, phnum: 0x2C, shentsize: 0x2E, shnum: 0x30, shstrndx: 0x32, wordSize: 4}
+	header64 = headerLayout{phoff: 0x20, shoff: 0x28, ehsize: 0x34, phentsize: 0x36, phnum: 0x38, shentsize: 0x3A, shnum: 0x3C, shstrndx: 0x3E, wordSize: 8}
+)
+
+// rawSection is a section header, with the name as an offset in the section name table.
+type rawSection struct {
+	name, typ          uint32
+	flags, addr        uint64
+	off, size          uint64
+	link, info         uint32
+	addralign, entsize uint64
+}
+
+// isDebugSection reports whether the non-allocated section name is stripped: debug information
+// and the static symbol table, which the dynamic linker does not use.
+func isDebugSection(name string) bool {
+	return strings.HasPrefix(name, ".debug") || strings.HasPrefix(name, ".zdebug") || name == ".symtab" || name == ".strtab"
+}
+
+// stripELF returns the ELF file b without its debug sections, like strip --strip-debug
+// --strip-unneeded. The headers and segments are kept in place, the remaining non-allocated
+// sections and the section headers are moved to follow them. It returns b if there is nothing to
+// strip.
+func stripELF(b []byte) ([]byte, error) {
+	f, err := elf.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	layout := header64
+	if f.Class == elf.ELFCLASS32 {
+		layout = header32
+	}
+	bo := f.ByteOrder
+	word := func(off int) uint64 {
+		if layout.wordSize == 8 {
+			return bo.Uint64(b[off:])
+		}
+		return uint64(bo.Uint32(b[off:]))
+	}
+	shoff := int(word(layout.shoff))
+	shentsize := int(bo.Uint16(b[layout.shentsize:]))
+	shnum := int(bo.Uint16(b[layout.shnum:]))
+	shstrndx := int(bo.Uint16(b[layout.shstrndx:]))
+	if shnum == 0 || shnum != len(f.Sections) || shstrndx >= shnum {
+		return nil, fmt.Errorf("unsupported section header table")
+	}
+
+	sections := make([]rawSection, shnum)
+	for i := range sections {
+		off := shoff + i*shentsize
+		if off+shentsize > len(b) {
+			return nil, fmt.Errorf("section header %d out of bounds", i)
+		}
+		sections[i] = readSection(b[off:off+shentsize], bo, layout.wordSize)
+	}
+
+	drop := make([]bool, shnum)
+	stripped := false
+	for i, s := range f.Sections {
+		if i != 0 && i != shstrndx && s.Flags&elf.SHF_ALLOC == 0 && isDebugSection(s.Name) {
+			drop[i] = true
+			stripped = true
+		}
+	}
+	if !stripped {
+		return b, nil
+	}
+	// Keep the sections still linked to, e.g. a .strtab used by another section than .symtab.
+	for i, s := range sections {
+		if !drop[i] && int(s.link) < shnum {
+			drop[s.link] = false
+		}
+	}
+
+	// The headers and segments are kept in place.
+	end := int(bo.Uint16(b[layout.ehsize:]))
+	end = max(end, int(word(layout.phoff))+int(bo.Uint16(b[layout.phnum:]))*int(bo.Uint16(b[layout.phentsize:])))
+	for _, p := range f.Progs {
+		end = max(end, int(p.Off+p.Filesz))
+	}
+	if end > len(b) {
+		return nil, fmt.Errorf("segments out of bounds")
+	}
+
+	var out bytes.Buffer
+	out.Write(b[:end])
+	index := make([]uint32, shnum)
+	var kept []rawSection
+	for i, s := range sections {
+		if drop[i] {
+			continue
+		}
+		index[i] = uint32(len(kept))
+		if s.typ != uint32(elf.SHT_NOBITS) && s.off+s.size > uint64(end) {
+			if s.off+s.size > uint64(len(b)) {
+				return nil, fmt.Errorf("section %d out of bounds", i)
+			}
+			data := b[s.off : s.off+s.size]
+			pad(&out, s.addralign)
+			s.off = uint64(out.Len())
+			out.Write(data)
+		}
+		kept = append(kept, s)
+	}
+	for i := range kept {
+		s := &kept[i]
+		if int(s.link) < shnum {
+			s.link = index[s.link]
+		}
+		if elf.SectionFlag(s.flags)&elf.SHF_INFO_LINK != 0 && int(s.info) < shnum {
+			s.info = index[s.info]
+		}
+	}
+
+	pad(&out, uint64(layout.wordSize))
+	newShoff := out.Len()
+	for _, s := range kept {
+		out.Write(writeSection(s, bo, layout.wordSize, shentsize))
+	}
+	res := out.Bytes()
+	if layout.wordSize == 8 {
+		bo.PutUint64(res[layout.shoff:], uint64(newShoff))
+	} else {
+		bo.PutUint32(res[layout.shoff:], uint32(newShoff))
+	}
+	bo.PutUint16(res[layout.shnum:], uint16(len(kept)))
+	bo.PutUint16(res[layout.shstrndx:], uint16(index[shstrndx]))
+	return res, nil
+}
+
+func pad(b *bytes.Buffer, align uint64) {
+	if align > 1 {
+		for uint64(b.Len())%align != 0 {
+			b.WriteByte(0)
+		}
+	}
+}
+
+func readSection(b []byte, bo binary.ByteOrder, wordSize int) rawSection {
+	off := 0
+	u32 := func() uint32 {
+		v := bo.Uint32(b[off:])
+		off += 4
+		return v
+	}
+	word := func() uint64 {
+		if wordSize == 4 {
+			return uint64(u32())
+		}
+		v := bo.Uint64(b[off:])
+		off += 8
+		return v
+	}
+	var s rawSection
+	s.name = u32()
+	s.typ = u32()
+	s.flags = word()
+	s.addr = word()
+	s.off = word()
+	s.size = word()
+	s.link = u32()
+	s.info = u32()
+	s.addralign = word()
+	s.entsize = word()
+	return s
+}
+
+func writeSection(s rawSection, bo binary.ByteOrder, wordSize, size int) []byte {
+	b := make([]byte, size)
+	off := 0
+	u32 := func(v uint32) {
+		bo.PutUint32(b[off:], v)
+		off += 4
+	}
+	word := func(v uint64) {
+		if wordSize == 4 {
+			u32(uint32(v))
+			return
+		}
+		bo.PutUint64(b[off:], v)
+		off += 8
+	}
+	u32(s.name)
+	u32(s.typ)
+	word(s.flags)
+	word(s.addr)
+	word(s.off)
+	word(s.size)
+	u32(s.link)
+	u32(s.info)
+	word(s.addralign)
+	word(s.entsize)
+	return b
+}
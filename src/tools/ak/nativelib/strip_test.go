@@ -0,0 +1,96 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nativelib
+
+import (
+	"bytes"
+	"debug/elf"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func sectionNames(t *testing.T, b []byte) []string {
+	t.Helper()
+	f, err := elf.NewFile(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("elf.NewFile() unexpected error: %v", err)
+	}
+	var names []string
+	for _, s := range f.Sections {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestStripELF(t *testing.T) {
+	for _, class := range []elf.Class{elf.ELFCLASS32, elf.ELFCLASS64} {
+		t.Run(class.String(), func(t *testing.T) {
+			s := elfSpec{class: class, machine: elf.EM_AARCH64, align: pageSize, soname: "libfoo.so", needed: []string{"libbar.so"}, debug: true}
+			b, err := os.ReadFile(writeELF(t, t.TempDir(), "libfoo.so", s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff([]string{"", ".dynstr", ".dynamic", ".shstrtab", ".debug_info", ".strtab", ".symtab"}, sectionNames(t, b)); diff != "" {
+				t.Fatalf("writeELF() sections diff (-want, +got):\n%v", diff)
+			}
+
+			stripped, err := stripELF(b)
+			if err != nil {
+				t.Fatalf("stripELF() unexpected error: %v", err)
+			}
+			if len(stripped) >= len(b) {
+				t.Errorf("stripELF() returned %d bytes, want less than %d", len(stripped), len(b))
+			}
+			if diff := cmp.Diff([]string{"", ".dynstr", ".dynamic", ".shstrtab"}, sectionNames(t, stripped)); diff != "" {
+				t.Errorf("stripELF() sections diff (-want, +got):\n%v", diff)
+			}
+			f, err := elf.NewFile(bytes.NewReader(stripped))
+			if err != nil {
+				t.Fatal(err)
+			}
+			needed, err := f.ImportedLibraries()
+			if err != nil {
+				t.Fatalf("ImportedLibraries() unexpected error: %v", err)
+			}
+			if diff := cmp.Diff([]string{"libbar.so"}, needed); diff != "" {
+				t.Errorf("stripped DT_NEEDED diff (-want, +got):\n%v", diff)
+			}
+			// The LOAD segment is kept, but for the section header fields of the ELF header.
+			ehsize := 52
+			if class == elf.ELFCLASS64 {
+				ehsize = 64
+			}
+			if end := f.Progs[0].Filesz; !bytes.Equal(stripped[ehsize:end], b[ehsize:end]) {
+				t.Errorf("stripELF() changed the LOAD segment")
+			}
+		})
+	}
+}
+
+func TestStripELFNothingToStrip(t *testing.T) {
+	b, err := os.ReadFile(writeELF(t, t.TempDir(), "libfoo.so", arm64Lib("libfoo.so")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped, err := stripELF(b)
+	if err != nil {
+		t.Fatalf("stripELF() unexpected error: %v", err)
+	}
+	if !bytes.Equal(stripped, b) {
+		t.Errorf("stripELF() changed a library without debug sections")
+	}
+}
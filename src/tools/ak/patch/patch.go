@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package patch sets/replaces the application class/package in a given AndroidManifest.xml.
+// Package patch sets/replaces the application class/package in a given AndroidManifest.xml, and
+// writes the manifests of its configuration and feature splits.
 package patch
 
 import (
@@ -42,17 +43,21 @@ var (
 		Init:  Init,
 		Run:   Run,
 		Desc:  desc,
-		Flags: []string{"in", "out", "attr", "app", "oldapp", "pkg"},
+		Flags: []string{"in", "out", "attr", "app", "oldapp", "pkg", "split_manifest", "placeholder", "placeholders_file", "edit"},
 	}
 
 	// Variables that hold flag values
-	split  flags.StringList
-	attr   flags.StringList
-	in     string
-	out    string
-	app    string
-	oldApp string
-	pkg    string
+	split            flags.StringList
+	splitManifests   flags.MultiString
+	attr             flags.StringList
+	placeholders     flags.MultiString
+	placeholdersFile string
+	edits            flags.MultiString
+	in               string
+	out              string
+	app              string
+	oldApp           string
+	pkg              string
 
 	initOnce sync.Once
 )
@@ -64,8 +69,12 @@ func Init() {
 		flag.StringVar(&out, "out", "", "Path to the output xml file.")
 		flag.Var(&attr, "attr", "(optional) attr(s) to set. {element}:{attr}:{value}.")
 		flag.Var(&split, "split", "(optional) splits(s) to write. {name}:{file}.")
+		flag.Var(&splitManifests, "split_manifest", "(optional) Repeatable split to write. {name}:{file}[,{option}]*, where the options are feature, config_for={feature}, has_code={bool}, title={resource}, delivery={install-time|on-demand}, fusing={bool}, min_sdk={level}, max_sdk={level}, device_feature={name}, countries={code}[+{code}]* and exclude_countries={code}[+{code}]*.")
 		flag.StringVar(&oldApp, "oldapp", "", "(optional) Path to output the old application class name.")
 		flag.StringVar(&pkg, "pkg", "", "(optional) Path to output the package name.")
+		flag.Var(&placeholders, "placeholder", "(optional) Repeatable placeholder value to substitute. {name}={value}.")
+		flag.StringVar(&placeholdersFile, "placeholders_file", "", "(optional) Path to a file of {name}={value} placeholder values.")
+		flag.Var(&edits, "edit", "(optional) Repeatable edit applied in order: set:{path}@{attr}={value}, add:{path}@{attr}={value}, remove:{path}[@{attr}] or insert:{path}={xml}, where {path} selects elements, e.g. application/activity[@android:name=.Main].")
 	})
 }
 
@@ -75,8 +84,8 @@ func desc() string {
 
 // Run is the entry point for patch.
 func Run() {
-	if in == "" || (out == "" && split == nil) {
-		log.Fatal("fields and -in and -out|-splits and must be defined.")
+	if in == "" || (out == "" && split == nil && splitManifests == nil) {
+		log.Fatal("fields and -in and -out|-split|-split_manifest and must be defined.")
 	}
 
 	elems := manifestutils.CreatePatchElements(attr)
@@ -86,8 +95,23 @@ func Run() {
 	if err != nil {
 		log.Fatalf("ioutil.ReadFile(%q) failed: %v", in, err)
 	}
-	var manifest manifestutils.Manifest
-	xml.Unmarshal(b, &manifest)
+	values, err := manifestutils.LoadPlaceholders(placeholdersFile, placeholders)
+	if err != nil {
+		log.Fatalf("Error reading placeholders: %v", err)
+	}
+	// Placeholders left unresolved fail, whether or not values are given.
+	if b, err = manifestutils.ExpandManifest(b, values); err != nil {
+		log.Fatalf("Error expanding placeholders of %q: %v", in, err)
+	}
+	if len(edits) > 0 {
+		if b, err = manifestutils.EditManifest(b, edits); err != nil {
+			log.Fatalf("Error editing %q: %v", in, err)
+		}
+	}
+	manifest, err := manifestutils.ReadManifest(bytes.NewReader(b))
+	if err != nil {
+		log.Fatalf("Error reading manifest %q: %v", in, err)
+	}
 
 	// Optional parse package name and/or application class name before replacing
 	if pkg != "" || oldApp != "" {
@@ -99,7 +123,10 @@ func Run() {
 			}
 		}
 		if oldApp != "" {
-			appName := manifest.Application.Name
+			appName := ""
+			if manifest.Application != nil {
+				appName = manifest.Application.Name
+			}
 			if appName == "" {
 				appName = "android.app.Application"
 			}
@@ -136,19 +163,19 @@ func Run() {
 			Value: manifest.VersionName}
 	}
 
+	var patched bytes.Buffer
+	if err := manifestutils.WriteManifest(&patched, bytes.NewReader(b), elems); err != nil {
+		log.Fatalf("Error setting fields: %v", err)
+	}
 	if out != "" {
-		o, err := os.Create(out)
-		if err != nil {
-			log.Fatalf("Error creating output file %q:  %v", out, err)
-		}
-		defer o.Close()
-
-		if err := manifestutils.WriteManifest(o, bytes.NewReader(b), elems); err != nil {
-			log.Fatalf("Error setting fields: %v", err)
+		if err := ioutil.WriteFile(out, patched.Bytes(), 0644); err != nil {
+			log.Fatalf("Error writing output file %q:  %v", out, err)
 		}
-
 	}
 
+	// Write the split manifests, which share the package and versions of the patched manifest
+	writeSplitManifests(patched.Bytes())
+
 	// Patch the splits
 	b = []byte(stubManifest)
 	for _, s := range split {
@@ -169,3 +196,36 @@ func Run() {
 		}
 	}
 }
+
+// writeSplitManifests writes the manifests of the -split_manifest splits of the patched manifest.
+func writeSplitManifests(patched []byte) {
+	var specs []SplitSpec
+	for _, s := range splitManifests {
+		spec, err := ParseSplitSpec(s)
+		if err != nil {
+			log.Fatalf("Failed to parse split: %v", err)
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 {
+		return
+	}
+	base, err := manifestutils.ReadManifest(bytes.NewReader(patched))
+	if err != nil {
+		log.Fatalf("Error reading patched manifest: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, spec := range specs {
+		if names[spec.Name] {
+			log.Fatalf("Split %s is defined more than once", spec.Name)
+		}
+		names[spec.Name] = true
+		var o bytes.Buffer
+		if err := WriteSplitManifest(&o, base, spec); err != nil {
+			log.Fatalf("Error writing split manifest: %v", err)
+		}
+		if err := ioutil.WriteFile(spec.File, o.Bytes(), 0644); err != nil {
+			log.Fatalf("Error writing output file %q:  %v", spec.File, err)
+		}
+	}
+}
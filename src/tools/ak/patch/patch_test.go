@@ -94,8 +94,10 @@ func TestParsing(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			var manifest manifestutils.Manifest
-			xml.Unmarshal([]byte(test.manifestXML), &manifest)
+			manifest, err := manifestutils.ReadManifest(strings.NewReader(test.manifestXML))
+			if err != nil {
+				t.Fatalf("Error reading manifest: %v", err)
+			}
 			if manifest.Package != test.wantPkg {
 				t.Errorf("Parsed package name not correct: got: %q wanted: %q", manifest.Package, test.wantPkg)
 			}
@@ -140,8 +142,10 @@ func TestSetAppName(t *testing.T) {
 			if err := e.Flush(); err != nil {
 				t.Fatalf("Error occurred during encoder flush: %v", err)
 			}
-			var manifest manifestutils.Manifest
-			xml.Unmarshal([]byte(b.String()), &manifest)
+			manifest, err := manifestutils.ReadManifest(&b)
+			if err != nil {
+				t.Fatalf("Error reading patched manifest: %v", err)
+			}
 			if manifest.Application.Name != test.newApp {
 				t.Errorf("New application class name not correct: got: %q wanted: %q", manifest.Application.Name, test.newApp)
 			}
@@ -0,0 +1,349 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"src/tools/ak/manifestutils"
+	"src/tools/ak/sdklevel"
+)
+
+// Delivery modes of feature splits.
+const (
+	DeliveryInstallTime = "install-time"
+	DeliveryOnDemand    = "on-demand"
+)
+
+var featureNameRE = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// SplitSpec describes a split manifest to write for bundletool.
+type SplitSpec struct {
+	// Name is the split name, e.g. config.xxhdpi or the name of a feature module.
+	Name string
+	// File is the path to write the manifest to.
+	File string
+	// Feature marks the splits of dynamic feature modules, as opposed to configuration splits.
+	Feature bool
+	// ConfigFor is the feature split a configuration split belongs to, empty for the base.
+	ConfigFor string
+	// HasCode is whether a feature split has dex code. Configuration splits never have.
+	HasCode bool
+	// Title is the dist:title string resource of a feature split, e.g. @string/feature_title.
+	Title string
+	// Delivery is the delivery mode of a feature split, DeliveryInstallTime or DeliveryOnDemand.
+	Delivery string
+	// Conditions restrict the install-time delivery of a feature split.
+	Conditions SplitConditions
+	// Fusing includes the feature split in the APKs of devices without split support.
+	Fusing bool
+}
+
+// SplitConditions are the dist:conditions of an install-time feature split.
+type SplitConditions struct {
+	MinSdk, MaxSdk string
+	DeviceFeatures []string
+	// UserCountries are the country codes the split is delivered in, or not delivered in if
+	// ExcludeCountries is set.
+	UserCountries    []string
+	ExcludeCountries bool
+}
+
+func (c SplitConditions) empty() bool {
+	return c.MinSdk == "" && c.MaxSdk == "" && len(c.DeviceFeatures) == 0 && len(c.UserCountries) == 0
+}
+
+// ParseSplitSpec parses a split of the form {name}:{file}[,{option}]*. Options are:
+//
+//	feature                            the split of a dynamic feature module
+//	config_for={feature}               a configuration split of the feature split
+//	has_code={true|false}              whether a feature split has code, defaults to true
+//	title={@string/name}               the title of a feature split
+//	delivery={install-time|on-demand}  the delivery of a feature split, defaults to install-time
+//	fusing={true|false}                whether a feature split is fused, defaults to true
+//	min_sdk={level}, max_sdk={level}   install-time conditions on the SDK level
+//	device_feature={name}              repeatable install-time condition on a device feature
+//	countries={code}[+{code}]*         install-time condition on the user country
+//	exclude_countries={code}[+{code}]* install-time condition excluding user countries
+func ParseSplitSpec(s string) (SplitSpec, error) {
+	opts := strings.Split(s, ",")
+	name, file, ok := strings.Cut(opts[0], ":")
+	if !ok || name == "" || file == "" {
+		return SplitSpec{}, fmt.Errorf("split %q is not of the form {name}:{file}[,{option}]*", s)
+	}
+	spec := SplitSpec{Name: name, File: file}
+	// The defaults of feature splits, configuration splits have no code, delivery nor fusing.
+	hasCode, delivery, fusing := true, DeliveryInstallTime, true
+	var minSdk, maxSdk sdklevel.Level
+	featureOnly := ""
+	for _, opt := range opts[1:] {
+		if opt == "feature" {
+			spec.Feature = true
+			continue
+		}
+		k, v, ok := strings.Cut(opt, "=")
+		if !ok || v == "" {
+			return SplitSpec{}, fmt.Errorf("split %q has option %q, want {key}={value}", s, opt)
+		}
+		var err error
+		switch k {
+		case "config_for":
+			spec.ConfigFor = v
+		case "has_code":
+			hasCode, err = parseBool(v)
+		case "title":
+			spec.Title = v
+		case "delivery":
+			if v != DeliveryInstallTime && v != DeliveryOnDemand {
+				err = fmt.Errorf("want %s or %s", DeliveryInstallTime, DeliveryOnDemand)
+			}
+			delivery = v
+		case "fusing":
+			fusing, err = parseBool(v)
+		case "min_sdk":
+			minSdk, err = parseAPILevel(v)
+			spec.Conditions.MinSdk = minSdk.String()
+		case "max_sdk":
+			maxSdk, err = parseAPILevel(v)
+			spec.Conditions.MaxSdk = maxSdk.String()
+		case "device_feature":
+			spec.Conditions.DeviceFeatures = append(spec.Conditions.DeviceFeatures, v)
+		case "countries", "exclude_countries":
+			if len(spec.Conditions.UserCountries) > 0 {
+				err = fmt.Errorf("only one of countries and exclude_countries may be set")
+			}
+			spec.Conditions.UserCountries = strings.Split(v, "+")
+			spec.Conditions.ExcludeCountries = k == "exclude_countries"
+		default:
+			return SplitSpec{}, fmt.Errorf("split %q has unknown option %q", s, k)
+		}
+		if err != nil {
+			return SplitSpec{}, fmt.Errorf("split %q has invalid option %q: %v", s, opt, err)
+		}
+		if k != "config_for" && featureOnly == "" {
+			featureOnly = k
+		}
+	}
+	switch {
+	case !minSdk.IsZero() && !maxSdk.IsZero() && maxSdk.Major < minSdk.Major:
+		return SplitSpec{}, fmt.Errorf("split %q has max_sdk %s lower than min_sdk %s", s, maxSdk, minSdk)
+	case !spec.Feature && featureOnly != "":
+		return SplitSpec{}, fmt.Errorf("split %q is a configuration split, option %q is only for feature splits", s, featureOnly)
+	case spec.Feature && spec.ConfigFor != "":
+		return SplitSpec{}, fmt.Errorf("split %q is a feature split, option config_for is only for configuration splits", s)
+	case spec.Feature && !featureNameRE.MatchString(spec.Name):
+		return SplitSpec{}, fmt.Errorf("split %q has invalid feature name %q, want letters, digits and underscores", s, spec.Name)
+	case delivery == DeliveryOnDemand && !spec.Conditions.empty():
+		return SplitSpec{}, fmt.Errorf("split %q has install-time conditions but %s delivery", s, DeliveryOnDemand)
+	case spec.Feature && spec.Title == "" && (delivery == DeliveryOnDemand || !spec.Conditions.empty()):
+		return SplitSpec{}, fmt.Errorf("split %q needs a title, bundletool requires one for on-demand and conditional features", s)
+	}
+	if spec.Feature {
+		spec.HasCode, spec.Delivery, spec.Fusing = hasCode, delivery, fusing
+	}
+	return spec, nil
+}
+
+// parseAPILevel parses the value of the dist:min-sdk and dist:max-sdk conditions, which take
+// neither preview codenames nor minor versions.
+func parseAPILevel(v string) (sdklevel.Level, error) {
+	l, err := sdklevel.Parse(v)
+	switch {
+	case err != nil:
+		return sdklevel.Level{}, err
+	case l.IsPreview() || l.Minor != 0:
+		return sdklevel.Level{}, fmt.Errorf("want an API level, e.g. 24")
+	}
+	return l, nil
+}
+
+func parseBool(v string) (bool, error) {
+	switch v {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("want true or false")
+}
+
+// WriteSplitManifest writes the manifest of the split spec of the base manifest to w, after
+// validating it against the base.
+func WriteSplitManifest(w io.Writer, base *manifestutils.Manifest, spec SplitSpec) error {
+	if base.Package == "" {
+		return fmt.Errorf("base manifest has no package")
+	}
+	var b bytes.Buffer
+	writeSplitManifest(&b, base, spec)
+	split, err := manifestutils.ReadManifest(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		return fmt.Errorf("split %s: %v", spec.Name, err)
+	}
+	if err := ValidateSplitManifest(base, split); err != nil {
+		return fmt.Errorf("split %s: %v", spec.Name, err)
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+func writeSplitManifest(b *bytes.Buffer, base *manifestutils.Manifest, spec SplitSpec) {
+	x := &xmlWriter{b: b}
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	attrs := []string{"xmlns:android", manifestutils.NameSpace}
+	if spec.Feature {
+		attrs = append(attrs, "xmlns:dist", manifestutils.DistNameSpace)
+	}
+	attrs = append(attrs, manifestutils.AttrPackage, base.Package)
+	for _, a := range [][2]string{
+		{manifestutils.AttrSharedUserID, base.SharedUserID},
+		{manifestutils.AttrSharedUserLabel, base.SharedUserLabel},
+		{manifestutils.AttrVersionCode, base.VersionCode},
+		{manifestutils.AttrVersionName, base.VersionName},
+	} {
+		if a[1] != "" {
+			attrs = append(attrs, "android:"+a[0], a[1])
+		}
+	}
+	attrs = append(attrs, manifestutils.AttrSplit, spec.Name)
+	if spec.ConfigFor != "" {
+		attrs = append(attrs, "configForSplit", spec.ConfigFor)
+	}
+	if spec.Feature {
+		attrs = append(attrs, "android:isFeatureSplit", "true")
+	}
+	x.open("manifest", attrs...)
+
+	if spec.Feature {
+		var module []string
+		if spec.Title != "" {
+			module = append(module, "dist:title", spec.Title)
+		}
+		x.open("dist:module", module...)
+		x.open("dist:delivery")
+		if spec.Delivery == DeliveryOnDemand {
+			x.empty("dist:on-demand")
+		} else if c := spec.Conditions; c.empty() {
+			x.empty("dist:install-time")
+		} else {
+			x.open("dist:install-time")
+			x.open("dist:conditions")
+			if c.MinSdk != "" {
+				x.empty("dist:min-sdk", "dist:value", c.MinSdk)
+			}
+			if c.MaxSdk != "" {
+				x.empty("dist:max-sdk", "dist:value", c.MaxSdk)
+			}
+			for _, f := range c.DeviceFeatures {
+				x.empty("dist:device-feature", "dist:name", f)
+			}
+			if len(c.UserCountries) > 0 {
+				x.open("dist:user-countries", "dist:exclude", fmt.Sprint(c.ExcludeCountries))
+				for _, code := range c.UserCountries {
+					x.empty("dist:country", "dist:code", code)
+				}
+				x.close("dist:user-countries")
+			}
+			x.close("dist:conditions")
+			x.close("dist:install-time")
+		}
+		x.close("dist:delivery")
+		x.empty("dist:fusing", "dist:include", fmt.Sprint(spec.Fusing))
+		x.close("dist:module")
+	}
+	x.empty("application", "android:hasCode", fmt.Sprint(spec.Feature && spec.HasCode))
+	x.close("manifest")
+}
+
+// ValidateSplitManifest checks that the split manifest is consistent with the base manifest, as
+// required by bundletool.
+func ValidateSplitManifest(base, split *manifestutils.Manifest) error {
+	switch {
+	case split.Split == "":
+		return fmt.Errorf("manifest has no split name")
+	case split.Package != base.Package:
+		return fmt.Errorf("package %q differs from the package of the base manifest (%q)", split.Package, base.Package)
+	case split.VersionCode != base.VersionCode:
+		return fmt.Errorf("versionCode %q differs from the versionCode of the base manifest (%q)", split.VersionCode, base.VersionCode)
+	}
+	feature := split.IsFeatureSplit == "true"
+	var module *manifestutils.Element
+	for _, e := range split.Element().Elements("module") {
+		if e.Name.Space == manifestutils.DistNameSpace {
+			module = e
+		}
+	}
+	switch {
+	case feature && split.ConfigForSplit != "":
+		return fmt.Errorf("feature split has configForSplit %q", split.ConfigForSplit)
+	case feature && module == nil:
+		return fmt.Errorf("feature split has no dist:module element")
+	case !feature && module != nil:
+		return fmt.Errorf("configuration split has a dist:module element")
+	case !feature && split.Application != nil && split.Application.HasCode != "false":
+		return fmt.Errorf("configuration split has code")
+	}
+	if module == nil {
+		return nil
+	}
+	var delivery, fusing bool
+	for _, e := range module.Elements("") {
+		delivery = delivery || e.Name.Local == "delivery"
+		fusing = fusing || e.Name.Local == "fusing"
+	}
+	switch {
+	case !delivery:
+		return fmt.Errorf("feature split has no dist:delivery element")
+	case !fusing:
+		return fmt.Errorf("feature split has no dist:fusing element")
+	}
+	return nil
+}
+
+// xmlWriter writes indented elements.
+type xmlWriter struct {
+	b     *bytes.Buffer
+	depth int
+}
+
+func (x *xmlWriter) start(name string, attrs []string) {
+	x.b.WriteString(strings.Repeat("  ", x.depth))
+	x.b.WriteString("<" + name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		x.b.WriteString(" " + attrs[i] + `="`)
+		xml.EscapeText(x.b, []byte(attrs[i+1]))
+		x.b.WriteString(`"`)
+	}
+}
+
+func (x *xmlWriter) open(name string, attrs ...string) {
+	x.start(name, attrs)
+	x.b.WriteString(">\n")
+	x.depth++
+}
+
+func (x *xmlWriter) empty(name string, attrs ...string) {
+	x.start(name, attrs)
+	x.b.WriteString("/>\n")
+}
+
+func (x *xmlWriter) close(name string) {
+	x.depth--
+	x.b.WriteString(strings.Repeat("  ", x.depth) + "</" + name + ">\n")
+}
@@ -0,0 +1,273 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"src/tools/ak/manifestutils"
+	"github.com/google/go-cmp/cmp"
+)
+
+const baseManifest = `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" android:versionCode="42" android:versionName="4.2">
+  <application android:name=".App"/>
+</manifest>`
+
+func TestParseSplitSpec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want SplitSpec
+	}{
+		{
+			in:   "config.xxhdpi:out/xxhdpi.xml",
+			want: SplitSpec{Name: "config.xxhdpi", File: "out/xxhdpi.xml"},
+		},
+		{
+			in:   "camera.config.xxhdpi:out/camera_xxhdpi.xml,config_for=camera",
+			want: SplitSpec{Name: "camera.config.xxhdpi", File: "out/camera_xxhdpi.xml", ConfigFor: "camera"},
+		},
+		{
+			in:   "camera:out/camera.xml,feature,title=@string/camera,delivery=on-demand,fusing=false,has_code=false",
+			want: SplitSpec{Name: "camera", File: "out/camera.xml", Feature: true, Title: "@string/camera", Delivery: DeliveryOnDemand},
+		},
+		{
+			in: "ar:out/ar.xml,feature,title=@string/ar,min_sdk=24,device_feature=android.hardware.camera.ar,device_feature=android.hardware.camera,exclude_countries=US+CA",
+			want: SplitSpec{Name: "ar", File: "out/ar.xml", Feature: true, Title: "@string/ar", HasCode: true, Delivery: DeliveryInstallTime, Fusing: true,
+				Conditions: SplitConditions{
+					MinSdk:           "24",
+					DeviceFeatures:   []string{"android.hardware.camera.ar", "android.hardware.camera"},
+					UserCountries:    []string{"US", "CA"},
+					ExcludeCountries: true,
+				}},
+		},
+	}
+	for _, tc := range tests {
+		got, err := ParseSplitSpec(tc.in)
+		if err != nil {
+			t.Errorf("ParseSplitSpec(%q) failed: %v", tc.in, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("ParseSplitSpec(%q) returned diff (-want, +got):\n%v", tc.in, diff)
+		}
+	}
+}
+
+func TestParseSplitSpecErrors(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr string
+	}{
+		{"camera", "is not of the form"},
+		{"camera:", "is not of the form"},
+		{"camera:f.xml,feature,bogus=1", `unknown option "bogus"`},
+		{"camera:f.xml,feature,fusing", `want {key}={value}`},
+		{"camera:f.xml,feature,fusing=yes", "want true or false"},
+		{"camera:f.xml,feature,delivery=later", "want install-time or on-demand"},
+		{"config.xxhdpi:f.xml,title=@string/t", `option "title" is only for feature splits`},
+		{"camera:f.xml,feature,config_for=base", "option config_for is only for configuration splits"},
+		{"camera.ar:f.xml,feature", `invalid feature name "camera.ar"`},
+		{"camera:f.xml,feature,title=@string/t,delivery=on-demand,min_sdk=24", "has install-time conditions but on-demand delivery"},
+		{"camera:f.xml,feature,delivery=on-demand", "needs a title"},
+		{"camera:f.xml,feature,title=@string/t,countries=US,exclude_countries=CA", "only one of countries and exclude_countries"},
+		{"camera:f.xml,feature,title=@string/t,min_sdk=twenty", `invalid SDK level "twenty"`},
+		{"camera:f.xml,feature,title=@string/t,min_sdk=Baklava", "want an API level"},
+		{"camera:f.xml,feature,title=@string/t,max_sdk=33.1", "want an API level"},
+		{"camera:f.xml,feature,title=@string/t,min_sdk=28,max_sdk=24", "has max_sdk 24 lower than min_sdk 28"},
+	}
+	for _, tc := range tests {
+		if _, err := ParseSplitSpec(tc.in); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("ParseSplitSpec(%q) returned error %v, want %q", tc.in, err, tc.wantErr)
+		}
+	}
+}
+
+func TestWriteSplitManifest(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{
+			name: "config split",
+			spec: "config.xxhdpi:f.xml",
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" android:versionCode="42" android:versionName="4.2" split="config.xxhdpi">
+  <application android:hasCode="false"/>
+</manifest>
+`,
+		},
+		{
+			name: "config split of feature",
+			spec: "camera.config.xxhdpi:f.xml,config_for=camera",
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" android:versionCode="42" android:versionName="4.2" split="camera.config.xxhdpi" configForSplit="camera">
+  <application android:hasCode="false"/>
+</manifest>
+`,
+		},
+		{
+			name: "install-time feature",
+			spec: "camera:f.xml,feature",
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" xmlns:dist="http://schemas.android.com/apk/distribution" package="com.example" android:versionCode="42" android:versionName="4.2" split="camera" android:isFeatureSplit="true">
+  <dist:module>
+    <dist:delivery>
+      <dist:install-time/>
+    </dist:delivery>
+    <dist:fusing dist:include="true"/>
+  </dist:module>
+  <application android:hasCode="true"/>
+</manifest>
+`,
+		},
+		{
+			name: "on-demand feature without code",
+			spec: "camera:f.xml,feature,title=@string/camera,delivery=on-demand,fusing=false,has_code=false",
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" xmlns:dist="http://schemas.android.com/apk/distribution" package="com.example" android:versionCode="42" android:versionName="4.2" split="camera" android:isFeatureSplit="true">
+  <dist:module dist:title="@string/camera">
+    <dist:delivery>
+      <dist:on-demand/>
+    </dist:delivery>
+    <dist:fusing dist:include="false"/>
+  </dist:module>
+  <application android:hasCode="false"/>
+</manifest>
+`,
+		},
+		{
+			name: "conditional feature",
+			spec: "ar:f.xml,feature,title=@string/ar,min_sdk=24,device_feature=android.hardware.camera.ar,countries=US+CA",
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android" xmlns:dist="http://schemas.android.com/apk/distribution" package="com.example" android:versionCode="42" android:versionName="4.2" split="ar" android:isFeatureSplit="true">
+  <dist:module dist:title="@string/ar">
+    <dist:delivery>
+      <dist:install-time>
+        <dist:conditions>
+          <dist:min-sdk dist:value="24"/>
+          <dist:device-feature dist:name="android.hardware.camera.ar"/>
+          <dist:user-countries dist:exclude="false">
+            <dist:country dist:code="US"/>
+            <dist:country dist:code="CA"/>
+          </dist:user-countries>
+        </dist:conditions>
+      </dist:install-time>
+    </dist:delivery>
+    <dist:fusing dist:include="true"/>
+  </dist:module>
+  <application android:hasCode="true"/>
+</manifest>
+`,
+		},
+	}
+	base, err := manifestutils.ReadManifest(strings.NewReader(baseManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, err := ParseSplitSpec(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := WriteSplitManifest(&b, base, spec); err != nil {
+				t.Fatalf("WriteSplitManifest failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+				t.Errorf("WriteSplitManifest returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestWriteSplitManifestNoPackage(t *testing.T) {
+	base, err := manifestutils.ReadManifest(strings.NewReader(`<manifest/>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := WriteSplitManifest(&b, base, SplitSpec{Name: "config.xxhdpi"}); err == nil || !strings.Contains(err.Error(), "base manifest has no package") {
+		t.Errorf("WriteSplitManifest returned error %v, want missing package error", err)
+	}
+}
+
+func TestValidateSplitManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		split   string
+		wantErr string
+	}{
+		{
+			name:  "config split",
+			split: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" android:versionCode="42" split="config.en"><application android:hasCode="false"/></manifest>`,
+		},
+		{
+			name:    "other package",
+			split:   `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.other" android:versionCode="42" split="config.en"><application android:hasCode="false"/></manifest>`,
+			wantErr: `package "com.other" differs from the package of the base manifest ("com.example")`,
+		},
+		{
+			name:    "other version code",
+			split:   `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" android:versionCode="41" split="config.en"><application android:hasCode="false"/></manifest>`,
+			wantErr: `versionCode "41" differs from the versionCode of the base manifest ("42")`,
+		},
+		{
+			name:    "no split name",
+			split:   `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" android:versionCode="42"/>`,
+			wantErr: "manifest has no split name",
+		},
+		{
+			name:    "config split with code",
+			split:   `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" android:versionCode="42" split="config.en"><application/></manifest>`,
+			wantErr: "configuration split has code",
+		},
+		{
+			name:    "feature split without module",
+			split:   `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example" android:versionCode="42" split="camera" android:isFeatureSplit="true"/>`,
+			wantErr: "feature split has no dist:module element",
+		},
+		{
+			name: "feature split without fusing",
+			split: `<manifest xmlns:android="http://schemas.android.com/apk/res/android" xmlns:dist="http://schemas.android.com/apk/distribution" package="com.example" android:versionCode="42" split="camera" android:isFeatureSplit="true">
+  <dist:module><dist:delivery><dist:on-demand/></dist:delivery></dist:module>
+</manifest>`,
+			wantErr: "feature split has no dist:fusing element",
+		},
+	}
+	base, err := manifestutils.ReadManifest(strings.NewReader(baseManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			split, err := manifestutils.ReadManifest(strings.NewReader(tc.split))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ValidateSplitManifest(base, split)
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("ValidateSplitManifest returned unexpected error: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("ValidateSplitManifest returned error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestutils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path selects elements of a document with a small XPath-like syntax, e.g.
+// manifest/application/activity[@android:name=.Main]/meta-data.
+//
+// Steps are separated by "/" and name elements, with "*" matching any element. Each step may be
+// followed by predicates: [@attr=value] keeps the elements whose attribute has the value, which
+// may be quoted, [@attr] those having the attribute and [n] the n-th remaining element of each
+// parent, counting from 1. Paths starting with "/" match from the root element, other paths from
+// any element. Prefixes resolve with the namespace declarations of the root element, android, dist
+// and tools resolving to their usual namespaces by default.
+type Path struct {
+	raw      string
+	absolute bool
+	steps    []pathStep
+}
+
+type pathStep struct {
+	// name is the qualified name of the elements, or "*".
+	name  string
+	preds []pathPred
+}
+
+type pathPred struct {
+	// attr is the qualified name of the attribute, or "" for position predicates.
+	attr     string
+	value    string
+	hasValue bool
+	pos      int
+}
+
+// match is an element selected by a path, along with its parent, which is nil for the root.
+type match struct {
+	parent, elem *Element
+}
+
+// ParsePath parses the path s.
+func ParsePath(s string) (*Path, error) {
+	p := &Path{raw: s}
+	rest := s
+	if strings.HasPrefix(rest, "/") {
+		p.absolute = true
+		rest = rest[1:]
+	}
+	parts, err := splitOutside(rest, '/')
+	if err != nil {
+		return nil, fmt.Errorf("path %q: %v", s, err)
+	}
+	for _, part := range parts {
+		st, err := parseStep(part)
+		if err != nil {
+			return nil, fmt.Errorf("path %q: %v", s, err)
+		}
+		p.steps = append(p.steps, st)
+	}
+	return p, nil
+}
+
+// String returns the path as parsed.
+func (p *Path) String() string {
+	return p.raw
+}
+
+func parseStep(s string) (pathStep, error) {
+	i := strings.IndexByte(s, '[')
+	if i < 0 {
+		i = len(s)
+	}
+	st := pathStep{name: s[:i]}
+	if st.name == "" {
+		return st, fmt.Errorf("empty step in %q", s)
+	}
+	rest := s[i:]
+	for rest != "" {
+		end, err := closingBracket(rest)
+		if err != nil {
+			return st, err
+		}
+		pred, err := parsePred(rest[1:end])
+		if err != nil {
+			return st, err
+		}
+		st.preds = append(st.preds, pred)
+		rest = rest[end+1:]
+	}
+	return st, nil
+}
+
+func parsePred(s string) (pathPred, error) {
+	if attr, ok := strings.CutPrefix(s, "@"); ok {
+		name, value, hasValue := strings.Cut(attr, "=")
+		if name == "" {
+			return pathPred{}, fmt.Errorf("empty attribute in [%s]", s)
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		return pathPred{attr: name, value: value, hasValue: hasValue}, nil
+	}
+	pos, err := strconv.Atoi(s)
+	if err != nil || pos < 1 {
+		return pathPred{}, fmt.Errorf("predicate [%s] is neither [@attr=value], [@attr] nor a position", s)
+	}
+	return pathPred{pos: pos}, nil
+}
+
+// closingBracket returns the index of the bracket closing the one s starts with.
+func closingBracket(s string) (int, error) {
+	if s[0] != '[' {
+		return 0, fmt.Errorf("unexpected %q", s)
+	}
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ']':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unclosed predicate in %q", s)
+}
+
+// splitOutside splits s around the separators which are not within predicates.
+func splitOutside(s string, sep byte) ([]string, error) {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			end, err := closingBracket(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			i += end
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:]), nil
+}
+
+// indexOutside returns the index of the first c of s which is not within predicates, or -1.
+func indexOutside(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			end, err := closingBracket(s[i:])
+			if err != nil {
+				return -1
+			}
+			i += end
+		case c:
+			return i
+		}
+	}
+	return -1
+}
+
+// Select returns the elements of the document selected by the path, in document order.
+func (p *Path) Select(doc *Document) ([]*Element, error) {
+	matches, err := p.selectMatches(doc)
+	if err != nil {
+		return nil, err
+	}
+	var r []*Element
+	for _, m := range matches {
+		r = append(r, m.elem)
+	}
+	return r, nil
+}
+
+func (p *Path) selectMatches(doc *Document) ([]match, error) {
+	ns := namespaces(doc)
+	root := doc.Root()
+	// Each group holds siblings, to which position predicates apply.
+	type group struct {
+		parent *Element
+		elems  []*Element
+	}
+	groups := []group{{nil, []*Element{root}}}
+	if !p.absolute {
+		root.Walk(func(e *Element) {
+			groups = append(groups, group{e, e.Elements("")})
+		})
+	}
+	var cur []match
+	for i, st := range p.steps {
+		if i > 0 {
+			groups = nil
+			for _, m := range cur {
+				groups = append(groups, group{m.elem, m.elem.Elements("")})
+			}
+		}
+		cur = nil
+		for _, g := range groups {
+			elems, err := st.filter(g.elems, ns)
+			if err != nil {
+				return nil, fmt.Errorf("path %q: %v", p.raw, err)
+			}
+			for _, e := range elems {
+				cur = append(cur, match{g.parent, e})
+			}
+		}
+	}
+	return cur, nil
+}
+
+func (st pathStep) filter(elems []*Element, ns map[string]string) ([]*Element, error) {
+	var name xml.Name
+	if st.name != "*" {
+		var err error
+		if name, err = resolveName(st.name, ns); err != nil {
+			return nil, err
+		}
+	}
+	var r []*Element
+	for _, e := range elems {
+		if st.name == "*" || e.Name == name {
+			r = append(r, e)
+		}
+	}
+	for _, pred := range st.preds {
+		if pred.attr == "" {
+			if pred.pos > len(r) {
+				return nil, nil
+			}
+			r = r[pred.pos-1 : pred.pos]
+			continue
+		}
+		attr, err := resolveName(pred.attr, ns)
+		if err != nil {
+			return nil, err
+		}
+		var kept []*Element
+		for _, e := range r {
+			if v, ok := e.AttrValue(attr); ok && (!pred.hasValue || v == pred.value) {
+				kept = append(kept, e)
+			}
+		}
+		r = kept
+	}
+	return r, nil
+}
+
+// resolveName turns a qualified name, e.g. android:name, into a name with a namespace URI.
+func resolveName(qname string, ns map[string]string) (xml.Name, error) {
+	prefix, local, ok := strings.Cut(qname, ":")
+	if !ok {
+		return xml.Name{Local: qname}, nil
+	}
+	uri, ok := ns[prefix]
+	if !ok {
+		return xml.Name{}, fmt.Errorf("undeclared namespace prefix %q in %q", prefix, qname)
+	}
+	return xml.Name{Space: uri, Local: local}, nil
+}
+
+// namespaces maps the prefixes usable in paths to their namespace URIs.
+func namespaces(doc *Document) map[string]string {
+	ns := make(map[string]string)
+	for p, uri := range DefaultNamespaces {
+		ns[p] = uri
+	}
+	for _, a := range doc.Root().Attr {
+		if a.Name.Space == "xmlns" {
+			ns[a.Name.Local] = a.Value
+		}
+	}
+	return ns
+}
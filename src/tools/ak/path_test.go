@@ -0,0 +1,103 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestutils
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const pathManifest = `<manifest xmlns:android="http://schemas.android.com/apk/res/android" xmlns:dist="http://schemas.android.com/apk/distribution" package="com.example">
+  <dist:module dist:instant="false"/>
+  <application android:name=".App">
+    <activity android:name=".Main">
+      <meta-data android:name="a" android:value="1"/>
+      <meta-data android:name="b"/>
+    </activity>
+    <activity android:name=".Settings" android:exported="false">
+      <meta-data android:name="a" android:value="2"/>
+    </activity>
+  </application>
+</manifest>`
+
+func TestPathSelect(t *testing.T) {
+	doc, err := ReadDocument(xml.NewDecoder(strings.NewReader(pathManifest)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/manifest/application/activity", []string{"activity:.Main", "activity:.Settings"}},
+		{"activity[@android:name=.Main]", []string{"activity:.Main"}},
+		{`activity[@android:name=".Settings"]/meta-data`, []string{"meta-data:a"}},
+		{"activity/meta-data[@android:name='a']", []string{"meta-data:a", "meta-data:a"}},
+		{"meta-data[@android:value]", []string{"meta-data:a", "meta-data:a"}},
+		{"activity[@android:exported=false]", []string{"activity:.Settings"}},
+		{"activity[2]", []string{"activity:.Settings"}},
+		{"meta-data[1]", []string{"meta-data:a", "meta-data:a"}},
+		{"activity[1]/meta-data[2]", []string{"meta-data:b"}},
+		{"application/*", []string{"activity:.Main", "activity:.Settings"}},
+		{"dist:module", []string{"module:"}},
+		{"module", nil},
+		{"/application", nil},
+		{"/manifest", []string{"manifest:"}},
+		{"activity[3]", nil},
+	}
+	for _, tc := range tests {
+		p, err := ParsePath(tc.path)
+		if err != nil {
+			t.Fatalf("ParsePath(%q) failed: %v", tc.path, err)
+		}
+		elems, err := p.Select(doc)
+		if err != nil {
+			t.Fatalf("Select(%q) failed: %v", tc.path, err)
+		}
+		var got []string
+		for _, e := range elems {
+			name, _ := e.AttrValue(xml.Name{Space: NameSpace, Local: "name"})
+			got = append(got, e.Name.Local+":"+name)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("Select(%q) returned diff (-want, +got):\n%v", tc.path, diff)
+		}
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	for _, path := range []string{"", "a//b", "a[", "a[@]", "a[0]", "a[x]", "a]b[1"} {
+		if _, err := ParsePath(path); err == nil {
+			t.Errorf("ParsePath(%q) succeeded, want error", path)
+		}
+	}
+}
+
+func TestSelectUndeclaredPrefix(t *testing.T) {
+	doc, err := ReadDocument(xml.NewDecoder(strings.NewReader(pathManifest)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePath("activity[@foo:name=x]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Select(doc); err == nil || !strings.Contains(err.Error(), `undeclared namespace prefix "foo"`) {
+		t.Errorf("Select returned error %v, want undeclared prefix error", err)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prefab
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// packageJSON is prefab/prefab.json.
+type packageJSON struct {
+	SchemaVersion int      `json:"schema_version"`
+	Name          string   `json:"name"`
+	Version       string   `json:"version"`
+	Dependencies  []string `json:"dependencies"`
+}
+
+// moduleJSON is prefab/modules/<module>/module.json.
+type moduleJSON struct {
+	ExportLibraries []string `json:"export_libraries"`
+	LibraryName     *string  `json:"library_name"`
+	// Android overrides the fields above for Android when set.
+	Android struct {
+		ExportLibraries []string `json:"export_libraries"`
+		LibraryName     *string  `json:"library_name"`
+	} `json:"android"`
+}
+
+// abiJSON is prefab/modules/<module>/libs/android.<identifier>/abi.json. The identifier is
+// arbitrary, it usually is the ABI but lets a module have variants of a library for an ABI, e.g.
+// for several API levels.
+type abiJSON struct {
+	ABI    string `json:"abi"`
+	API    int    `json:"api"`
+	NDK    int    `json:"ndk"`
+	STL    string `json:"stl"`
+	Static bool   `json:"static"`
+}
+
+// Package is a prefab package, the native libraries of an aar.
+type Package struct {
+	Name         string
+	Version      string
+	Dependencies []string
+	// Modules are sorted by name.
+	Modules []*Module
+}
+
+// Module is a library of a prefab package, and its headers.
+type Module struct {
+	Name string
+	// LibraryName is the file name of the library, without extension, e.g. libcurl.
+	LibraryName string
+	// ExportLibraries are the libraries consumers link against: -l flags, modules of the same
+	// package as :module, or of other packages as //package/module.
+	ExportLibraries []string
+	// Include is the directory of the headers, empty if none.
+	Include string
+	// HeaderOnly modules have no libs directory.
+	HeaderOnly bool
+	// Libraries are the variants of the library of the module by ABI, sorted by API level.
+	Libraries map[string][]*Library
+}
+
+// Library returns the variant of the library of the module for abi with the highest API level
+// not above minSdk, or the lowest if there is none or minSdk is 0. It returns nil if the module
+// has no library for abi.
+func (m *Module) Library(abi string, minSdk int) *Library {
+	libs := m.Libraries[abi]
+	if len(libs) == 0 {
+		return nil
+	}
+	lib := libs[0]
+	for _, l := range libs[1:] {
+		if minSdk != 0 && l.API <= minSdk {
+			lib = l
+		}
+	}
+	return lib
+}
+
+// Library is the library of a module for an ABI.
+type Library struct {
+	ABI string
+	// API is the lowest API level the library runs on.
+	API int
+	NDK int
+	STL string
+	// Path is the .so, or .a for static libraries.
+	Path   string
+	Static bool
+	// Include overrides the headers of the module for the ABI, empty if none.
+	Include string
+}
+
+// ReadPackage reads the prefab package in dir, the prefab directory of an aar.
+func ReadPackage(dir string) (*Package, error) {
+	var pj packageJSON
+	if err := readJSON(filepath.Join(dir, "prefab.json"), &pj); err != nil {
+		return nil, err
+	}
+	if pj.SchemaVersion != 1 && pj.SchemaVersion != 2 {
+		return nil, fmt.Errorf("prefab.json: unsupported schema_version %d", pj.SchemaVersion)
+	}
+	if pj.Name == "" {
+		return nil, fmt.Errorf("prefab.json: missing name")
+	}
+	p := &Package{Name: pj.Name, Version: pj.Version, Dependencies: pj.Dependencies}
+
+	modulesDir := filepath.Join(dir, "modules")
+	entries, err := os.ReadDir(modulesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		m, err := readModule(filepath.Join(modulesDir, e.Name()), e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %v", e.Name(), err)
+		}
+		p.Modules = append(p.Modules, m)
+	}
+	sort.Slice(p.Modules, func(i, j int) bool { return p.Modules[i].Name < p.Modules[j].Name })
+	return p, nil
+}
+
+func readModule(dir, name string) (*Module, error) {
+	var mj moduleJSON
+	if err := readJSON(filepath.Join(dir, "module.json"), &mj); err != nil {
+		return nil, err
+	}
+	m := &Module{
+		Name:            name,
+		LibraryName:     "lib" + name,
+		ExportLibraries: mj.ExportLibraries,
+		Libraries:       make(map[string][]*Library),
+	}
+	if mj.LibraryName != nil {
+		m.LibraryName = *mj.LibraryName
+	}
+	if mj.Android.LibraryName != nil {
+		m.LibraryName = *mj.Android.LibraryName
+	}
+	if mj.Android.ExportLibraries != nil {
+		m.ExportLibraries = mj.Android.ExportLibraries
+	}
+	if isDir(filepath.Join(dir, "include")) {
+		m.Include = filepath.Join(dir, "include")
+	}
+
+	libsDir := filepath.Join(dir, "libs")
+	entries, err := os.ReadDir(libsDir)
+	if os.IsNotExist(err) {
+		m.HeaderOnly = true
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), "android.") {
+			continue
+		}
+		lib, err := readLibrary(filepath.Join(libsDir, e.Name()), m.LibraryName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.Name(), err)
+		}
+		if lib.ABI == "" {
+			return nil, fmt.Errorf("%s: abi.json declares no ABI", e.Name())
+		}
+		m.Libraries[lib.ABI] = append(m.Libraries[lib.ABI], lib)
+	}
+	for _, libs := range m.Libraries {
+		sort.Slice(libs, func(i, j int) bool { return libs[i].API < libs[j].API })
+	}
+	return m, nil
+}
+
+func readLibrary(dir, libraryName string) (*Library, error) {
+	var aj abiJSON
+	if err := readJSON(filepath.Join(dir, "abi.json"), &aj); err != nil {
+		return nil, err
+	}
+	lib := &Library{ABI: aj.ABI, API: aj.API, NDK: aj.NDK, STL: aj.STL, Static: aj.Static}
+	// Libraries of schema version 1 do not declare whether they are static.
+	shared, static := filepath.Join(dir, libraryName+".so"), filepath.Join(dir, libraryName+".a")
+	switch {
+	case !aj.Static && isFile(shared):
+		lib.Path = shared
+	case isFile(static):
+		lib.Path, lib.Static = static, true
+	default:
+		return nil, fmt.Errorf("no %s library found", libraryName)
+	}
+	if isDir(filepath.Join(dir, "include")) {
+		lib.Include = filepath.Join(dir, "include")
+	}
+	return lib, nil
+}
+
+func readJSON(name string, v any) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%s: %v", filepath.Base(name), err)
+	}
+	return nil
+}
+
+func isDir(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.IsDir()
+}
+
+func isFile(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// Requirements are what a consumer requires of the libraries of a package.
+type Requirements struct {
+	// ABIs are the ABIs the consumer is built for.
+	ABIs []string
+	// MinSdk is the minSdkVersion of the consumer, libraries must not require a higher API level.
+	// Unchecked if 0.
+	MinSdk int
+	// STL is the C++ runtime of the consumer, e.g. c++_shared. Unchecked if empty.
+	STL string
+}
+
+// Check returns an error listing the incompatibilities of the libraries of p with r.
+func (p *Package) Check(r Requirements) error {
+	var errs []string
+	for _, m := range p.Modules {
+		if m.HeaderOnly {
+			continue
+		}
+		for _, abi := range r.ABIs {
+			lib := m.Library(abi, r.MinSdk)
+			if lib == nil {
+				errs = append(errs, fmt.Sprintf("module %s has no library for ABI %s", m.Name, abi))
+				continue
+			}
+			if r.MinSdk != 0 && lib.API > r.MinSdk {
+				errs = append(errs, fmt.Sprintf("module %s for ABI %s requires API %d, higher than the min SDK %d", m.Name, abi, lib.API, r.MinSdk))
+			}
+			if !stlCompatible(lib.STL, r.STL) {
+				errs = append(errs, fmt.Sprintf("module %s for ABI %s uses STL %s, incompatible with %s", m.Name, abi, lib.STL, r.STL))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("prefab package %s is incompatible:\n\t- %s", p.Name, strings.Join(errs, "\n\t- "))
+	}
+	return nil
+}
+
+// stlCompatible reports whether a library using the STL lib links with a consumer using want.
+// Libraries without C++ runtime, or using the system one, link with any.
+func stlCompatible(lib, want string) bool {
+	switch lib {
+	case "", "none", "system":
+		return true
+	}
+	return want == "" || lib == want
+}
@@ -0,0 +1,270 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prefab
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// curlPackage is a prefab package of a shared library depending on a static one, and a header
+// only module.
+var curlPackage = map[string]string{
+	"prefab/prefab.json":                                         `{"schema_version": 2, "name": "curl", "version": "7.79.1", "dependencies": ["openssl"]}`,
+	"prefab/modules/curl/module.json":                            `{"export_libraries": ["//openssl/ssl", ":urlapi", "-lz"], "android": {}}`,
+	"prefab/modules/curl/include/curl/curl.h":                    "// curl",
+	"prefab/modules/curl/libs/android.arm64-v8a/abi.json":        `{"abi": "arm64-v8a", "api": 21, "ndk": 25, "stl": "c++_shared", "static": false}`,
+	"prefab/modules/curl/libs/android.arm64-v8a/libcurl.so":      "arm64 curl",
+	"prefab/modules/curl/libs/android.x86_64/abi.json":           `{"abi": "x86_64", "api": 24, "ndk": 25, "stl": "c++_shared", "static": false}`,
+	"prefab/modules/curl/libs/android.x86_64/libcurl.so":         "x86_64 curl",
+	"prefab/modules/urlapi/module.json":                          `{"library_name": "liburl", "export_libraries": []}`,
+	"prefab/modules/urlapi/libs/android.arm64-v8a/abi.json":      `{"abi": "arm64-v8a", "api": 21, "ndk": 25, "stl": "none"}`,
+	"prefab/modules/urlapi/libs/android.arm64-v8a/liburl.a":      "arm64 url",
+	"prefab/modules/urlapi/libs/android.arm64-v8a/include/url.h": "// arm64 url",
+	"prefab/modules/urlapi/libs/android.x86_64/abi.json":         `{"abi": "x86_64", "api": 21, "ndk": 25, "stl": "none"}`,
+	"prefab/modules/urlapi/libs/android.x86_64/liburl.a":         "x86_64 url",
+	"prefab/modules/headers/module.json":                         `{"export_libraries": ["-llog"]}`,
+	"prefab/modules/headers/include/config.h":                    "// config",
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadPackage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, curlPackage)
+	root := filepath.Join(dir, "prefab")
+	p, err := ReadPackage(root)
+	if err != nil {
+		t.Fatalf("ReadPackage() unexpected error: %v", err)
+	}
+	modules := filepath.Join(root, "modules")
+	want := &Package{
+		Name:         "curl",
+		Version:      "7.79.1",
+		Dependencies: []string{"openssl"},
+		Modules: []*Module{
+			{
+				Name:            "curl",
+				LibraryName:     "libcurl",
+				ExportLibraries: []string{"//openssl/ssl", ":urlapi", "-lz"},
+				Include:         filepath.Join(modules, "curl/include"),
+				Libraries: map[string][]*Library{
+					"arm64-v8a": {{ABI: "arm64-v8a", API: 21, NDK: 25, STL: "c++_shared", Path: filepath.Join(modules, "curl/libs/android.arm64-v8a/libcurl.so")}},
+					"x86_64":    {{ABI: "x86_64", API: 24, NDK: 25, STL: "c++_shared", Path: filepath.Join(modules, "curl/libs/android.x86_64/libcurl.so")}},
+				},
+			},
+			{
+				Name:            "headers",
+				LibraryName:     "libheaders",
+				ExportLibraries: []string{"-llog"},
+				Include:         filepath.Join(modules, "headers/include"),
+				HeaderOnly:      true,
+				Libraries:       map[string][]*Library{},
+			},
+			{
+				Name:            "urlapi",
+				LibraryName:     "liburl",
+				ExportLibraries: []string{},
+				Libraries: map[string][]*Library{
+					"arm64-v8a": {{ABI: "arm64-v8a", API: 21, NDK: 25, STL: "none", Static: true, Path: filepath.Join(modules, "urlapi/libs/android.arm64-v8a/liburl.a"), Include: filepath.Join(modules, "urlapi/libs/android.arm64-v8a/include")}},
+					"x86_64":    {{ABI: "x86_64", API: 21, NDK: 25, STL: "none", Static: true, Path: filepath.Join(modules, "urlapi/libs/android.x86_64/liburl.a")}},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, p); diff != "" {
+		t.Errorf("ReadPackage() returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestReadPackageError(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing prefab.json",
+			files:   map[string]string{"prefab/modules/foo/module.json": `{}`},
+			wantErr: "prefab.json",
+		},
+		{
+			name:    "unsupported schema",
+			files:   map[string]string{"prefab/prefab.json": `{"schema_version": 3, "name": "foo"}`},
+			wantErr: "unsupported schema_version 3",
+		},
+		{
+			name: "missing ABI",
+			files: map[string]string{
+				"prefab/prefab.json":                            `{"schema_version": 2, "name": "foo"}`,
+				"prefab/modules/foo/module.json":                `{}`,
+				"prefab/modules/foo/libs/android.x86/abi.json":  `{"api": 21}`,
+				"prefab/modules/foo/libs/android.x86/libfoo.so": "",
+			},
+			wantErr: "abi.json declares no ABI",
+		},
+		{
+			name: "missing library",
+			files: map[string]string{
+				"prefab/prefab.json":                           `{"schema_version": 2, "name": "foo"}`,
+				"prefab/modules/foo/module.json":               `{}`,
+				"prefab/modules/foo/libs/android.x86/abi.json": `{"abi": "x86", "api": 21}`,
+			},
+			wantErr: "no libfoo library found",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			_, err := ReadPackage(filepath.Join(dir, "prefab"))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("ReadPackage() returned error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, curlPackage)
+	p, err := ReadPackage(filepath.Join(dir, "prefab"))
+	if err != nil {
+		t.Fatalf("ReadPackage() unexpected error: %v", err)
+	}
+	tests := []struct {
+		name     string
+		r        Requirements
+		wantErrs []string
+	}{
+		{
+			name: "compatible",
+			r:    Requirements{ABIs: []string{"arm64-v8a", "x86_64"}, MinSdk: 24, STL: "c++_shared"},
+		},
+		{
+			name: "unchecked STL",
+			r:    Requirements{ABIs: []string{"arm64-v8a"}, MinSdk: 21},
+		},
+		{
+			name: "unchecked min SDK",
+			r:    Requirements{ABIs: []string{"x86_64"}, STL: "c++_shared"},
+		},
+		{
+			name: "missing ABI",
+			r:    Requirements{ABIs: []string{"armeabi-v7a"}, MinSdk: 21},
+			wantErrs: []string{
+				"module curl has no library for ABI armeabi-v7a",
+				"module urlapi has no library for ABI armeabi-v7a",
+			},
+		},
+		{
+			name:     "min SDK too low",
+			r:        Requirements{ABIs: []string{"x86_64"}, MinSdk: 21},
+			wantErrs: []string{"module curl for ABI x86_64 requires API 24, higher than the min SDK 21"},
+		},
+		{
+			name:     "STL mismatch",
+			r:        Requirements{ABIs: []string{"arm64-v8a"}, MinSdk: 21, STL: "c++_static"},
+			wantErrs: []string{"module curl for ABI arm64-v8a uses STL c++_shared, incompatible with c++_static"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.Check(tc.r)
+			if len(tc.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("Check(%+v) unexpected error: %v", tc.r, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Check(%+v) succeeded, want errors %q", tc.r, tc.wantErrs)
+			}
+			for _, want := range tc.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Check(%+v) returned error %v, want %q", tc.r, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLibraryVariants(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"prefab/prefab.json":                                     `{"schema_version": 2, "name": "foo"}`,
+		"prefab/modules/foo/module.json":                         `{}`,
+		"prefab/modules/foo/libs/android.arm64-v8a.29/abi.json":  `{"abi": "arm64-v8a", "api": 29, "ndk": 25, "stl": "none"}`,
+		"prefab/modules/foo/libs/android.arm64-v8a.29/libfoo.so": "api 29",
+		"prefab/modules/foo/libs/android.arm64-v8a.21/abi.json":  `{"abi": "arm64-v8a", "api": 21, "ndk": 25, "stl": "none"}`,
+		"prefab/modules/foo/libs/android.arm64-v8a.21/libfoo.so": "api 21",
+	})
+	p, err := ReadPackage(filepath.Join(dir, "prefab"))
+	if err != nil {
+		t.Fatalf("ReadPackage() unexpected error: %v", err)
+	}
+	m := p.Modules[0]
+	for _, tc := range []struct {
+		minSdk  int
+		wantAPI int
+	}{
+		{minSdk: 0, wantAPI: 21},
+		{minSdk: 19, wantAPI: 21},
+		{minSdk: 21, wantAPI: 21},
+		{minSdk: 28, wantAPI: 21},
+		{minSdk: 29, wantAPI: 29},
+		{minSdk: 34, wantAPI: 29},
+	} {
+		if got := m.Library("arm64-v8a", tc.minSdk); got.API != tc.wantAPI {
+			t.Errorf("Library(arm64-v8a, %d) returned the API %d variant, want API %d", tc.minSdk, got.API, tc.wantAPI)
+		}
+	}
+	if got := m.Library("x86_64", 29); got != nil {
+		t.Errorf("Library(x86_64, 29) = %+v, want nil", got)
+	}
+	if err := p.Check(Requirements{ABIs: []string{"arm64-v8a"}, MinSdk: 24}); err != nil {
+		t.Errorf("Check() unexpected error: %v", err)
+	}
+	if err := p.Check(Requirements{ABIs: []string{"arm64-v8a"}, MinSdk: 19}); err == nil || !strings.Contains(err.Error(), "requires API 21") {
+		t.Errorf("Check() returned error %v, want the API 21 variant to be too new", err)
+	}
+
+	outDir := t.TempDir()
+	if _, err := Extract(p, Requirements{ABIs: []string{"arm64-v8a"}, MinSdk: 30}, outDir); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(outDir, "arm64-v8a/foo/lib/libfoo.so"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "api 29" {
+		t.Errorf("Extract() extracted %q, want the api 29 variant", got)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prefab extracts the prefab packages of aars, the native libraries and headers under
+// prefab/, and describes them as cc_library equivalents for Starlark to turn into targets.
+package prefab
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"src/common/golang/flags"
+	"src/tools/ak/extractaar/extractaar"
+	"src/tools/ak/types"
+)
+
+var (
+	// Cmd defines the command to run prefab.
+	Cmd = types.Command{
+		Init:  Init,
+		Run:   Run,
+		Desc:  desc,
+		Flags: []string{"aar", "prefab_dir", "abis", "min_sdk", "stl", "out_dir", "out_manifest"},
+	}
+
+	// Variables to hold flag values.
+	aar         string
+	prefabDir   string
+	abis        flags.StringList
+	minSdk      int
+	stl         string
+	outDir      string
+	outManifest string
+
+	initOnce sync.Once
+)
+
+// Init initializes prefab.
+func Init() {
+	initOnce.Do(func() {
+		flag.StringVar(&aar, "aar", "", "Path to the aar, exclusive with -prefab_dir.")
+		flag.StringVar(&prefabDir, "prefab_dir", "", "Directory holding the prefab directory of the aar, e.g. the -out_prefab_dir of extractaar.")
+		flag.Var(&abis, "abis", "ABIs to extract the libraries of, e.g. arm64-v8a,x86_64.")
+		flag.IntVar(&minSdk, "min_sdk", 0, "(optional) minSdkVersion of the consumer. Selects the variant of each library with the highest API level not above it, which must exist.")
+		flag.StringVar(&stl, "stl", "", "(optional) C++ runtime of the consumer, e.g. c++_shared.")
+		flag.StringVar(&outDir, "out_dir", "", "Directory to extract the headers and libraries to, as <abi>/<module>/include and <abi>/<module>/lib.")
+		flag.StringVar(&outManifest, "out_manifest", "", "Path to write the JSON manifest of the libraries to.")
+	})
+}
+
+func desc() string {
+	return "prefab extracts and validates the native libraries of the prefab package of an aar"
+}
+
+// Run is the entry point for prefab. Will exit on error.
+func Run() {
+	if (aar == "") == (prefabDir == "") {
+		log.Fatal("Exactly one of -aar and -prefab_dir must be specified.")
+	}
+	if len(abis) == 0 {
+		log.Fatal("Flag -abis must be specified.")
+	}
+	if outDir == "" || outManifest == "" {
+		log.Fatal("Flags -out_dir and -out_manifest must be specified.")
+	}
+	r := Requirements{ABIs: abis, MinSdk: minSdk, STL: stl}
+	if err := doWork(aar, prefabDir, r, outDir, outManifest); err != nil {
+		log.Fatalf("error extracting prefab package: %v", err)
+	}
+}
+
+func doWork(aar, prefabDir string, r Requirements, outDir, outManifest string) error {
+	if aar != "" {
+		tmpDir, err := os.MkdirTemp("", "prefab")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+		if _, err := extractaar.Extract(aar, tmpDir); err != nil {
+			return err
+		}
+		prefabDir = tmpDir
+	}
+	root := filepath.Join(prefabDir, "prefab")
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return fmt.Errorf("no prefab package found")
+	}
+	p, err := ReadPackage(root)
+	if err != nil {
+		return err
+	}
+	if err := p.Check(r); err != nil {
+		return err
+	}
+	m, err := Extract(p, r, outDir)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(outManifest)
+	if err != nil {
+		return err
+	}
+	if err := m.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Manifest describes the libraries of a prefab package as cc_library equivalents.
+type Manifest struct {
+	Package      string   `json:"package"`
+	Version      string   `json:"version,omitempty"`
+	Dependencies []string `json:"dependencies,omitempty"`
+	// Libraries are sorted by ABI, then module name.
+	Libraries []CCLibrary `json:"libraries"`
+}
+
+// CCLibrary is the library of a module for an ABI. Paths are relative to the output directory.
+type CCLibrary struct {
+	Name string `json:"name"`
+	ABI  string `json:"abi"`
+	// Hdrs are the headers, under Includes.
+	Hdrs     []string `json:"hdrs,omitempty"`
+	Includes []string `json:"includes,omitempty"`
+	// SharedLibrary or StaticLibrary is the library, neither is set for header only modules.
+	SharedLibrary string `json:"shared_library,omitempty"`
+	StaticLibrary string `json:"static_library,omitempty"`
+	// Deps are the modules the library exports, as //package/module.
+	Deps []string `json:"deps,omitempty"`
+	// Linkopts are the other libraries the library exports, e.g. -llog.
+	Linkopts []string `json:"linkopts,omitempty"`
+}
+
+// Write writes m as JSON to w.
+func (m *Manifest) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// Extract copies the headers and libraries of the modules of p for the ABIs of r under outDir, as
+// <abi>/<module>/include and <abi>/<module>/lib, and returns their manifest. Of the variants of a
+// library, the one selected by Module.Library for r.MinSdk is extracted. p must have been checked
+// against r.
+func Extract(p *Package, r Requirements, outDir string) (*Manifest, error) {
+	m := &Manifest{Package: p.Name, Version: p.Version, Dependencies: p.Dependencies, Libraries: []CCLibrary{}}
+	abis := append([]string(nil), r.ABIs...)
+	sort.Strings(abis)
+	for _, abi := range abis {
+		for _, mod := range p.Modules {
+			lib, err := extractModule(p, mod, abi, r.MinSdk, outDir)
+			if err != nil {
+				return nil, fmt.Errorf("module %s: %v", mod.Name, err)
+			}
+			m.Libraries = append(m.Libraries, lib)
+		}
+	}
+	return m, nil
+}
+
+func extractModule(p *Package, mod *Module, abi string, minSdk int, outDir string) (CCLibrary, error) {
+	dir := filepath.Join(abi, mod.Name)
+	cc := CCLibrary{Name: mod.Name, ABI: abi}
+	include := mod.Include
+	lib := mod.Library(abi, minSdk)
+	if lib != nil && lib.Include != "" {
+		include = lib.Include
+	}
+	if include != "" {
+		incDir := filepath.Join(dir, "include")
+		cc.Includes = []string{incDir}
+		err := filepath.WalkDir(include, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(include, path)
+			if err != nil {
+				return err
+			}
+			hdr := filepath.Join(incDir, rel)
+			cc.Hdrs = append(cc.Hdrs, hdr)
+			return copyFile(path, filepath.Join(outDir, hdr))
+		})
+		if err != nil {
+			return CCLibrary{}, err
+		}
+	}
+	if !mod.HeaderOnly {
+		dest := filepath.Join(dir, "lib", filepath.Base(lib.Path))
+		if err := copyFile(lib.Path, filepath.Join(outDir, dest)); err != nil {
+			return CCLibrary{}, err
+		}
+		if lib.Static {
+			cc.StaticLibrary = dest
+		} else {
+			cc.SharedLibrary = dest
+		}
+	}
+	for _, l := range mod.ExportLibraries {
+		switch {
+		case strings.HasPrefix(l, ":"):
+			cc.Deps = append(cc.Deps, "//"+p.Name+"/"+l[1:])
+		case strings.HasPrefix(l, "//"):
+			cc.Deps = append(cc.Deps, l)
+		default:
+			cc.Linkopts = append(cc.Linkopts, l)
+		}
+	}
+	return cc, nil
+}
+
+func copyFile(name, dest string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,179 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prefab
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"src/common/golang/ziptest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDoWork(t *testing.T) {
+	tmp := t.TempDir()
+	aarPath := filepath.Join(tmp, "curl.aar")
+	files := map[string]string{"AndroidManifest.xml": "<manifest/>"}
+	for name, content := range curlPackage {
+		files[name] = content
+	}
+	ziptest.Write(t, aarPath, files)
+	outDir := filepath.Join(tmp, "out")
+	outManifest := filepath.Join(tmp, "prefab.json")
+
+	r := Requirements{ABIs: []string{"x86_64", "arm64-v8a"}, MinSdk: 24, STL: "c++_shared"}
+	if err := doWork(aarPath, "", r, outDir, outManifest); err != nil {
+		t.Fatalf("doWork() unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(outManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "package": "curl",
+  "version": "7.79.1",
+  "dependencies": [
+    "openssl"
+  ],
+  "libraries": [
+    {
+      "name": "curl",
+      "abi": "arm64-v8a",
+      "hdrs": [
+        "arm64-v8a/curl/include/curl/curl.h"
+      ],
+      "includes": [
+        "arm64-v8a/curl/include"
+      ],
+      "shared_library": "arm64-v8a/curl/lib/libcurl.so",
+      "deps": [
+        "//openssl/ssl",
+        "//curl/urlapi"
+      ],
+      "linkopts": [
+        "-lz"
+      ]
+    },
+    {
+      "name": "headers",
+      "abi": "arm64-v8a",
+      "hdrs": [
+        "arm64-v8a/headers/include/config.h"
+      ],
+      "includes": [
+        "arm64-v8a/headers/include"
+      ],
+      "linkopts": [
+        "-llog"
+      ]
+    },
+    {
+      "name": "urlapi",
+      "abi": "arm64-v8a",
+      "hdrs": [
+        "arm64-v8a/urlapi/include/url.h"
+      ],
+      "includes": [
+        "arm64-v8a/urlapi/include"
+      ],
+      "static_library": "arm64-v8a/urlapi/lib/liburl.a"
+    },
+    {
+      "name": "curl",
+      "abi": "x86_64",
+      "hdrs": [
+        "x86_64/curl/include/curl/curl.h"
+      ],
+      "includes": [
+        "x86_64/curl/include"
+      ],
+      "shared_library": "x86_64/curl/lib/libcurl.so",
+      "deps": [
+        "//openssl/ssl",
+        "//curl/urlapi"
+      ],
+      "linkopts": [
+        "-lz"
+      ]
+    },
+    {
+      "name": "headers",
+      "abi": "x86_64",
+      "hdrs": [
+        "x86_64/headers/include/config.h"
+      ],
+      "includes": [
+        "x86_64/headers/include"
+      ],
+      "linkopts": [
+        "-llog"
+      ]
+    },
+    {
+      "name": "urlapi",
+      "abi": "x86_64",
+      "static_library": "x86_64/urlapi/lib/liburl.a"
+    }
+  ]
+}
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("doWork() wrote manifest diff (-want, +got):\n%v", diff)
+	}
+
+	for name, want := range map[string]string{
+		"arm64-v8a/curl/include/curl/curl.h": "// curl",
+		"arm64-v8a/curl/lib/libcurl.so":      "arm64 curl",
+		"arm64-v8a/urlapi/include/url.h":     "// arm64 url",
+		"x86_64/curl/lib/libcurl.so":         "x86_64 curl",
+		"x86_64/urlapi/lib/liburl.a":         "x86_64 url",
+	} {
+		got, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Errorf("os.ReadFile(%s) unexpected error: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDoWorkPrefabDir(t *testing.T) {
+	tmp := t.TempDir()
+	prefabDir := filepath.Join(tmp, "prefab_dir")
+	writeFiles(t, prefabDir, curlPackage)
+
+	r := Requirements{ABIs: []string{"arm64-v8a"}, MinSdk: 21}
+	if err := doWork("", prefabDir, r, filepath.Join(tmp, "out"), filepath.Join(tmp, "prefab.json")); err != nil {
+		t.Fatalf("doWork() unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "out/arm64-v8a/curl/lib/libcurl.so")); err != nil {
+		t.Errorf("doWork() did not extract libcurl.so: %v", err)
+	}
+}
+
+func TestDoWorkError(t *testing.T) {
+	tmp := t.TempDir()
+	aarPath := filepath.Join(tmp, "lib.aar")
+	ziptest.Write(t, aarPath, map[string]string{"AndroidManifest.xml": "<manifest/>"})
+	r := Requirements{ABIs: []string{"arm64-v8a"}, MinSdk: 21}
+	err := doWork(aarPath, "", r, filepath.Join(tmp, "out"), filepath.Join(tmp, "prefab.json"))
+	if err == nil || !strings.Contains(err.Error(), "no prefab package found") {
+		t.Errorf("doWork() returned error %v, want no prefab package error", err)
+	}
+}
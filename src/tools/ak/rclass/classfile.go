@@ -0,0 +1,302 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+// Class file constants, from the Java Virtual Machine Specification, Chapter 4.
+const (
+	classMagic = 0xCAFEBABE
+	// R classes only use straight-line code, so targeting Java 8 avoids the need for
+	// StackMapTable frames while remaining loadable by every supported toolchain.
+	classMajorVersion = 52
+	classMinorVersion = 0
+
+	accPublic = 0x0001
+	accStatic = 0x0008
+	accFinal  = 0x0010
+	accSuper  = 0x0020
+
+	constUtf8        = 1
+	constInteger     = 3
+	constClass       = 7
+	constFieldref    = 9
+	constMethodref   = 10
+	constNameAndType = 12
+
+	opIconstM1      = 0x02
+	opBipush        = 0x10
+	opSipush        = 0x11
+	opLdc           = 0x12
+	opLdcW          = 0x13
+	opAload0        = 0x2a
+	opIastore       = 0x4f
+	opDup           = 0x59
+	opReturn        = 0xb1
+	opGetstatic     = 0xb2
+	opPutstatic     = 0xb3
+	opInvokespecial = 0xb7
+	opNewarray      = 0xbc
+
+	arrayTypeInt = 10
+
+	maxCodeLength = 65535
+	maxPoolSize   = 65535
+)
+
+// constPool accumulates the constant pool of a class file, deduplicating entries.
+type constPool struct {
+	buf   bytes.Buffer
+	index map[string]uint16
+	count uint16
+}
+
+func newConstPool() *constPool {
+	// Index 0 is reserved by the class file format.
+	return &constPool{index: make(map[string]uint16), count: 1}
+}
+
+func (p *constPool) add(entry []byte) (uint16, error) {
+	if i, ok := p.index[string(entry)]; ok {
+		return i, nil
+	}
+	if p.count >= maxPoolSize {
+		return 0, fmt.Errorf("constant pool exceeds %d entries", maxPoolSize)
+	}
+	i := p.count
+	p.count++
+	p.index[string(entry)] = i
+	p.buf.Write(entry)
+	return i, nil
+}
+
+func (p *constPool) utf8(s string) (uint16, error) {
+	enc := modifiedUTF8(s)
+	if len(enc) > math.MaxUint16 {
+		return 0, fmt.Errorf("string constant %q is too long", s)
+	}
+	entry := make([]byte, 0, len(enc)+3)
+	entry = append(entry, constUtf8)
+	entry = binary.BigEndian.AppendUint16(entry, uint16(len(enc)))
+	entry = append(entry, enc...)
+	return p.add(entry)
+}
+
+func (p *constPool) ref(tag byte, a, b uint16) (uint16, error) {
+	entry := []byte{tag}
+	entry = binary.BigEndian.AppendUint16(entry, a)
+	if tag != constClass {
+		entry = binary.BigEndian.AppendUint16(entry, b)
+	}
+	return p.add(entry)
+}
+
+func (p *constPool) class(name string) (uint16, error) {
+	n, err := p.utf8(name)
+	if err != nil {
+		return 0, err
+	}
+	return p.ref(constClass, n, 0)
+}
+
+func (p *constPool) integer(v int32) (uint16, error) {
+	entry := []byte{constInteger}
+	entry = binary.BigEndian.AppendUint32(entry, uint32(v))
+	return p.add(entry)
+}
+
+func (p *constPool) nameAndType(name, desc string) (uint16, error) {
+	n, err := p.utf8(name)
+	if err != nil {
+		return 0, err
+	}
+	d, err := p.utf8(desc)
+	if err != nil {
+		return 0, err
+	}
+	return p.ref(constNameAndType, n, d)
+}
+
+func (p *constPool) member(tag byte, class, name, desc string) (uint16, error) {
+	c, err := p.class(class)
+	if err != nil {
+		return 0, err
+	}
+	nt, err := p.nameAndType(name, desc)
+	if err != nil {
+		return 0, err
+	}
+	return p.ref(tag, c, nt)
+}
+
+// modifiedUTF8 encodes s in the modified UTF-8 format used by class files.
+func modifiedUTF8(s string) []byte {
+	var b []byte
+	for _, r := range s {
+		switch {
+		case r != 0 && r < 0x80:
+			b = append(b, byte(r))
+		case r < 0x800:
+			b = append(b, byte(0xc0|r>>6), byte(0x80|r&0x3f))
+		case r < 0x10000:
+			b = append(b, byte(0xe0|r>>12), byte(0x80|(r>>6)&0x3f), byte(0x80|r&0x3f))
+		default:
+			// Supplementary characters are written as a surrogate pair of 3 byte sequences.
+			hi, lo := utf16.EncodeRune(r)
+			for _, c := range []rune{hi, lo} {
+				b = append(b, byte(0xe0|c>>12), byte(0x80|(c>>6)&0x3f), byte(0x80|c&0x3f))
+			}
+		}
+	}
+	return b
+}
+
+// code assembles the bytecode of a single method.
+type code struct {
+	pool     *constPool
+	buf      bytes.Buffer
+	stack    int
+	maxStack int
+}
+
+func (c *code) op(opcode byte, stackDelta int) {
+	c.buf.WriteByte(opcode)
+	c.stack += stackDelta
+	if c.stack > c.maxStack {
+		c.maxStack = c.stack
+	}
+}
+
+func (c *code) u1(v byte) {
+	c.buf.WriteByte(v)
+}
+
+func (c *code) u2(v uint16) {
+	binary.Write(&c.buf, binary.BigEndian, v)
+}
+
+// pushInt pushes v onto the operand stack using the shortest available instruction.
+func (c *code) pushInt(v int32) error {
+	switch {
+	case v >= -1 && v <= 5:
+		c.op(byte(opIconstM1+v+1), 1)
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		c.op(opBipush, 1)
+		c.u1(byte(int8(v)))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		c.op(opSipush, 1)
+		c.u2(uint16(int16(v)))
+	default:
+		i, err := c.pool.integer(v)
+		if err != nil {
+			return err
+		}
+		if i <= math.MaxUint8 {
+			c.op(opLdc, 1)
+			c.u1(byte(i))
+		} else {
+			c.op(opLdcW, 1)
+			c.u2(i)
+		}
+	}
+	return nil
+}
+
+// fieldInsn emits getstatic or putstatic for the given field.
+func (c *code) fieldInsn(opcode byte, class, name, desc string) error {
+	i, err := c.pool.member(constFieldref, class, name, desc)
+	if err != nil {
+		return err
+	}
+	delta := 1
+	if opcode == opPutstatic {
+		delta = -1
+	}
+	c.op(opcode, delta)
+	c.u2(i)
+	return nil
+}
+
+// newIntArray emits the instructions to create an int[] holding values.
+func (c *code) newIntArray(values []int32) error {
+	if err := c.pushInt(int32(len(values))); err != nil {
+		return err
+	}
+	c.op(opNewarray, 0)
+	c.u1(arrayTypeInt)
+	for i, v := range values {
+		c.op(opDup, 1)
+		if err := c.pushInt(int32(i)); err != nil {
+			return err
+		}
+		if err := c.pushInt(v); err != nil {
+			return err
+		}
+		c.op(opIastore, -3)
+	}
+	return nil
+}
+
+// attribute encodes a class file attribute_info structure.
+func attribute(pool *constPool, name string, info []byte) ([]byte, error) {
+	n, err := pool.utf8(name)
+	if err != nil {
+		return nil, err
+	}
+	b := binary.BigEndian.AppendUint16(nil, n)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(info)))
+	return append(b, info...), nil
+}
+
+// attribute wraps the assembled bytecode, terminated by a return, into a Code attribute.
+func (c *code) attribute(maxLocals uint16) ([]byte, error) {
+	c.u1(opReturn)
+	if c.buf.Len() > maxCodeLength {
+		return nil, fmt.Errorf("method code is %d bytes long, exceeding the %d byte limit", c.buf.Len(), maxCodeLength)
+	}
+	info := binary.BigEndian.AppendUint16(nil, uint16(c.maxStack))
+	info = binary.BigEndian.AppendUint16(info, maxLocals)
+	info = binary.BigEndian.AppendUint32(info, uint32(c.buf.Len()))
+	info = append(info, c.buf.Bytes()...)
+	info = binary.BigEndian.AppendUint16(info, 0) // exception_table_length
+	info = binary.BigEndian.AppendUint16(info, 0) // attributes_count
+	return attribute(c.pool, "Code", info)
+}
+
+// member encodes a field_info or method_info structure.
+func member(pool *constPool, access uint16, name, desc string, attrs ...[]byte) ([]byte, error) {
+	n, err := pool.utf8(name)
+	if err != nil {
+		return nil, err
+	}
+	d, err := pool.utf8(desc)
+	if err != nil {
+		return nil, err
+	}
+	b := binary.BigEndian.AppendUint16(nil, access)
+	b = binary.BigEndian.AppendUint16(b, n)
+	b = binary.BigEndian.AppendUint16(b, d)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+	for _, a := range attrs {
+		b = append(b, a...)
+	}
+	return b, nil
+}
@@ -0,0 +1,580 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// decodedClass is a class file parsed back by decodeClass, used to check what the writer emits.
+type decodedClass struct {
+	major        uint16
+	pool         []cpEntry
+	access       uint16
+	name         string
+	super        string
+	fields       []*decodedMember
+	methods      []*decodedMember
+	innerClasses []decodedInnerClass
+}
+
+type cpEntry struct {
+	tag  byte
+	str  string
+	i    int32
+	a, b uint16
+}
+
+type decodedMember struct {
+	access uint16
+	name   string
+	desc   string
+	attrs  map[string][]byte
+}
+
+type decodedInnerClass struct {
+	inner, outer, name string
+	access             uint16
+}
+
+type classReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (cr *classReader) u1() byte {
+	var v byte
+	cr.read(&v)
+	return v
+}
+
+func (cr *classReader) u2() uint16 {
+	var v uint16
+	cr.read(&v)
+	return v
+}
+
+func (cr *classReader) u4() uint32 {
+	var v uint32
+	cr.read(&v)
+	return v
+}
+
+func (cr *classReader) read(v any) {
+	if cr.err == nil {
+		cr.err = binary.Read(cr.r, binary.BigEndian, v)
+	}
+}
+
+func (cr *classReader) bytes(n int) []byte {
+	b := make([]byte, n)
+	if cr.err == nil {
+		_, cr.err = io.ReadFull(cr.r, b)
+	}
+	return b
+}
+
+func decodeModifiedUTF8(b []byte) (string, error) {
+	var u []uint16
+	for i := 0; i < len(b); {
+		switch {
+		case b[i]&0x80 == 0:
+			if b[i] == 0 {
+				return "", fmt.Errorf("raw NUL byte in modified UTF-8")
+			}
+			u = append(u, uint16(b[i]))
+			i++
+		case b[i]&0xe0 == 0xc0 && i+1 < len(b):
+			u = append(u, uint16(b[i]&0x1f)<<6|uint16(b[i+1]&0x3f))
+			i += 2
+		case b[i]&0xf0 == 0xe0 && i+2 < len(b):
+			u = append(u, uint16(b[i]&0x0f)<<12|uint16(b[i+1]&0x3f)<<6|uint16(b[i+2]&0x3f))
+			i += 3
+		default:
+			return "", fmt.Errorf("invalid modified UTF-8 byte %#x", b[i])
+		}
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+func decodeClass(b []byte) (*decodedClass, error) {
+	cr := &classReader{r: bytes.NewReader(b)}
+	if magic := cr.u4(); magic != classMagic {
+		return nil, fmt.Errorf("bad magic %#x", magic)
+	}
+	c := &decodedClass{}
+	cr.u2() // minor
+	c.major = cr.u2()
+	count := int(cr.u2())
+	c.pool = make([]cpEntry, count)
+	for i := 1; i < count; i++ {
+		e := cpEntry{tag: cr.u1()}
+		switch e.tag {
+		case constUtf8:
+			s, err := decodeModifiedUTF8(cr.bytes(int(cr.u2())))
+			if err != nil {
+				return nil, err
+			}
+			e.str = s
+		case constInteger:
+			e.i = int32(cr.u4())
+		case constClass:
+			e.a = cr.u2()
+		case constFieldref, constMethodref, constNameAndType:
+			e.a, e.b = cr.u2(), cr.u2()
+		default:
+			return nil, fmt.Errorf("constant pool entry %d has unexpected tag %d", i, e.tag)
+		}
+		c.pool[i] = e
+	}
+	if cr.err != nil {
+		return nil, cr.err
+	}
+	var err error
+	c.access = cr.u2()
+	if c.name, err = c.className(cr.u2()); err != nil {
+		return nil, err
+	}
+	if c.super, err = c.className(cr.u2()); err != nil {
+		return nil, err
+	}
+	if n := cr.u2(); n != 0 {
+		return nil, fmt.Errorf("unexpected interfaces: %d", n)
+	}
+	for _, list := range []*[]*decodedMember{&c.fields, &c.methods} {
+		for n := cr.u2(); n > 0; n-- {
+			m := &decodedMember{access: cr.u2()}
+			if m.name, err = c.utf8(cr.u2()); err != nil {
+				return nil, err
+			}
+			if m.desc, err = c.utf8(cr.u2()); err != nil {
+				return nil, err
+			}
+			if m.attrs, err = c.readAttrs(cr); err != nil {
+				return nil, err
+			}
+			*list = append(*list, m)
+		}
+	}
+	attrs, err := c.readAttrs(cr)
+	if err != nil {
+		return nil, err
+	}
+	if ic, ok := attrs["InnerClasses"]; ok {
+		icr := &classReader{r: bytes.NewReader(ic)}
+		for n := icr.u2(); n > 0; n-- {
+			var e decodedInnerClass
+			if e.inner, err = c.className(icr.u2()); err != nil {
+				return nil, err
+			}
+			if e.outer, err = c.className(icr.u2()); err != nil {
+				return nil, err
+			}
+			if e.name, err = c.utf8(icr.u2()); err != nil {
+				return nil, err
+			}
+			e.access = icr.u2()
+			c.innerClasses = append(c.innerClasses, e)
+		}
+		if icr.err != nil || icr.r.Len() != 0 {
+			return nil, fmt.Errorf("malformed InnerClasses attribute")
+		}
+	}
+	if cr.r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes", cr.r.Len())
+	}
+	return c, cr.err
+}
+
+func (c *decodedClass) readAttrs(cr *classReader) (map[string][]byte, error) {
+	attrs := make(map[string][]byte)
+	for n := cr.u2(); n > 0; n-- {
+		name, err := c.utf8(cr.u2())
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := attrs[name]; ok {
+			return nil, fmt.Errorf("duplicate %s attribute", name)
+		}
+		attrs[name] = cr.bytes(int(cr.u4()))
+	}
+	return attrs, cr.err
+}
+
+func (c *decodedClass) entry(i uint16, tag byte) (cpEntry, error) {
+	if int(i) <= 0 || int(i) >= len(c.pool) || c.pool[i].tag != tag {
+		return cpEntry{}, fmt.Errorf("constant pool index %d is not a valid entry of tag %d", i, tag)
+	}
+	return c.pool[i], nil
+}
+
+func (c *decodedClass) utf8(i uint16) (string, error) {
+	e, err := c.entry(i, constUtf8)
+	return e.str, err
+}
+
+func (c *decodedClass) className(i uint16) (string, error) {
+	e, err := c.entry(i, constClass)
+	if err != nil {
+		return "", err
+	}
+	return c.utf8(e.a)
+}
+
+// memberRef resolves a Fieldref or Methodref into its class, name and descriptor.
+func (c *decodedClass) memberRef(i uint16, tag byte) (class, name, desc string, err error) {
+	e, err := c.entry(i, tag)
+	if err != nil {
+		return "", "", "", err
+	}
+	if class, err = c.className(e.a); err != nil {
+		return "", "", "", err
+	}
+	nt, err := c.entry(e.b, constNameAndType)
+	if err != nil {
+		return "", "", "", err
+	}
+	if name, err = c.utf8(nt.a); err != nil {
+		return "", "", "", err
+	}
+	desc, err = c.utf8(nt.b)
+	return class, name, desc, err
+}
+
+func (c *decodedClass) field(name string) *decodedMember {
+	for _, f := range c.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// staticRef is a reference to a static field of another class, left unresolved by initialize.
+type staticRef struct {
+	class, name string
+}
+
+// initialize verifies the methods of the class the way the JVM type checker would for the subset
+// of instructions R classes use, and returns the value of each static field once the class is
+// initialized: an int32, a []int32, or a staticRef for values read from other classes.
+func (c *decodedClass) initialize() (map[string]any, error) {
+	if c.major != classMajorVersion {
+		return nil, fmt.Errorf("unexpected class version %d", c.major)
+	}
+	values := make(map[string]any)
+	for _, f := range c.fields {
+		if f.access&accStatic == 0 {
+			return nil, fmt.Errorf("field %s is not static", f.name)
+		}
+		if _, ok := values[f.name]; ok {
+			return nil, fmt.Errorf("duplicate field %s", f.name)
+		}
+		cv, hasCV := f.attrs["ConstantValue"]
+		switch {
+		case hasCV && (f.access&accFinal == 0 || f.desc != intDesc):
+			return nil, fmt.Errorf("field %s has a ConstantValue but is not a final int", f.name)
+		case hasCV:
+			e, err := c.entry(binary.BigEndian.Uint16(cv), constInteger)
+			if err != nil {
+				return nil, err
+			}
+			values[f.name] = e.i
+		case f.desc == intDesc:
+			values[f.name] = int32(0)
+		case f.desc == intsDesc:
+			values[f.name] = []int32(nil)
+		default:
+			return nil, fmt.Errorf("field %s has unexpected type %s", f.name, f.desc)
+		}
+	}
+	assigned := make(map[string]bool)
+	for _, m := range c.methods {
+		if m.desc != "()V" || (m.name != "<init>" && m.name != "<clinit>") {
+			return nil, fmt.Errorf("unexpected method %s%s", m.name, m.desc)
+		}
+		if err := c.run(m, values, assigned); err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", c.name, m.name, err)
+		}
+	}
+	for _, f := range c.fields {
+		if f.access&accFinal != 0 && f.attrs["ConstantValue"] == nil && !assigned[f.name] {
+			return nil, fmt.Errorf("final field %s is never assigned", f.name)
+		}
+	}
+	return values, nil
+}
+
+// run interprets the code of m, type checking every instruction.
+func (c *decodedClass) run(m *decodedMember, values map[string]any, assigned map[string]bool) error {
+	attr, ok := m.attrs["Code"]
+	if !ok {
+		return fmt.Errorf("missing Code attribute")
+	}
+	cr := &classReader{r: bytes.NewReader(attr)}
+	maxStack, maxLocals := int(cr.u2()), int(cr.u2())
+	insns := cr.bytes(int(cr.u4()))
+	if cr.u2() != 0 || cr.u2() != 0 || cr.err != nil || cr.r.Len() != 0 {
+		return fmt.Errorf("malformed Code attribute")
+	}
+	if len(insns) == 0 || len(insns) > maxCodeLength {
+		return fmt.Errorf("invalid code length %d", len(insns))
+	}
+	isStatic := m.access&accStatic != 0
+	if (m.name == "<clinit>") != isStatic {
+		return fmt.Errorf("unexpected access flags %#x", m.access)
+	}
+	if !isStatic && maxLocals < 1 {
+		return fmt.Errorf("max_locals %d has no room for this", maxLocals)
+	}
+
+	var stack []any
+	push := func(v any) error {
+		if len(stack) == maxStack {
+			return fmt.Errorf("operand stack exceeds max_stack %d", maxStack)
+		}
+		stack = append(stack, v)
+		return nil
+	}
+	popInt := func() (int32, error) {
+		if len(stack) == 0 {
+			return 0, fmt.Errorf("operand stack underflow")
+		}
+		v, ok := stack[len(stack)-1].(int32)
+		if !ok {
+			return 0, fmt.Errorf("expected int on the stack, got %T", stack[len(stack)-1])
+		}
+		stack = stack[:len(stack)-1]
+		return v, nil
+	}
+	code := &classReader{r: bytes.NewReader(insns)}
+	for code.r.Len() > 0 {
+		op := code.u1()
+		var err error
+		switch {
+		case op >= opIconstM1 && op <= opIconstM1+6:
+			err = push(int32(op) - opIconstM1 - 1)
+		case op == opBipush:
+			err = push(int32(int8(code.u1())))
+		case op == opSipush:
+			err = push(int32(int16(code.u2())))
+		case op == opLdc || op == opLdcW:
+			i := uint16(0)
+			if op == opLdc {
+				i = uint16(code.u1())
+			} else {
+				i = code.u2()
+			}
+			var e cpEntry
+			if e, err = c.entry(i, constInteger); err == nil {
+				err = push(e.i)
+			}
+		case op == opAload0:
+			if isStatic {
+				return fmt.Errorf("aload_0 in a static method")
+			}
+			err = push(c.name)
+		case op == opInvokespecial:
+			class, name, desc, err := c.memberRef(code.u2(), constMethodref)
+			if err != nil {
+				return err
+			}
+			if class != c.super || name != "<init>" || desc != "()V" {
+				return fmt.Errorf("unexpected invokespecial %s.%s%s", class, name, desc)
+			}
+			if len(stack) == 0 || stack[len(stack)-1] != c.name {
+				return fmt.Errorf("invokespecial without this on the stack")
+			}
+			stack = stack[:len(stack)-1]
+		case op == opNewarray:
+			if t := code.u1(); t != arrayTypeInt {
+				return fmt.Errorf("unexpected newarray type %d", t)
+			}
+			n, err := popInt()
+			if err != nil {
+				return err
+			}
+			if n < 0 {
+				return fmt.Errorf("negative array size")
+			}
+			err = push(make([]int32, n))
+		case op == opDup:
+			if len(stack) == 0 {
+				return fmt.Errorf("operand stack underflow")
+			}
+			err = push(stack[len(stack)-1])
+		case op == opIastore:
+			v, err := popInt()
+			if err != nil {
+				return err
+			}
+			i, err := popInt()
+			if err != nil {
+				return err
+			}
+			if len(stack) == 0 {
+				return fmt.Errorf("operand stack underflow")
+			}
+			a, ok := stack[len(stack)-1].([]int32)
+			if !ok {
+				return fmt.Errorf("iastore on %T", stack[len(stack)-1])
+			}
+			stack = stack[:len(stack)-1]
+			if i < 0 || int(i) >= len(a) {
+				return fmt.Errorf("array index %d out of bounds", i)
+			}
+			a[i] = v
+		case op == opGetstatic:
+			class, name, desc, err := c.memberRef(code.u2(), constFieldref)
+			if err != nil {
+				return err
+			}
+			if desc != intDesc && desc != intsDesc {
+				return fmt.Errorf("getstatic of unexpected type %s", desc)
+			}
+			err = push(staticRef{class: class, name: name})
+		case op == opPutstatic:
+			class, name, desc, err := c.memberRef(code.u2(), constFieldref)
+			if err != nil {
+				return err
+			}
+			f := c.field(name)
+			if class != c.name || f == nil || f.desc != desc {
+				return fmt.Errorf("putstatic to unknown field %s.%s:%s", class, name, desc)
+			}
+			if f.access&accFinal != 0 && (m.name != "<clinit>" || assigned[name]) {
+				return fmt.Errorf("invalid assignment of final field %s", name)
+			}
+			if len(stack) == 0 {
+				return fmt.Errorf("operand stack underflow")
+			}
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			switch v.(type) {
+			case int32:
+				if desc != intDesc {
+					return fmt.Errorf("int stored to %s field %s", desc, name)
+				}
+			case []int32:
+				if desc != intsDesc {
+					return fmt.Errorf("int[] stored to %s field %s", desc, name)
+				}
+			case staticRef:
+			default:
+				return fmt.Errorf("%T stored to field %s", v, name)
+			}
+			values[name] = v
+			assigned[name] = true
+		case op == opReturn:
+			if code.r.Len() != 0 {
+				return fmt.Errorf("return is not the last instruction")
+			}
+			return nil
+		default:
+			return fmt.Errorf("unexpected opcode %#x", op)
+		}
+		if err != nil {
+			return err
+		}
+		if code.err != nil {
+			return fmt.Errorf("truncated instruction %#x", op)
+		}
+	}
+	return fmt.Errorf("code falls off the end of the method")
+}
+
+func TestModifiedUTF8(t *testing.T) {
+	for _, s := range []string{"", "abc_fade_in", "\x00", "é", "日本", "\U0001F600"} {
+		got, err := decodeModifiedUTF8(modifiedUTF8(s))
+		if err != nil {
+			t.Errorf("decodeModifiedUTF8(modifiedUTF8(%q)) failed: %v", s, err)
+			continue
+		}
+		if got != s {
+			t.Errorf("decodeModifiedUTF8(modifiedUTF8(%q)) = %q", s, got)
+		}
+	}
+	if got := modifiedUTF8("\x00"); !bytes.Equal(got, []byte{0xc0, 0x80}) {
+		t.Errorf("modifiedUTF8(NUL) = %x, want c080", got)
+	}
+}
+
+func TestConstPoolDeduplicates(t *testing.T) {
+	p := newConstPool()
+	a, err := p.member(constFieldref, "R$id", "foo", intDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.member(constFieldref, "R$id", "foo", intDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("member() returned %d and %d for the same reference", a, b)
+	}
+	// utf8 x3 (class name, field name, descriptor), class, name and type, fieldref.
+	if want := uint16(7); p.count != want {
+		t.Errorf("pool count is %d, want %d", p.count, want)
+	}
+}
+
+func TestPushInt(t *testing.T) {
+	tests := []struct {
+		v       int32
+		wantOps []byte
+	}{
+		{v: -1, wantOps: []byte{opIconstM1}},
+		{v: 5, wantOps: []byte{opIconstM1 + 6}},
+		{v: 6, wantOps: []byte{opBipush, 6}},
+		{v: -128, wantOps: []byte{opBipush, 0x80}},
+		{v: 128, wantOps: []byte{opSipush, 0, 128}},
+		{v: math.MinInt16, wantOps: []byte{opSipush, 0x80, 0}},
+		{v: 0x7f010000, wantOps: []byte{opLdc, 1}},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprint(tc.v), func(t *testing.T) {
+			c := &code{pool: newConstPool()}
+			if err := c.pushInt(tc.v); err != nil {
+				t.Fatalf("pushInt(%d) failed: %v", tc.v, err)
+			}
+			if diff := cmp.Diff(tc.wantOps, c.buf.Bytes()); diff != "" {
+				t.Errorf("pushInt(%d) returned diff (-want, +got):\n%v", tc.v, diff)
+			}
+			if c.maxStack != 1 {
+				t.Errorf("pushInt(%d) max stack is %d, want 1", tc.v, c.maxStack)
+			}
+		})
+	}
+}
+
+func TestPushIntWideIndex(t *testing.T) {
+	c := &code{pool: newConstPool()}
+	for i := int32(0); i < 300; i++ {
+		if err := c.pushInt(0x7f000000 + i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := c.buf.Bytes()[len(c.buf.Bytes())-3]; got != opLdcW {
+		t.Errorf("constant beyond index 255 loaded with opcode %#x, want ldc_w", got)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rclass writes compiled R classes directly from resource symbols, without a Java compiler.
+package rclass
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"src/common/golang/ziputils"
+	"src/tools/ak/rtxt/rtxt"
+)
+
+const (
+	objectClass = "java/lang/Object"
+	intDesc     = "I"
+	intsDesc    = "[I"
+)
+
+// Field is a static field of an R class.
+type Field struct {
+	Name string
+	// Array is true for int[] fields.
+	Array bool
+	// Values holds the value of an int field, or the elements of an int[] field.
+	Values []int32
+}
+
+func (f *Field) desc() string {
+	if f.Array {
+		return intsDesc
+	}
+	return intDesc
+}
+
+// Class is an R class, or one of the resource type classes nested in it.
+type Class struct {
+	// Name is the binary name of the class, e.g. com/example/R$string.
+	Name string
+	// Super is the binary name of the super class, java/lang/Object if unset.
+	Super string
+	// Final makes the fields final, which turns int fields into compile time constants.
+	Final  bool
+	Fields []*Field
+	Nested []*Class
+}
+
+// New returns the R class of pkg with one nested class per resource type in symbols.
+//
+// Symbols which cannot be represented as java fields are skipped, as well as duplicates of an
+// already seen type and name.
+func New(pkg string, symbols []*rtxt.Symbol, final bool) *Class {
+	r := &Class{Name: binaryName(pkg), Final: final}
+	nested := make(map[string]*Class)
+	seen := make(map[string]bool)
+	for _, s := range symbols {
+		// Aapt2 will sometime add resources containing the char '$'.
+		// Those should be ignored - they are derived from an actual resource.
+		if !isIdentifier(s.Type) || !isIdentifier(s.Name) || strings.Contains(s.Name, "$") {
+			continue
+		}
+		id := s.Type + "." + s.Name
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		c, ok := nested[s.Type]
+		if !ok {
+			c = &Class{Name: r.Name + "$" + s.Type, Final: final}
+			nested[s.Type] = c
+			r.Nested = append(r.Nested, c)
+		}
+		c.Fields = append(c.Fields, &Field{Name: s.Name, Array: s.Array, Values: s.Values})
+	}
+	sort.Slice(r.Nested, func(i, j int) bool { return r.Nested[i].Name < r.Nested[j].Name })
+	for _, c := range r.Nested {
+		sort.Slice(c.Fields, func(i, j int) bool { return c.Fields[i].Name < c.Fields[j].Name })
+	}
+	return r
+}
+
+// Extend returns an R class for pkg which, like each of its nested classes, extends the
+// corresponding class of parent without declaring fields of its own.
+func Extend(pkg string, parent *Class) *Class {
+	r := &Class{Name: binaryName(pkg), Super: parent.Name}
+	for _, p := range parent.Nested {
+		r.Nested = append(r.Nested, &Class{
+			Name:  r.Name + p.Name[len(parent.Name):],
+			Super: p.Name,
+		})
+	}
+	return r
+}
+
+func binaryName(pkg string) string {
+	if pkg == "" {
+		return "R"
+	}
+	return strings.ReplaceAll(pkg, ".", "/") + "/R"
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || IsReserved(s) {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Bytes returns the class file of c. Nested classes are encoded separately.
+func (c *Class) Bytes() ([]byte, error) {
+	pool := newConstPool()
+	super := c.Super
+	if super == "" {
+		super = objectClass
+	}
+	thisIdx, err := pool.class(c.Name)
+	if err != nil {
+		return nil, err
+	}
+	superIdx, err := pool.class(super)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields [][]byte
+	clinit := &code{pool: pool}
+	for _, f := range c.Fields {
+		access := uint16(accPublic | accStatic)
+		if c.Final {
+			access |= accFinal
+		}
+		var attrs [][]byte
+		switch {
+		case c.Final && !f.Array:
+			v, err := pool.integer(f.value())
+			if err != nil {
+				return nil, err
+			}
+			cv, err := attribute(pool, "ConstantValue", binary.BigEndian.AppendUint16(nil, v))
+			if err != nil {
+				return nil, err
+			}
+			attrs = append(attrs, cv)
+		case f.Array:
+			if err := clinit.newIntArray(f.Values); err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", c.Name, f.Name, err)
+			}
+			if err := clinit.fieldInsn(opPutstatic, c.Name, f.Name, f.desc()); err != nil {
+				return nil, err
+			}
+		default:
+			if err := clinit.pushInt(f.value()); err != nil {
+				return nil, err
+			}
+			if err := clinit.fieldInsn(opPutstatic, c.Name, f.Name, f.desc()); err != nil {
+				return nil, err
+			}
+		}
+		fb, err := member(pool, access, f.Name, f.desc(), attrs...)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, fb)
+	}
+
+	var methods [][]byte
+	init := &code{pool: pool}
+	init.op(opAload0, 1)
+	ctor, err := pool.member(constMethodref, super, "<init>", "()V")
+	if err != nil {
+		return nil, err
+	}
+	init.op(opInvokespecial, -1)
+	init.u2(ctor)
+	initCode, err := init.attribute(1)
+	if err != nil {
+		return nil, err
+	}
+	m, err := member(pool, accPublic, "<init>", "()V", initCode)
+	if err != nil {
+		return nil, err
+	}
+	methods = append(methods, m)
+	if clinit.buf.Len() > 0 {
+		clinitCode, err := clinit.attribute(0)
+		if err != nil {
+			return nil, fmt.Errorf("%s static initializer: %v", c.Name, err)
+		}
+		m, err := member(pool, accStatic, "<clinit>", "()V", clinitCode)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
+	}
+
+	innerClasses, err := c.innerClasses(pool, super)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint32(classMagic))
+	binary.Write(&b, binary.BigEndian, uint16(classMinorVersion))
+	binary.Write(&b, binary.BigEndian, uint16(classMajorVersion))
+	binary.Write(&b, binary.BigEndian, pool.count)
+	b.Write(pool.buf.Bytes())
+	binary.Write(&b, binary.BigEndian, uint16(accPublic|accSuper))
+	binary.Write(&b, binary.BigEndian, thisIdx)
+	binary.Write(&b, binary.BigEndian, superIdx)
+	binary.Write(&b, binary.BigEndian, uint16(0)) // interfaces_count
+	for _, list := range [][][]byte{fields, methods} {
+		binary.Write(&b, binary.BigEndian, uint16(len(list)))
+		for _, m := range list {
+			b.Write(m)
+		}
+	}
+	if innerClasses == nil {
+		binary.Write(&b, binary.BigEndian, uint16(0))
+	} else {
+		binary.Write(&b, binary.BigEndian, uint16(1))
+		b.Write(innerClasses)
+	}
+	return b.Bytes(), nil
+}
+
+func (f *Field) value() int32 {
+	if len(f.Values) == 0 {
+		return 0
+	}
+	return f.Values[0]
+}
+
+// innerClasses returns the InnerClasses attribute listing every nested class c refers to, or
+// nil if there are none.
+func (c *Class) innerClasses(pool *constPool, super string) ([]byte, error) {
+	var names []string
+	for _, n := range append([]string{c.Name, super}, nestedNames(c)...) {
+		if strings.Contains(n, "$") {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	info := binary.BigEndian.AppendUint16(nil, uint16(len(names)))
+	for _, n := range names {
+		sep := strings.LastIndex(n, "$")
+		inner, err := pool.class(n)
+		if err != nil {
+			return nil, err
+		}
+		outer, err := pool.class(n[:sep])
+		if err != nil {
+			return nil, err
+		}
+		simple, err := pool.utf8(n[sep+1:])
+		if err != nil {
+			return nil, err
+		}
+		info = binary.BigEndian.AppendUint16(info, inner)
+		info = binary.BigEndian.AppendUint16(info, outer)
+		info = binary.BigEndian.AppendUint16(info, simple)
+		info = binary.BigEndian.AppendUint16(info, accPublic|accStatic)
+	}
+	return attribute(pool, "InnerClasses", info)
+}
+
+func nestedNames(c *Class) []string {
+	var names []string
+	for _, n := range c.Nested {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+// WriteJar writes the class files of classes, and of the classes nested in them, to w.
+func WriteJar(w io.Writer, classes ...*Class) error {
+	files := make(map[string][]byte)
+	var walk func(c *Class) error
+	walk = func(c *Class) error {
+		b, err := c.Bytes()
+		if err != nil {
+			return err
+		}
+		files[c.Name+".class"] = b
+		for _, n := range c.Nested {
+			if err := walk(n); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, c := range classes {
+		if err := walk(c); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	zw := zip.NewWriter(w)
+	for _, n := range names {
+		if err := ziputils.WriteReader(zw, bytes.NewReader(files[n]), n); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// IsReserved tells whether s is a Java keyword or literal, which cannot be used as
+// an identifier.
+func IsReserved(s string) bool {
+	return javaReserved[s]
+}
+
+var javaReserved = map[string]bool{
+	"abstract":     true,
+	"assert":       true,
+	"boolean":      true,
+	"break":        true,
+	"byte":         true,
+	"case":         true,
+	"catch":        true,
+	"char":         true,
+	"class":        true,
+	"const":        true,
+	"continue":     true,
+	"default":      true,
+	"do":           true,
+	"double":       true,
+	"else":         true,
+	"enum":         true,
+	"extends":      true,
+	"false":        true,
+	"final":        true,
+	"finally":      true,
+	"float":        true,
+	"for":          true,
+	"goto":         true,
+	"if":           true,
+	"implements":   true,
+	"import":       true,
+	"instanceof":   true,
+	"int":          true,
+	"interface":    true,
+	"long":         true,
+	"native":       true,
+	"new":          true,
+	"null":         true,
+	"package":      true,
+	"private":      true,
+	"protected":    true,
+	"public":       true,
+	"return":       true,
+	"short":        true,
+	"static":       true,
+	"strictfp":     true,
+	"super":        true,
+	"switch":       true,
+	"synchronized": true,
+	"this":         true,
+	"throw":        true,
+	"throws":       true,
+	"transient":    true,
+	"true":         true,
+	"try":          true,
+	"void":         true,
+	"volatile":     true,
+	"while":        true}
@@ -0,0 +1,199 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rclass
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"src/tools/ak/rtxt/rtxt"
+)
+
+const rTxt = `int anim abc_fade_in 0x7f010000
+int attr actionBarSize 0x7f030003
+int id my_view 0x7f080001
+int id my_view 0x7f080002
+int id class 0x7f080003
+int id root$inner 0x7f080004
+int string app_name 0x7f0b0001
+int[] styleable ActionBar { 0x7f030031, 0x7f030003 }
+int styleable ActionBar_background 0
+int styleable ActionBar_height 1
+int[] styleable Empty {  }
+`
+
+// initialized returns the static field values of every class in the jar, keyed by binary class
+// name, after verifying each class file.
+func initialized(t *testing.T, jar []byte) (map[string]*decodedClass, map[string]map[string]any) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(jar), int64(len(jar)))
+	if err != nil {
+		t.Fatalf("zip.NewReader failed: %v", err)
+	}
+	classes := make(map[string]*decodedClass)
+	values := make(map[string]map[string]any)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open(%s) failed: %v", f.Name, err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("ReadAll(%s) failed: %v", f.Name, err)
+		}
+		c, err := decodeClass(b)
+		if err != nil {
+			t.Fatalf("decodeClass(%s) failed: %v", f.Name, err)
+		}
+		if want := strings.TrimSuffix(f.Name, ".class"); c.name != want {
+			t.Errorf("%s declares class %s", f.Name, c.name)
+		}
+		v, err := c.initialize()
+		if err != nil {
+			t.Fatalf("%s does not verify: %v", f.Name, err)
+		}
+		classes[c.name] = c
+		values[c.name] = v
+	}
+	return classes, values
+}
+
+func writeJar(t *testing.T, classes ...*Class) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := WriteJar(&b, classes...); err != nil {
+		t.Fatalf("WriteJar failed: %v", err)
+	}
+	return b.Bytes()
+}
+
+func TestWriteJar(t *testing.T) {
+	symbols, err := rtxt.Parse(strings.NewReader(rTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantValues := map[string]map[string]any{
+		"com/example/R":      {},
+		"com/example/R$anim": {"abc_fade_in": int32(0x7f010000)},
+		"com/example/R$attr": {"actionBarSize": int32(0x7f030003)},
+		// Duplicates keep the first value, reserved words and derived names are skipped.
+		"com/example/R$id":     {"my_view": int32(0x7f080001)},
+		"com/example/R$string": {"app_name": int32(0x7f0b0001)},
+		"com/example/R$styleable": {
+			"ActionBar":            []int32{0x7f030031, 0x7f030003},
+			"ActionBar_background": int32(0),
+			"ActionBar_height":     int32(1),
+			"Empty":                []int32{},
+		},
+	}
+	for _, final := range []bool{true, false} {
+		t.Run(map[bool]string{true: "final", false: "non-final"}[final], func(t *testing.T) {
+			classes, values := initialized(t, writeJar(t, New("com.example", symbols, final)))
+			if diff := cmp.Diff(wantValues, values); diff != "" {
+				t.Errorf("New(%v) static values returned diff (-want, +got):\n%v", final, diff)
+			}
+			for name, c := range classes {
+				for _, f := range c.fields {
+					if got := f.access&accFinal != 0; got != final {
+						t.Errorf("%s.%s final = %v, want %v", name, f.name, got, final)
+					}
+					if _, cv := f.attrs["ConstantValue"]; cv != (final && f.desc == intDesc) {
+						t.Errorf("%s.%s has ConstantValue = %v", name, f.name, cv)
+					}
+				}
+			}
+			wantInner := []decodedInnerClass{{inner: "com/example/R$id", outer: "com/example/R", name: "id", access: accPublic | accStatic}}
+			if diff := cmp.Diff(wantInner, classes["com/example/R$id"].innerClasses, cmp.AllowUnexported(decodedInnerClass{})); diff != "" {
+				t.Errorf("R$id InnerClasses returned diff (-want, +got):\n%v", diff)
+			}
+			if got := len(classes["com/example/R"].innerClasses); got != 5 {
+				t.Errorf("R InnerClasses has %d entries, want 5", got)
+			}
+		})
+	}
+}
+
+func TestWriteJarEntries(t *testing.T) {
+	symbols := []*rtxt.Symbol{
+		{Type: "string", Name: "b", Values: []int32{2}},
+		{Type: "id", Name: "a", Values: []int32{1}},
+	}
+	r := New("com.example", symbols, true)
+	jar := writeJar(t, r, Extend("com.example.lib", r))
+	zr, err := zip.NewReader(bytes.NewReader(jar), int64(len(jar)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range zr.File {
+		got = append(got, f.Name)
+	}
+	want := []string{
+		"com/example/R$id.class",
+		"com/example/R$string.class",
+		"com/example/R.class",
+		"com/example/lib/R$id.class",
+		"com/example/lib/R$string.class",
+		"com/example/lib/R.class",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WriteJar entries returned diff (-want, +got):\n%v", diff)
+	}
+	if again := writeJar(t, r, Extend("com.example.lib", r)); !bytes.Equal(jar, again) {
+		t.Error("WriteJar output is not deterministic")
+	}
+}
+
+func TestExtend(t *testing.T) {
+	r := New("com.example", []*rtxt.Symbol{{Type: "id", Name: "a", Values: []int32{1}}}, true)
+	classes, values := initialized(t, writeJar(t, Extend("com.example.lib", r)))
+	if got := classes["com/example/lib/R$id"].super; got != "com/example/R$id" {
+		t.Errorf("lib R$id extends %s, want com/example/R$id", got)
+	}
+	if got := classes["com/example/lib/R"].super; got != "com/example/R" {
+		t.Errorf("lib R extends %s, want com/example/R", got)
+	}
+	if diff := cmp.Diff(map[string]any{}, values["com/example/lib/R$id"]); diff != "" {
+		t.Errorf("lib R$id static values returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestLargeStyleable(t *testing.T) {
+	var attrs []int32
+	for i := int32(0); i < 1000; i++ {
+		attrs = append(attrs, 0x7f030000+i)
+	}
+	symbols := []*rtxt.Symbol{{Type: "styleable", Name: "Big", Array: true, Values: attrs}}
+	_, values := initialized(t, writeJar(t, New("", symbols, true)))
+	if diff := cmp.Diff(attrs, values["R$styleable"]["Big"]); diff != "" {
+		t.Errorf("R$styleable.Big returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestCodeTooLarge(t *testing.T) {
+	var attrs []int32
+	for i := int32(0); i < 10000; i++ {
+		attrs = append(attrs, 0x7f030000+i)
+	}
+	c := New("com.example", []*rtxt.Symbol{{Type: "styleable", Name: "Huge", Array: true, Values: attrs}}, true)
+	if _, err := c.Nested[0].Bytes(); err == nil || !strings.Contains(err.Error(), "byte limit") {
+		t.Errorf("Bytes() returned error %v, want code size error", err)
+	}
+}
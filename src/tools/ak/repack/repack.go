@@ -26,6 +26,9 @@ import (
 	"sync"
 
 	"src/common/golang/flags"
+	"src/common/golang/ziputils"
+	"src/tools/ak/extractresources/extractresources"
+	"src/tools/ak/jarmerge"
 	"src/tools/ak/types"
 )
 
@@ -45,6 +48,15 @@ var (
 			"filter_manifest",
 			"compress",
 			"remove_dirs",
+			"align",
+			"include",
+			"exclude",
+			"strip_prefix",
+			"add_prefix",
+			"method",
+			"duplicates",
+			"duplicate_rule",
+			"report",
 		},
 	}
 
@@ -58,9 +70,17 @@ var (
 	filterManifest bool
 	compress       bool
 	removeDirs     bool
+	align          bool
+	include        flags.MultiString
+	exclude        flags.MultiString
+	stripPrefix    flags.MultiString
+	addPrefix      string
+	methodRules    flags.MultiString
+	duplicates     string
+	duplicateRules flags.MultiString
+	report         string
 
 	b2i      = map[bool]int8{false: 0, true: 1}
-	seen     = make(map[string]bool)
 	initOnce sync.Once
 )
 
@@ -76,6 +96,15 @@ func Init() {
 		flag.BoolVar(&filterManifest, "filter_manifest", false, "Whether to filter AndroidManifest.xml or not.")
 		flag.BoolVar(&compress, "compress", false, "Whether to compress or just store files in all outputs.")
 		flag.BoolVar(&removeDirs, "remove_dirs", true, "Whether to remove directory entries or not.")
+		flag.Var(&include, "include", "Repeatable pattern of the entries to keep, others are filtered. Globs, where ** matches across directories, or regular expressions prefixed by re:.")
+		flag.Var(&exclude, "exclude", "Repeatable pattern of the entries to filter, see -include.")
+		flag.Var(&stripPrefix, "strip_prefix", "Repeatable prefix to remove from the entry names, the first matching one is removed.")
+		flag.StringVar(&addPrefix, "add_prefix", "", "Prefix to add to the entry names, after -strip_prefix.")
+		flag.Var(&methodRules, "method", "Repeatable compression method of the entries matching a pattern, overriding -compress: {pattern}:{store|deflate}.")
+		flag.StringVar(&duplicates, "duplicates", jarmerge.First, "What to do with entries found more than once: first, last, error, drop, or merge them. Service loader files, Kotlin modules and MANIFEST.MF are merged by content, others concatenated.")
+		flag.Var(&duplicateRules, "duplicate_rule", "Repeatable -duplicates policy of the entries matching a pattern: {pattern}:{first|last|error|drop|merge}. META-INF/services and Kotlin modules are merged by default.")
+		flag.StringVar(&report, "report", "", "(optional) Path to write how the entries found more than once were resolved to.")
+		flag.BoolVar(&align, "align", false, "Whether to align stored files like zipalign, to 4 bytes and native libraries to 16 KB. Compressed files are not aligned.")
 	})
 }
 
@@ -85,6 +114,20 @@ func desc() string {
 
 type filterFunc func(name string) bool
 
+// zipWriter is implemented by zip.Writer and ziputils.AlignedWriter.
+type zipWriter interface {
+	CreateHeader(fh *zip.FileHeader) (io.Writer, error)
+	Close() error
+}
+
+func newWriter(w io.Writer) zipWriter {
+	if align {
+		return ziputils.NewAlignedWriter(w, ziputils.NewAligner(ziputils.DefaultAlignment,
+			ziputils.AlignRule{Pattern: ziputils.NativeLibsPattern, Alignment: ziputils.PageAlignment}))
+	}
+	return zip.NewWriter(w)
+}
+
 func filterNone(name string) bool {
 	return false
 }
@@ -101,80 +144,101 @@ func isManifest(name string) bool {
 	return name == "AndroidManifest.xml"
 }
 
-func repackZip(in *zip.Reader, out *zip.Writer, filteredZipOut *zip.Writer, filter filterFunc, method uint16) error {
-	for _, f := range in.File {
-		if removeDirs && strings.HasSuffix(f.Name, "/") {
-			continue
-		}
-		reader, err := f.Open()
-		if err != nil {
-			return err
-		}
-		if err := writeToZip(f.Name, reader, out, filteredZipOut, filter, method); err != nil {
-			return err
+// packer routes the entries of the inputs to the outputs, resolving duplicate names.
+type packer struct {
+	filter   filterFunc
+	rules    *rules
+	out      *jarmerge.Archive
+	filtered *jarmerge.Archive
+}
+
+func newPacker(filter filterFunc, r *rules) *packer {
+	return &packer{
+		filter:   filter,
+		rules:    r,
+		out:      jarmerge.NewArchive(),
+		filtered: jarmerge.NewArchive(),
+	}
+}
+
+func (p *packer) add(name string, src jarmerge.Source) error {
+	if removeDirs && strings.HasSuffix(name, "/") {
+		return nil
+	}
+	a := p.out
+	if p.filter(name) || p.rules.filtered(name) {
+		a = p.filtered
+	}
+	renamed := p.rules.rename(name)
+	if renamed == "" {
+		return nil
+	}
+	return a.Add(renamed, p.rules.methodOf(renamed), p.rules.policyOf(renamed), src)
+}
+
+// write writes the entries to out and the filtered ones to filteredOut, if not nil. It returns
+// how the duplicates were resolved.
+func (p *packer) write(out, filteredOut zipWriter) ([]jarmerge.Collision, error) {
+	collisions, err := p.out.Write(out)
+	if err != nil || filteredOut == nil {
+		return collisions, err
+	}
+	filtered, err := p.filtered.Write(filteredOut)
+	return append(collisions, filtered...), err
+}
+
+// writeReport writes the collisions to report, noting those of entries which are not Java
+// resources, e.g. build metadata, and usually harmless.
+func writeReport(report string, collisions []jarmerge.Collision) error {
+	for i := range collisions {
+		c := &collisions[i]
+		if reason := extractresources.ExclusionReason(c.Name); reason != "" {
+			if c.Note != "" {
+				c.Note += ", "
+			}
+			c.Note += "not a java resource: " + reason
 		}
-		if err := reader.Close(); err != nil {
+	}
+	f, err := os.Create(report)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := jarmerge.WriteReport(f, collisions); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func repackZip(in *zip.Reader, origin string, p *packer) error {
+	for _, f := range in.File {
+		if err := p.add(f.Name, jarmerge.Source{Origin: origin, Open: f.Open}); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func repackDir(dir string, out *zip.Writer, filteredZipOut *zip.Writer, filter filterFunc, method uint16) error {
+func repackDir(dir string, p *packer) error {
 	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
 		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
 		if info.IsDir() {
-			if removeDirs {
+			if name == "." {
 				return nil
 			}
 			name += "/"
 		}
-
-		reader, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer reader.Close()
-
-		return writeToZip(name, reader, out, filteredZipOut, filter, method)
-	})
-}
-
-func writeToZip(name string, in io.Reader, out, filteredZipOut *zip.Writer, filter filterFunc, method uint16) error {
-	if seen[name] {
-		return nil
-	}
-	seen[name] = true
-
-	if filter(name) {
-		if filteredZipOut != nil {
-			write(filteredZipOut, in, name, method)
-		}
-		return nil
-	}
-	return write(out, in, name, method)
-}
-
-func write(out *zip.Writer, in io.Reader, name string, method uint16) error {
-	writer, err := out.CreateHeader(&zip.FileHeader{
-		Name:   name,
-		Method: method,
+		open := func() (io.ReadCloser, error) { return os.Open(path) }
+		return p.add(name, jarmerge.Source{Origin: dir, Open: open})
 	})
-	if err != nil {
-		return err
-	}
-
-	// Only files have data, io.Copy will fail for directories. Header entry is required for both.
-	if !strings.HasSuffix(name, "/") {
-		if _, err := io.Copy(writer, in); err != nil {
-			return err
-		}
-	}
-	return nil
 }
 
 // Run is the entry point for repack.
@@ -199,45 +263,31 @@ func Run() {
 		filter = isManifest
 	}
 
-	w, err := os.Create(out)
-	if err != nil {
-		log.Fatalf("os.Create(%q) failed: %v", out, err)
-	}
-	defer w.Close()
-
-	zipOut := zip.NewWriter(w)
-	defer zipOut.Close()
-
-	var filteredZipOut *zip.Writer
-	if filteredOut != "" {
-		w, err := os.Create(filteredOut)
-		if err != nil {
-			log.Fatalf("os.Create(%q) failed: %v", filteredOut, err)
-		}
-		defer w.Close()
-		filteredZipOut = zip.NewWriter(w)
-		defer filteredZipOut.Close()
-	}
-
 	method := zip.Store
 	if compress {
 		method = zip.Deflate
 	}
+	r, err := newRules(include, exclude, stripPrefix, addPrefix, methodRules, method, duplicateRules, duplicates)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p := newPacker(filter, r)
 
 	for _, d := range dir {
-		if err := repackDir(d, zipOut, filteredZipOut, filter, method); err != nil {
+		if err := repackDir(d, p); err != nil {
 			log.Fatal(err)
 		}
 	}
 
+	// The inputs are read when writing the outputs, once all duplicates are known.
 	for _, f := range in {
 		file, err := os.Open(f)
 		if err != nil {
 			log.Fatalf("os.Open(%q) failed: %v", f, err)
 		}
+		defer file.Close()
 		fi, err := file.Stat()
 		if err != nil {
-			file.Close()
 			log.Fatalf("File.Stat() failed for %q: %v", f, err)
 		}
 		size := fi.Size()
@@ -248,14 +298,40 @@ func Run() {
 		}
 		zipIn, err := zip.NewReader(file, size)
 		if err != nil {
-			file.Close()
 			log.Fatalf("zip.OpenReader(%q) failed: %v", f, err)
 		}
+		if err := repackZip(zipIn, f, p); err != nil {
+			log.Fatalf("repacking %q failed: %v", f, err)
+		}
+	}
+
+	w, err := os.Create(out)
+	if err != nil {
+		log.Fatalf("os.Create(%q) failed: %v", out, err)
+	}
+	defer w.Close()
 
-		err = repackZip(zipIn, zipOut, filteredZipOut, filter, method)
-		file.Close()
+	zipOut := newWriter(w)
+	defer zipOut.Close()
+
+	var filteredZipOut zipWriter
+	if filteredOut != "" {
+		w, err := os.Create(filteredOut)
 		if err != nil {
-			log.Fatal(err)
+			log.Fatalf("os.Create(%q) failed: %v", filteredOut, err)
+		}
+		defer w.Close()
+		filteredZipOut = newWriter(w)
+		defer filteredZipOut.Close()
+	}
+
+	collisions, err := p.write(zipOut, filteredZipOut)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if report != "" {
+		if err := writeReport(report, collisions); err != nil {
+			log.Fatalf("writing %q failed: %v", report, err)
 		}
 	}
 }
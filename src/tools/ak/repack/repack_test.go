@@ -21,7 +21,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"src/tools/ak/jarmerge"
 )
 
 type test struct {
@@ -33,6 +36,8 @@ type test struct {
 type testData struct {
 	name  string
 	match bool
+	// content is written to the entry, a single byte if empty.
+	content string
 }
 
 var (
@@ -104,6 +109,8 @@ var (
 			match: false,
 		},
 	}
+	noRules = &rules{method: zip.Store, policy: func(string) string { return jarmerge.First }}
+
 	tests = []test{
 		{
 			name:     "filterNone",
@@ -185,8 +192,11 @@ func repackZipTest(t *testing.T, in *zip.ReadCloser, test test) {
 	bufOut := new(bytes.Buffer)
 	zipOut := zip.NewWriter(bufOut)
 
-	seen = make(map[string]bool)
-	if err := repackZip(&in.Reader, zipOut, nil, test.filter, zip.Store); err != nil {
+	p := newPacker(test.filter, noRules)
+	if err := repackZip(&in.Reader, "in.zip", p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.write(zipOut, nil); err != nil {
 		t.Fatal(err)
 	}
 
@@ -224,8 +234,11 @@ func repackZipWithFiletedOutTest(t *testing.T, in *zip.ReadCloser, test test) {
 	buffilteredOut := new(bytes.Buffer)
 	zipfilteredOut := zip.NewWriter(buffilteredOut)
 
-	seen = make(map[string]bool)
-	if err := repackZip(&in.Reader, zipOut, zipfilteredOut, test.filter, zip.Store); err != nil {
+	p := newPacker(test.filter, noRules)
+	if err := repackZip(&in.Reader, "in.zip", p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.write(zipOut, zipfilteredOut); err != nil {
 		t.Fatal(err)
 	}
 
@@ -281,8 +294,11 @@ func repackDirTest(t *testing.T, dir string, test test) {
 	bufOut := new(bytes.Buffer)
 	zipOut := zip.NewWriter(bufOut)
 
-	seen = make(map[string]bool)
-	if err := repackDir(dir, zipOut, nil, test.filter, zip.Store); err != nil {
+	p := newPacker(test.filter, noRules)
+	if err := repackDir(dir, p); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := p.write(zipOut, nil); err != nil {
 		log.Fatal(err)
 	}
 
@@ -319,7 +335,11 @@ func createZip(w *zip.Writer, testDatas []testData) {
 		if err != nil {
 			log.Fatal(err)
 		}
-		_, err = f.Write([]byte{42})
+		content := []byte{42}
+		if testData.content != "" {
+			content = []byte(testData.content)
+		}
+		_, err = f.Write(content)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -337,3 +357,77 @@ func createDir(base string, testDatas []testData) {
 		}
 	}
 }
+
+func TestRepackZipAligned(t *testing.T) {
+	bufIn := new(bytes.Buffer)
+	zipIn := zip.NewWriter(bufIn)
+	createZip(zipIn, []testData{
+		{name: "AndroidManifest.xml"},
+		{name: "lib/arm64-v8a/liba.so"},
+		{name: "lib/arm64-v8a/libb.so"},
+		{name: "res/raw/data.bin"},
+	})
+	if err := zipIn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	in, err := zip.NewReader(bytes.NewReader(bufIn.Bytes()), int64(bufIn.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	align = true
+	defer func() { align = false }()
+	bufOut := new(bytes.Buffer)
+	zipOut := newWriter(bufOut)
+	p := newPacker(filterNone, noRules)
+	if err := repackZip(in, "in.zip", p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.write(zipOut, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := zipOut.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(bufOut.Bytes()), int64(bufOut.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != 4 {
+		t.Fatalf("Output file number differ, got: %v wanted: 4", len(r.File))
+	}
+	for _, f := range r.File {
+		alignment := int64(4)
+		if strings.HasSuffix(f.Name, ".so") {
+			alignment = 16384
+		}
+		off, err := f.DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if off%alignment != 0 {
+			t.Errorf("%s: data offset %d is not aligned to %d", f.Name, off, alignment)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "report.txt")
+	collisions := []jarmerge.Collision{
+		{Name: "res/a.txt", Policy: jarmerge.First, Origins: []string{"a.jar", "b.jar"}},
+		{Name: "META-INF/MANIFEST.MF", Policy: jarmerge.Merge, Origins: []string{"a.jar", "b.jar"}},
+	}
+	if err := writeReport(report, collisions); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "META-INF/MANIFEST.MF: merge of a.jar, b.jar (not a java resource: excluded directory meta-inf)\n" +
+		"res/a.txt: first of a.jar, b.jar\n"
+	if string(got) != want {
+		t.Errorf("writeReport() got: %q want: %q", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repack
+
+import (
+	"archive/zip"
+	"fmt"
+	"slices"
+	"strings"
+
+	"src/tools/ak/jarmerge"
+)
+
+var methods = map[string]uint16{"store": zip.Store, "deflate": zip.Deflate}
+
+// rules select, rename and configure the entries of the output.
+type rules struct {
+	// include, if set, are the patterns at least one of which an entry must match to be kept.
+	include []*jarmerge.Pattern
+	// exclude are the patterns of the entries to filter.
+	exclude []*jarmerge.Pattern
+	// stripPrefixes are removed from the start of the names, the first matching one only.
+	stripPrefixes []string
+	// addPrefix is prepended to the names, after stripping.
+	addPrefix string
+	// methods are the compression methods of the entries, by renamed name.
+	methods []jarmerge.Rule
+	// method is the compression method of the entries no method rule matches.
+	method uint16
+	// policy returns the policy of entries found more than once, by renamed name.
+	policy func(name string) string
+}
+
+func newRules(include, exclude, stripPrefixes []string, addPrefix string, methodRules []string, method uint16, duplicateRules []string, duplicate string) (*rules, error) {
+	r := &rules{stripPrefixes: stripPrefixes, addPrefix: addPrefix, method: method}
+	var err error
+	if r.include, err = jarmerge.ParsePatterns(include); err != nil {
+		return nil, err
+	}
+	if r.exclude, err = jarmerge.ParsePatterns(exclude); err != nil {
+		return nil, err
+	}
+	if r.methods, err = jarmerge.ParseRules(methodRules, sortedKeys(methods)); err != nil {
+		return nil, fmt.Errorf("invalid -method: %v", err)
+	}
+	if r.policy, err = jarmerge.DuplicatePolicy(duplicateRules, duplicate); err != nil {
+		return nil, fmt.Errorf("invalid -duplicate_rule or -duplicates: %v", err)
+	}
+	return r, nil
+}
+
+// filtered reports whether the entry with the original name goes to the filtered output.
+func (r *rules) filtered(name string) bool {
+	if len(r.include) > 0 && jarmerge.FirstMatch(r.include, name) == nil {
+		return true
+	}
+	return jarmerge.FirstMatch(r.exclude, name) != nil
+}
+
+// rename returns the name of the entry in the outputs, empty if nothing is left of it.
+func (r *rules) rename(name string) string {
+	for _, p := range r.stripPrefixes {
+		if s, ok := strings.CutPrefix(name, p); ok {
+			if s == "" {
+				return ""
+			}
+			name = s
+			break
+		}
+	}
+	return r.addPrefix + name
+}
+
+func (r *rules) methodOf(name string) uint16 {
+	if m := jarmerge.Lookup(r.methods, name, ""); m != "" {
+		return methods[m]
+	}
+	return r.method
+}
+
+func (r *rules) policyOf(name string) string {
+	return r.policy(name)
+}
+
+func sortedKeys(m map[string]uint16) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,227 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repack
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"src/tools/ak/jarmerge"
+)
+
+func TestRules(t *testing.T) {
+	r, err := newRules(
+		[]string{"**.class", "META-INF/**"},
+		[]string{"**/R.class"},
+		[]string{"classes/", "META-INF/"},
+		"lib/",
+		[]string{"lib/**.class:deflate"},
+		zip.Store,
+		[]string{"lib/services/**:merge"},
+		jarmerge.First)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"com/A.class":       false,
+		"com/R.class":       true,
+		"META-INF/services": false,
+		"res/a.xml":         true,
+	} {
+		if got := r.filtered(name); got != want {
+			t.Errorf("filtered(%q) got: %v want: %v", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{
+		"classes/com/A.class":     "lib/com/A.class",
+		"META-INF/services/a.B":   "lib/services/a.B",
+		"com/META-INF/a":          "lib/com/META-INF/a",
+		"classes/":                "",
+		"classes/classes/A.class": "lib/classes/A.class",
+	} {
+		if got := r.rename(name); got != want {
+			t.Errorf("rename(%q) got: %q want: %q", name, got, want)
+		}
+	}
+	if got := r.methodOf("lib/com/A.class"); got != zip.Deflate {
+		t.Errorf("methodOf(lib/com/A.class) got: %d want: %d", got, zip.Deflate)
+	}
+	if got := r.methodOf("lib/a.txt"); got != zip.Store {
+		t.Errorf("methodOf(lib/a.txt) got: %d want: %d", got, zip.Store)
+	}
+	if got := r.policyOf("lib/services/a.B"); got != jarmerge.Merge {
+		t.Errorf("policyOf(lib/services/a.B) got: %q want: %q", got, jarmerge.Merge)
+	}
+	if got := r.policyOf("lib/a.txt"); got != jarmerge.First {
+		t.Errorf("policyOf(lib/a.txt) got: %q want: %q", got, jarmerge.First)
+	}
+}
+
+func TestNewRulesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		methods    []string
+		duplicates []string
+		duplicate  string
+	}{
+		{name: "invalid_method", methods: []string{"*.so:zstd"}, duplicate: jarmerge.First},
+		{name: "missing_method", methods: []string{"*.so"}, duplicate: jarmerge.First},
+		{name: "invalid_duplicate_rule", duplicates: []string{"*:keep"}, duplicate: jarmerge.First},
+		{name: "invalid_duplicates", duplicate: "keep"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newRules(nil, nil, nil, "", tc.methods, zip.Store, tc.duplicates, tc.duplicate); err == nil {
+				t.Error("newRules succeeded, want an error")
+			}
+		})
+	}
+}
+
+func readerOf(t *testing.T, testDatas []testData) *zip.Reader {
+	t.Helper()
+	b := new(bytes.Buffer)
+	w := zip.NewWriter(b)
+	createZip(w, testDatas)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func contents(t *testing.T, b *bytes.Buffer) map[string]string {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, f := range r.File {
+		if _, ok := got[f.Name]; ok {
+			t.Errorf("duplicate entry %s", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[f.Name] = string(data)
+	}
+	return got
+}
+
+func TestDuplicates(t *testing.T) {
+	a := readerOf(t, []testData{{name: "a.txt", content: "a1"}, {name: "META-INF/services/x.Y", content: "com.A"}, {name: "R.txt", content: "r1"}})
+	b := readerOf(t, []testData{{name: "a.txt", content: "a2"}, {name: "META-INF/services/x.Y", content: "com.B\n"}, {name: "R.txt", content: "r2"}})
+	c := readerOf(t, []testData{{name: "META-INF/services/x.Y", content: "com.C"}})
+	tests := []struct {
+		name         string
+		rules        []string
+		duplicate    string
+		want         map[string]string
+		wantFiltered map[string]string
+		wantErr      bool
+	}{
+		{
+			name:         "first",
+			duplicate:    jarmerge.First,
+			want:         map[string]string{"a.txt": "a1", "META-INF/services/x.Y": "com.A\ncom.B\ncom.C\n"},
+			wantFiltered: map[string]string{"R.txt": "r1"},
+		},
+		{
+			name:         "last",
+			duplicate:    jarmerge.Last,
+			want:         map[string]string{"a.txt": "a2", "META-INF/services/x.Y": "com.A\ncom.B\ncom.C\n"},
+			wantFiltered: map[string]string{"R.txt": "r2"},
+		},
+		{
+			name:         "first_services",
+			rules:        []string{"META-INF/services/**:first"},
+			duplicate:    jarmerge.Merge,
+			want:         map[string]string{"a.txt": "a1\na2", "META-INF/services/x.Y": "com.A"},
+			wantFiltered: map[string]string{"R.txt": "r1\nr2"},
+		},
+		{
+			name:         "drop",
+			duplicate:    jarmerge.Drop,
+			want:         map[string]string{"META-INF/services/x.Y": "com.A\ncom.B\ncom.C\n"},
+			wantFiltered: map[string]string{},
+		},
+		{
+			name:      "error",
+			duplicate: jarmerge.Error,
+			wantErr:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newRules(nil, []string{"R.txt"}, nil, "", nil, zip.Store, tc.rules, tc.duplicate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := newPacker(filterNone, r)
+			for i, in := range []*zip.Reader{a, b, c} {
+				if err = repackZip(in, []string{"a.zip", "b.zip", "c.zip"}[i], p); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("repackZip got err: %v, want err: %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if !strings.Contains(err.Error(), "a.txt") {
+					t.Errorf("repackZip got err: %v, want the duplicate a.txt", err)
+				}
+				return
+			}
+			out, filtered := new(bytes.Buffer), new(bytes.Buffer)
+			zw, fzw := zip.NewWriter(out), zip.NewWriter(filtered)
+			if _, err := p.write(zw, fzw); err != nil {
+				t.Fatal(err)
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := fzw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			assertContents(t, contents(t, out), tc.want)
+			assertContents(t, contents(t, filtered), tc.wantFiltered)
+		})
+	}
+}
+
+func assertContents(t *testing.T, got, want map[string]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("got %d entries %v, want %d %v", len(got), got, len(want), want)
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s got: %q want: %q", name, got[name], w)
+		}
+	}
+}
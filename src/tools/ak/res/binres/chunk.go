@@ -0,0 +1,355 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package binres reads and writes the binary resource formats found in linked APKs: the resource
+// table (resources.arsc) and compiled XML files.
+package binres
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Chunk types, see ResourceTypes.h of the Android framework.
+const (
+	chunkStringPool        = 0x0001
+	chunkTable             = 0x0002
+	chunkXML               = 0x0003
+	chunkXMLStartNamespace = 0x0100
+	chunkXMLEndNamespace   = 0x0101
+	chunkXMLStartElement   = 0x0102
+	chunkXMLEndElement     = 0x0103
+	chunkXMLCData          = 0x0104
+	chunkXMLResourceMap    = 0x0180
+	chunkTablePackage      = 0x0200
+	chunkTableType         = 0x0201
+	chunkTableTypeSpec     = 0x0202
+
+	chunkHeaderSize = 8
+	noIndex         = 0xffffffff
+)
+
+var le = binary.LittleEndian
+
+// chunk is a ResChunk_header and the data following it.
+type chunk struct {
+	typ        uint16
+	headerSize int
+	// data holds the whole chunk, including its header.
+	data []byte
+}
+
+// header returns the type specific part of the chunk header.
+func (c chunk) header() []byte {
+	return c.data[chunkHeaderSize:c.headerSize]
+}
+
+func (c chunk) body() []byte {
+	return c.data[c.headerSize:]
+}
+
+// readChunk returns the chunk at the start of b.
+func readChunk(b []byte) (chunk, error) {
+	if len(b) < chunkHeaderSize {
+		return chunk{}, fmt.Errorf("truncated chunk header: %d bytes", len(b))
+	}
+	typ, headerSize, size := le.Uint16(b), int(le.Uint16(b[2:])), le.Uint32(b[4:])
+	if headerSize < chunkHeaderSize || uint32(headerSize) > size || uint64(size) > uint64(len(b)) {
+		return chunk{}, fmt.Errorf("chunk 0x%04x: invalid header size %d or size %d, %d bytes available", typ, headerSize, size, len(b))
+	}
+	return chunk{typ: typ, headerSize: headerSize, data: b[:size]}, nil
+}
+
+// readChunks splits b into consecutive chunks.
+func readChunks(b []byte) ([]chunk, error) {
+	var cs []chunk
+	for len(b) > 0 {
+		c, err := readChunk(b)
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, c)
+		b = b[len(c.data):]
+	}
+	return cs, nil
+}
+
+// readSizedChunk reads the chunk at the start of b, requiring it to be of type typ and to have
+// a header of at least minHeader bytes.
+func readSizedChunk(b []byte, typ uint16, minHeader int) (chunk, error) {
+	c, err := readChunk(b)
+	if err != nil {
+		return chunk{}, err
+	}
+	if c.typ != typ {
+		return chunk{}, fmt.Errorf("got chunk type 0x%04x, want 0x%04x", c.typ, typ)
+	}
+	if c.headerSize < minHeader {
+		return chunk{}, fmt.Errorf("chunk 0x%04x: header of %d bytes, want at least %d", typ, c.headerSize, minHeader)
+	}
+	return c, nil
+}
+
+// appendChunk appends a chunk made of the given type specific header and body to dst.
+func appendChunk(dst []byte, typ uint16, header, body []byte) []byte {
+	headerSize := chunkHeaderSize + len(header)
+	dst = le.AppendUint16(dst, typ)
+	dst = le.AppendUint16(dst, uint16(headerSize))
+	dst = le.AppendUint32(dst, uint32(headerSize+len(body)))
+	dst = append(dst, header...)
+	return append(dst, body...)
+}
+
+func pad4(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// Value types of a Value.
+const (
+	TypeNull             uint8 = 0x00
+	TypeReference        uint8 = 0x01
+	TypeAttribute        uint8 = 0x02
+	TypeString           uint8 = 0x03
+	TypeFloat            uint8 = 0x04
+	TypeDimension        uint8 = 0x05
+	TypeFraction         uint8 = 0x06
+	TypeDynamicReference uint8 = 0x07
+	TypeDynamicAttribute uint8 = 0x08
+	TypeIntDec           uint8 = 0x10
+	TypeIntHex           uint8 = 0x11
+	TypeIntBoolean       uint8 = 0x12
+	TypeIntColorARGB8    uint8 = 0x1c
+	TypeIntColorRGB8     uint8 = 0x1d
+	TypeIntColorARGB4    uint8 = 0x1e
+	TypeIntColorRGB4     uint8 = 0x1f
+
+	valueSize = 8
+)
+
+// Value is a typed resource value, the Res_value struct of the Android framework.
+type Value struct {
+	Type uint8
+	// Data is the raw value, e.g. a resource ID for references or a string pool index for strings.
+	Data uint32
+}
+
+// Ref returns the ID of the resource v refers to, either directly or as a theme attribute.
+func (v Value) Ref() (uint32, bool) {
+	switch v.Type {
+	case TypeReference, TypeAttribute, TypeDynamicReference, TypeDynamicAttribute:
+		return v.Data, v.Data != 0
+	}
+	return 0, false
+}
+
+func readValue(b []byte) (Value, error) {
+	if len(b) < valueSize {
+		return Value{}, fmt.Errorf("truncated value: %d bytes", len(b))
+	}
+	if size := le.Uint16(b); size < valueSize {
+		return Value{}, fmt.Errorf("invalid value size %d", size)
+	}
+	return Value{Type: b[3], Data: le.Uint32(b[4:])}, nil
+}
+
+func appendValue(dst []byte, v Value) []byte {
+	dst = le.AppendUint16(dst, valueSize)
+	dst = append(dst, 0, v.Type)
+	return le.AppendUint32(dst, v.Data)
+}
+
+// String pool flags.
+const (
+	poolUTF8       = 1 << 8
+	poolHeaderSize = chunkHeaderSize + 20
+)
+
+// StringPool is a ResStringPool chunk. Style spans of strings are not retained.
+type StringPool struct {
+	Strings []string
+	// UTF8 selects the UTF-8 encoding rather than UTF-16 when writing the pool.
+	UTF8 bool
+}
+
+func readStringPool(b []byte) (*StringPool, error) {
+	c, err := readSizedChunk(b, chunkStringPool, poolHeaderSize)
+	if err != nil {
+		return nil, fmt.Errorf("string pool: %v", err)
+	}
+	h := c.header()
+	count, flags, stringsStart := le.Uint32(h), le.Uint32(h[8:]), le.Uint32(h[12:])
+	if uint64(c.headerSize)+4*uint64(count) > uint64(len(c.data)) {
+		return nil, fmt.Errorf("string pool: %d string offsets do not fit in %d bytes", count, len(c.data))
+	}
+	p := &StringPool{UTF8: flags&poolUTF8 != 0, Strings: make([]string, count)}
+	offsets := c.body()
+	for i := range p.Strings {
+		start := uint64(stringsStart) + uint64(le.Uint32(offsets[4*i:]))
+		if start >= uint64(len(c.data)) {
+			return nil, fmt.Errorf("string pool: string %d starts out of bounds", i)
+		}
+		var err error
+		if p.UTF8 {
+			p.Strings[i], err = decodeUTF8(c.data[start:])
+		} else {
+			p.Strings[i], err = decodeUTF16(c.data[start:])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("string pool: string %d: %v", i, err)
+		}
+	}
+	return p, nil
+}
+
+// get returns the string at index i, or the empty string for noIndex.
+func (p *StringPool) get(i uint32) (string, error) {
+	if i == noIndex {
+		return "", nil
+	}
+	if p == nil || uint64(i) >= uint64(len(p.Strings)) {
+		return "", fmt.Errorf("string index %d out of bounds", i)
+	}
+	return p.Strings[i], nil
+}
+
+func decodeUTF8(b []byte) (string, error) {
+	// The UTF-16 length precedes the UTF-8 length, only the latter is needed.
+	_, n, err := decodeLength8(b)
+	if err != nil {
+		return "", err
+	}
+	size, m, err := decodeLength8(b[n:])
+	if err != nil {
+		return "", err
+	}
+	start := n + m
+	if start+size > len(b) {
+		return "", fmt.Errorf("truncated string of %d bytes", size)
+	}
+	return string(b[start : start+size]), nil
+}
+
+func decodeLength8(b []byte) (int, int, error) {
+	if len(b) < 1 {
+		return 0, 0, fmt.Errorf("truncated string length")
+	}
+	if b[0]&0x80 == 0 {
+		return int(b[0]), 1, nil
+	}
+	if len(b) < 2 {
+		return 0, 0, fmt.Errorf("truncated string length")
+	}
+	return int(b[0]&0x7f)<<8 | int(b[1]), 2, nil
+}
+
+func decodeUTF16(b []byte) (string, error) {
+	if len(b) < 2 {
+		return "", fmt.Errorf("truncated string length")
+	}
+	size, start := int(le.Uint16(b)), 2
+	if size&0x8000 != 0 {
+		if len(b) < 4 {
+			return "", fmt.Errorf("truncated string length")
+		}
+		size, start = (size&0x7fff)<<16|int(le.Uint16(b[2:])), 4
+	}
+	if start+2*size > len(b) {
+		return "", fmt.Errorf("truncated string of %d code units", size)
+	}
+	units := make([]uint16, size)
+	for i := range units {
+		units[i] = le.Uint16(b[start+2*i:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// appendTo appends the pool as a chunk to dst.
+func (p *StringPool) appendTo(dst []byte) ([]byte, error) {
+	var offsets, data []byte
+	for _, s := range p.Strings {
+		offsets = le.AppendUint32(offsets, uint32(len(data)))
+		units := len(utf16.Encode([]rune(s)))
+		if p.UTF8 {
+			if !utf8.ValidString(s) {
+				return nil, fmt.Errorf("string %q is not valid UTF-8", s)
+			}
+			if units > 0x7fff || len(s) > 0x7fff {
+				return nil, fmt.Errorf("string of %d bytes is too long for a UTF-8 pool", len(s))
+			}
+			data = appendLength8(data, units)
+			data = appendLength8(data, len(s))
+			data = append(data, s...)
+			data = append(data, 0)
+			continue
+		}
+		if units > 0x7fffffff {
+			return nil, fmt.Errorf("string of %d code units is too long", units)
+		}
+		if units > 0x7fff {
+			data = le.AppendUint16(data, uint16(0x8000|units>>16))
+		}
+		data = le.AppendUint16(data, uint16(units))
+		for _, u := range utf16.Encode([]rune(s)) {
+			data = le.AppendUint16(data, u)
+		}
+		data = le.AppendUint16(data, 0)
+	}
+	var flags uint32
+	if p.UTF8 {
+		flags |= poolUTF8
+	}
+	stringsStart := uint32(0)
+	if len(p.Strings) > 0 {
+		stringsStart = uint32(poolHeaderSize + len(offsets))
+	}
+	header := le.AppendUint32(nil, uint32(len(p.Strings)))
+	header = le.AppendUint32(header, 0) // styleCount
+	header = le.AppendUint32(header, flags)
+	header = le.AppendUint32(header, stringsStart)
+	header = le.AppendUint32(header, 0) // stylesStart
+	return appendChunk(dst, chunkStringPool, header, pad4(append(offsets, data...))), nil
+}
+
+func appendLength8(dst []byte, n int) []byte {
+	if n > 0x7f {
+		dst = append(dst, byte(0x80|n>>8))
+	}
+	return append(dst, byte(n))
+}
+
+// pool builds a StringPool, interning strings.
+type pool struct {
+	StringPool
+	index map[string]uint32
+}
+
+func newPool(utf8 bool) *pool {
+	return &pool{StringPool: StringPool{UTF8: utf8}, index: make(map[string]uint32)}
+}
+
+// add returns the index of s, adding it to the pool if missing.
+func (p *pool) add(s string) uint32 {
+	if i, ok := p.index[s]; ok {
+		return i
+	}
+	i := uint32(len(p.Strings))
+	p.Strings = append(p.Strings, s)
+	p.index[s] = i
+	return i
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binres
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStringPoolRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 200)
+	longer := strings.Repeat("y", 0x8001)
+	tests := []struct {
+		name string
+		pool *StringPool
+	}{
+		{name: "utf8", pool: &StringPool{UTF8: true, Strings: []string{"", "res/drawable/icon.png", "Grüße", "😀", long}}},
+		{name: "utf16", pool: &StringPool{Strings: []string{"", "app_name", "Grüße", "😀", long, longer}}},
+		{name: "empty", pool: &StringPool{UTF8: true, Strings: []string{}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.pool.appendTo(nil)
+			if err != nil {
+				t.Fatalf("appendTo() failed: %v", err)
+			}
+			if len(b)%4 != 0 {
+				t.Errorf("appendTo() wrote %d bytes, want a multiple of 4", len(b))
+			}
+			got, err := readStringPool(b)
+			if err != nil {
+				t.Fatalf("readStringPool() failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.pool, got); diff != "" {
+				t.Errorf("readStringPool(appendTo(%v)) returned diff (-want, +got):\n%v", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestStringPoolErrors(t *testing.T) {
+	if _, err := (&StringPool{UTF8: true, Strings: []string{strings.Repeat("x", 0x8000)}}).appendTo(nil); err == nil {
+		t.Error("appendTo() of an oversized UTF-8 string succeeded, want error")
+	}
+	b, err := (&StringPool{UTF8: true, Strings: []string{"abc"}}).appendTo(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{4, poolHeaderSize + 2, len(b) - 4} {
+		truncated := append([]byte(nil), b[:n]...)
+		if n >= chunkHeaderSize {
+			le.PutUint32(truncated[4:], uint32(n))
+		}
+		if _, err := readStringPool(truncated); err == nil {
+			t.Errorf("readStringPool() of %d of %d bytes succeeded, want error", n, len(b))
+		}
+	}
+}
+
+func TestValueRef(t *testing.T) {
+	tests := []struct {
+		v      Value
+		want   uint32
+		wantOK bool
+	}{
+		{v: Value{Type: TypeReference, Data: 0x7f010001}, want: 0x7f010001, wantOK: true},
+		{v: Value{Type: TypeAttribute, Data: 0x01010030}, want: 0x01010030, wantOK: true},
+		{v: Value{Type: TypeDynamicReference, Data: 0x00020001}, want: 0x00020001, wantOK: true},
+		{v: Value{Type: TypeReference}},
+		{v: Value{Type: TypeIntDec, Data: 0x7f010001}},
+	}
+	for _, tc := range tests {
+		got, ok := tc.v.Ref()
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("%v.Ref() = %x, %v, want %x, %v", tc.v, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binres
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf16"
+)
+
+// Entry flags.
+const (
+	// FlagComplex marks entries holding a map of values, e.g. styles, rather than a single value.
+	FlagComplex = 0x0001
+	// FlagPublic marks entries declared public.
+	FlagPublic = 0x0002
+	// FlagWeak marks entries which may be overridden by entries of the same name.
+	FlagWeak = 0x0004
+	// flagCompact marks the compact encoding of simple entries, which Entry does not retain.
+	flagCompact = 0x0008
+
+	// Type chunk flags.
+	typeSparse   = 0x01
+	typeOffset16 = 0x02
+
+	packageNameUnits       = 128
+	packageHeaderSize      = chunkHeaderSize + 4 + 2*packageNameUnits + 5*4
+	packageHeaderSizeNoOff = packageHeaderSize - 4
+	typeSpecHeaderSize     = chunkHeaderSize + 8
+	typeHeaderSize         = chunkHeaderSize + 12
+	defaultConfigSize      = 64
+	entryHeaderSize        = 8
+	mapEntryHeaderSize     = 16
+	mapSize                = 4 + valueSize
+)
+
+// Table is a resource table, as found in the resources.arsc file of an APK.
+type Table struct {
+	// Strings is the global string pool, which values of type TypeString index.
+	Strings  *StringPool
+	Packages []*Package
+}
+
+// Package holds the resources of one package ID of a table.
+type Package struct {
+	ID   uint8
+	Name string
+	// Types is sorted by type ID.
+	Types []*Type
+}
+
+// Type holds the entries of one resource type of a package, e.g. drawable.
+type Type struct {
+	ID   uint8
+	Name string
+	// Specs holds the configuration change flags of each entry, indexed by entry ID.
+	Specs   []uint32
+	Configs []*Config
+}
+
+// Config holds the entries of a type in one configuration, e.g. for night mode.
+type Config struct {
+	// Raw is the ResTable_config struct, whose first word holds its size. An empty Raw stands for
+	// the default configuration.
+	Raw []byte
+	// Entries is indexed by entry ID and holds nil for entries missing from the configuration.
+	Entries []*Entry
+}
+
+// Entry is the value of a resource in one configuration.
+type Entry struct {
+	Key   string
+	Flags uint16
+	// Value is the value of a simple entry.
+	Value Value
+	// Parent and Map are the parent style and values of a complex entry.
+	Parent uint32
+	Map    []MapEntry
+}
+
+// Complex reports whether e holds a map of values rather than a single value.
+func (e *Entry) Complex() bool {
+	return e.Flags&FlagComplex != 0
+}
+
+// MapEntry is a value of a complex entry, keyed by the attribute resource ID Name.
+type MapEntry struct {
+	Name  uint32
+	Value Value
+}
+
+// ID returns the resource ID of the entry entry of type t of package p.
+func ID(p *Package, t *Type, entry int) uint32 {
+	return uint32(p.ID)<<24 | uint32(t.ID)<<16 | uint32(entry)
+}
+
+// Resource is a resource of a table along with its entries in all configurations.
+type Resource struct {
+	ID   uint32
+	Type string
+	Name string
+	// Entries holds the entry of the resource in each configuration defining it.
+	Entries []*Entry
+}
+
+// Resources returns the resources of the table, sorted by ID.
+func (t *Table) Resources() []*Resource {
+	var rs []*Resource
+	for _, p := range t.Packages {
+		for _, typ := range p.Types {
+			n := len(typ.Specs)
+			for _, c := range typ.Configs {
+				n = max(n, len(c.Entries))
+			}
+			for i := 0; i < n; i++ {
+				r := &Resource{ID: ID(p, typ, i), Type: typ.Name}
+				for _, c := range typ.Configs {
+					if i < len(c.Entries) && c.Entries[i] != nil {
+						r.Entries = append(r.Entries, c.Entries[i])
+					}
+				}
+				if len(r.Entries) > 0 {
+					r.Name = r.Entries[0].Key
+					rs = append(rs, r)
+				}
+			}
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].ID < rs[j].ID })
+	return rs
+}
+
+// String returns the string of a TypeString value.
+func (t *Table) String(v Value) (string, bool) {
+	if v.Type != TypeString || t.Strings == nil || uint64(v.Data) >= uint64(len(t.Strings.Strings)) {
+		return "", false
+	}
+	return t.Strings.Strings[v.Data], true
+}
+
+// ReadTable parses a resource table.
+func ReadTable(b []byte) (*Table, error) {
+	c, err := readSizedChunk(b, chunkTable, chunkHeaderSize+4)
+	if err != nil {
+		return nil, fmt.Errorf("resource table: %v", err)
+	}
+	cs, err := readChunks(c.body())
+	if err != nil {
+		return nil, fmt.Errorf("resource table: %v", err)
+	}
+	t := &Table{}
+	for _, c := range cs {
+		switch c.typ {
+		case chunkStringPool:
+			if t.Strings != nil {
+				return nil, fmt.Errorf("resource table: more than one global string pool")
+			}
+			if t.Strings, err = readStringPool(c.data); err != nil {
+				return nil, fmt.Errorf("resource table: %v", err)
+			}
+		case chunkTablePackage:
+			p, err := readPackage(c)
+			if err != nil {
+				return nil, fmt.Errorf("resource table: %v", err)
+			}
+			t.Packages = append(t.Packages, p)
+		}
+	}
+	return t, nil
+}
+
+func readPackage(c chunk) (*Package, error) {
+	if c.headerSize < packageHeaderSizeNoOff {
+		return nil, fmt.Errorf("package header of %d bytes, want at least %d", c.headerSize, packageHeaderSizeNoOff)
+	}
+	h := c.header()
+	p := &Package{ID: uint8(le.Uint32(h))}
+	var name []uint16
+	for i := 0; i < packageNameUnits; i++ {
+		u := le.Uint16(h[4+2*i:])
+		if u == 0 {
+			break
+		}
+		name = append(name, u)
+	}
+	p.Name = string(utf16.Decode(name))
+	h = h[4+2*packageNameUnits:]
+	typeStrings, keyStrings := le.Uint32(h), le.Uint32(h[8:])
+	var typeIDOffset uint32
+	if c.headerSize >= packageHeaderSize {
+		typeIDOffset = le.Uint32(h[16:])
+	}
+	if uint64(typeStrings) >= uint64(len(c.data)) || uint64(keyStrings) >= uint64(len(c.data)) {
+		return nil, fmt.Errorf("package %s: string pools out of bounds", p.Name)
+	}
+	typeNames, err := readStringPool(c.data[typeStrings:])
+	if err != nil {
+		return nil, fmt.Errorf("package %s: type names: %v", p.Name, err)
+	}
+	keys, err := readStringPool(c.data[keyStrings:])
+	if err != nil {
+		return nil, fmt.Errorf("package %s: keys: %v", p.Name, err)
+	}
+
+	cs, err := readChunks(c.body())
+	if err != nil {
+		return nil, fmt.Errorf("package %s: %v", p.Name, err)
+	}
+	types := make(map[uint8]*Type)
+	typeOf := func(id uint8) (*Type, error) {
+		if typ, ok := types[id]; ok {
+			return typ, nil
+		}
+		if uint32(id) <= typeIDOffset {
+			return nil, fmt.Errorf("package %s: invalid type ID %d", p.Name, id)
+		}
+		name, err := typeNames.get(uint32(id) - 1 - typeIDOffset)
+		if err != nil {
+			return nil, fmt.Errorf("package %s: type %d: %v", p.Name, id, err)
+		}
+		typ := &Type{ID: id, Name: name}
+		types[id] = typ
+		p.Types = append(p.Types, typ)
+		return typ, nil
+	}
+	for _, c := range cs {
+		switch c.typ {
+		case chunkTableTypeSpec:
+			if c.headerSize < typeSpecHeaderSize {
+				return nil, fmt.Errorf("package %s: type spec header of %d bytes", p.Name, c.headerSize)
+			}
+			h := c.header()
+			typ, err := typeOf(h[0])
+			if err != nil {
+				return nil, err
+			}
+			count := le.Uint32(h[4:])
+			if uint64(count)*4 > uint64(len(c.body())) {
+				return nil, fmt.Errorf("package %s: type %s: truncated type spec", p.Name, typ.Name)
+			}
+			typ.Specs = make([]uint32, count)
+			for i := range typ.Specs {
+				typ.Specs[i] = le.Uint32(c.body()[4*i:])
+			}
+		case chunkTableType:
+			if c.headerSize < typeHeaderSize+4 {
+				return nil, fmt.Errorf("package %s: type header of %d bytes", p.Name, c.headerSize)
+			}
+			typ, err := typeOf(c.header()[0])
+			if err != nil {
+				return nil, err
+			}
+			config, err := readConfig(c, len(typ.Specs), keys)
+			if err != nil {
+				return nil, fmt.Errorf("package %s: type %s: %v", p.Name, typ.Name, err)
+			}
+			typ.Configs = append(typ.Configs, config)
+		}
+	}
+	sort.Slice(p.Types, func(i, j int) bool { return p.Types[i].ID < p.Types[j].ID })
+	return p, nil
+}
+
+// readConfig reads a type chunk. specCount is the number of entries declared by the type spec.
+func readConfig(c chunk, specCount int, keys *StringPool) (*Config, error) {
+	h := c.header()
+	flags, count, entriesStart := h[1], int(le.Uint32(h[4:])), le.Uint32(h[8:])
+	config := &Config{Raw: append([]byte(nil), h[12:]...)}
+	if size := le.Uint32(config.Raw); uint64(size) > uint64(len(config.Raw)) {
+		return nil, fmt.Errorf("config of %d bytes does not fit its header", size)
+	}
+	config.Raw = config.Raw[:le.Uint32(config.Raw)]
+
+	offsets := c.body()
+	entrySize := 4
+	if flags&(typeSparse|typeOffset16) != 0 {
+		entrySize = 2
+		if flags&typeSparse != 0 {
+			entrySize = 4
+		}
+	}
+	if count*entrySize > len(offsets) {
+		return nil, fmt.Errorf("truncated entry offsets")
+	}
+	offset := make(map[int]uint32)
+	n := count
+	for i := 0; i < count; i++ {
+		switch {
+		case flags&typeSparse != 0:
+			idx := int(le.Uint16(offsets[4*i:]))
+			offset[idx] = uint32(le.Uint16(offsets[4*i+2:])) * 4
+			n = max(n, idx+1)
+		case flags&typeOffset16 != 0:
+			if o := le.Uint16(offsets[2*i:]); o != 0xffff {
+				offset[i] = uint32(o) * 4
+			}
+		default:
+			if o := le.Uint32(offsets[4*i:]); o != noIndex {
+				offset[i] = o
+			}
+		}
+	}
+	if flags&typeSparse != 0 {
+		n = max(n, specCount)
+	}
+	config.Entries = make([]*Entry, n)
+	for i, o := range offset {
+		start := uint64(entriesStart) + uint64(o)
+		if start >= uint64(len(c.data)) {
+			return nil, fmt.Errorf("entry %d out of bounds", i)
+		}
+		e, err := readEntry(c.data[start:], keys)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i, err)
+		}
+		config.Entries[i] = e
+	}
+	return config, nil
+}
+
+func readEntry(b []byte, keys *StringPool) (*Entry, error) {
+	if len(b) < entryHeaderSize {
+		return nil, fmt.Errorf("truncated entry")
+	}
+	size, flags := le.Uint16(b), le.Uint16(b[2:])
+	if flags&flagCompact != 0 {
+		key, err := keys.get(uint32(size))
+		if err != nil {
+			return nil, err
+		}
+		return &Entry{Key: key, Flags: flags & 0xff &^ flagCompact, Value: Value{Type: uint8(flags >> 8), Data: le.Uint32(b[4:])}}, nil
+	}
+	key, err := keys.get(le.Uint32(b[4:]))
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{Key: key, Flags: flags}
+	if int(size) > len(b) || size < entryHeaderSize {
+		return nil, fmt.Errorf("invalid entry size %d", size)
+	}
+	if !e.Complex() {
+		e.Value, err = readValue(b[size:])
+		return e, err
+	}
+	if size < mapEntryHeaderSize {
+		return nil, fmt.Errorf("invalid complex entry size %d", size)
+	}
+	e.Parent = le.Uint32(b[8:])
+	count := uint64(le.Uint32(b[12:]))
+	maps := b[size:]
+	if count*mapSize > uint64(len(maps)) {
+		return nil, fmt.Errorf("truncated map of %d values", count)
+	}
+	e.Map = make([]MapEntry, count)
+	for i := range e.Map {
+		m := maps[i*mapSize:]
+		v, err := readValue(m[4:])
+		if err != nil {
+			return nil, err
+		}
+		e.Map[i] = MapEntry{Name: le.Uint32(m), Value: v}
+	}
+	return e, nil
+}
+
+// MarshalBinary encodes the table. Types are written with dense entry offsets and without
+// type ID offset, key strings are deduplicated.
+func (t *Table) MarshalBinary() ([]byte, error) {
+	global := t.Strings
+	if global == nil {
+		global = &StringPool{UTF8: true}
+	}
+	body, err := global.appendTo(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range t.Packages {
+		if body, err = p.appendTo(body); err != nil {
+			return nil, fmt.Errorf("package %s: %v", p.Name, err)
+		}
+	}
+	return appendChunk(nil, chunkTable, le.AppendUint32(nil, uint32(len(t.Packages))), body), nil
+}
+
+func (p *Package) appendTo(dst []byte) ([]byte, error) {
+	name := utf16.Encode([]rune(p.Name))
+	if len(name) >= packageNameUnits {
+		return nil, fmt.Errorf("name longer than %d code units", packageNameUnits-1)
+	}
+	typeNames := &StringPool{UTF8: false}
+	keys := newPool(true)
+	var types []byte
+	for _, typ := range p.Types {
+		if typ.ID == 0 {
+			return nil, fmt.Errorf("type %s: invalid type ID 0", typ.Name)
+		}
+		for len(typeNames.Strings) < int(typ.ID) {
+			typeNames.Strings = append(typeNames.Strings, "")
+		}
+		typeNames.Strings[typ.ID-1] = typ.Name
+		var err error
+		if types, err = typ.appendTo(types, keys); err != nil {
+			return nil, fmt.Errorf("type %s: %v", typ.Name, err)
+		}
+	}
+	typePool, err := typeNames.appendTo(nil)
+	if err != nil {
+		return nil, err
+	}
+	keyPool, err := keys.appendTo(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	header := le.AppendUint32(nil, uint32(p.ID))
+	for i := 0; i < packageNameUnits; i++ {
+		var u uint16
+		if i < len(name) {
+			u = name[i]
+		}
+		header = le.AppendUint16(header, u)
+	}
+	header = le.AppendUint32(header, packageHeaderSize)
+	header = le.AppendUint32(header, 0) // lastPublicType
+	header = le.AppendUint32(header, uint32(packageHeaderSize+len(typePool)))
+	header = le.AppendUint32(header, 0) // lastPublicKey
+	header = le.AppendUint32(header, 0) // typeIdOffset
+	body := append(typePool, keyPool...)
+	return appendChunk(dst, chunkTablePackage, header, append(body, types...)), nil
+}
+
+func (typ *Type) appendTo(dst []byte, keys *pool) ([]byte, error) {
+	var specs []byte
+	for _, s := range typ.Specs {
+		specs = le.AppendUint32(specs, s)
+	}
+	header := []byte{typ.ID, 0}
+	header = le.AppendUint16(header, uint16(len(typ.Configs)))
+	header = le.AppendUint32(header, uint32(len(typ.Specs)))
+	dst = appendChunk(dst, chunkTableTypeSpec, header, specs)
+
+	for _, c := range typ.Configs {
+		raw := c.Raw
+		if len(raw) == 0 {
+			raw = le.AppendUint32(make([]byte, 0, defaultConfigSize), defaultConfigSize)
+			raw = raw[:defaultConfigSize]
+		}
+		if len(raw) < 4 || int(le.Uint32(raw)) != len(raw) || len(raw)%4 != 0 {
+			return nil, fmt.Errorf("invalid config of %d bytes", len(raw))
+		}
+		var offsets, entries []byte
+		for _, e := range c.Entries {
+			if e == nil {
+				offsets = le.AppendUint32(offsets, noIndex)
+				continue
+			}
+			offsets = le.AppendUint32(offsets, uint32(len(entries)))
+			entries = e.appendTo(entries, keys)
+		}
+		header := []byte{typ.ID, 0, 0, 0}
+		header = le.AppendUint32(header, uint32(len(c.Entries)))
+		header = le.AppendUint32(header, uint32(typeHeaderSize+len(raw)+len(offsets)))
+		header = append(header, raw...)
+		dst = appendChunk(dst, chunkTableType, header, append(offsets, entries...))
+	}
+	return dst, nil
+}
+
+func (e *Entry) appendTo(dst []byte, keys *pool) []byte {
+	flags := e.Flags &^ flagCompact
+	if !e.Complex() {
+		dst = le.AppendUint16(dst, entryHeaderSize)
+		dst = le.AppendUint16(dst, flags)
+		dst = le.AppendUint32(dst, keys.add(e.Key))
+		return appendValue(dst, e.Value)
+	}
+	dst = le.AppendUint16(dst, mapEntryHeaderSize)
+	dst = le.AppendUint16(dst, flags)
+	dst = le.AppendUint32(dst, keys.add(e.Key))
+	dst = le.AppendUint32(dst, e.Parent)
+	dst = le.AppendUint32(dst, uint32(len(e.Map)))
+	for _, m := range e.Map {
+		dst = le.AppendUint32(dst, m.Name)
+		dst = appendValue(dst, m.Value)
+	}
+	return dst
+}
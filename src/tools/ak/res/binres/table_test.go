@@ -0,0 +1,227 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binres
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testConfig returns a ResTable_config for the given screen density, 0 being the default.
+func testConfig(density uint16) []byte {
+	c := le.AppendUint32(nil, defaultConfigSize)
+	c = append(c, make([]byte, defaultConfigSize-4)...)
+	le.PutUint16(c[14:], density)
+	return c
+}
+
+func testTable() *Table {
+	return &Table{
+		Strings: &StringPool{UTF8: true, Strings: []string{"res/drawable/icon.png", "res/drawable-hdpi/icon.png", "Example"}},
+		Packages: []*Package{{
+			ID:   0x7f,
+			Name: "com.example",
+			Types: []*Type{
+				{
+					ID:      1,
+					Name:    "attr",
+					Specs:   []uint32{0},
+					Configs: []*Config{{Raw: testConfig(0), Entries: []*Entry{{Key: "colorAccent", Flags: FlagComplex | FlagPublic, Map: []MapEntry{{Name: 0x01000000, Value: Value{Type: TypeIntDec, Data: 0x10}}}}}}},
+				},
+				{
+					ID:    2,
+					Name:  "drawable",
+					Specs: []uint32{0x100, 0},
+					Configs: []*Config{
+						{Raw: testConfig(0), Entries: []*Entry{
+							{Key: "icon", Value: Value{Type: TypeString, Data: 0}},
+							{Key: "alias", Value: Value{Type: TypeReference, Data: 0x7f020000}},
+						}},
+						{Raw: testConfig(240), Entries: []*Entry{{Key: "icon", Value: Value{Type: TypeString, Data: 1}}, nil}},
+					},
+				},
+				{
+					ID:    4,
+					Name:  "style",
+					Specs: []uint32{0},
+					Configs: []*Config{{Raw: testConfig(0), Entries: []*Entry{{
+						Key:    "Theme.App",
+						Flags:  FlagComplex,
+						Parent: 0x01030005,
+						Map:    []MapEntry{{Name: 0x7f010000, Value: Value{Type: TypeReference, Data: 0x7f020001}}},
+					}}}},
+				},
+			},
+		}},
+	}
+}
+
+func TestTableRoundTrip(t *testing.T) {
+	want := testTable()
+	b, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %v", err)
+	}
+	got, err := ReadTable(b)
+	if err != nil {
+		t.Fatalf("ReadTable() failed: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadTable(MarshalBinary()) returned diff (-want, +got):\n%v", diff)
+	}
+	again, err := got.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %v", err)
+	}
+	if string(b) != string(again) {
+		t.Error("MarshalBinary() is not stable across a round trip")
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	table := &Table{Packages: []*Package{{ID: 0x7f, Types: []*Type{{ID: 1, Name: "id", Specs: []uint32{0}, Configs: []*Config{{Entries: []*Entry{{Key: "a"}}}}}}}}}
+	b, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %v", err)
+	}
+	got, err := ReadTable(b)
+	if err != nil {
+		t.Fatalf("ReadTable() failed: %v", err)
+	}
+	if diff := cmp.Diff(testConfig(0), got.Packages[0].Types[0].Configs[0].Raw); diff != "" {
+		t.Errorf("default config returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestResources(t *testing.T) {
+	table := testTable()
+	var got []string
+	for _, r := range table.Resources() {
+		var values []string
+		for _, e := range r.Entries {
+			if s, ok := table.String(e.Value); ok {
+				values = append(values, s)
+			}
+		}
+		got = append(got, strings.TrimSpace(strings.Join(append([]string{r.Type + "/" + r.Name}, values...), " ")))
+		if want := ID(table.Packages[0], findType(table, r.Type), int(r.ID&0xffff)); r.ID != want {
+			t.Errorf("%s/%s has ID 0x%08x, want 0x%08x", r.Type, r.Name, r.ID, want)
+		}
+	}
+	want := []string{
+		"attr/colorAccent",
+		"drawable/icon res/drawable/icon.png res/drawable-hdpi/icon.png",
+		"drawable/alias",
+		"style/Theme.App",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Resources() returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func findType(table *Table, name string) *Type {
+	for _, typ := range table.Packages[0].Types {
+		if typ.Name == name {
+			return typ
+		}
+	}
+	return nil
+}
+
+// typeChunk builds a type chunk with the given flags, offsets and entry data by hand, to cover
+// the encodings MarshalBinary does not produce.
+func typeChunk(flags uint8, count int, offsets, entries []byte) chunk {
+	raw := testConfig(0)
+	header := []byte{2, flags, 0, 0}
+	header = le.AppendUint32(header, uint32(count))
+	header = le.AppendUint32(header, uint32(typeHeaderSize+len(raw)+len(offsets)))
+	header = append(header, raw...)
+	c, err := readChunk(appendChunk(nil, chunkTableType, header, append(offsets, entries...)))
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestReadConfigEncodings(t *testing.T) {
+	keys := &StringPool{Strings: []string{"a", "b"}}
+	simple := func(key uint32, data uint32) []byte {
+		b := le.AppendUint16(nil, entryHeaderSize)
+		b = le.AppendUint16(b, 0)
+		b = le.AppendUint32(b, key)
+		return appendValue(b, Value{Type: TypeIntDec, Data: data})
+	}
+	compact := le.AppendUint16(nil, 1)                                    // key index
+	compact = le.AppendUint16(compact, uint16(TypeIntHex)<<8|flagCompact) // type and flags
+	compact = le.AppendUint32(compact, 7)
+	entries := append(simple(0, 5), compact...)
+
+	a := &Entry{Key: "a", Value: Value{Type: TypeIntDec, Data: 5}}
+	b := &Entry{Key: "b", Value: Value{Type: TypeIntHex, Data: 7}}
+	tests := []struct {
+		name      string
+		chunk     chunk
+		specCount int
+		want      []*Entry
+	}{
+		{
+			name:      "sparse",
+			chunk:     typeChunk(typeSparse, 2, []byte{1, 0, 0, 0, 3, 0, 4, 0}, entries),
+			specCount: 5,
+			want:      []*Entry{nil, a, nil, b, nil},
+		},
+		{
+			name:  "offset16",
+			chunk: typeChunk(typeOffset16, 3, []byte{4, 0, 0xff, 0xff, 0, 0, 0, 0}, entries),
+			want:  []*Entry{b, nil, a},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readConfig(tc.chunk, tc.specCount, keys)
+			if err != nil {
+				t.Fatalf("readConfig() failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got.Entries); diff != "" {
+				t.Errorf("readConfig() returned diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestReadTableErrors(t *testing.T) {
+	b, err := testTable().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		input   []byte
+		wantErr string
+	}{
+		{name: "not a table", input: []byte{3, 0, 8, 0, 8, 0, 0, 0}, wantErr: "got chunk type 0x0003"},
+		{name: "truncated", input: b[:len(b)-1], wantErr: "invalid header size"},
+		{name: "empty", input: nil, wantErr: "truncated chunk header"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ReadTable(tc.input); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("ReadTable() returned error %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
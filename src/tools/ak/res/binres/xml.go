@@ -0,0 +1,323 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binres
+
+import "fmt"
+
+const (
+	xmlNodeHeaderSize = chunkHeaderSize + 8
+	attrExtSize       = 20
+	attrSize          = 12 + valueSize
+)
+
+// Element is an element of a compiled XML file.
+type Element struct {
+	// NS is the namespace URI of the element, empty if unqualified.
+	NS   string
+	Name string
+	// Namespaces holds the namespace declarations in scope from this element on.
+	Namespaces []Namespace
+	Attrs      []*Attr
+	Children   []*Element
+	// Text holds the character data directly inside the element.
+	Text string
+	// Line is the line number of the element in the source file.
+	Line uint32
+}
+
+// Namespace is a namespace declaration.
+type Namespace struct {
+	Prefix string
+	URI    string
+}
+
+// Attr is an attribute of an element.
+type Attr struct {
+	NS   string
+	Name string
+	// ResID is the ID of the attribute resource, e.g. 0x01010003 for android:name, or 0 for
+	// attributes without one.
+	ResID uint32
+	// Raw is the original string value, which aapt2 only keeps for string values. The Data of a
+	// TypeString Value is ignored, Raw holds the string instead.
+	Raw   string
+	Value Value
+}
+
+// Walk calls f for e and each of its descendants, parents first.
+func (e *Element) Walk(f func(*Element)) {
+	f(e)
+	for _, c := range e.Children {
+		c.Walk(f)
+	}
+}
+
+// IsXML reports whether b starts like a compiled XML file, as opposed to e.g. a raw XML file.
+func IsXML(b []byte) bool {
+	return len(b) >= chunkHeaderSize && le.Uint16(b) == chunkXML && le.Uint16(b[2:]) == chunkHeaderSize
+}
+
+// ReadXML parses a compiled XML file and returns its root element.
+func ReadXML(b []byte) (*Element, error) {
+	c, err := readSizedChunk(b, chunkXML, chunkHeaderSize)
+	if err != nil {
+		return nil, fmt.Errorf("compiled XML: %v", err)
+	}
+	cs, err := readChunks(c.body())
+	if err != nil {
+		return nil, fmt.Errorf("compiled XML: %v", err)
+	}
+	var (
+		strings *StringPool
+		resIDs  []uint32
+		root    *Element
+		stack   []*Element
+		pending []Namespace
+	)
+	str := func(i uint32) (string, error) {
+		s, err := strings.get(i)
+		if err != nil {
+			return "", fmt.Errorf("compiled XML: %v", err)
+		}
+		return s, nil
+	}
+	for _, c := range cs {
+		switch c.typ {
+		case chunkStringPool:
+			if strings, err = readStringPool(c.data); err != nil {
+				return nil, fmt.Errorf("compiled XML: %v", err)
+			}
+			continue
+		case chunkXMLResourceMap:
+			for b := c.body(); len(b) >= 4; b = b[4:] {
+				resIDs = append(resIDs, le.Uint32(b))
+			}
+			continue
+		case chunkXMLStartNamespace, chunkXMLEndNamespace, chunkXMLStartElement, chunkXMLEndElement, chunkXMLCData:
+		default:
+			continue
+		}
+		if c.headerSize < xmlNodeHeaderSize || len(c.body()) < 8 {
+			return nil, fmt.Errorf("compiled XML: truncated node 0x%04x", c.typ)
+		}
+		line, ext := le.Uint32(c.header()), c.body()
+		switch c.typ {
+		case chunkXMLStartNamespace:
+			prefix, err := str(le.Uint32(ext))
+			if err != nil {
+				return nil, err
+			}
+			uri, err := str(le.Uint32(ext[4:]))
+			if err != nil {
+				return nil, err
+			}
+			pending = append(pending, Namespace{Prefix: prefix, URI: uri})
+		case chunkXMLStartElement:
+			e, err := readElement(ext, str, resIDs)
+			if err != nil {
+				return nil, err
+			}
+			e.Line, e.Namespaces, pending = line, pending, nil
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, fmt.Errorf("compiled XML: more than one root element")
+				}
+				root = e
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, e)
+			}
+			stack = append(stack, e)
+		case chunkXMLEndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("compiled XML: unbalanced end of element on line %d", line)
+			}
+			stack = stack[:len(stack)-1]
+		case chunkXMLCData:
+			if len(stack) > 0 {
+				text, err := str(le.Uint32(ext))
+				if err != nil {
+					return nil, err
+				}
+				stack[len(stack)-1].Text += text
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("compiled XML: no root element")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("compiled XML: element %s is not closed", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+func readElement(ext []byte, str func(uint32) (string, error), resIDs []uint32) (*Element, error) {
+	if len(ext) < attrExtSize {
+		return nil, fmt.Errorf("compiled XML: truncated element")
+	}
+	ns, err := str(le.Uint32(ext))
+	if err != nil {
+		return nil, err
+	}
+	name, err := str(le.Uint32(ext[4:]))
+	if err != nil {
+		return nil, err
+	}
+	e := &Element{NS: ns, Name: name}
+	start, size, count := int(le.Uint16(ext[8:])), int(le.Uint16(ext[10:])), int(le.Uint16(ext[12:]))
+	if count > 0 && (size < attrSize || start+count*size > len(ext)) {
+		return nil, fmt.Errorf("compiled XML: element %s: truncated attributes", name)
+	}
+	for i := 0; i < count; i++ {
+		b := ext[start+i*size:]
+		a := &Attr{}
+		if a.NS, err = str(le.Uint32(b)); err != nil {
+			return nil, err
+		}
+		nameIdx := le.Uint32(b[4:])
+		if a.Name, err = str(nameIdx); err != nil {
+			return nil, err
+		}
+		if uint64(nameIdx) < uint64(len(resIDs)) {
+			a.ResID = resIDs[nameIdx]
+		}
+		if a.Raw, err = str(le.Uint32(b[8:])); err != nil {
+			return nil, err
+		}
+		if a.Value, err = readValue(b[12:]); err != nil {
+			return nil, fmt.Errorf("compiled XML: element %s: attribute %s: %v", name, a.Name, err)
+		}
+		if a.Value.Type == TypeString {
+			if a.Raw, err = str(a.Value.Data); err != nil {
+				return nil, err
+			}
+			a.Value.Data = 0
+		}
+		e.Attrs = append(e.Attrs, a)
+	}
+	return e, nil
+}
+
+// EncodeXML compiles the tree rooted at root. Attributes are written in the given order, which
+// should be sorted by resource ID for the framework to look them up.
+func EncodeXML(root *Element) ([]byte, error) {
+	// Attribute names with a resource ID come first in the string pool, in the order of the
+	// resource map.
+	type mapped struct {
+		name  string
+		resID uint32
+	}
+	mappedIdx := make(map[mapped]uint32)
+	var resIDs []uint32
+	strings := newPool(true)
+	root.Walk(func(e *Element) {
+		for _, a := range e.Attrs {
+			m := mapped{a.Name, a.ResID}
+			if _, ok := mappedIdx[m]; a.ResID != 0 && !ok {
+				mappedIdx[m] = uint32(len(strings.Strings))
+				strings.Strings = append(strings.Strings, a.Name)
+				resIDs = append(resIDs, a.ResID)
+			}
+		}
+	})
+	str := func(s string) uint32 {
+		if s == "" {
+			return noIndex
+		}
+		return strings.add(s)
+	}
+
+	var nodes []byte
+	node := func(typ uint16, line uint32, ext []byte) {
+		header := le.AppendUint32(nil, line)
+		header = le.AppendUint32(header, noIndex) // comment
+		nodes = appendChunk(nodes, typ, header, ext)
+	}
+	var encode func(e *Element)
+	encode = func(e *Element) {
+		for _, ns := range e.Namespaces {
+			ext := le.AppendUint32(nil, str(ns.Prefix))
+			node(chunkXMLStartNamespace, e.Line, le.AppendUint32(ext, str(ns.URI)))
+		}
+		ext := le.AppendUint32(nil, str(e.NS))
+		ext = le.AppendUint32(ext, str(e.Name))
+		ext = le.AppendUint16(ext, attrExtSize)
+		ext = le.AppendUint16(ext, attrSize)
+		ext = le.AppendUint16(ext, uint16(len(e.Attrs)))
+		var special [3]uint16 // 1-based indices of the id, class and style attributes
+		for i, a := range e.Attrs {
+			if a.NS != "" {
+				continue
+			}
+			switch a.Name {
+			case "id":
+				special[0] = uint16(i + 1)
+			case "class":
+				special[1] = uint16(i + 1)
+			case "style":
+				special[2] = uint16(i + 1)
+			}
+		}
+		for _, s := range special {
+			ext = le.AppendUint16(ext, s)
+		}
+		for _, a := range e.Attrs {
+			ext = le.AppendUint32(ext, str(a.NS))
+			if a.ResID != 0 {
+				ext = le.AppendUint32(ext, mappedIdx[mapped{a.Name, a.ResID}])
+			} else {
+				ext = le.AppendUint32(ext, str(a.Name))
+			}
+			v := a.Value
+			if v.Type == TypeString {
+				v.Data = strings.add(a.Raw)
+				ext = le.AppendUint32(ext, v.Data)
+			} else {
+				ext = le.AppendUint32(ext, str(a.Raw))
+			}
+			ext = appendValue(ext, v)
+		}
+		node(chunkXMLStartElement, e.Line, ext)
+		if e.Text != "" {
+			ext := le.AppendUint32(nil, strings.add(e.Text))
+			node(chunkXMLCData, e.Line, appendValue(ext, Value{}))
+		}
+		for _, c := range e.Children {
+			encode(c)
+		}
+		ext = le.AppendUint32(nil, str(e.NS))
+		node(chunkXMLEndElement, e.Line, le.AppendUint32(ext, str(e.Name)))
+		for i := len(e.Namespaces) - 1; i >= 0; i-- {
+			ns := e.Namespaces[i]
+			ext := le.AppendUint32(nil, str(ns.Prefix))
+			node(chunkXMLEndNamespace, e.Line, le.AppendUint32(ext, str(ns.URI)))
+		}
+	}
+	encode(root)
+
+	body, err := strings.appendTo(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(resIDs) > 0 {
+		var ids []byte
+		for _, id := range resIDs {
+			ids = le.AppendUint32(ids, id)
+		}
+		body = appendChunk(body, chunkXMLResourceMap, nil, ids)
+	}
+	return appendChunk(nil, chunkXML, nil, append(body, nodes...)), nil
+}
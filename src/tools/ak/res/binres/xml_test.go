@@ -0,0 +1,125 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binres
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const androidNS = "http://schemas.android.com/apk/res/android"
+
+func testManifest() *Element {
+	return &Element{
+		Name:       "manifest",
+		Namespaces: []Namespace{{Prefix: "android", URI: androidNS}},
+		Line:       2,
+		Attrs: []*Attr{
+			{NS: androidNS, Name: "versionCode", ResID: 0x0101021b, Value: Value{Type: TypeIntDec, Data: 3}},
+			{Name: "package", Raw: "com.example", Value: Value{Type: TypeString}},
+		},
+		Children: []*Element{{
+			Name: "application",
+			Line: 3,
+			Attrs: []*Attr{
+				{NS: androidNS, Name: "icon", ResID: 0x01010002, Value: Value{Type: TypeReference, Data: 0x7f020000}},
+				{NS: androidNS, Name: "label", ResID: 0x01010001, Raw: "", Value: Value{Type: TypeString}},
+				{Name: "id", Raw: "@+id/app"},
+			},
+			Children: []*Element{
+				{Name: "meta-data", Line: 4, Text: "text"},
+				// A name used both as element name and as mapped attribute name.
+				{Name: "icon", Line: 5, Attrs: []*Attr{{Name: "icon", Raw: "unmapped", Value: Value{Type: TypeString}}}},
+			},
+		}},
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	want := testManifest()
+	b, err := EncodeXML(want)
+	if err != nil {
+		t.Fatalf("EncodeXML() failed: %v", err)
+	}
+	if !IsXML(b) {
+		t.Error("IsXML(EncodeXML()) = false, want true")
+	}
+	got, err := ReadXML(b)
+	if err != nil {
+		t.Fatalf("ReadXML() failed: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadXML(EncodeXML()) returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestEncodeXMLSpecialAttributes(t *testing.T) {
+	b, err := EncodeXML(testManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs, err := readChunks(b[chunkHeaderSize:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idIndices []uint16
+	for _, c := range cs {
+		if c.typ == chunkXMLStartElement {
+			idIndices = append(idIndices, le.Uint16(c.body()[14:]))
+		}
+	}
+	if diff := cmp.Diff([]uint16{0, 3, 0, 0}, idIndices); diff != "" {
+		t.Errorf("id attribute indices returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	var got []string
+	testManifest().Walk(func(e *Element) { got = append(got, e.Name) })
+	if diff := cmp.Diff([]string{"manifest", "application", "meta-data", "icon"}, got); diff != "" {
+		t.Errorf("Walk() returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestReadXMLErrors(t *testing.T) {
+	b, err := EncodeXML(testManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Drop the end of the root element, keeping the document size consistent.
+	unclosed := append([]byte(nil), b[:len(b)-2*(xmlNodeHeaderSize+8)]...)
+	le.PutUint32(unclosed[4:], uint32(len(unclosed)))
+	tests := []struct {
+		name    string
+		input   []byte
+		wantErr string
+	}{
+		{name: "raw XML", input: []byte(`<?xml version="1.0"?><manifest/>`), wantErr: "compiled XML"},
+		{name: "no root", input: appendChunk(nil, chunkXML, nil, nil), wantErr: "no root element"},
+		{name: "unclosed", input: unclosed, wantErr: "element manifest is not closed"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if IsXML(tc.input) && tc.name == "raw XML" {
+				t.Error("IsXML() of raw XML = true, want false")
+			}
+			if _, err := ReadXML(tc.input); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("ReadXML() returned error %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
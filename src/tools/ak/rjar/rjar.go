@@ -29,6 +29,8 @@ import (
 	"sync"
 
 	"src/common/golang/ziputils"
+	"src/tools/ak/rclass/rclass"
+	"src/tools/ak/rtxt/rtxt"
 	"src/tools/ak/types"
 )
 
@@ -38,11 +40,14 @@ var (
 		Init:  Init,
 		Run:   Run,
 		Desc:  desc,
-		Flags: []string{"rjava", "pkgs", "rjar", "jdk", "jartool", "target_label", "jvm_opts"},
+		Flags: []string{"rjava", "r_txt", "direct_r_txts", "mode", "pkgs", "rjar", "jdk", "jartool", "target_label", "jvm_opts"},
 	}
 
 	// Variables to hold flag values.
 	rjava       string
+	rTxt        string
+	directRTxts string
+	mode        string
 	pkgs        string
 	rjar        string
 	jdk         string
@@ -52,66 +57,22 @@ var (
 
 	initOnce sync.Once
 
-	javaReserved = map[string]bool{
-		"abstract":     true,
-		"assert":       true,
-		"boolean":      true,
-		"break":        true,
-		"byte":         true,
-		"case":         true,
-		"catch":        true,
-		"char":         true,
-		"class":        true,
-		"const":        true,
-		"continue":     true,
-		"default":      true,
-		"do":           true,
-		"double":       true,
-		"else":         true,
-		"enum":         true,
-		"extends":      true,
-		"false":        true,
-		"final":        true,
-		"finally":      true,
-		"float":        true,
-		"for":          true,
-		"goto":         true,
-		"if":           true,
-		"implements":   true,
-		"import":       true,
-		"instanceof":   true,
-		"int":          true,
-		"interface":    true,
-		"long":         true,
-		"native":       true,
-		"new":          true,
-		"null":         true,
-		"package":      true,
-		"private":      true,
-		"protected":    true,
-		"public":       true,
-		"return":       true,
-		"short":        true,
-		"static":       true,
-		"strictfp":     true,
-		"super":        true,
-		"switch":       true,
-		"synchronized": true,
-		"this":         true,
-		"throw":        true,
-		"throws":       true,
-		"transient":    true,
-		"true":         true,
-		"try":          true,
-		"void":         true,
-		"volatile":     true,
-		"while":        true}
+	// modes maps the values of the mode flag to whether the R fields are final.
+	modes = map[string]bool{
+		// Compile time constants holding the final resource IDs, for binaries.
+		"final": true,
+		// Fields which are not inlined into the classes using them, for libraries.
+		"nonfinal": false,
+	}
 )
 
 // Init initiailizes rjar action. Must be called before google.Init.
 func Init() {
 	initOnce.Do(func() {
 		flag.StringVar(&rjava, "rjava", "", "Input R.java path")
+		flag.StringVar(&rTxt, "r_txt", "", "Input R.txt path, used instead of -rjava to create the R.jar without a Java compiler")
+		flag.StringVar(&directRTxts, "direct_r_txts", "", "(optional) Comma separated list of the R.txt files of the target and its direct dependencies. If set with -r_txt, only their resources are part of the R.jar")
+		flag.StringVar(&mode, "mode", "final", "How the R fields are generated with -r_txt, one of: final, nonfinal")
 		flag.StringVar(&pkgs, "pkgs", "", "Packages file path")
 		flag.StringVar(&rjar, "rjar", "", "Output R.jar path")
 		flag.StringVar(&jdk, "jdk", "", "Jdk path")
@@ -127,11 +88,100 @@ func desc() string {
 
 // Run is the entry point for rjar. Will exit on error.
 func Run() {
+	if rTxt != "" {
+		final, ok := modes[mode]
+		if !ok {
+			log.Fatalf("Unknown mode %q, want one of: final, nonfinal", mode)
+		}
+		var direct []string
+		if directRTxts != "" {
+			direct = strings.Split(directRTxts, ",")
+		}
+		if err := doWorkFromRTxt(rTxt, direct, pkgs, rjar, final); err != nil {
+			log.Fatalf("Error creating R.jar: %v", err)
+		}
+		return
+	}
 	if err := doWork(rjava, pkgs, rjar, jdk, jartool, targetLabel, jvmOpts); err != nil {
 		log.Fatalf("Error creating R.jar: %v", err)
 	}
 }
 
+// doWorkFromRTxt writes the R classes of the R.txt symbols directly as class files. If direct is
+// not empty, only the symbols also declared in those R.txt files are kept.
+func doWorkFromRTxt(rTxt string, direct []string, pkgs, rjar string, final bool) error {
+	if err := os.MkdirAll(filepath.Dir(rjar), 0777); err != nil {
+		return err
+	}
+	symbols, err := readSymbols(rTxt)
+	if os.IsNotExist(err) {
+		return ziputils.EmptyZip(rjar)
+	}
+	if err != nil {
+		return err
+	}
+	if len(direct) > 0 {
+		var allowed []*rtxt.Symbol
+		for _, d := range direct {
+			s, err := readSymbols(d)
+			if err != nil {
+				return err
+			}
+			allowed = append(allowed, s...)
+		}
+		symbols = rtxt.Restrict(symbols, allowed)
+	}
+	if len(symbols) == 0 {
+		return ziputils.EmptyZip(rjar)
+	}
+
+	filteredPkgs, err := getPkgs(pkgs)
+	if err != nil {
+		return err
+	}
+	classes := rClasses(symbols, filteredPkgs, final)
+
+	out, err := os.Create(rjar)
+	if err != nil {
+		return err
+	}
+	if err := rclass.WriteJar(out, classes...); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func readSymbols(rTxt string) ([]*rtxt.Symbol, error) {
+	in, err := os.Open(rTxt)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	symbols, err := rtxt.Parse(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", rTxt, err)
+	}
+	return symbols, nil
+}
+
+// rClasses returns the R classes of pkgs. Like doWork, the first valid package holds the fields
+// and the R classes of the other packages extend it.
+func rClasses(symbols []*rtxt.Symbol, pkgs []string, final bool) []*rclass.Class {
+	var classes []*rclass.Class
+	for _, pkg := range pkgs {
+		if hasInvalid(strings.Split(pkg, ".")) {
+			continue
+		}
+		if len(classes) == 0 {
+			classes = append(classes, rclass.New(pkg, symbols, final))
+		} else {
+			classes = append(classes, rclass.Extend(pkg, classes[0]))
+		}
+	}
+	return classes
+}
+
 func doWork(rjava, pkgs, rjar, jdk, jartool string, targetLabel string, jvmOpts string) error {
 	f, err := os.Stat(rjava)
 	if os.IsNotExist(err) || (err == nil && f.Size() == 0) {
@@ -303,7 +353,7 @@ func getPkgs(pkgs string) ([]string, error) {
 
 func hasInvalid(parts []string) bool {
 	for _, p := range parts {
-		if javaReserved[p] {
+		if rclass.IsReserved(p) {
 			return true
 		}
 	}
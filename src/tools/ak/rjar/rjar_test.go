@@ -22,6 +22,10 @@ import (
 	"path"
 	"path/filepath"
 	"testing"
+
+	"src/tools/ak/rclass/rclass"
+	"src/tools/ak/rtxt/rtxt"
+	"github.com/google/go-cmp/cmp"
 )
 
 var (
@@ -70,6 +74,45 @@ func TestCreateRJar(t *testing.T) {
 	}
 }
 
+func TestRClasses(t *testing.T) {
+	symbols := []*rtxt.Symbol{
+		{Type: "id", Name: "view_tree", Values: []int32{0x7f080002}},
+		{Type: "styleable", Name: "View", Array: true, Values: []int32{0x7f030001}},
+	}
+	pkgs := []string{"com.google.android.samples.skeletonapp", "com.google.android.package.test", "android.support.v7"}
+	for _, final := range []bool{true, false} {
+		want := []*rclass.Class{
+			{
+				Name:  "com/google/android/samples/skeletonapp/R",
+				Final: final,
+				Nested: []*rclass.Class{
+					{
+						Name:   "com/google/android/samples/skeletonapp/R$id",
+						Final:  final,
+						Fields: []*rclass.Field{{Name: "view_tree", Values: []int32{0x7f080002}}},
+					},
+					{
+						Name:   "com/google/android/samples/skeletonapp/R$styleable",
+						Final:  final,
+						Fields: []*rclass.Field{{Name: "View", Array: true, Values: []int32{0x7f030001}}},
+					},
+				},
+			},
+			{
+				Name:  "android/support/v7/R",
+				Super: "com/google/android/samples/skeletonapp/R",
+				Nested: []*rclass.Class{
+					{Name: "android/support/v7/R$id", Super: "com/google/android/samples/skeletonapp/R$id"},
+					{Name: "android/support/v7/R$styleable", Super: "com/google/android/samples/skeletonapp/R$styleable"},
+				},
+			},
+		}
+		if diff := cmp.Diff(want, rClasses(symbols, pkgs, final)); diff != "" {
+			t.Errorf("rClasses(%v, %v, %v) returned diff (-want, +got):\n%v", symbols, pkgs, final, diff)
+		}
+	}
+}
+
 func dataPath(fn string) string {
 	return filepath.Join(os.Getenv("TEST_SRCDIR"), testDataBase, fn)
 }
@@ -0,0 +1,404 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rtxt reads, writes and merges the R.txt symbol files produced by aapt2.
+package rtxt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Styleable is the type of the int[] symbols holding the attributes of a declare-styleable, and
+// of the int symbols holding the index of each attribute in that array.
+const Styleable = "styleable"
+
+// Symbol is a resource symbol as listed in an R.txt file.
+type Symbol struct {
+	// Type is the resource type, e.g. string or styleable.
+	Type string
+	// Name is the java field name of the symbol.
+	Name string
+	// Array is true for int[] symbols, i.e. styleables.
+	Array bool
+	// Values holds the resource ID of an int symbol, or the attribute IDs of an int[] symbol.
+	// For styleable child indices, it holds the index of the attribute in the parent array.
+	Values []int32
+	// Parent is the name of the styleable array a styleable child index points into.
+	Parent string
+}
+
+// Key identifies a symbol regardless of its value, e.g. string.app_name.
+func (s *Symbol) Key() string {
+	return s.Type + "." + s.Name
+}
+
+// Package returns the package ID of the resource, 0x7f for apps and 0x01 for the framework, or
+// 0 if the symbol has no resource ID of its own.
+func (s *Symbol) Package() uint8 {
+	if s.Array || s.Parent != "" || len(s.Values) == 0 {
+		return 0
+	}
+	return uint8(uint32(s.Values[0]) >> 24)
+}
+
+func (s *Symbol) String() string {
+	var b strings.Builder
+	if s.Array {
+		fmt.Fprintf(&b, "int[] %s %s {", s.Type, s.Name)
+		for i, v := range s.Values {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, " 0x%08x", uint32(v))
+		}
+		b.WriteString(" }")
+		return b.String()
+	}
+	var v int32
+	if len(s.Values) > 0 {
+		v = s.Values[0]
+	}
+	if s.Parent != "" {
+		return fmt.Sprintf("int %s %s %d", s.Type, s.Name, v)
+	}
+	return fmt.Sprintf("int %s %s 0x%08x", s.Type, s.Name, uint32(v))
+}
+
+func (s *Symbol) equal(o *Symbol) bool {
+	if s.Array != o.Array || s.Parent != o.Parent || len(s.Values) != len(o.Values) {
+		return false
+	}
+	for i, v := range s.Values {
+		if v != o.Values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// unassigned reports whether the symbol only holds placeholder IDs, as in the R.txt of libraries
+// whose resources are not final yet.
+func (s *Symbol) unassigned() bool {
+	if s.Parent != "" {
+		return false
+	}
+	for _, v := range s.Values {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Read parses and validates the symbols of an R.txt file.
+//
+// Each line is in the following format:
+// [int|int[]] resType resID value
+// Ex: int anim abc_fade_in 0x7f010000
+// Ex: int[] styleable ActionBar { 0x7f030031, 0x7f030032 }
+// Ex: int styleable ActionBar_background 0
+func Read(r io.Reader) ([]*Symbol, error) {
+	return read(r, true)
+}
+
+// Parse parses the symbols of an R.txt file like Read, but only checks the syntax of each line.
+// Resource IDs are not validated, int[] values may omit their braces and styleable child indices
+// without a parent styleable are kept, with an empty Parent, as older tools accept such files.
+func Parse(r io.Reader) ([]*Symbol, error) {
+	return read(r, false)
+}
+
+func read(r io.Reader, strict bool) ([]*Symbol, error) {
+	var symbols []*Symbol
+	lines := make(map[*Symbol]int)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		s, err := parseLine(line, strict)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		symbols = append(symbols, s)
+		lines[s] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !strict {
+		return symbols, nil
+	}
+	if err := linkStyleables(symbols); err != nil {
+		return nil, fmt.Errorf("line %d: %v", lines[err.symbol], err.msg)
+	}
+	return symbols, nil
+}
+
+// ReadFile parses and validates the symbols of the R.txt file at path.
+func ReadFile(path string) ([]*Symbol, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	symbols, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return symbols, nil
+}
+
+func parseLine(line string, strict bool) (*Symbol, error) {
+	parts := strings.SplitN(line, " ", 4)
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("malformed R.txt entry %q", line)
+	}
+	s := &Symbol{Type: parts[1], Name: parts[2]}
+	if s.Type == "" || s.Name == "" {
+		return nil, fmt.Errorf("malformed R.txt entry %q", line)
+	}
+	switch parts[0] {
+	case "int":
+		v, err := parseValue(parts[3])
+		if err != nil {
+			return nil, err
+		}
+		// Styleable child indices are checked once their parent is known.
+		if strict && s.Type != Styleable {
+			if err := validateID(v); err != nil {
+				return nil, fmt.Errorf("%s: %v", s.Key(), err)
+			}
+		}
+		s.Values = []int32{v}
+	case "int[]":
+		s.Array = true
+		elems := strings.TrimSpace(parts[3])
+		if strict && (!strings.HasPrefix(elems, "{") || !strings.HasSuffix(elems, "}")) {
+			return nil, fmt.Errorf("%s: int[] value %q is not enclosed in braces", s.Key(), elems)
+		}
+		elems = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(elems, "{"), "}"))
+		if elems == "" {
+			break
+		}
+		for _, e := range strings.Split(elems, ",") {
+			v, err := parseValue(strings.TrimSpace(e))
+			if err != nil {
+				return nil, err
+			}
+			if strict {
+				if err := validateID(v); err != nil {
+					return nil, fmt.Errorf("%s: %v", s.Key(), err)
+				}
+			}
+			s.Values = append(s.Values, v)
+		}
+	default:
+		return nil, fmt.Errorf("unknown symbol kind %q", parts[0])
+	}
+	return s, nil
+}
+
+func parseValue(s string) (int32, error) {
+	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil || v < -1<<31 || v > 1<<32-1 {
+		return 0, fmt.Errorf("invalid resource value %q", s)
+	}
+	// Resource IDs are unsigned 32 bit values but are stored in java int fields.
+	return int32(uint32(v)), nil
+}
+
+// validateID checks that v is a resource ID in the 0xPPTTEEEE format, with non zero package and
+// type IDs, or the 0 placeholder used before IDs are assigned.
+func validateID(v int32) error {
+	id := uint32(v)
+	if id != 0 && (id>>24 == 0 || (id>>16)&0xff == 0) {
+		return fmt.Errorf("invalid resource ID 0x%08x", id)
+	}
+	return nil
+}
+
+type styleableError struct {
+	symbol *Symbol
+	msg    string
+}
+
+// linkStyleables sets the parent of every styleable child index and checks that the index is
+// within the bounds of the parent array.
+func linkStyleables(symbols []*Symbol) *styleableError {
+	parents := make(map[string]*Symbol)
+	for _, s := range symbols {
+		if s.Type == Styleable && s.Array {
+			parents[s.Name] = s
+		}
+	}
+	for _, s := range symbols {
+		if s.Type != Styleable || s.Array {
+			continue
+		}
+		// Styleable names can contain '_' themselves, the longest matching parent wins.
+		var parent *Symbol
+		for i := strings.LastIndex(s.Name, "_"); i > 0; i = strings.LastIndex(s.Name[:i], "_") {
+			if p, ok := parents[s.Name[:i]]; ok {
+				parent = p
+				break
+			}
+		}
+		if parent == nil {
+			return &styleableError{s, fmt.Sprintf("styleable index %s has no parent styleable", s.Name)}
+		}
+		if i := s.Values[0]; i < 0 || int(i) >= len(parent.Values) {
+			return &styleableError{s, fmt.Sprintf("styleable index %s=%d is out of the bounds of %s, which has %d attributes", s.Name, i, parent.Name, len(parent.Values))}
+		}
+		s.Parent = parent.Name
+	}
+	return nil
+}
+
+// Write writes symbols to w in the R.txt format, in order.
+func Write(w io.Writer, symbols []*Symbol) error {
+	bw := bufio.NewWriter(w)
+	for _, s := range symbols {
+		if _, err := fmt.Fprintln(bw, s); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Conflict is a symbol declared with different values by two R.txt files.
+type Conflict struct {
+	// Kept is the first declaration, which is part of the merged symbols.
+	Kept *Symbol
+	// Dropped is the conflicting declaration.
+	Dropped *Symbol
+}
+
+// ConflictError lists the conflicts found while merging R.txt files.
+type ConflictError struct {
+	Conflicts []*Conflict
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d conflicting R.txt symbols:", len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(&b, "\n  %s: %q vs %q", c.Kept.Key(), c.Kept, c.Dropped)
+	}
+	return b.String()
+}
+
+// Merge merges the symbols of several R.txt files, keeping the first declaration of each symbol,
+// in the order they are first declared.
+//
+// Declarations holding only placeholder IDs never conflict, and are replaced by a later
+// declaration with assigned IDs. Merge always returns the merged symbols; if other declarations
+// disagree, it also returns a *ConflictError listing them, which callers may choose to ignore.
+func Merge(sets ...[]*Symbol) ([]*Symbol, error) {
+	var merged []*Symbol
+	index := make(map[string]int)
+	var conflicts []*Conflict
+	for _, set := range sets {
+		for _, s := range set {
+			i, ok := index[s.Key()]
+			if !ok {
+				index[s.Key()] = len(merged)
+				merged = append(merged, s)
+				continue
+			}
+			kept := merged[i]
+			switch {
+			case kept.equal(s) || s.unassigned() && kept.Array == s.Array:
+			case kept.unassigned() && kept.Array == s.Array:
+				merged[i] = s
+			default:
+				conflicts = append(conflicts, &Conflict{Kept: kept, Dropped: s})
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return merged, &ConflictError{Conflicts: conflicts}
+	}
+	return merged, nil
+}
+
+// FilterPackage returns the symbols of the resources of the package with the given ID, e.g. 0x7f.
+// Styleables are kept, with their child indices, if one of their attributes is in the package.
+func FilterPackage(symbols []*Symbol, pkgID uint8) []*Symbol {
+	styleables := make(map[string]bool)
+	for _, s := range symbols {
+		if !s.Array {
+			continue
+		}
+		for _, v := range s.Values {
+			if uint8(uint32(v)>>24) == pkgID {
+				styleables[s.Name] = true
+				break
+			}
+		}
+	}
+	var filtered []*Symbol
+	for _, s := range symbols {
+		switch {
+		case s.Array:
+			if styleables[s.Name] {
+				filtered = append(filtered, s)
+			}
+		case s.Parent != "":
+			if styleables[s.Parent] {
+				filtered = append(filtered, s)
+			}
+		case s.Package() == pkgID:
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// Restrict returns the symbols which are also declared in allowed, compared by type and name.
+// It is used to keep an R class limited to the resources of a target and its direct dependencies.
+func Restrict(symbols, allowed []*Symbol) []*Symbol {
+	keep := make(map[string]bool)
+	for _, s := range allowed {
+		keep[s.Key()] = true
+	}
+	var restricted []*Symbol
+	for _, s := range symbols {
+		if keep[s.Key()] {
+			restricted = append(restricted, s)
+		}
+	}
+	return restricted
+}
+
+// Sort sorts symbols by type and name, listing styleable child indices right after their parent,
+// as aapt2 does.
+func Sort(symbols []*Symbol) {
+	key := func(s *Symbol) string {
+		if s.Parent != "" {
+			// '\x00' sorts a child before any other styleable sharing the parent as a prefix.
+			return s.Type + "\x00" + s.Parent + "\x00" + s.Name
+		}
+		return s.Type + "\x00" + s.Name
+	}
+	sort.SliceStable(symbols, func(i, j int) bool { return key(symbols[i]) < key(symbols[j]) })
+}
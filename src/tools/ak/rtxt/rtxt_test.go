@@ -0,0 +1,314 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtxt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const appRTxt = `int anim abc_fade_in 0x7f010000
+int attr actionBarSize 0x7f030003
+int drawable $avd_hide_password__0 0x7f070001
+int[] styleable ActionBar { 0x7f030031, 0x7f030003 }
+int styleable ActionBar_background 0
+int styleable ActionBar_height 1
+int[] styleable ActionBar_LayoutParams { 0x010100b3 }
+int styleable ActionBar_LayoutParams_android_layout_gravity 0
+int[] styleable Empty { }
+`
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []*Symbol
+		wantErr string
+	}{
+		{
+			name:  "app R.txt",
+			input: appRTxt,
+			want: []*Symbol{
+				{Type: "anim", Name: "abc_fade_in", Values: []int32{0x7f010000}},
+				{Type: "attr", Name: "actionBarSize", Values: []int32{0x7f030003}},
+				{Type: "drawable", Name: "$avd_hide_password__0", Values: []int32{0x7f070001}},
+				{Type: "styleable", Name: "ActionBar", Array: true, Values: []int32{0x7f030031, 0x7f030003}},
+				{Type: "styleable", Name: "ActionBar_background", Values: []int32{0}, Parent: "ActionBar"},
+				{Type: "styleable", Name: "ActionBar_height", Values: []int32{1}, Parent: "ActionBar"},
+				{Type: "styleable", Name: "ActionBar_LayoutParams", Array: true, Values: []int32{0x010100b3}},
+				{Type: "styleable", Name: "ActionBar_LayoutParams_android_layout_gravity", Values: []int32{0}, Parent: "ActionBar_LayoutParams"},
+				{Type: "styleable", Name: "Empty", Array: true},
+			},
+		},
+		{
+			name:  "library placeholders",
+			input: "int string app_name 0\nint[] styleable View { 0, 0 }\nint styleable View_a 1\n",
+			want: []*Symbol{
+				{Type: "string", Name: "app_name", Values: []int32{0}},
+				{Type: "styleable", Name: "View", Array: true, Values: []int32{0, 0}},
+				{Type: "styleable", Name: "View_a", Values: []int32{1}, Parent: "View"},
+			},
+		},
+		{
+			name:    "malformed line",
+			input:   "int string app_name 0x7f0b0001\nint string\n",
+			wantErr: "line 2: malformed",
+		},
+		{
+			name:    "bad value",
+			input:   "int string app_name 0x7f0g\n",
+			wantErr: "invalid resource value",
+		},
+		{
+			name:    "out of range value",
+			input:   "int string app_name 0x1ffffffff\n",
+			wantErr: "invalid resource value",
+		},
+		{
+			name:    "missing package ID",
+			input:   "int string app_name 0x000b0001\n",
+			wantErr: "line 1: string.app_name: invalid resource ID 0x000b0001",
+		},
+		{
+			name:    "missing type ID",
+			input:   "int[] styleable View { 0x7f000001 }\n",
+			wantErr: "invalid resource ID 0x7f000001",
+		},
+		{
+			name:    "array without braces",
+			input:   "int[] styleable View 0\n",
+			wantErr: "not enclosed in braces",
+		},
+		{
+			name:    "unknown kind",
+			input:   "long string app_name 1\n",
+			wantErr: "unknown symbol kind",
+		},
+		{
+			name:    "orphan styleable index",
+			input:   "int[] styleable View { 0x7f030001 }\n\nint styleable Other_attr 0\n",
+			wantErr: "line 3: styleable index Other_attr has no parent",
+		},
+		{
+			name:    "styleable index out of bounds",
+			input:   "int[] styleable View { 0x7f030001 }\nint styleable View_attr 1\n",
+			wantErr: "line 2: styleable index View_attr=1 is out of the bounds of View",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Read(strings.NewReader(tc.input))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Read(%q) returned error %v, want error containing %q", tc.input, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read(%q) failed: %v", tc.input, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Read(%q) returned diff (-want, +got):\n%v", tc.input, diff)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	input := `int string app_name 0x000b0001
+int[] color selector 0
+int[] styleable View { 0x7f030001 }
+int styleable toolbar_logo 0
+int styleable View_attr 3
+`
+	want := []*Symbol{
+		{Type: "string", Name: "app_name", Values: []int32{0x000b0001}},
+		{Type: "color", Name: "selector", Array: true, Values: []int32{0}},
+		{Type: "styleable", Name: "View", Array: true, Values: []int32{0x7f030001}},
+		{Type: "styleable", Name: "toolbar_logo", Values: []int32{0}},
+		{Type: "styleable", Name: "View_attr", Values: []int32{3}},
+	}
+	got, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", input, err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Parse(%q) returned diff (-want, +got):\n%v", input, diff)
+	}
+	for _, bad := range []string{"int string\n", "int string app_name 0x7f0g\n", "int string app_name 0x1ffffffff\n", "long string app_name 1\n"} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want a syntax error", bad)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	symbols, err := Read(strings.NewReader(appRTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := Write(&b, symbols); err != nil {
+		t.Fatalf("Write(%v) failed: %v", symbols, err)
+	}
+	want := `int anim abc_fade_in 0x7f010000
+int attr actionBarSize 0x7f030003
+int drawable $avd_hide_password__0 0x7f070001
+int[] styleable ActionBar { 0x7f030031, 0x7f030003 }
+int styleable ActionBar_background 0
+int styleable ActionBar_height 1
+int[] styleable ActionBar_LayoutParams { 0x010100b3 }
+int styleable ActionBar_LayoutParams_android_layout_gravity 0
+int[] styleable Empty { }
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("Write(%v) returned diff (-want, +got):\n%v", symbols, diff)
+	}
+	again, err := Read(&b)
+	if err != nil {
+		t.Fatalf("Read of written R.txt failed: %v", err)
+	}
+	if diff := cmp.Diff(symbols, again); diff != "" {
+		t.Errorf("Read(Write(%v)) returned diff (-want, +got):\n%v", symbols, diff)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	lib := []*Symbol{
+		{Type: "string", Name: "app_name", Values: []int32{0}},
+		{Type: "id", Name: "view", Values: []int32{0x7f080001}},
+		{Type: "styleable", Name: "View", Array: true, Values: []int32{0, 0}},
+	}
+	app := []*Symbol{
+		{Type: "string", Name: "app_name", Values: []int32{0x7f0b0001}},
+		{Type: "id", Name: "view", Values: []int32{0x7f080001}},
+		{Type: "styleable", Name: "View", Array: true, Values: []int32{0x7f030001, 0x7f030002}},
+		{Type: "layout", Name: "main", Values: []int32{0x7f0a0001}},
+	}
+	got, err := Merge(lib, app)
+	if err != nil {
+		t.Fatalf("Merge(%v, %v) failed: %v", lib, app, err)
+	}
+	want := []*Symbol{app[0], lib[1], app[2], app[3]}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Merge(%v, %v) returned diff (-want, +got):\n%v", lib, app, diff)
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	a := []*Symbol{
+		{Type: "id", Name: "view", Values: []int32{0x7f080001}},
+		{Type: "styleable", Name: "View", Array: true, Values: []int32{0x7f030001}},
+	}
+	b := []*Symbol{
+		{Type: "id", Name: "view", Values: []int32{0x7f080002}},
+		{Type: "styleable", Name: "View", Array: true, Values: []int32{0x7f030001}},
+		{Type: "styleable", Name: "View", Values: []int32{0}},
+	}
+	got, err := Merge(a, b)
+	var conflicts *ConflictError
+	if !errors.As(err, &conflicts) {
+		t.Fatalf("Merge(%v, %v) returned error %v, want a *ConflictError", a, b, err)
+	}
+	want := []*Conflict{
+		{Kept: a[0], Dropped: b[0]},
+		{Kept: a[1], Dropped: b[2]},
+	}
+	if diff := cmp.Diff(want, conflicts.Conflicts); diff != "" {
+		t.Errorf("Merge(%v, %v) conflicts returned diff (-want, +got):\n%v", a, b, diff)
+	}
+	if diff := cmp.Diff(a, got); diff != "" {
+		t.Errorf("Merge(%v, %v) returned diff (-want, +got):\n%v", a, b, diff)
+	}
+	if !strings.Contains(err.Error(), `id.view: "int id view 0x7f080001" vs "int id view 0x7f080002"`) {
+		t.Errorf("Merge(%v, %v) error %q does not describe the id conflict", a, b, err)
+	}
+}
+
+func TestFilterPackage(t *testing.T) {
+	symbols, err := Read(strings.NewReader(`int attr actionBarSize 0x7f030003
+int attr textSize 0x01010095
+int[] styleable Framework { 0x01010095 }
+int styleable Framework_android_textSize 0
+int[] styleable Mixed { 0x01010095, 0x7f030003 }
+int styleable Mixed_android_textSize 0
+int styleable Mixed_actionBarSize 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := FilterPackage(symbols, 0x7f)
+	var b bytes.Buffer
+	if err := Write(&b, filtered); err != nil {
+		t.Fatalf("Write(%v) failed: %v", filtered, err)
+	}
+	want := `int attr actionBarSize 0x7f030003
+int[] styleable Mixed { 0x01010095, 0x7f030003 }
+int styleable Mixed_android_textSize 0
+int styleable Mixed_actionBarSize 1
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("Write(FilterPackage(0x7f)) returned diff (-want, +got):\n%v", diff)
+	}
+	again, err := Read(&b)
+	if err != nil {
+		t.Fatalf("Read of the filtered R.txt failed: %v", err)
+	}
+	if diff := cmp.Diff(filtered, again); diff != "" {
+		t.Errorf("Read(Write(FilterPackage(0x7f))) returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	symbols := []*Symbol{
+		{Type: "id", Name: "a", Values: []int32{1}},
+		{Type: "string", Name: "a", Values: []int32{2}},
+		{Type: "styleable", Name: "View", Array: true, Values: []int32{3}},
+		{Type: "styleable", Name: "View_attr", Values: []int32{0}, Parent: "View"},
+	}
+	allowed := []*Symbol{
+		{Type: "id", Name: "a"},
+		{Type: "styleable", Name: "View", Array: true},
+		{Type: "styleable", Name: "View_attr"},
+		{Type: "layout", Name: "unused"},
+	}
+	want := []*Symbol{symbols[0], symbols[2], symbols[3]}
+	if diff := cmp.Diff(want, Restrict(symbols, allowed)); diff != "" {
+		t.Errorf("Restrict(%v, %v) returned diff (-want, +got):\n%v", symbols, allowed, diff)
+	}
+}
+
+func TestSort(t *testing.T) {
+	symbols := []*Symbol{
+		{Type: "styleable", Name: "ActionBar_background", Parent: "ActionBar"},
+		{Type: "styleable", Name: "ActionBarLayout", Array: true},
+		{Type: "id", Name: "b"},
+		{Type: "styleable", Name: "ActionBar", Array: true},
+		{Type: "id", Name: "a"},
+	}
+	Sort(symbols)
+	var got []string
+	for _, s := range symbols {
+		got = append(got, s.Key())
+	}
+	want := []string{"id.a", "id.b", "styleable.ActionBar", "styleable.ActionBar_background", "styleable.ActionBarLayout"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Sort() returned diff (-want, +got):\n%v", diff)
+	}
+}
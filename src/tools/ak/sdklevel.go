@@ -0,0 +1,169 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sdklevel provides the Android SDK levels found in the uses-sdk element of manifests:
+// numeric API levels, with an optional minor version, e.g. 36.1, and preview codenames, e.g.
+// VanillaIceCream.
+package sdklevel
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrPlaceholder is returned by Parse for ${name} placeholders, whose value is not known yet.
+var ErrPlaceholder = errors.New("placeholder SDK level")
+
+var (
+	numericRE  = regexp.MustCompile(`^([0-9]+)(?:\.([0-9]+))?$`)
+	codenameRE = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+	// codenames maps the codenames of preview platforms to the API level they were released as.
+	codenames = map[string]int{
+		"Q":               29,
+		"R":               30,
+		"S":               31,
+		"Sv2":             32,
+		"Tiramisu":        33,
+		"UpsideDownCake":  34,
+		"VanillaIceCream": 35,
+		"Baklava":         36,
+	}
+)
+
+// Level is an SDK level. The zero Level is unset.
+type Level struct {
+	// Major is the API level. For previews it is the API level the codename is released as, or 0
+	// for unknown codenames.
+	Major int
+	// Minor is the minor SDK version, e.g. 1 for 36.1.
+	Minor int
+	// Codename is set for preview platforms.
+	Codename string
+}
+
+// API returns the stable level of the API level n, or the zero Level for 0.
+func API(n int) Level {
+	return Level{Major: n}
+}
+
+// Parse parses an SDK level. It returns ErrPlaceholder, wrapped, for placeholders.
+func Parse(s string) (Level, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return Level{}, errors.New("empty SDK level")
+	case strings.Contains(s, "${"):
+		return Level{}, fmt.Errorf("%w %q", ErrPlaceholder, s)
+	}
+	if m := numericRE.FindStringSubmatch(s); m != nil {
+		major, err := strconv.Atoi(m[1])
+		if err != nil {
+			return Level{}, fmt.Errorf("invalid SDK level %q: %v", s, err)
+		}
+		var minor int
+		if m[2] != "" {
+			if minor, err = strconv.Atoi(m[2]); err != nil {
+				return Level{}, fmt.Errorf("invalid SDK level %q: %v", s, err)
+			}
+		}
+		if major == 0 {
+			return Level{}, fmt.Errorf("invalid SDK level %q: API levels start at 1", s)
+		}
+		return Level{Major: major, Minor: minor}, nil
+	}
+	if codenameRE.MatchString(s) {
+		return Level{Major: codenames[s], Codename: s}, nil
+	}
+	return Level{}, fmt.Errorf("invalid SDK level %q: want an API level, e.g. 34 or 36.1, or a preview codename", s)
+}
+
+// IsZero reports whether l is unset.
+func (l Level) IsZero() bool {
+	return l == Level{}
+}
+
+// IsPreview reports whether l is the codename of a preview platform.
+func (l Level) IsPreview() bool {
+	return l.Codename != ""
+}
+
+// String returns l as written in manifests.
+func (l Level) String() string {
+	switch {
+	case l.IsPreview():
+		return l.Codename
+	case l.Minor != 0:
+		return fmt.Sprintf("%d.%d", l.Major, l.Minor)
+	default:
+		return strconv.Itoa(l.Major)
+	}
+}
+
+// Compare returns -1, 0 or 1 as l is lower than, equal to or higher than o. Previews only compare
+// with previews: apps built against a preview platform only install on that preview, so mixing
+// preview and stable levels is an error. So is comparing different unknown codenames.
+func (l Level) Compare(o Level) (int, error) {
+	if l.IsPreview() != o.IsPreview() {
+		p, s := l, o
+		if o.IsPreview() {
+			p, s = o, l
+		}
+		return 0, fmt.Errorf("cannot mix the preview SDK %s with the stable SDK %s", p, s)
+	}
+	if l.IsPreview() && l.Codename != o.Codename && (l.Major == 0 || o.Major == 0) {
+		return 0, fmt.Errorf("cannot compare the preview SDKs %s and %s", l, o)
+	}
+	if l.Major != o.Major {
+		return cmpInt(l.Major, o.Major), nil
+	}
+	return cmpInt(l.Minor, o.Minor), nil
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Max returns the highest of the levels, ignoring unset ones. It returns the zero Level if all
+// are unset.
+func Max(levels ...Level) (Level, error) {
+	var m Level
+	for _, l := range levels {
+		if l.IsZero() {
+			continue
+		}
+		if m.IsZero() {
+			m = l
+			continue
+		}
+		c, err := l.Compare(m)
+		if err != nil {
+			return Level{}, err
+		}
+		if c > 0 {
+			m = l
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdklevel
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Level
+	}{
+		{"34", Level{Major: 34}},
+		{" 21 ", Level{Major: 21}},
+		{"36.1", Level{Major: 36, Minor: 1}},
+		{"36.0", Level{Major: 36}},
+		{"VanillaIceCream", Level{Major: 35, Codename: "VanillaIceCream"}},
+		{"Sv2", Level{Major: 32, Codename: "Sv2"}},
+		{"FutureCodename", Level{Codename: "FutureCodename"}},
+	}
+	for _, tc := range tests {
+		got, err := Parse(tc.in)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tc.in, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("Parse(%q) returned diff (-want, +got):\n%v", tc.in, diff)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{"", "0", "-1", "34.", "3.4.5", "vanilla", "@integer/min_sdk"} {
+		if l, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %v, want error", in, l)
+		}
+	}
+	if _, err := Parse("${minSdkVersion}"); !errors.Is(err, ErrPlaceholder) {
+		t.Errorf("Parse(${minSdkVersion}) returned error %v, want ErrPlaceholder", err)
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, in := range []string{"34", "36.1", "Baklava", "FutureCodename"} {
+		l, err := Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", in, err)
+		}
+		if got := l.String(); got != in {
+			t.Errorf("Parse(%q).String() = %q", in, got)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		want    int
+		wantErr string
+	}{
+		{a: "21", b: "34", want: -1},
+		{a: "34", b: "34", want: 0},
+		{a: "9", b: "10", want: -1},
+		{a: "36.1", b: "36", want: 1},
+		{a: "36.1", b: "36.2", want: -1},
+		{a: "37", b: "36.1", want: 1},
+		{a: "Baklava", b: "Baklava", want: 0},
+		{a: "VanillaIceCream", b: "Baklava", want: -1},
+		{a: "FutureCodename", b: "FutureCodename", want: 0},
+		{a: "FutureCodename", b: "Baklava", wantErr: "cannot compare the preview SDKs FutureCodename and Baklava"},
+		{a: "VanillaIceCream", b: "34", wantErr: "cannot mix the preview SDK VanillaIceCream with the stable SDK 34"},
+		{a: "35", b: "VanillaIceCream", wantErr: "cannot mix the preview SDK VanillaIceCream with the stable SDK 35"},
+	}
+	for _, tc := range tests {
+		a, err := Parse(tc.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(tc.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := a.Compare(b)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s.Compare(%s) returned error %v, want %q", tc.a, tc.b, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s.Compare(%s) failed: %v", tc.a, tc.b, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestMax(t *testing.T) {
+	got, err := Max(Level{}, API(21), Level{Major: 36, Minor: 1}, API(34), Level{})
+	if err != nil {
+		t.Fatalf("Max failed: %v", err)
+	}
+	if diff := cmp.Diff(Level{Major: 36, Minor: 1}, got); diff != "" {
+		t.Errorf("Max returned diff (-want, +got):\n%v", diff)
+	}
+	if got, err := Max(Level{}, Level{}); err != nil || !got.IsZero() {
+		t.Errorf("Max of unset levels = %v, %v, want the zero Level", got, err)
+	}
+	if _, err := Max(API(21), Level{Major: 35, Codename: "VanillaIceCream"}); err == nil {
+		t.Error("Max of preview and stable levels succeeded, want error")
+	}
+}
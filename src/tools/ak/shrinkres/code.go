@@ -0,0 +1,481 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shrinkres
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	dexHeaderSize = 0x70
+	classDefSize  = 32
+	classMagic    = 0xcafebabe
+
+	// Dalvik opcodes and payload identifiers of interest.
+	opConst                = 0x14
+	opConstHigh16          = 0x15
+	opSget                 = 0x60
+	opSgetShort            = 0x66
+	packedSwitchPayload    = 0x0100
+	sparseSwitchPayload    = 0x0200
+	fillArrayDataPayload   = 0x0300
+	encodedValueInt        = 0x04
+	encodedValueArray      = 0x1c
+	encodedValueAnnotation = 0x1d
+	encodedValueNull       = 0x1e
+	encodedValueBoolean    = 0x1f
+)
+
+var dexMagic = []byte("dex\n")
+
+// classUsage holds the integer constants and the static fields used by the code of a class.
+type classUsage struct {
+	// name is the java name of the class, e.g. com.example.R$drawable.
+	name   string
+	ints   []uint32
+	fields []fieldRef
+}
+
+// fieldRef is a static field, with the java name of its class.
+type fieldRef struct {
+	class, name string
+}
+
+// scanCodeFile returns the classes of a dex file, a class file, or a zip holding those.
+func scanCodeFile(name string) ([]*classUsage, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := scanCode(name, b, true)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return cs, nil
+}
+
+func scanCode(name string, b []byte, zipOK bool) ([]*classUsage, error) {
+	switch {
+	case bytes.HasPrefix(b, dexMagic):
+		return scanDex(b)
+	case len(b) >= 4 && binary.BigEndian.Uint32(b) == classMagic:
+		c, err := scanClass(b)
+		if err != nil {
+			return nil, err
+		}
+		return []*classUsage{c}, nil
+	case zipOK && bytes.HasPrefix(b, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, err
+		}
+		var cs []*classUsage
+		for _, f := range zr.File {
+			if !strings.HasSuffix(f.Name, ".dex") && !strings.HasSuffix(f.Name, ".class") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			fb, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			c, err := scanCode(f.Name, fb, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Name, err)
+			}
+			cs = append(cs, c...)
+		}
+		return cs, nil
+	}
+	return nil, fmt.Errorf("not a dex, class or zip file")
+}
+
+// dexReader reads a dex file. Reads out of bounds return zero values and record an error.
+type dexReader struct {
+	b   []byte
+	err error
+}
+
+func (d *dexReader) check(off, n uint32) bool {
+	if d.err != nil {
+		return false
+	}
+	if uint64(off)+uint64(n) > uint64(len(d.b)) {
+		d.err = fmt.Errorf("read of %d bytes at 0x%x is out of bounds", n, off)
+		return false
+	}
+	return true
+}
+
+func (d *dexReader) u8(off uint32) uint8 {
+	if !d.check(off, 1) {
+		return 0
+	}
+	return d.b[off]
+}
+
+func (d *dexReader) u16(off uint32) uint16 {
+	if !d.check(off, 2) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(d.b[off:])
+}
+
+func (d *dexReader) u32(off uint32) uint32 {
+	if !d.check(off, 4) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(d.b[off:])
+}
+
+// uleb128 reads an unsigned LEB128 value at *off and advances *off past it.
+func (d *dexReader) uleb128(off *uint32) uint32 {
+	var v uint32
+	for shift := 0; shift < 35; shift += 7 {
+		b := d.u8(*off)
+		*off++
+		v |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	if d.err == nil {
+		d.err = fmt.Errorf("invalid uleb128 at 0x%x", *off)
+	}
+	return 0
+}
+
+// string returns the string of the given string_ids index.
+func (d *dexReader) string(idx uint32) string {
+	off := d.u32(d.u32(60) + 4*idx)
+	d.uleb128(&off) // UTF-16 size
+	if d.err != nil || off >= uint32(len(d.b)) {
+		return ""
+	}
+	end := bytes.IndexByte(d.b[off:], 0)
+	if end < 0 {
+		d.err = fmt.Errorf("unterminated string %d", idx)
+		return ""
+	}
+	return string(d.b[off : off+uint32(end)])
+}
+
+// className returns the java name of the class of the given type_ids index.
+func (d *dexReader) className(idx uint32) string {
+	desc := d.string(d.u32(d.u32(68) + 4*idx))
+	return strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(desc, "L"), ";"), "/", ".")
+}
+
+func (d *dexReader) field(idx uint32) fieldRef {
+	off := d.u32(84) + 8*idx
+	return fieldRef{class: d.className(uint32(d.u16(off))), name: d.string(d.u32(off + 4))}
+}
+
+// scanDex returns the classes defined in a dex file.
+func scanDex(b []byte) ([]*classUsage, error) {
+	if len(b) < dexHeaderSize {
+		return nil, fmt.Errorf("truncated dex header")
+	}
+	d := &dexReader{b: b}
+	var cs []*classUsage
+	count, defs := d.u32(96), d.u32(100)
+	for i := uint32(0); i < count && d.err == nil; i++ {
+		def := defs + classDefSize*i
+		c := &classUsage{name: d.className(d.u32(def))}
+		if off := d.u32(def + 24); off != 0 {
+			d.scanClassData(off, c)
+		}
+		if off := d.u32(def + 28); off != 0 {
+			d.encodedArray(&off, c)
+		}
+		cs = append(cs, c)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return cs, nil
+}
+
+func (d *dexReader) scanClassData(off uint32, c *classUsage) {
+	var sizes [4]uint32 // static fields, instance fields, direct methods, virtual methods
+	for i := range sizes {
+		sizes[i] = d.uleb128(&off)
+	}
+	for i := uint32(0); i < sizes[0]+sizes[1] && d.err == nil; i++ {
+		d.uleb128(&off) // field_idx_diff
+		d.uleb128(&off) // access_flags
+	}
+	for i := uint32(0); i < sizes[2]+sizes[3] && d.err == nil; i++ {
+		d.uleb128(&off) // method_idx_diff
+		d.uleb128(&off) // access_flags
+		if code := d.uleb128(&off); code != 0 {
+			d.scanInsns(code, c)
+		}
+	}
+}
+
+// scanInsns collects the 32 bit constants, the elements of int array payloads and the static
+// field reads of a code_item.
+func (d *dexReader) scanInsns(code uint32, c *classUsage) {
+	size := d.u32(code + 12)
+	insns := code + 16
+	if !d.check(insns, 2*size) {
+		return
+	}
+	unit := func(i uint32) uint32 { return uint32(d.u16(insns + 2*i)) }
+	for i := uint32(0); i < size && d.err == nil; {
+		op := unit(i) & 0xff
+		n := uint32(insnUnits(uint8(op)))
+		switch {
+		case op == 0x00:
+			switch unit(i) {
+			case packedSwitchPayload:
+				n = 4 + 2*unit(i+1)
+			case sparseSwitchPayload:
+				n = 2 + 4*unit(i+1)
+			case fillArrayDataPayload:
+				width, count := unit(i+1), unit(i+2)|unit(i+3)<<16
+				if uint64(width)*uint64(count) > uint64(2*size) {
+					d.err = fmt.Errorf("invalid fill-array-data payload at 0x%x", insns+2*i)
+					return
+				}
+				if width == 4 {
+					for j := uint32(0); j < count; j++ {
+						c.ints = append(c.ints, d.u32(insns+2*(i+4)+4*j))
+					}
+				}
+				n = 4 + (width*count+1)/2
+			}
+		case op == opConst:
+			c.ints = append(c.ints, unit(i+1)|unit(i+2)<<16)
+		case op == opConstHigh16:
+			c.ints = append(c.ints, unit(i+1)<<16)
+		case op >= opSget && op <= opSgetShort:
+			c.fields = append(c.fields, d.field(unit(i+1)))
+		}
+		if i+n > size {
+			d.err = fmt.Errorf("truncated instruction 0x%02x at 0x%x", op, insns+2*i)
+			return
+		}
+		i += n
+	}
+}
+
+// insnUnits returns the size in 16 bit code units of the instructions of opcode op.
+func insnUnits(op uint8) int {
+	switch {
+	case op == 0x02, op == 0x05, op == 0x08: // move*/from16
+		return 2
+	case op == 0x03, op == 0x06, op == 0x09: // move*/16
+		return 3
+	case op <= 0x12: // moves, returns, const/4
+		return 1
+	case op == 0x13, op == 0x15, op == 0x16, op == 0x19, op == 0x1a, op == 0x1c:
+		return 2
+	case op == 0x14, op == 0x17, op == 0x1b:
+		return 3
+	case op == 0x18: // const-wide
+		return 5
+	case op == 0x1d, op == 0x1e, op == 0x21, op == 0x27, op == 0x28:
+		return 1
+	case op == 0x1f, op == 0x20, op == 0x22, op == 0x23, op == 0x29:
+		return 2
+	case op <= 0x2c: // filled-new-array*, fill-array-data, goto/32, switches
+		return 3
+	case op <= 0x3d: // cmp*, if-*
+		return 2
+	case op <= 0x43: // unused
+		return 1
+	case op <= 0x6d: // array, instance and static field accesses
+		return 2
+	case op <= 0x72: // invoke-*
+		return 3
+	case op == 0x73: // unused
+		return 1
+	case op <= 0x78: // invoke-*/range
+		return 3
+	case op <= 0x8f: // unused, unary operations
+		return 1
+	case op <= 0xaf: // binary operations
+		return 2
+	case op <= 0xcf: // binary operations/2addr
+		return 1
+	case op <= 0xe2: // binary operations with literals
+		return 2
+	case op <= 0xf9: // unused
+		return 1
+	case op <= 0xfb: // invoke-polymorphic*
+		return 4
+	case op <= 0xfd: // invoke-custom*
+		return 3
+	default: // const-method-handle, const-method-type
+		return 2
+	}
+}
+
+// encodedArray collects the int values of an encoded_array, e.g. the initial values of the
+// static fields of a class.
+func (d *dexReader) encodedArray(off *uint32, c *classUsage) {
+	size := d.uleb128(off)
+	for i := uint32(0); i < size && d.err == nil; i++ {
+		d.encodedValue(off, c)
+	}
+}
+
+func (d *dexReader) encodedValue(off *uint32, c *classUsage) {
+	h := d.u8(*off)
+	*off++
+	typ, arg := h&0x1f, uint32(h>>5)
+	switch typ {
+	case encodedValueArray:
+		d.encodedArray(off, c)
+	case encodedValueAnnotation:
+		d.uleb128(off) // type_idx
+		size := d.uleb128(off)
+		for i := uint32(0); i < size && d.err == nil; i++ {
+			d.uleb128(off) // name_idx
+			d.encodedValue(off, c)
+		}
+	case encodedValueNull, encodedValueBoolean:
+	case encodedValueInt:
+		var v uint32
+		for i := uint32(0); i <= arg; i++ {
+			v |= uint32(d.u8(*off+i)) << (8 * i)
+		}
+		if shift := 8 * (3 - arg); shift > 0 && shift < 32 {
+			v = uint32(int32(v<<shift) >> shift)
+		}
+		c.ints = append(c.ints, v)
+		*off += arg + 1
+	default:
+		*off += arg + 1
+	}
+}
+
+// scanClass returns the integer constants and field references of the constant pool of a class
+// file.
+func scanClass(b []byte) (*classUsage, error) {
+	be := binary.BigEndian
+	if len(b) < 10 {
+		return nil, fmt.Errorf("truncated class file")
+	}
+	type constant struct {
+		tag  byte
+		a, b uint16
+		utf8 string
+	}
+	count := int(be.Uint16(b[8:]))
+	pool := make([]constant, count)
+	c := &classUsage{}
+	pos := 10
+	for i := 1; i < count; i++ {
+		if pos >= len(b) {
+			return nil, fmt.Errorf("truncated constant pool")
+		}
+		tag := b[pos]
+		size := 0
+		switch tag {
+		case 1: // Utf8
+			if pos+3 > len(b) {
+				return nil, fmt.Errorf("truncated constant pool")
+			}
+			size = 3 + int(be.Uint16(b[pos+1:]))
+		case 3, 4, 9, 10, 11, 12, 17, 18: // Integer, Float, member references, NameAndType, dynamic
+			size = 5
+		case 5, 6: // Long, Double
+			size = 9
+		case 7, 8, 16, 19, 20: // Class, String, MethodType, Module, Package
+			size = 3
+		case 15: // MethodHandle
+			size = 4
+		default:
+			return nil, fmt.Errorf("unknown constant pool tag %d", tag)
+		}
+		if pos+size > len(b) {
+			return nil, fmt.Errorf("truncated constant pool")
+		}
+		e := constant{tag: tag}
+		switch {
+		case tag == 1:
+			e.utf8 = string(b[pos+3 : pos+size])
+		case tag == 3:
+			c.ints = append(c.ints, be.Uint32(b[pos+1:]))
+		case size >= 3:
+			e.a = be.Uint16(b[pos+1:])
+			if size >= 5 {
+				e.b = be.Uint16(b[pos+3:])
+			}
+		}
+		pool[i] = e
+		pos += size
+		if tag == 5 || tag == 6 {
+			i++
+		}
+	}
+	get := func(i uint16, tag byte) (constant, error) {
+		if int(i) >= count || pool[i].tag != tag {
+			return constant{}, fmt.Errorf("constant %d is not of tag %d", i, tag)
+		}
+		return pool[i], nil
+	}
+	className := func(i uint16) (string, error) {
+		cl, err := get(i, 7)
+		if err != nil {
+			return "", err
+		}
+		name, err := get(cl.a, 1)
+		if err != nil {
+			return "", err
+		}
+		return strings.ReplaceAll(name.utf8, "/", "."), nil
+	}
+	for _, e := range pool {
+		if e.tag != 9 { // Fieldref
+			continue
+		}
+		class, err := className(e.a)
+		if err != nil {
+			return nil, err
+		}
+		nt, err := get(e.b, 12)
+		if err != nil {
+			return nil, err
+		}
+		name, err := get(nt.a, 1)
+		if err != nil {
+			return nil, err
+		}
+		c.fields = append(c.fields, fieldRef{class: class, name: name.utf8})
+	}
+	if pos+4 > len(b) {
+		return nil, fmt.Errorf("truncated class file")
+	}
+	name, err := className(be.Uint16(b[pos+2:]))
+	if err != nil {
+		return nil, err
+	}
+	c.name = name
+	return c, nil
+}
@@ -0,0 +1,270 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shrinkres
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"src/tools/ak/rclass/rclass"
+	"src/tools/ak/rtxt/rtxt"
+)
+
+// dexClass describes a class of a dex file built by testDex.
+type dexClass struct {
+	name string
+	// methods holds the instructions of each method.
+	methods [][]uint16
+	// staticValues is the encoded_array of the static field values, if any.
+	staticValues []byte
+}
+
+// testDex builds a dex file holding just what scanDex reads. Field indices used by the methods
+// index fields.
+func testDex(fields []fieldRef, classes ...dexClass) []byte {
+	le := binary.LittleEndian
+	descriptor := func(class string) string { return "L" + strings.ReplaceAll(class, ".", "/") + ";" }
+	var strs, types []string
+	addString := func(s string) {
+		for _, have := range strs {
+			if have == s {
+				return
+			}
+		}
+		strs = append(strs, s)
+	}
+	addType := func(class string) {
+		addString(descriptor(class))
+		for _, have := range types {
+			if have == class {
+				return
+			}
+		}
+		types = append(types, class)
+	}
+	for _, c := range classes {
+		addType(c.name)
+	}
+	for _, f := range fields {
+		addType(f.class)
+		addString(f.name)
+	}
+	sort.Strings(strs)
+	index := func(list []string, s string) uint32 {
+		for i, have := range list {
+			if have == s {
+				return uint32(i)
+			}
+		}
+		panic(s)
+	}
+
+	stringIDs := uint32(dexHeaderSize)
+	typeIDs := stringIDs + 4*uint32(len(strs))
+	fieldIDs := typeIDs + 4*uint32(len(types))
+	classDefs := fieldIDs + 8*uint32(len(fields))
+	b := make([]byte, classDefs+classDefSize*uint32(len(classes)))
+	copy(b, "dex\n035\x00")
+	le.PutUint32(b[56:], uint32(len(strs)))
+	le.PutUint32(b[60:], stringIDs)
+	le.PutUint32(b[64:], uint32(len(types)))
+	le.PutUint32(b[68:], typeIDs)
+	le.PutUint32(b[80:], uint32(len(fields)))
+	le.PutUint32(b[84:], fieldIDs)
+	le.PutUint32(b[96:], uint32(len(classes)))
+	le.PutUint32(b[100:], classDefs)
+	for i, s := range strs {
+		le.PutUint32(b[stringIDs+4*uint32(i):], uint32(len(b)))
+		b = append(b, byte(len(s)))
+		b = append(append(b, s...), 0)
+	}
+	for i, class := range types {
+		le.PutUint32(b[typeIDs+4*uint32(i):], index(strs, descriptor(class)))
+	}
+	for i, f := range fields {
+		off := fieldIDs + 8*uint32(i)
+		le.PutUint16(b[off:], uint16(index(types, f.class)))
+		le.PutUint32(b[off+4:], index(strs, f.name))
+	}
+	for i, c := range classes {
+		def := classDefs + classDefSize*uint32(i)
+		le.PutUint32(b[def:], index(types, c.name))
+		var codeOffs []uint32
+		for _, insns := range c.methods {
+			for len(b)%4 != 0 {
+				b = append(b, 0)
+			}
+			codeOffs = append(codeOffs, uint32(len(b)))
+			b = append(b, make([]byte, 12)...)
+			b = le.AppendUint32(b, uint32(len(insns)))
+			for _, u := range insns {
+				b = le.AppendUint16(b, u)
+			}
+		}
+		if len(c.methods) > 0 {
+			le.PutUint32(b[def+24:], uint32(len(b)))
+			b = append(b, 0, 0, byte(len(c.methods)), 0)
+			for j, off := range codeOffs {
+				diff := 0
+				if j > 0 {
+					diff = 1
+				}
+				b = append(b, byte(diff), 1)
+				b = appendUleb128(b, off)
+			}
+		}
+		if c.staticValues != nil {
+			le.PutUint32(b[def+28:], uint32(len(b)))
+			b = append(b, c.staticValues...)
+		}
+	}
+	return b
+}
+
+func appendUleb128(b []byte, v uint32) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func TestScanDex(t *testing.T) {
+	fields := []fieldRef{{class: "com.example.R$drawable", name: "icon"}, {class: "com.example.Util", name: "count"}}
+	main := dexClass{
+		name: "com.example.Main",
+		methods: [][]uint16{
+			{
+				0x0014, 0x0000, 0x7f02, // const v0, 0x7f020000
+				0x0015, 0x7f03, // const/high16 v0, 0x7f030000
+				0x0060, 0x0000, // sget v0, R$drawable.icon
+				0x0018, 0x0001, 0x7f05, 0x0000, 0x0000, // const-wide v0, not an int constant
+				0x0062, 0x0001, // sget-object v0, Util.count
+				0x0059, 0x0000, // iput v0, v0, field@0 is not a static read
+				0x000e,                                                         // return-void
+				0x0000,                                                         // nop, aligning the payload
+				0x0300, 0x0004, 0x0002, 0x0000, 0x0001, 0x7f06, 0x0002, 0x7f06, // fill-array-data-payload
+				0x0100, 0x0001, 0x0000, 0x7f07, 0x0000, 0x0000, // packed-switch-payload
+			},
+			{
+				0x0013, 0x7f08, // const/16 v0, too small for a resource ID
+				0x000e,
+			},
+		},
+		// Static values: int 0x7f040001, int -1, an array holding int 2, null.
+		staticValues: []byte{4, 0x64, 0x01, 0x00, 0x04, 0x7f, 0x04, 0xff, 0x1c, 1, 0x04, 2, 0x1e},
+	}
+	iface := dexClass{name: "com.example.Iface"}
+	got, err := scanCode("classes.dex", testDex(fields, main, iface), true)
+	if err != nil {
+		t.Fatalf("scanDex() failed: %v", err)
+	}
+	want := []*classUsage{
+		{
+			name:   "com.example.Main",
+			ints:   []uint32{0x7f020000, 0x7f030000, 0x7f060001, 0x7f060002, 0x7f040001, 0xffffffff, 2},
+			fields: fields,
+		},
+		{name: "com.example.Iface"},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(classUsage{}, fieldRef{})); diff != "" {
+		t.Errorf("scanDex() returned diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestScanDexErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		wantErr string
+	}{
+		{name: "truncated header", input: []byte("dex\n035\x00"), wantErr: "truncated dex header"},
+		{
+			name:    "truncated instruction",
+			input:   testDex(nil, dexClass{name: "A", methods: [][]uint16{{0x0014, 0x0000}}}),
+			wantErr: "truncated instruction 0x14",
+		},
+		{
+			name:    "oversized payload",
+			input:   testDex(nil, dexClass{name: "A", methods: [][]uint16{{0x0300, 0x0004, 0xffff, 0xffff}}}),
+			wantErr: "invalid fill-array-data payload",
+		},
+		{name: "unknown format", input: []byte("not code"), wantErr: "not a dex, class or zip file"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := scanCode(tc.name, tc.input, true); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("scanCode() returned error %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestScanClass(t *testing.T) {
+	symbols := []*rtxt.Symbol{
+		{Type: "drawable", Name: "icon", Values: []int32{0x7f020000}},
+		{Type: "styleable", Name: "View", Array: true, Values: []int32{0x7f010000}},
+	}
+	tests := []struct {
+		final bool
+		want  *classUsage
+	}{
+		{
+			final: true,
+			want:  &classUsage{name: "com.example.R$drawable", ints: []uint32{0x7f020000}},
+		},
+		{
+			final: false,
+			want: &classUsage{
+				name:   "com.example.R$drawable",
+				ints:   []uint32{0x7f020000},
+				fields: []fieldRef{{class: "com.example.R$drawable", name: "icon"}},
+			},
+		},
+	}
+	for _, tc := range tests {
+		r := rclass.New("com.example", symbols, tc.final)
+		b, err := r.Nested[0].Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := scanClass(b)
+		if err != nil {
+			t.Fatalf("scanClass(final=%v) failed: %v", tc.final, err)
+		}
+		if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(classUsage{}, fieldRef{})); diff != "" {
+			t.Errorf("scanClass(final=%v) returned diff (-want, +got):\n%v", tc.final, diff)
+		}
+	}
+	if _, err := scanClass([]byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 52, 0, 2, 99}); err == nil || !strings.Contains(err.Error(), "unknown constant pool tag 99") {
+		t.Errorf("scanClass() of a bad constant pool returned error %v", err)
+	}
+}
+
+func TestInsnUnits(t *testing.T) {
+	tests := map[uint8]int{
+		0x00: 1, 0x02: 2, 0x03: 3, 0x12: 1, 0x13: 2, 0x14: 3, 0x18: 5, 0x1a: 2, 0x1b: 3, 0x24: 3,
+		0x26: 3, 0x28: 1, 0x2a: 3, 0x2c: 3, 0x38: 2, 0x44: 2, 0x60: 2, 0x6e: 3, 0x74: 3, 0x7b: 1,
+		0x90: 2, 0xb0: 1, 0xd0: 2, 0xd8: 2, 0xfa: 4, 0xfc: 3, 0xfe: 2,
+	}
+	for op, want := range tests {
+		if got := insnUnits(op); got != want {
+			t.Errorf("insnUnits(0x%02x) = %d, want %d", op, got, want)
+		}
+	}
+}
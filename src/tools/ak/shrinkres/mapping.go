@@ -0,0 +1,99 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shrinkres
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mapping maps obfuscated class and field names back to the original ones, as read from an R8 or
+// ProGuard mapping file. A nil mapping maps every name to itself.
+type mapping struct {
+	// classes maps obfuscated to original class names.
+	classes map[string]string
+	// fields maps the obfuscated fields of each obfuscated class name to their original names.
+	fields map[string]map[string]string
+}
+
+// readMapping parses a mapping file, made of class lines "original -> obfuscated:" each followed
+// by indented member lines "type name -> obfuscated". Methods are ignored.
+func readMapping(r io.Reader) (*mapping, error) {
+	m := &mapping{classes: make(map[string]string), fields: make(map[string]map[string]string)}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	var class string
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		orig, obf, ok := strings.Cut(trimmed, " -> ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: %q is not of the form original -> obfuscated", n, line)
+		}
+		if trimmed == line {
+			if !strings.HasSuffix(obf, ":") {
+				return nil, fmt.Errorf("line %d: class mapping %q does not end with ':'", n, line)
+			}
+			class = strings.TrimSuffix(obf, ":")
+			m.classes[class] = orig
+			continue
+		}
+		if class == "" {
+			return nil, fmt.Errorf("line %d: member mapping outside of a class", n)
+		}
+		if strings.Contains(orig, "(") {
+			continue
+		}
+		fields := strings.Fields(orig)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: %q is not of the form type name -> obfuscated", n, line)
+		}
+		if m.fields[class] == nil {
+			m.fields[class] = make(map[string]string)
+		}
+		m.fields[class][obf] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// class returns the original name of the class name.
+func (m *mapping) class(name string) string {
+	if m == nil {
+		return name
+	}
+	if orig, ok := m.classes[name]; ok {
+		return orig
+	}
+	return name
+}
+
+// field returns the original name of the field name of the obfuscated class.
+func (m *mapping) field(class, name string) string {
+	if m == nil {
+		return name
+	}
+	if orig, ok := m.fields[class][name]; ok {
+		return orig
+	}
+	return name
+}
@@ -0,0 +1,97 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shrinkres
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    *mapping
+		wantErr string
+	}{
+		{
+			name: "r8 mapping",
+			input: `# compiler: R8
+# {"id":"com.android.tools.r8.mapping","version":"2.2"}
+com.example.R$drawable -> a.b:
+    int icon -> a
+    int[] frames -> b
+    1:1:void <init>():10:10 -> <init>
+com.example.Main -> com.example.Main:
+# {"id":"sourceFile","fileName":"Main.java"}
+    android.view.View root -> c
+`,
+			want: &mapping{
+				classes: map[string]string{"a.b": "com.example.R$drawable", "com.example.Main": "com.example.Main"},
+				fields: map[string]map[string]string{
+					"a.b":              {"a": "icon", "b": "frames"},
+					"com.example.Main": {"c": "root"},
+				},
+			},
+		},
+		{
+			name:    "member outside of class",
+			input:   "    int icon -> a\n",
+			wantErr: "line 1: member mapping outside of a class",
+		},
+		{
+			name:    "class without colon",
+			input:   "com.example.R -> a\n",
+			wantErr: "does not end with ':'",
+		},
+		{
+			name:    "malformed",
+			input:   "com.example.R -> a:\n    icon\n",
+			wantErr: "line 2: \"    icon\" is not of the form original -> obfuscated",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readMapping(strings.NewReader(tc.input))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("readMapping(%q) returned error %v, want error containing %q", tc.input, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readMapping(%q) failed: %v", tc.input, err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(mapping{})); diff != "" {
+				t.Errorf("readMapping(%q) returned diff (-want, +got):\n%v", tc.input, diff)
+			}
+			if got := got.field("a.b", "a"); got != "icon" {
+				t.Errorf("field(a.b, a) = %q, want icon", got)
+			}
+		})
+	}
+}
+
+func TestNilMapping(t *testing.T) {
+	var m *mapping
+	if got := m.class("a.b"); got != "a.b" {
+		t.Errorf("class(a.b) = %q, want a.b", got)
+	}
+	if got := m.field("a.b", "c"); got != "c" {
+		t.Errorf("field(a.b, c) = %q, want c", got)
+	}
+}
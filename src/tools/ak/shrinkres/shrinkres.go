@@ -0,0 +1,485 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shrinkres replaces the unused resources of a linked resource APK with placeholders. A
+// resource is used when code, the manifest, a kept resource, or a used resource refers to it.
+package shrinkres
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"src/common/golang/flags"
+	"src/tools/ak/res/binres/binres"
+	"src/tools/ak/res/res"
+	"src/tools/ak/types"
+)
+
+const (
+	resourceTable = "resources.arsc"
+	manifest      = "AndroidManifest.xml"
+	toolsNS       = "http://schemas.android.com/tools"
+)
+
+var (
+	// Cmd defines the command to run shrinkres.
+	Cmd = types.Command{
+		Init:  Init,
+		Run:   Run,
+		Desc:  desc,
+		Flags: []string{"apk", "dex", "mapping", "keep", "out", "report"},
+	}
+
+	// Variables to hold flag values.
+	apk         string
+	dex         flags.StringList
+	mappingFile string
+	keep        flags.StringList
+	out         string
+	report      string
+
+	initOnce sync.Once
+
+	// javaNames turns resource names into R field names.
+	javaNames = strings.NewReplacer(".", "_", "-", "_", ":", "_")
+
+	// tinyPNG and tinyXML are the placeholders of unused images and compiled XML files, other
+	// files are emptied.
+	tinyPNG = encodeTinyPNG()
+	tinyXML = encodeTinyXML()
+)
+
+// Init initializes shrinkres.
+func Init() {
+	initOnce.Do(func() {
+		flag.StringVar(&apk, "apk", "", "Linked resource APK to shrink.")
+		flag.Var(&dex, "dex", "Dex files, class files, or zips of those, holding the code of the app.")
+		flag.StringVar(&mappingFile, "mapping", "", "(optional) R8 mapping of the obfuscated code.")
+		flag.Var(&keep, "keep", "(optional) XML files whose tools:keep attribute lists resources to keep, e.g. @drawable/flag_*.")
+		flag.StringVar(&out, "out", "", "Output path for the shrunk APK.")
+		flag.StringVar(&report, "report", "", "(optional) Output path for the list of replaced resource files.")
+	})
+}
+
+func desc() string {
+	return "shrinkres replaces unused resources of an APK with placeholders"
+}
+
+// Run is the entry point for shrinkres. Will exit on error.
+func Run() {
+	if apk == "" || dex == nil || out == "" {
+		log.Fatal("Flags -apk -dex and -out must be specified.")
+	}
+	if err := doWork(apk, dex, mappingFile, keep, out, report); err != nil {
+		log.Fatalf("error shrinking resources: %v", err)
+	}
+}
+
+func doWork(apk string, dex []string, mappingFile string, keep []string, out, report string) error {
+	zr, err := zip.OpenReader(apk)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	a, err := newAnalyzer(&zr.Reader)
+	if err != nil {
+		return fmt.Errorf("%s: %v", apk, err)
+	}
+
+	var m *mapping
+	if mappingFile != "" {
+		f, err := os.Open(mappingFile)
+		if err != nil {
+			return err
+		}
+		m, err = readMapping(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", mappingFile, err)
+		}
+	}
+	for _, d := range dex {
+		classes, err := scanCodeFile(d)
+		if err != nil {
+			return err
+		}
+		a.markCode(classes, m)
+	}
+	for _, k := range keep {
+		patterns, err := readKeep(k)
+		if err != nil {
+			return err
+		}
+		a.markKept(patterns)
+	}
+	if b, ok, err := a.open(manifest); err != nil {
+		return err
+	} else if ok {
+		root, err := binres.ReadXML(b)
+		if err != nil {
+			return fmt.Errorf("%s: %v", manifest, err)
+		}
+		a.markXML(root)
+	}
+	if err := a.propagate(); err != nil {
+		return fmt.Errorf("%s: %v", apk, err)
+	}
+
+	var replaced bytes.Buffer
+	if err := writeAPK(&zr.Reader, out, a.unusedFiles(), &replaced); err != nil {
+		return err
+	}
+	if report != "" {
+		return os.WriteFile(report, replaced.Bytes(), 0644)
+	}
+	return nil
+}
+
+// analyzer computes the resources reachable from a set of roots.
+type analyzer struct {
+	files     map[string]*zip.File
+	table     *binres.Table
+	resources map[uint32]*binres.Resource
+	// byJavaName maps type/name, with the name as in R classes, to resource IDs.
+	byJavaName map[string]uint32
+	reachable  map[uint32]bool
+	// usedFiles holds the files of the reachable resources.
+	usedFiles map[string]bool
+	queue     []uint32
+}
+
+func newAnalyzer(zr *zip.Reader) (*analyzer, error) {
+	a := &analyzer{
+		files:      make(map[string]*zip.File),
+		resources:  make(map[uint32]*binres.Resource),
+		byJavaName: make(map[string]uint32),
+		reachable:  make(map[uint32]bool),
+		usedFiles:  make(map[string]bool),
+	}
+	for _, f := range zr.File {
+		a.files[f.Name] = f
+	}
+	b, ok, err := a.open(resourceTable)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no %s", resourceTable)
+	}
+	if a.table, err = binres.ReadTable(b); err != nil {
+		return nil, err
+	}
+	for _, r := range a.table.Resources() {
+		a.resources[r.ID] = r
+		a.byJavaName[r.Type+"/"+javaNames.Replace(r.Name)] = r.ID
+	}
+	return a, nil
+}
+
+// open returns the content of the file name of the APK, if present.
+func (a *analyzer) open(name string) ([]byte, bool, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, false, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, false, err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %v", name, err)
+	}
+	return b, true, nil
+}
+
+// mark marks id as reachable. IDs which are not resources of the table, e.g. framework resources
+// or constants which happen to look like resource IDs, are ignored.
+func (a *analyzer) mark(id uint32) {
+	if _, ok := a.resources[id]; ok && !a.reachable[id] {
+		a.reachable[id] = true
+		a.queue = append(a.queue, id)
+	}
+}
+
+// markCode marks the resources whose IDs are constants in code, or whose R class fields code
+// reads. The code of R classes themselves is skipped, as it declares every resource.
+func (a *analyzer) markCode(classes []*classUsage, m *mapping) {
+	for _, c := range classes {
+		if isRClass(m.class(c.name)) {
+			continue
+		}
+		for _, v := range c.ints {
+			a.mark(v)
+		}
+		for _, f := range c.fields {
+			t, ok := rType(m.class(f.class))
+			if !ok {
+				continue
+			}
+			if id, ok := a.byJavaName[t+"/"+m.field(f.class, f.name)]; ok {
+				a.mark(id)
+			}
+		}
+	}
+}
+
+func isRClass(class string) bool {
+	simple := class[strings.LastIndex(class, ".")+1:]
+	return simple == "R" || strings.HasPrefix(simple, "R$")
+}
+
+// rType returns the resource type of the nested R class class, e.g. drawable for
+// com.example.R$drawable.
+func rType(class string) (string, bool) {
+	simple := class[strings.LastIndex(class, ".")+1:]
+	return strings.CutPrefix(simple, "R$")
+}
+
+// markKept marks the resources matching one of the type/name globs.
+func (a *analyzer) markKept(patterns []string) {
+	for id, r := range a.resources {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, r.Type+"/"+r.Name); ok {
+				a.mark(id)
+				break
+			}
+		}
+	}
+}
+
+// markXML marks the attributes and the resources referenced by a compiled XML file.
+func (a *analyzer) markXML(root *binres.Element) {
+	root.Walk(func(e *binres.Element) {
+		for _, attr := range e.Attrs {
+			a.mark(attr.ResID)
+			if id, ok := attr.Value.Ref(); ok {
+				a.mark(id)
+			}
+		}
+	})
+}
+
+// propagate marks the resources referenced by reachable resources until none is left.
+func (a *analyzer) propagate() error {
+	for len(a.queue) > 0 {
+		id := a.queue[0]
+		a.queue = a.queue[1:]
+		for _, e := range a.resources[id].Entries {
+			if !e.Complex() {
+				if err := a.markValue(e.Value); err != nil {
+					return err
+				}
+				continue
+			}
+			a.mark(e.Parent)
+			for _, m := range e.Map {
+				a.mark(m.Name)
+				if err := a.markValue(m.Value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// markValue marks the resource referenced by v. If v is the path of a file of the APK, the file
+// is used and the resources referenced by it are marked if it is compiled XML.
+func (a *analyzer) markValue(v binres.Value) error {
+	if id, ok := v.Ref(); ok {
+		a.mark(id)
+		return nil
+	}
+	p, ok := a.table.String(v)
+	if !ok || !strings.HasPrefix(p, "res/") || a.usedFiles[p] {
+		return nil
+	}
+	b, ok, err := a.open(p)
+	if err != nil || !ok {
+		return err
+	}
+	a.usedFiles[p] = true
+	if !binres.IsXML(b) {
+		return nil
+	}
+	root, err := binres.ReadXML(b)
+	if err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	a.markXML(root)
+	return nil
+}
+
+// unusedFiles returns the files of unreachable resources of types which may be files, unless
+// a reachable resource uses them as well.
+func (a *analyzer) unusedFiles() map[string]bool {
+	unused := make(map[string]bool)
+	for id, r := range a.resources {
+		if a.reachable[id] || !isFileType(r.Type) {
+			continue
+		}
+		for _, e := range r.Entries {
+			if p, ok := a.table.String(e.Value); ok && !e.Complex() && strings.HasPrefix(p, "res/") && !a.usedFiles[p] {
+				unused[p] = true
+			}
+		}
+	}
+	return unused
+}
+
+func isFileType(name string) bool {
+	t, err := res.ParseType(name)
+	return err == nil && (t.Kind() == res.NonValue || t.Kind() == res.Both)
+}
+
+// readKeep returns the type/name globs of the tools:keep attribute of the root element of a
+// resources XML file.
+func readKeep(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := xml.NewDecoder(f)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		var patterns []string
+		for _, attr := range start.Attr {
+			if attr.Name.Space != toolsNS || attr.Name.Local != "keep" {
+				continue
+			}
+			for _, ref := range strings.Split(attr.Value, ",") {
+				p := strings.TrimPrefix(strings.TrimSpace(ref), "@")
+				if p == "" {
+					continue
+				}
+				if _, _, ok := strings.Cut(p, "/"); !ok {
+					return nil, fmt.Errorf("%s: %q is not of the form @type/name", name, ref)
+				}
+				if _, err := path.Match(p, ""); err != nil {
+					return nil, fmt.Errorf("%s: %q: %v", name, ref, err)
+				}
+				patterns = append(patterns, p)
+			}
+		}
+		return patterns, nil
+	}
+}
+
+// writeAPK copies the APK to out, replacing the unused files with placeholders, and logs each
+// replacement.
+func writeAPK(zr *zip.Reader, out string, unused map[string]bool, replaced io.Writer) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	var count int
+	var saved uint64
+	for _, zf := range zr.File {
+		if !unused[zf.Name] {
+			if err := zw.Copy(zf); err != nil {
+				f.Close()
+				return err
+			}
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("%s: %v", zf.Name, err)
+		}
+		p := placeholder(zf.Name, b)
+		fh := zf.FileHeader
+		fh.Extra = nil
+		w, err := zw.CreateHeader(&fh)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := w.Write(p); err != nil {
+			f.Close()
+			return err
+		}
+		count++
+		if len(b) > len(p) {
+			saved += uint64(len(b) - len(p))
+		}
+		fmt.Fprintf(replaced, "Skipped unused resource %s: %d bytes (replaced with small dummy file of size %d bytes)\n", zf.Name, len(b), len(p))
+	}
+	fmt.Fprintf(replaced, "Replaced %d unused resource files, saving %d bytes\n", count, saved)
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// placeholder returns the file replacing the unused file name of content b.
+func placeholder(name string, b []byte) []byte {
+	switch {
+	case strings.HasSuffix(name, ".png"):
+		return tinyPNG
+	case binres.IsXML(b):
+		return tinyXML
+	default:
+		return nil
+	}
+}
+
+func encodeTinyPNG() []byte {
+	var b bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&b, image.NewNRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		panic(err)
+	}
+	return b.Bytes()
+}
+
+func encodeTinyXML() []byte {
+	b, err := binres.EncodeXML(&binres.Element{Name: "x"})
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,338 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shrinkres
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"src/common/golang/ziptest"
+	"src/tools/ak/res/binres/binres"
+)
+
+const androidNS = "http://schemas.android.com/apk/res/android"
+
+var tablePaths = []string{
+	"res/drawable/icon.png",
+	"res/drawable/unused.png",
+	"res/drawable/nested.png",
+	"res/drawable/background.xml",
+	"res/drawable/from_field.png",
+	"res/drawable/kept_1.png",
+	"res/layout/main.xml",
+	"res/layout/unused.xml",
+	"Example",
+	"res/raw/notes.txt",
+}
+
+func fileEntry(key string, path int) *binres.Entry {
+	return &binres.Entry{Key: key, Value: binres.Value{Type: binres.TypeString, Data: uint32(path)}}
+}
+
+func testTable(t *testing.T) []byte {
+	t.Helper()
+	config := func(entries ...*binres.Entry) []*binres.Config {
+		return []*binres.Config{{Entries: entries}}
+	}
+	table := &binres.Table{
+		Strings: &binres.StringPool{UTF8: true, Strings: tablePaths},
+		Packages: []*binres.Package{{
+			ID:   0x7f,
+			Name: "com.example",
+			Types: []*binres.Type{
+				{ID: 1, Name: "attr", Specs: []uint32{0}, Configs: config(&binres.Entry{Key: "tint", Flags: binres.FlagComplex})},
+				{ID: 2, Name: "drawable", Specs: make([]uint32, 7), Configs: config(
+					fileEntry("icon", 0),
+					fileEntry("unused", 1),
+					fileEntry("nested", 2),
+					fileEntry("background", 3),
+					fileEntry("from_field", 4),
+					fileEntry("kept.one", 5),
+					// Shares the file of the used icon.
+					fileEntry("icon_alias", 0),
+				)},
+				{ID: 3, Name: "layout", Specs: make([]uint32, 2), Configs: config(fileEntry("main", 6), fileEntry("unused", 7))},
+				{ID: 4, Name: "string", Specs: []uint32{0}, Configs: config(fileEntry("app_name", 8))},
+				{ID: 5, Name: "style", Specs: []uint32{0}, Configs: config(&binres.Entry{
+					Key:    "Theme.App",
+					Flags:  binres.FlagComplex,
+					Parent: 0x01030005,
+					Map:    []binres.MapEntry{{Name: 0x7f010000, Value: binres.Value{Type: binres.TypeReference, Data: 0x7f020003}}},
+				})},
+				{ID: 6, Name: "raw", Specs: []uint32{0}, Configs: config(fileEntry("notes", 9))},
+			},
+		}},
+	}
+	b, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %v", err)
+	}
+	return b
+}
+
+func compiledXML(t *testing.T, root *binres.Element) []byte {
+	t.Helper()
+	b, err := binres.EncodeXML(root)
+	if err != nil {
+		t.Fatalf("EncodeXML() failed: %v", err)
+	}
+	return b
+}
+
+func ref(name string, resID, id uint32) *binres.Attr {
+	return &binres.Attr{NS: androidNS, Name: name, ResID: resID, Value: binres.Value{Type: binres.TypeReference, Data: id}}
+}
+
+type apkFile struct {
+	name    string
+	content []byte
+	method  uint16
+}
+
+func testAPK(t *testing.T) []apkFile {
+	manifest := &binres.Element{
+		Name:       "manifest",
+		Namespaces: []binres.Namespace{{Prefix: "android", URI: androidNS}},
+		Children: []*binres.Element{{
+			Name:  "application",
+			Attrs: []*binres.Attr{ref("icon", 0x01010002, 0x7f020000), ref("theme", 0x01010000, 0x7f050000)},
+		}},
+	}
+	mainLayout := &binres.Element{
+		Name:  "TextView",
+		Attrs: []*binres.Attr{ref("text", 0x0101014f, 0x7f040000)},
+	}
+	unusedLayout := &binres.Element{
+		Name:  "ImageView",
+		Attrs: []*binres.Attr{ref("src", 0x01010119, 0x7f020002)},
+	}
+	return []apkFile{
+		{name: "AndroidManifest.xml", content: compiledXML(t, manifest), method: zip.Deflate},
+		{name: "resources.arsc", content: testTable(t), method: zip.Store},
+		{name: "res/drawable/background.xml", content: compiledXML(t, &binres.Element{Name: "shape"}), method: zip.Deflate},
+		{name: "res/drawable/from_field.png", content: []byte("from_field png"), method: zip.Store},
+		{name: "res/drawable/icon.png", content: []byte("icon png"), method: zip.Store},
+		{name: "res/drawable/kept_1.png", content: []byte("kept png"), method: zip.Store},
+		{name: "res/drawable/nested.png", content: []byte("nested png"), method: zip.Store},
+		{name: "res/drawable/unused.png", content: []byte("unused png"), method: zip.Store},
+		{name: "res/layout/main.xml", content: compiledXML(t, mainLayout), method: zip.Deflate},
+		{name: "res/layout/unused.xml", content: compiledXML(t, unusedLayout), method: zip.Deflate},
+		{name: "res/raw/notes.txt", content: []byte("notes"), method: zip.Deflate},
+	}
+}
+
+func writeZip(t *testing.T, name string, files []apkFile) {
+	t.Helper()
+	var entries []ziptest.Entry
+	for _, f := range files {
+		entries = append(entries, ziptest.Entry{Name: f.name, Data: f.content, Method: f.method})
+	}
+	ziptest.WriteEntries(t, name, entries)
+}
+
+func readZip(t *testing.T, name string) []apkFile {
+	t.Helper()
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var files []apkFile
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		files = append(files, apkFile{name: f.Name, content: b, method: f.Method})
+	}
+	return files
+}
+
+func TestDoWork(t *testing.T) {
+	tmp := t.TempDir()
+	files := testAPK(t)
+	apk := filepath.Join(tmp, "in.apk")
+	writeZip(t, apk, files)
+
+	code := testDex(
+		[]fieldRef{{class: "a.b", name: "a"}},
+		dexClass{
+			name: "com.example.Main",
+			methods: [][]uint16{{
+				0x0014, 0x0000, 0x7f03, // const v0, layout/main
+				0x0060, 0x0000, // sget v0, a.b.a, the obfuscated R.drawable.from_field
+				0x000e,
+			}},
+		},
+		// R classes declare every resource and do not make them reachable.
+		dexClass{name: "a.b", staticValues: []byte{1, 0x64, 0x01, 0x00, 0x02, 0x7f}},
+		dexClass{name: "com.example.R$raw", staticValues: []byte{1, 0x64, 0x00, 0x00, 0x06, 0x7f}},
+	)
+	dex := filepath.Join(tmp, "classes.dex")
+	mappingFile := filepath.Join(tmp, "mapping.txt")
+	keep := filepath.Join(tmp, "keep.xml")
+	for name, content := range map[string]string{
+		dex:         string(code),
+		mappingFile: "com.example.R$drawable -> a.b:\n    int from_field -> a\n",
+		keep:        `<resources xmlns:tools="http://schemas.android.com/tools" tools:keep="@drawable/kept*" />`,
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := filepath.Join(tmp, "out.apk")
+	report := filepath.Join(tmp, "report.txt")
+	if err := doWork(apk, []string{dex}, mappingFile, []string{keep}, out, report); err != nil {
+		t.Fatalf("doWork() failed: %v", err)
+	}
+
+	replacements := map[string][]byte{
+		"res/drawable/nested.png": tinyPNG,
+		"res/drawable/unused.png": tinyPNG,
+		"res/layout/unused.xml":   tinyXML,
+		"res/raw/notes.txt":       {},
+	}
+	var want []apkFile
+	var wantReport strings.Builder
+	saved := 0
+	for _, f := range files {
+		if p, ok := replacements[f.name]; ok {
+			fmt.Fprintf(&wantReport, "Skipped unused resource %s: %d bytes (replaced with small dummy file of size %d bytes)\n", f.name, len(f.content), len(p))
+			saved += max(len(f.content)-len(p), 0)
+			f.content = p
+		}
+		want = append(want, f)
+	}
+	fmt.Fprintf(&wantReport, "Replaced 4 unused resource files, saving %d bytes\n", saved)
+	if diff := cmp.Diff(want, readZip(t, out), cmp.AllowUnexported(apkFile{})); diff != "" {
+		t.Errorf("doWork() returned APK diff (-want, +got):\n%v", diff)
+	}
+	gotReport, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(wantReport.String(), string(gotReport)); diff != "" {
+		t.Errorf("doWork() returned report diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestDoWorkWithoutMapping(t *testing.T) {
+	tmp := t.TempDir()
+	apk := filepath.Join(tmp, "in.apk")
+	writeZip(t, apk, testAPK(t))
+	// Without the mapping a.b is not known to be an R class, its constants are used.
+	code := testDex(nil, dexClass{name: "a.b", staticValues: []byte{1, 0x64, 0x01, 0x00, 0x02, 0x7f}})
+	dex := filepath.Join(tmp, "classes.dex")
+	if err := os.WriteFile(dex, code, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(tmp, "out.apk")
+	if err := doWork(apk, []string{dex}, "", nil, out, ""); err != nil {
+		t.Fatalf("doWork() failed: %v", err)
+	}
+	var replaced []string
+	for _, f := range readZip(t, out) {
+		if bytes.Equal(f.content, tinyPNG) || bytes.Equal(f.content, tinyXML) || len(f.content) == 0 {
+			replaced = append(replaced, f.name)
+		}
+	}
+	want := []string{
+		"res/drawable/from_field.png",
+		"res/drawable/kept_1.png",
+		"res/drawable/nested.png",
+		"res/layout/main.xml",
+		"res/layout/unused.xml",
+		"res/raw/notes.txt",
+	}
+	if diff := cmp.Diff(want, replaced); diff != "" {
+		t.Errorf("doWork() replaced files diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestReadKeep(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "keeprules output",
+			input: "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<resources xmlns:tools=\"http://schemas.android.com/tools\"\n    tools:keep=\"@drawable/flag_ca, @layout/l_used*_c,@string/label_home\" />\n",
+			want:  []string{"drawable/flag_ca", "layout/l_used*_c", "string/label_home"},
+		},
+		{
+			name:  "nothing kept",
+			input: `<resources xmlns:tools="http://schemas.android.com/tools" tools:discard="@layout/unused" />`,
+		},
+		{
+			name:    "missing type",
+			input:   `<resources xmlns:tools="http://schemas.android.com/tools" tools:keep="@flag_ca" />`,
+			wantErr: `"@flag_ca" is not of the form @type/name`,
+		},
+		{
+			name:    "bad pattern",
+			input:   `<resources xmlns:tools="http://schemas.android.com/tools" tools:keep="@drawable/[" />`,
+			wantErr: "syntax error in pattern",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "keep.xml")
+			if err := os.WriteFile(name, []byte(tc.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readKeep(name)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("readKeep(%q) returned error %v, want error containing %q", tc.input, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readKeep(%q) failed: %v", tc.input, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("readKeep(%q) returned diff (-want, +got):\n%v", tc.input, diff)
+			}
+		})
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	if !bytes.HasPrefix(tinyPNG, []byte("\x89PNG")) {
+		t.Errorf("tinyPNG = %q, want a PNG image", tinyPNG)
+	}
+	root, err := binres.ReadXML(tinyXML)
+	if err != nil {
+		t.Fatalf("ReadXML(tinyXML) failed: %v", err)
+	}
+	if root.Name != "x" {
+		t.Errorf("tinyXML root element is %s, want x", root.Name)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package zipalign aligns the stored entries of zip archives, like the SDK's zipalign, so that
+// for instance uncompressed native libraries can be loaded directly from an APK.
+package zipalign
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"src/common/golang/flags"
+	"src/common/golang/ziputils"
+	"src/tools/ak/types"
+)
+
+var (
+	// Cmd defines the command to run zipalign.
+	Cmd = types.Command{
+		Init:  Init,
+		Run:   Run,
+		Desc:  desc,
+		Flags: []string{"in", "out", "alignment", "so_alignment", "align", "check"},
+	}
+
+	// Variables to hold flag values.
+	in          string
+	out         string
+	alignment   int
+	soAlignment int
+	align       flags.MultiString
+	check       bool
+
+	initOnce sync.Once
+)
+
+// Init initializes zipalign.
+func Init() {
+	initOnce.Do(func() {
+		flag.StringVar(&in, "in", "", "Path to the archive to align.")
+		flag.StringVar(&out, "out", "", "Path to write the aligned archive to.")
+		flag.IntVar(&alignment, "alignment", ziputils.DefaultAlignment, "Alignment in bytes of the stored entries not matched by other rules.")
+		flag.IntVar(&soAlignment, "so_alignment", ziputils.PageAlignment, "Alignment in bytes of the stored native libraries, lib/*/*.so.")
+		flag.Var(&align, "align", "Repeatable alignment of the stored entries matching a pattern: {pattern}:{alignment}. The first matching rule applies.")
+		flag.BoolVar(&check, "check", false, "Only check that -in is aligned, instead of writing -out.")
+	})
+}
+
+func desc() string {
+	return "zipalign aligns the uncompressed entries of zip archives"
+}
+
+// Run is the entry point for zipalign. Will exit on error.
+func Run() {
+	if in == "" {
+		log.Fatal("Flag -in must be specified.")
+	}
+	if out == "" && !check {
+		log.Fatal("Flag -out must be specified.")
+	}
+	aligner, err := newAligner(alignment, soAlignment, align)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if check {
+		err = doCheck(in, aligner)
+	} else {
+		err = doWork(in, out, aligner)
+	}
+	if err != nil {
+		log.Fatalf("error aligning %s: %v", in, err)
+	}
+}
+
+func newAligner(def, so int, align []string) (ziputils.Aligner, error) {
+	for _, a := range []int{def, so} {
+		if err := ziputils.CheckAlignment(a); err != nil {
+			return nil, err
+		}
+	}
+	var rules []ziputils.AlignRule
+	for _, a := range align {
+		r, err := ziputils.ParseAlignRule(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -align: %v", err)
+		}
+		rules = append(rules, r)
+	}
+	rules = append(rules, ziputils.AlignRule{Pattern: ziputils.NativeLibsPattern, Alignment: so})
+	return ziputils.NewAligner(def, rules...), nil
+}
+
+func doWork(in, out string, aligner ziputils.Aligner) error {
+	r, err := zip.OpenReader(in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := ziputils.NewAlignedWriter(f, aligner)
+	w.SetComment(r.Comment)
+	for _, zf := range r.File {
+		if err := w.Copy(zf); err != nil {
+			return fmt.Errorf("%s: %v", zf.Name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func doCheck(in string, aligner ziputils.Aligner) error {
+	r, err := zip.OpenReader(in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	var unaligned []string
+	for _, zf := range r.File {
+		a := aligner(zf.Name)
+		ok, err := ziputils.IsAligned(zf, a)
+		if err != nil {
+			return fmt.Errorf("%s: %v", zf.Name, err)
+		}
+		if !ok {
+			unaligned = append(unaligned, fmt.Sprintf("%s is not aligned to %d bytes", zf.Name, a))
+		}
+	}
+	if len(unaligned) > 0 {
+		return fmt.Errorf("%d entries are not aligned:\n%s", len(unaligned), strings.Join(unaligned, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipalign
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"src/common/golang/ziptest"
+)
+
+var entries = []ziptest.Entry{
+	{Name: "AndroidManifest.xml", Method: zip.Deflate, Data: []byte("manifest")},
+	{Name: "classes.dex", Method: zip.Store, Data: []byte("dex")},
+	{Name: "lib/arm64-v8a/libfoo.so", Method: zip.Store, Data: []byte("foo")},
+	{Name: "lib/arm64-v8a/libbar.so", Method: zip.Store, Data: []byte("bar")},
+	{Name: "assets/big.bin", Method: zip.Store, Data: []byte("big")},
+	{Name: "res/raw/a.txt", Method: zip.Store, Data: []byte("a")},
+}
+
+func TestDoWork(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "in.zip")
+	out := filepath.Join(tmp, "out.zip")
+	ziptest.WriteEntries(t, in, entries)
+
+	aligner, err := newAligner(4, 16384, []string{"assets/*.bin:4096", "lib/*/libbar.so:8192"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doCheck(in, aligner); err == nil {
+		t.Errorf("doCheck(%s) succeeded, want an error for the unaligned input", in)
+	}
+	if err := doWork(in, out, aligner); err != nil {
+		t.Fatalf("doWork failed: %v", err)
+	}
+	if err := doCheck(out, aligner); err != nil {
+		t.Errorf("doCheck(%s) failed: %v", out, err)
+	}
+
+	r, err := zip.OpenReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	want := map[string]int64{
+		"classes.dex":             4,
+		"lib/arm64-v8a/libfoo.so": 16384,
+		"lib/arm64-v8a/libbar.so": 8192,
+		"assets/big.bin":          4096,
+		"res/raw/a.txt":           4,
+	}
+	if len(r.File) != len(entries) {
+		t.Fatalf("got %d entries, want %d", len(r.File), len(entries))
+	}
+	for i, f := range r.File {
+		if f.Name != entries[i].Name || f.Method != entries[i].Method {
+			t.Errorf("got entry %q method %d, want %q method %d", f.Name, f.Method, entries[i].Name, entries[i].Method)
+		}
+		if a, ok := want[f.Name]; ok {
+			off, err := f.DataOffset()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if off%a != 0 {
+				t.Errorf("%s: data offset %d is not aligned to %d", f.Name, off, a)
+			}
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: reading failed: %v", f.Name, err)
+		}
+		if !bytes.Equal(data, entries[i].Data) {
+			t.Errorf("%s: got %q, want %q", f.Name, data, entries[i].Data)
+		}
+	}
+}
+
+func TestNewAligner(t *testing.T) {
+	tests := []struct {
+		name  string
+		def   int
+		so    int
+		align []string
+	}{
+		{name: "invalid_default", def: 3, so: 16384},
+		{name: "invalid_so", def: 4, so: 65536},
+		{name: "invalid_rule", def: 4, so: 16384, align: []string{"assets/*"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newAligner(tc.def, tc.so, tc.align); err == nil {
+				t.Errorf("newAligner(%d, %d, %v) succeeded, want an error", tc.def, tc.so, tc.align)
+			}
+		})
+	}
+}